@@ -0,0 +1,214 @@
+package main
+
+// engine-replay：离线回放某个 symbol 的 cmd WAL，排查"这笔成交为什么是这样"
+//
+//	# 回放并导出事件 + 最终簿（JSON lines）
+//	engine-replay -wal-dir ./data/wal -symbol BTCUSDT -events events.jsonl -orders book.jsonl
+//	# 从最新快照开始，只回放到 seq 12345，和录制的 ev.wal 比对（证明确定性）
+//	engine-replay -wal-dir ./data/wal -symbol BTCUSDT -snapshot latest -until 12345 -diff-recorded
+//	# 带上服务配置，成交号（交易对编号）、手续费、自成交处理才和 ev.wal 一致
+//	engine-replay -wal-dir ./data/wal -symbol BTCUSDT -config config/matching-service.yaml -diff-recorded
+//	# 两种簿实现对比
+//	engine-replay -wal-dir ./data/wal -symbol BTCUSDT -book heap -diff-book level
+//
+// 有差异时打印第一处不同并以退出码 2 结束
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"gopherex.com/internal/engine"
+	"gopherex.com/internal/engine/app"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	var (
+		walDir   = flag.String("wal-dir", "", "wal 目录（engine.wal_dir）")
+		symbol   = flag.String("symbol", "", "交易对")
+		bookKind = flag.String("book", "heap", "订单簿实现：heap | skiplist | level | naive")
+		snapshot = flag.String("snapshot", "", "快照：空=从 WAL 头回放，latest=最新快照，或快照文件路径")
+		until    = flag.Uint64("until", 0, "只回放到该 seq（含），0 表示到末尾")
+		events   = flag.String("events", "-", "事件导出（JSON lines），- 为 stdout，空为不导出")
+		orders   = flag.String("orders", "", "最终簿导出（JSON lines），- 为 stdout，空为不导出")
+		diffBook = flag.String("diff-book", "", "再用另一种簿实现回放一遍并比对")
+		diffRec  = flag.Bool("diff-recorded", false, "与录制的 ev.wal 比对")
+		evCodec  = flag.String("ev-codec", "binary", "ev.wal 编码：binary | json")
+		cfgPath  = flag.String("config", "", "matching-service 配置文件：取该交易对的编号和起始手续费率，空为编号 0、不算手续费（STP 模式按 WAL 里的 CmdSetSTP）")
+	)
+	flag.Parse()
+	if *walDir == "" || *symbol == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cfg := engine.ReplayConfig{
+		WALDir: *walDir, Symbol: *symbol, CmdCodec: engine.BinaryCMDCode{},
+		Snapshot: *snapshot, UntilSeq: *until,
+	}
+	if *cfgPath != "" {
+		spec, err := loadSpec(*cfgPath, *symbol)
+		if err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+		cfg.Fees, cfg.SymbolID = spec.Fees, spec.ID
+	}
+	res := mustReplay(cfg, *bookKind)
+	log.Printf("replayed %s with %s: seq (%d, %d], %d events, %d resting orders, %d stop orders, phase=%d",
+		*symbol, *bookKind, res.FromSeq, res.LastSeq, len(res.Events), len(res.Orders), len(res.Stops), res.Phase)
+
+	if err := export(*events, func(w io.Writer) error { return writeEvents(w, res.Events) }); err != nil {
+		log.Fatalf("export events: %v", err)
+	}
+	if err := export(*orders, func(w io.Writer) error { return writeOrders(w, res.Orders) }); err != nil {
+		log.Fatalf("export orders: %v", err)
+	}
+
+	same := true
+	if *diffBook != "" {
+		other := mustReplay(cfg, *diffBook)
+		same = diffEvents(*bookKind, *diffBook, res.Events, other.Events) && same
+		same = diffOrders(*bookKind, *diffBook, res.Orders, other.Orders) && same
+	}
+	if *diffRec {
+		var codec engine.EvCodec = engine.EvCmdCodec{}
+		if *evCodec == "json" {
+			codec = engine.JSONEvCodec{}
+		}
+		rec, err := engine.RecordedEvents(*walDir, *symbol, codec, res.FromSeq, res.LastSeq)
+		if err != nil {
+			log.Fatalf("read recorded events: %v", err)
+		}
+		same = diffEvents(*bookKind, "recorded", res.Events, rec) && same
+	}
+	if !same {
+		os.Exit(2)
+	}
+}
+
+func loadSpec(path, symbol string) (engine.SymbolSpec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return engine.SymbolSpec{}, err
+	}
+	var c app.Cfg
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return engine.SymbolSpec{}, err
+	}
+	spec, err := c.SymbolSpec(symbol)
+	if err != nil {
+		return engine.SymbolSpec{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+func mustReplay(cfg engine.ReplayConfig, kind string) *engine.ReplayResult {
+	f, err := engine.NewBookFactory(kind)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.BookFactory = f
+	res, err := engine.Replay(cfg)
+	if err != nil {
+		log.Fatalf("replay with %s: %v", kind, err)
+	}
+	return res
+}
+
+func export(path string, write func(w io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		bw := bufio.NewWriter(os.Stdout)
+		if err := write(bw); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeEvents：和 ev.wal 的 JSON 编码同格式，一行一个
+func writeEvents(w io.Writer, evs []engine.Event) error {
+	codec := engine.JSONEvCodec{Version: 1}
+	var buf []byte
+	for _, ev := range evs {
+		b, err := codec.Encode(buf[:0], ev)
+		if err != nil {
+			return err
+		}
+		buf = append(b, '\n')
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeOrders(w io.Writer, orders []engine.RestingOrder) error {
+	enc := json.NewEncoder(w)
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffEvents(aName, bName string, a, b []engine.Event) bool {
+	i := engine.DiffEvents(a, b)
+	if i < 0 {
+		log.Printf("events: %s == %s (%d events)", aName, bName, len(a))
+		return true
+	}
+	log.Printf("events: %s != %s at #%d (%d vs %d events)", aName, bName, i, len(a), len(b))
+	for _, side := range []struct {
+		name string
+		evs  []engine.Event
+	}{{aName, a}, {bName, b}} {
+		if i < len(side.evs) {
+			log.Printf("  %-8s %+v", side.name, side.evs[i])
+		} else {
+			log.Printf("  %-8s <end>", side.name)
+		}
+	}
+	return false
+}
+
+func diffOrders(aName, bName string, a, b []engine.RestingOrder) bool {
+	i := engine.DiffOrders(a, b)
+	if i < 0 {
+		log.Printf("book: %s == %s (%d orders)", aName, bName, len(a))
+		return true
+	}
+	log.Printf("book: %s != %s at #%d (%d vs %d orders)", aName, bName, i, len(a), len(b))
+	for _, side := range []struct {
+		name   string
+		orders []engine.RestingOrder
+	}{{aName, a}, {bName, b}} {
+		if i < len(side.orders) {
+			log.Printf("  %-8s %+v", side.name, side.orders[i])
+		} else {
+			log.Printf("  %-8s <end>", side.name)
+		}
+	}
+	return false
+}
@@ -295,7 +295,7 @@ func buildServices(ctx context.Context, sqlDB *sql.DB, rdb *redis.Client) (*fund
 	if err != nil {
 		return nil, err
 	}
-	repo := gmysql.NewBalancesRepo(newGorm)
+	repo := gmysql.NewRepo(newGorm)
 	cache := funds.NewRedisCache(rdb)
 	srv := funds.NewFundsService(ctx, repo, cache)
 	return srv, nil
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"gopherex.com/internal/engine/app"
+)
+
+func main() {
+	// 支持 Ctrl+C / kubernetes 停止信号的 context
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.Run(ctx); err != nil {
+		log.Fatalf("matching-service exit: %v", err)
+	}
+}
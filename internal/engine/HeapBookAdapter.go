@@ -5,40 +5,249 @@ import (
 )
 
 type HeapBookAdapter struct {
-	B *matching.LevelOrderBookHeap
+	B      *matching.LevelOrderBookHeap
+	lastPx int64 // 最新成交价（回放时同样会更新），0 表示还没有成交
 }
 
 func NewHeapBookAdapter(b *matching.LevelOrderBookHeap) *HeapBookAdapter {
 	return &HeapBookAdapter{B: b}
 }
 
-func (a *HeapBookAdapter) SubmitLimit(reqId, orderID, userID uint64, side uint8, price, qty int64, emit Emitter) {
+// SetSTP：实现 STPBook（模式只随 CmdSetSTP / 快照换）
+func (a *HeapBookAdapter) SetSTP(mode matching.STPMode) { a.B.SetSTP(mode) }
+
+func (a *HeapBookAdapter) Submit(reqId uint64, o OrderSpec, emit Emitter) {
+	// 0) PostOnly：会立即成交就直接拒（不 Accepted，簿不变）
+	if o.PostOnly && a.B.WouldCross(o.Side, o.Price) {
+		emit.Rejected(reqId, o.OrderID, o.UserID, RejectPostOnlyCross)
+		return
+	}
+
 	// 1) 先给“Accepted”（命令被接收，语义由你决定）
-	emit.Accepted(reqId, orderID, userID) // 如果你的 Emitter.Accepted 带 reqID，就传 cmd.ReqID；这里示意
+	emit.Accepted(reqId, o.OrderID, o.UserID) // 如果你的 Emitter.Accepted 带 reqID，就传 cmd.ReqID；这里示意
+
+	// 2) FOK：先预检查对手盘是否足够，不够整单过期（不产生任何成交）
+	if o.TIF == TifFOK && !a.B.CanFill(o.UserID, o.Side, o.Price, o.Qty, o.Market) {
+		emit.Expired(reqId, o.OrderID, o.UserID, o.Qty)
+		return
+	}
 
-	// 2) 构造 taker（先别纠结 alloc，后面再做 OrderPool 优化）
-	taker := &matching.Order{ID: orderID, UserID: userID, Side: side, Price: price, Qty: qty}
+	// 3) 构造 taker（先别纠结 alloc，后面再做 OrderPool 优化）
+	taker := &matching.Order{ID: o.OrderID, UserID: o.UserID, Side: o.Side, Price: o.Price, Qty: o.Qty, Display: o.Display, ClientID: o.ClientID, PostOnly: o.PostOnly}
 
-	// 3) 撮合：把 Trade 回调翻译成 Emitter.Trade
-	rest := a.B.MatchLimitEmit(taker, func(t matching.Trade) {
-		emit.Trade(reqId, t.MakerID, t.TakerID, t.Price, t.Qty)
-	})
+	// 4) 撮合：把 Trade / STP 回调翻译成 Emitter 事件
+	// STP 撤掉的 taker 数量不在 rest 里（已由 SelfTradePrevented 说明）
+	rest := a.B.MatchEmit(taker, o.Market, a.tradeEmit(reqId, o.Side, emit), stpEmit(reqId, emit))
+	if rest <= 0 {
+		return
+	}
 
-	// 4) 若 rest > 0：挂单入簿，并发 Added
-	if rest > 0 {
+	// 5) 剩余：GTC 限价挂单入簿并发 Added（冰山单由 matching 切片）；IOC/FOK/市价单剩余过期
+	if o.TIF == TifGTC && !o.Market {
 		taker.Qty = rest
 		a.B.Add(taker)
-		emit.Added(reqId, orderID, userID)
+		emit.Added(reqId, o.OrderID, o.UserID)
+		return
+	}
+	emit.Expired(reqId, o.OrderID, o.UserID, rest)
+}
+
+func (a *HeapBookAdapter) tradeEmit(reqId uint64, takerSide uint8, emit Emitter) func(matching.Trade) {
+	return func(t matching.Trade) {
+		a.lastPx = t.Price
+		emit.Trade(reqId, t.MakerID, t.TakerID, t.MakerUserID, t.TakerUserID, takerSide, t.Price, t.Qty)
+		if t.Refill > 0 {
+			emit.Replenished(reqId, t.MakerID, t.Refill)
+		}
+		if t.TakerRefill > 0 {
+			emit.Replenished(reqId, t.TakerID, t.TakerRefill)
+		}
+	}
+}
+
+// StartAuction：进入竞价收单（簿可以交叉，Submit 的 MatchEmit 不撮合）
+func (a *HeapBookAdapter) StartAuction() { a.B.SetAuction(true) }
+
+// Uncross：以最新成交价作参考价撮合竞价，所有成交价相同
+// 竞价成交没有主动方：takerSide=0（双方都按 maker 费率）
+func (a *HeapBookAdapter) Uncross(reqId uint64, emit Emitter) (price, qty int64) {
+	return a.B.Uncross(a.lastPx, a.tradeEmit(reqId, 0, emit), stpEmit(reqId, emit))
+}
+
+// TrackDepth / DepthChanges / RangeDepth：深度视图用（只含可见数量）
+func (a *HeapBookAdapter) TrackDepth() { a.B.TrackDepth() }
+
+func (a *HeapBookAdapter) DepthChanges(fn func(side uint8, price, qty int64)) {
+	a.B.DepthChanges(fn)
+}
+
+func (a *HeapBookAdapter) RangeDepth(fn func(side uint8, price, qty int64)) {
+	for _, side := range []uint8{Buy, Sell} {
+		for _, lv := range a.B.Depth(side, 0) {
+			fn(side, lv.Price, lv.Qty)
+		}
+	}
+}
+
+// Order / UserOrders / BestLevels / TopLevels：只读查询（actor 协程里调用）
+func (a *HeapBookAdapter) Order(orderID uint64) (RestingOrder, bool) {
+	o, ok := a.B.Order(orderID)
+	if !ok {
+		return RestingOrder{}, false
+	}
+	return restingFrom(o), true
+}
+
+func (a *HeapBookAdapter) UserOrders(userID uint64) []RestingOrder {
+	os := a.B.UserOrders(userID)
+	out := make([]RestingOrder, 0, len(os))
+	for _, o := range os {
+		out = append(out, restingFrom(o))
+	}
+	return out
+}
+
+func (a *HeapBookAdapter) ClientOrder(userID, clientOrderID uint64) (RestingOrder, bool) {
+	o, ok := a.B.UserClientOrder(userID, clientOrderID)
+	if !ok {
+		return RestingOrder{}, false
+	}
+	return restingFrom(o), true
+}
+
+func (a *HeapBookAdapter) BestLevels() (bid, ask DepthLevel) {
+	bid.Side, ask.Side = Buy, Sell
+	if lv, ok := a.B.BestLevel(Buy); ok {
+		bid.Price, bid.Qty = lv.Price, lv.Qty
+	}
+	if lv, ok := a.B.BestLevel(Sell); ok {
+		ask.Price, ask.Qty = lv.Price, lv.Qty
+	}
+	return bid, ask
+}
+
+func (a *HeapBookAdapter) TopLevels(n int) (bids, asks []DepthLevel) {
+	conv := func(side uint8) []DepthLevel {
+		lvs := a.B.Depth(side, n)
+		out := make([]DepthLevel, 0, len(lvs))
+		for _, lv := range lvs {
+			out = append(out, DepthLevel{Side: side, Price: lv.Price, Qty: lv.Qty})
+		}
+		return out
+	}
+	return conv(Buy), conv(Sell)
+}
+
+func restingFrom(o matching.Order) RestingOrder {
+	return RestingOrder{
+		OrderID: o.ID, UserID: o.UserID, Side: o.Side, Price: o.Price, Qty: o.Qty,
+		Display: o.Display, Reserve: o.Reserve, ClientOrderID: o.ClientID, PostOnly: o.PostOnly, Seq: o.Seq,
+	}
+}
+
+// LastPrice：最新成交价（价格带校验用）
+func (a *HeapBookAdapter) LastPrice() (int64, bool) {
+	return a.lastPx, a.lastPx > 0
+}
+
+// SetLastPrice：快照恢复时设回最新成交价
+func (a *HeapBookAdapter) SetLastPrice(px int64) { a.lastPx = px }
+
+// stpEmit：一次 STP 动作最多影响两张单，各发一个事件（先 maker 后 taker）
+func stpEmit(reqId uint64, emit Emitter) func(matching.SelfTrade) {
+	return func(st matching.SelfTrade) {
+		if st.MakerQty > 0 {
+			emit.SelfTradePrevented(reqId, st.MakerID, st.UserID, st.MakerID, st.TakerID, st.MakerQty)
+			if st.Refill > 0 {
+				emit.Replenished(reqId, st.MakerID, st.Refill)
+			}
+		}
+		if st.TakerQty > 0 {
+			emit.SelfTradePrevented(reqId, st.TakerID, st.UserID, st.MakerID, st.TakerID, st.TakerQty)
+			if st.TakerRefill > 0 {
+				emit.Replenished(reqId, st.TakerID, st.TakerRefill)
+			}
+		}
 	}
 }
 
 // Cancel：用你现有的 O(1) byID 撤单
+// 未找到只返回 false，由 applyCommandToBook 统一发 Rejected（避免重复拒单事件）
 func (a *HeapBookAdapter) Cancel(reqId, orderID uint64, emit Emitter) bool {
 	ok := a.B.Cancel(orderID)
 	if ok {
 		emit.Cancelled(reqId, orderID)
-	} else {
-		emit.Rejected(reqId, orderID, 0, "order not found")
 	}
 	return ok
 }
+
+// Amend：改价/改量
+// - 同价减量保留队列位置；改价或加量重新排队（matching 层处理）
+// - 改价后会穿价：先撤出簿，再按限价 taker 撮合，剩余以新价格挂单（订单号不变）
+// - PostOnly 挂单改价会穿价：拒单，原单不变
+func (a *HeapBookAdapter) Amend(reqId uint64, s AmendSpec, emit Emitter) {
+	o, ok := a.B.Order(s.OrderID)
+	if !ok {
+		emit.Rejected(reqId, s.OrderID, s.UserID, RejectOrderNotFound)
+		return
+	}
+	if s.UserID != 0 && s.UserID != o.UserID {
+		emit.Rejected(reqId, s.OrderID, s.UserID, RejectNotOwner)
+		return
+	}
+	price, qty := o.Price, o.Qty+o.Reserve // Qty 是剩余总量（冰山单含隐藏部分）
+	if s.Price > 0 {
+		price = s.Price
+	}
+	if s.Qty > 0 {
+		qty = s.Qty
+	}
+
+	if price != o.Price && a.B.WouldCross(o.Side, price) {
+		if o.PostOnly {
+			emit.Rejected(reqId, o.ID, o.UserID, RejectPostOnlyCross)
+			return
+		}
+		a.B.Cancel(o.ID)
+		emit.Amended(reqId, o.ID, o.UserID, price, qty)
+		taker := &matching.Order{ID: o.ID, UserID: o.UserID, Side: o.Side, Price: price, Qty: qty, Display: o.Display, ClientID: o.ClientID}
+		rest := a.B.MatchEmit(taker, false, a.tradeEmit(reqId, o.Side, emit), stpEmit(reqId, emit))
+		if rest > 0 {
+			taker.Qty = rest
+			a.B.Add(taker)
+		}
+		return
+	}
+
+	a.B.Amend(o.ID, price, qty)
+	emit.Amended(reqId, o.ID, o.UserID, price, qty)
+}
+
+// CancelAllForUser：按用户索引撤掉该用户所有挂单（按订单号顺序，回放得到同样的事件序列）
+func (a *HeapBookAdapter) CancelAllForUser(reqId, userID uint64, emit Emitter) int {
+	orders := a.B.UserOrders(userID)
+	for _, o := range orders {
+		a.B.Cancel(o.ID)
+		emit.Cancelled(reqId, o.ID)
+	}
+	return len(orders)
+}
+
+// SnapshotOrders：导出全部挂单（价格优先 + 同价 FIFO）
+func (a *HeapBookAdapter) SnapshotOrders() []RestingOrder {
+	out := make([]RestingOrder, 0, 1024)
+	a.B.RangeOrders(func(o matching.Order) {
+		out = append(out, restingFrom(o))
+	})
+	return out
+}
+
+// RestoreOrders：按快照顺序 Add 回簿，队列优先级与快照时一致
+func (a *HeapBookAdapter) RestoreOrders(orders []RestingOrder) {
+	for _, o := range orders {
+		a.B.Add(&matching.Order{
+			ID: o.OrderID, UserID: o.UserID, Side: o.Side, Price: o.Price, Qty: o.Qty,
+			Display: o.Display, Reserve: o.Reserve, ClientID: o.ClientOrderID, PostOnly: o.PostOnly, Seq: o.Seq,
+		})
+	}
+}
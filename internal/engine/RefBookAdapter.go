@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"math"
+	"sort"
+
+	"gopherex.com/internal/matching"
+)
+
+// RefBookAdapter：把 naive / level 两个对照实现包成 OrderBook，给离线回放做差异对比用
+// 只覆盖公共子集：限价/市价、GTC/IOC/FOK、PostOnly、改单、撤单
+// 不支持冰山（Display 忽略，整单可见）、STP、集合竞价；这些流程和 HeapBookAdapter 的差异是预期的
+// 查单/FOK 预检查是线性扫描，不要用在线上
+type RefBookAdapter struct {
+	b      refBook
+	lastPx int64
+}
+
+// refBook：两个对照簿的公共能力；submit 剩余数量会自动挂单（matching 的 SubmitLimit 语义）
+type refBook interface {
+	add(o *matching.Order)
+	submit(o *matching.Order) []matching.Trade
+	cancel(orderID uint64) bool
+	amend(orderID uint64, price, qty int64) bool
+	best(side uint8) (int64, bool)
+	rangeOrders(fn func(o matching.Order))
+}
+
+func NewNaiveBookAdapter(b *matching.NaiveOrderBook) *RefBookAdapter {
+	return &RefBookAdapter{b: naiveRef{b}}
+}
+
+func NewLevelBookAdapter(b *matching.LevelOrderBook) *RefBookAdapter {
+	return &RefBookAdapter{b: levelRef{b}}
+}
+
+type naiveRef struct{ b *matching.NaiveOrderBook }
+
+func (r naiveRef) add(o *matching.Order)                     { _ = r.b.Add(o) }
+func (r naiveRef) submit(o *matching.Order) []matching.Trade { return r.b.SubmitLimit(o) }
+func (r naiveRef) cancel(orderID uint64) bool                { return r.b.Cancel(orderID) }
+func (r naiveRef) rangeOrders(fn func(o matching.Order))     { r.b.RangeOrders(fn) }
+func (r naiveRef) amend(orderID uint64, price, qty int64) bool {
+	_, ok := r.b.Amend(orderID, price, qty)
+	return ok
+}
+func (r naiveRef) best(side uint8) (int64, bool) {
+	o := r.b.BestAsk()
+	if side == Buy {
+		o = r.b.BestBid()
+	}
+	if o == nil {
+		return 0, false
+	}
+	return o.Price, true
+}
+
+type levelRef struct{ b *matching.LevelOrderBook }
+
+func (r levelRef) add(o *matching.Order)                     { r.b.Add(o) }
+func (r levelRef) submit(o *matching.Order) []matching.Trade { return r.b.SubmitLimit(o) }
+func (r levelRef) cancel(orderID uint64) bool                { return r.b.Cancel(orderID) }
+func (r levelRef) rangeOrders(fn func(o matching.Order))     { r.b.RangeOrders(fn) }
+func (r levelRef) amend(orderID uint64, price, qty int64) bool {
+	_, ok := r.b.Amend(orderID, price, qty)
+	return ok
+}
+func (r levelRef) best(side uint8) (int64, bool) {
+	if side == Buy {
+		return r.b.BestBid()
+	}
+	return r.b.BestAsk()
+}
+
+// Submit：事件顺序与 HeapBookAdapter.Submit 一致
+func (a *RefBookAdapter) Submit(reqId uint64, o OrderSpec, emit Emitter) {
+	if o.PostOnly && a.wouldCross(o.Side, o.Price) {
+		emit.Rejected(reqId, o.OrderID, o.UserID, RejectPostOnlyCross)
+		return
+	}
+	emit.Accepted(reqId, o.OrderID, o.UserID)
+	if o.TIF == TifFOK && !a.canFill(o.Side, o.Price, o.Qty, o.Market) {
+		emit.Expired(reqId, o.OrderID, o.UserID, o.Qty)
+		return
+	}
+
+	// 市价单用极限价格撮合；剩余会被 submit 挂上，下面再撤掉
+	price := o.Price
+	if o.Market {
+		price = 0
+		if o.Side == Buy {
+			price = math.MaxInt64
+		}
+	}
+	taker := &matching.Order{ID: o.OrderID, UserID: o.UserID, Side: o.Side, Price: price, Qty: o.Qty, ClientID: o.ClientID, PostOnly: o.PostOnly}
+	a.emitTrades(reqId, o.Side, a.b.submit(taker), emit)
+	rest := taker.Qty
+	if rest <= 0 {
+		return
+	}
+	if o.TIF == TifGTC && !o.Market {
+		emit.Added(reqId, o.OrderID, o.UserID)
+		return
+	}
+	a.b.cancel(o.OrderID)
+	emit.Expired(reqId, o.OrderID, o.UserID, rest)
+}
+
+func (a *RefBookAdapter) emitTrades(reqId uint64, takerSide uint8, trades []matching.Trade, emit Emitter) {
+	for _, t := range trades {
+		a.lastPx = t.Price
+		emit.Trade(reqId, t.MakerID, t.TakerID, t.MakerUserID, t.TakerUserID, takerSide, t.Price, t.Qty)
+	}
+}
+
+func (a *RefBookAdapter) wouldCross(side uint8, price int64) bool {
+	if side == Buy {
+		p, ok := a.b.best(Sell)
+		return ok && p <= price
+	}
+	p, ok := a.b.best(Buy)
+	return ok && p >= price
+}
+
+func (a *RefBookAdapter) canFill(side uint8, price, qty int64, market bool) bool {
+	var avail int64
+	a.b.rangeOrders(func(o matching.Order) {
+		if o.Side == side {
+			return
+		}
+		if market || (side == Buy && o.Price <= price) || (side == Sell && o.Price >= price) {
+			avail += o.Qty
+		}
+	})
+	return avail >= qty
+}
+
+func (a *RefBookAdapter) order(orderID uint64) (matching.Order, bool) {
+	var out matching.Order
+	var found bool
+	a.b.rangeOrders(func(o matching.Order) {
+		if o.ID == orderID {
+			out, found = o, true
+		}
+	})
+	return out, found
+}
+
+func (a *RefBookAdapter) Cancel(reqId, orderID uint64, emit Emitter) bool {
+	ok := a.b.cancel(orderID)
+	if ok {
+		emit.Cancelled(reqId, orderID)
+	}
+	return ok
+}
+
+// Amend：语义同 HeapBookAdapter.Amend（改价穿价先撤出再按限价 taker 撮合）
+func (a *RefBookAdapter) Amend(reqId uint64, s AmendSpec, emit Emitter) {
+	o, ok := a.order(s.OrderID)
+	if !ok {
+		emit.Rejected(reqId, s.OrderID, s.UserID, RejectOrderNotFound)
+		return
+	}
+	if s.UserID != 0 && s.UserID != o.UserID {
+		emit.Rejected(reqId, s.OrderID, s.UserID, RejectNotOwner)
+		return
+	}
+	price, qty := o.Price, o.Qty
+	if s.Price > 0 {
+		price = s.Price
+	}
+	if s.Qty > 0 {
+		qty = s.Qty
+	}
+
+	if price != o.Price && a.wouldCross(o.Side, price) {
+		if o.PostOnly {
+			emit.Rejected(reqId, o.ID, o.UserID, RejectPostOnlyCross)
+			return
+		}
+		a.b.cancel(o.ID)
+		emit.Amended(reqId, o.ID, o.UserID, price, qty)
+		a.emitTrades(reqId, o.Side, a.b.submit(&matching.Order{ID: o.ID, UserID: o.UserID, Side: o.Side, Price: price, Qty: qty, ClientID: o.ClientID}), emit)
+		return
+	}
+
+	a.b.amend(o.ID, price, qty)
+	emit.Amended(reqId, o.ID, o.UserID, price, qty)
+}
+
+// CancelAllForUser：按订单号顺序撤，事件序列和 HeapBookAdapter 一致
+func (a *RefBookAdapter) CancelAllForUser(reqId, userID uint64, emit Emitter) int {
+	var ids []uint64
+	a.b.rangeOrders(func(o matching.Order) {
+		if o.UserID == userID {
+			ids = append(ids, o.ID)
+		}
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		a.b.cancel(id)
+		emit.Cancelled(reqId, id)
+	}
+	return len(ids)
+}
+
+func (a *RefBookAdapter) LastPrice() (int64, bool) {
+	return a.lastPx, a.lastPx > 0
+}
+
+func (a *RefBookAdapter) SetLastPrice(px int64) { a.lastPx = px }
+
+func (a *RefBookAdapter) SnapshotOrders() []RestingOrder {
+	out := make([]RestingOrder, 0, 1024)
+	a.b.rangeOrders(func(o matching.Order) {
+		out = append(out, restingFrom(o))
+	})
+	return out
+}
+
+// RestoreOrders：冰山单恢复成整单可见（对照簿不支持冰山）
+func (a *RefBookAdapter) RestoreOrders(orders []RestingOrder) {
+	for _, o := range orders {
+		a.b.add(&matching.Order{ID: o.OrderID, UserID: o.UserID, Side: o.Side, Price: o.Price, Qty: o.Qty + o.Reserve, ClientID: o.ClientOrderID, PostOnly: o.PostOnly, Seq: o.Seq})
+	}
+}
@@ -3,11 +3,16 @@ package engine
 import (
 	"context"
 	"sync/atomic"
+	"time"
+
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/wal"
 )
 
 type ActorConfig struct {
 	MailboxSize int // 有多少个mail处理
 	BatchMax    int // 一次最多多少
+	QuerySize   int // 只读查询通道大小，默认 64
 }
 type walWriter interface {
 	Append(payload []byte) error
@@ -18,19 +23,38 @@ type walWriter interface {
 type SymbolActor struct {
 	book OrderBook    // 订单id
 	in   chan Command // Common通道
+	q    chan query   // 只读查询通道（batch 间隙处理，见 query.go）
 	//out  EventSink    // 输出事件
 	cfg ActorConfig // 配置
 
 	seq uint64 //序列号
 
 	// metrics
-	mailboxFull uint64 // 再说
+	mailboxFull uint64 // TryEnqueue 因 mailbox 满被拒的次数（同时计入 metrics）
 	eventsDrop  uint64 // 再说
 	wal         walWriter
 	outbox      Outbox
 	pubNotify   chan struct{} // buffered=1，用于通知 Publisher “有新事件了
 	cmdCodec    CmdCodec
 	evCodec     EvCodec
+	snap        *snapshotter // nil 表示不做快照
+
+	symbol  string
+	symbols *SymbolRegistry // nil 表示不做交易对规则校验
+	phase   Phase           // 当前交易阶段：precheck 时按命令顺序推进
+	stops   *stopBook       // 止损单触发簿（不进订单簿，随快照保存）
+	depth   *depthView      // L2 聚合深度，nil 表示未开启
+	blocked *userBlocklist  // kill-switch 冻结名单（引擎共享），nil 表示不拦截
+	metrics *actorMetrics   // Prometheus 指标，nil 表示不采集
+	durable atomic.Uint64   // 已落盘（outbox flush 后）的最大 seq，publisher 算 lag 用
+
+	pendingClients map[clientKey]struct{} // 本 batch 已通过校验、还没 apply 的客户端订单号
+	limits         *userLimiter           // 用户级限额状态（见 user_limits.go），频率窗口随快照保存
+	loads          *bookLoads             // 簿里挂单按用户累计（用户级限额用），nil 表示簿不支持
+	now            func() time.Time       // 引擎时钟（EngineTs）
+	trades         tradeSeq               // 成交号生成状态（见 ids.go），随快照保存
+	fees           *FeeSchedule           // 生效的费率表：注册表变化时经 CmdSetFees 落 WAL 再换，随快照保存
+	stp            matching.STPMode       // 生效的自成交防护模式：注册表变化时经 CmdSetSTP 落 WAL 再换，随快照保存
 }
 
 func NewSymbolActor(book OrderBook, cfg ActorConfig, wal walWriter,
@@ -45,6 +69,9 @@ func NewSymbolActor(book OrderBook, cfg ActorConfig, wal walWriter,
 	if cfg.BatchMax <= 0 {
 		cfg.BatchMax = 258
 	}
+	if cfg.QuerySize <= 0 {
+		cfg.QuerySize = 64
+	}
 	if pubNotify == nil {
 		pubNotify = make(chan struct{}, 1)
 	}
@@ -52,6 +79,7 @@ func NewSymbolActor(book OrderBook, cfg ActorConfig, wal walWriter,
 	return &SymbolActor{
 		book: book,
 		in:   make(chan Command, cfg.MailboxSize), //mailbox
+		q:    make(chan query, cfg.QuerySize),
 		//out:       out,
 		cfg:       cfg,
 		wal:       wal,       //WAL（cmd.wal）
@@ -59,6 +87,12 @@ func NewSymbolActor(book OrderBook, cfg ActorConfig, wal walWriter,
 		pubNotify: pubNotify, // actor 写完 outbox 并 flush 后，用它“踢一脚”publisher，减少 poll 延迟
 		cmdCodec:  cmdCodec,
 		evCodec:   evCodec,
+		stops:     newStopBook(),
+
+		pendingClients: make(map[clientKey]struct{}),
+		limits:         newUserLimiter(),
+		loads:          newBookLoads(book),
+		now:            time.Now,
 	}
 }
 
@@ -66,22 +100,43 @@ func (a *SymbolActor) TryEnqueue(cmd Command) error {
 	//  将命令写入in chan
 	// chan限制了数量 如果chan满了 就直接走default 导致刷爆
 	// 可以使用这种方式来限制并发
+	cmd.enqAt = time.Now().UnixNano()
 	select {
 	case a.in <- cmd:
 		return nil
 	default:
 		atomic.AddUint64(&a.mailboxFull, 1)
+		a.metrics.full()
 		return ErrEngineBusy
 	}
 }
 
+// Enqueue：阻塞入队（同步提交用），mailbox 满时等到 ctx 结束
+func (a *SymbolActor) Enqueue(ctx context.Context, cmd Command) error {
+	cmd.enqAt = time.Now().UnixNano()
+	select {
+	case a.in <- cmd:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type pendingReply struct {
+	ch  chan Result
+	res *Result
+}
+
 func (a *SymbolActor) MailboxFull() uint64   { return atomic.LoadUint64(&a.mailboxFull) }
 func (a *SymbolActor) EventsDropped() uint64 { return atomic.LoadUint64(&a.eventsDrop) }
 
 func (a *SymbolActor) Run(ctx context.Context) {
-	if a.wal != nil {
-		defer a.wal.Close()
-	}
+	// 快照可能会替换 a.wal，用闭包关闭“最后那个”
+	defer func() {
+		if a.wal != nil {
+			_ = a.wal.Close()
+		}
+	}()
 	if a.outbox != nil {
 		defer a.outbox.Close()
 	}
@@ -89,6 +144,7 @@ func (a *SymbolActor) Run(ctx context.Context) {
 	// 复用 batch slice，避免每轮分配
 	batch := make([]Command, 0, a.cfg.BatchMax)
 	seqs := make([]uint64, 0, a.cfg.BatchMax) // 对齐 batch，用于第二段 apply
+	replies := make([]pendingReply, 0, 8)     // 本 batch 里同步提交的命令，outbox flush 后回填
 	for {
 		var first Command
 		//这段结构是一个非常常见的模式：“先阻塞拿 1 条，再尽量多拿几条（不阻塞）”。
@@ -96,6 +152,11 @@ func (a *SymbolActor) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case first = <-a.in:
+		case q := <-a.q:
+			// 查询只在 batch 之间执行：看到的一定是完整 batch 之后的状态
+			q.fn(a)
+			close(q.done)
+			continue
 		}
 		// 这句不是“清空数组”，而是：
 		//把 slice 的 长度变成 0
@@ -108,6 +169,12 @@ func (a *SymbolActor) Run(ctx context.Context) {
 		//每轮都 make([]Command, 0, BatchMax) → 频繁分配
 		//或者 batch 会不断增长，内容混进上一轮的数据（逻辑错误）
 		batch = batch[:0]
+		if c, ok := a.feeChange(); ok {
+			batch = append(batch, c)
+		}
+		if c, ok := a.stpChange(); ok {
+			batch = append(batch, c)
+		}
 		batch = append(batch, first)
 		// 不阻塞 尽量拿多条
 		for len(batch) < a.cfg.BatchMax {
@@ -119,8 +186,12 @@ func (a *SymbolActor) Run(ctx context.Context) {
 			}
 		}
 	PROCESS:
+		a.metrics.batch(len(batch), len(a.in))
 		//  记录所有执行的命令
 		seqs = seqs[:0]
+		clear(a.pendingClients)
+		clear(a.limits.pending)
+		clear(a.limits.fresh)
 		if cap(seqs) < len(batch) {
 			seqs = make([]uint64, 0, len(batch))
 		}
@@ -131,6 +202,7 @@ func (a *SymbolActor) Run(ctx context.Context) {
 				a.seq++
 				cmdSeq := a.seq
 				seqs = append(seqs, cmdSeq)
+				batch[i].Reject = a.prepare(&batch[i], cmdSeq)
 				// 栈上数组：避免每条命令分配 payload
 				var rec [cmdRecordLen]byte
 				// wal写了cmd命令
@@ -142,51 +214,60 @@ func (a *SymbolActor) Run(ctx context.Context) {
 				}
 			}
 			// 写了一轮 刷新下flush
+			start := time.Now()
 			if err := a.wal.Flush(); err != nil {
 				return
 			}
+			a.metrics.walFlushed(start)
 		} else {
 			// 未开启 WAL，也要分配 seq，保持事件序号一致
 			for i := 0; i < len(batch); i++ {
 				a.seq++
 				seqs = append(seqs, a.seq)
+				batch[i].Reject = a.prepare(&batch[i], a.seq)
 			}
 		}
 		// ---------- Phase 2: Apply + Outbox（事件事实） ----------
 		// 逐命令执行，事件写 outbox；每条命令末尾写 EvCmdEnd(seq)
+		replies = replies[:0]
 		for i := 0; i < len(batch); i++ {
 			cmd := batch[i]
 			seq := seqs[i]
+			if cmd.Type == CmdSetFees && cmd.Reject == RejectNone {
+				a.fees = cmd.Fees // 从下一条命令起按新费率
+			}
+			if cmd.Type == CmdSetSTP && cmd.Reject == RejectNone {
+				a.stp = cmd.STP // 簿在 applyCommand 里换
+			}
 			var emit Emitter
 			var obEm *outboxEmitter
 			if a.outbox != nil {
 				//  这个seq是每轮都会重置 是否用这个比较可靠
 				//  reqId是由上游传过来的
-				emit = &outboxEmitter{out: a.outbox, seq: seq, req: cmd.ReqID}
+				obEm = &outboxEmitter{out: a.outbox, seq: seq, req: cmd.ReqID, fees: a.fees, trades: &a.trades}
+				emit = obEm
 			} else {
-				emit = noopEmitter{} // 或者你旧的 actorEmitter
+				emit = tradeCounter{trades: &a.trades} // 不出事件也要推进成交序号
 			}
-
-			// 判断命令类型
-			switch cmd.Type {
-			case CmdSubmitLimit:
-				if cmd.OrderID == 0 || cmd.Qty <= 0 || cmd.Price <= 0 || (cmd.Side != Buy && cmd.Side != Sell) {
-					emit.Rejected(cmd.ReqID, cmd.OrderID, cmd.UserID, "bad submit")
-					continue
-				}
-				a.book.SubmitLimit(cmd.ReqID, cmd.OrderID, cmd.UserID, cmd.Side, cmd.Price, cmd.Qty, emit)
-			case CmdCancel:
-				if cmd.CancelOrderID == 0 {
-					emit.Rejected(cmd.ReqID, 0, 0, "bad cancel")
-					continue
+			if cmd.reply != nil {
+				res := &Result{Seq: seq}
+				if obEm != nil {
+					obEm.res = res
+				} else {
+					emit = &outboxEmitter{seq: seq, req: cmd.ReqID, res: res, fees: a.fees, trades: &a.trades}
 				}
-				ok := a.book.Cancel(cmd.ReqID, cmd.CancelOrderID, emit)
-				if !ok {
-					// V0 语义：取消不存在也发一个 Rejected（或你可改成 Cancelled(false)）
-					emit.Rejected(cmd.ReqID, cmd.CancelOrderID, 0, "order not found")
-				}
-			default:
-				emit.Rejected(cmd.ReqID, cmd.OrderID, cmd.UserID, "unknown cmd")
+				replies = append(replies, pendingReply{ch: cmd.reply, res: res})
+			}
+			if a.metrics != nil {
+				emit = rejectCounter{Emitter: emit, m: a.metrics}
+			}
+			if a.loads != nil {
+				emit = loadEmitter{Emitter: emit, l: a.loads}
+			}
+
+			applyCommand(a.book, a.stops, seq, cmd, emit)
+			if a.loads != nil {
+				a.loads.sync()
 			}
 			// outbox 写事件失败：直接停止（重启会靠 cmd.wal 补齐 outbox）
 			if obEm != nil && obEm.err != nil {
@@ -201,18 +282,189 @@ func (a *SymbolActor) Run(ctx context.Context) {
 		}
 		// batch 末尾：outbox Flush 一次（组提交）
 		if a.outbox != nil {
+			start := time.Now()
 			if err := a.outbox.Flush(); err != nil {
 				return
 			}
+			a.metrics.outboxFlushed(start)
+			a.durable.Store(a.seq)
 			// 通知 publisher（不阻塞）
 			select {
 			case a.pubNotify <- struct{}{}:
 			default:
 			}
 		}
+		// 深度增量：每个 batch 一条（行情不需要落盘）
+		if a.depth != nil {
+			a.depth.flush(a.seq)
+		}
+		a.metrics.durable(batch, time.Now())
+		// 事件（含 CmdEnd）已落盘：回填同步调用方，不依赖 publisher/bus
+		for _, r := range replies {
+			select {
+			case r.ch <- *r.res:
+			default:
+			}
+		}
+		// batch 边界：cmd WAL / outbox 都已落盘，可以安全做快照
+		if a.snap.due(a.seq) {
+			if err := a.snapshot(); err != nil {
+				return
+			}
+		}
 	}
 }
 
+// prepare：写 WAL 前分配订单号 + precheck + 客户端订单号查重 + 用户级限额
+func (a *SymbolActor) prepare(cmd *Command, seq uint64) RejectCode {
+	a.limits.stamp(cmd, a.now().UnixNano())
+	if cmd.Type == CmdSetFees || cmd.Type == CmdSetSTP {
+		return RejectNone // actor 自己生成，不走校验
+	}
+	if code := assignOrderID(cmd, a.trades.sym, seq); code != RejectNone {
+		return code
+	}
+	if isSubmit(cmd.Type) && a.trades.n >= tradeSeqLimit {
+		return RejectIDExhausted
+	}
+	code := a.precheck(*cmd)
+	if code != RejectNone {
+		return code
+	}
+	if cmd.Type == CmdAmend {
+		return a.checkAmendLimits(cmd)
+	}
+	if !isSubmit(cmd.Type) {
+		return RejectNone
+	}
+	if cmd.ClientOrderID != 0 && a.dupClientOrder(*cmd) {
+		return RejectDupClientOrderID
+	}
+	if code := a.checkLimits(cmd); code != RejectNone {
+		return code
+	}
+	if cmd.ClientOrderID != 0 {
+		a.pendingClients[clientKey{user: cmd.UserID, client: cmd.ClientOrderID}] = struct{}{}
+	}
+	return RejectNone
+}
+
+// feeChange：每个 batch 开头比对注册表里的费率表，变了就生成一条 CmdSetFees 排在 batch 最前面
+// 费率随 cmd WAL 落盘，重启/回放补 outbox 用的是当时的费率，而不是重启时注册表里的
+func (a *SymbolActor) feeChange() (Command, bool) {
+	if a.symbols == nil {
+		return Command{}, false
+	}
+	spec, _ := a.symbols.Get(a.symbol)
+	if spec.Fees == a.fees {
+		return Command{}, false
+	}
+	if spec.Fees.equal(a.fees) {
+		a.fees = spec.Fees // 内容相同（如重启后快照里的费率）：换成注册表的指针，之后走快路径
+		return Command{}, false
+	}
+	return Command{Type: CmdSetFees, Fees: spec.Fees}, true
+}
+
+// stpChange：同 feeChange，注册表里的 STP 模式变了就生成一条 CmdSetSTP
+// 簿只按 WAL 里的 CmdSetSTP / 快照换模式，重启/备库/离线回放的撮合结果和线上一致
+func (a *SymbolActor) stpChange() (Command, bool) {
+	if a.symbols == nil {
+		return Command{}, false
+	}
+	spec, _ := a.symbols.Get(a.symbol)
+	if spec.STP == a.stp {
+		return Command{}, false
+	}
+	return Command{Type: CmdSetSTP, STP: spec.STP}, true
+}
+
+// precheck：写 WAL 前按交易阶段 + 交易对规则校验（结果码随命令落 WAL）
+// 注意：按 batch 顺序逐条校验，价格带用的是校验时刻的最新成交价
+func (a *SymbolActor) precheck(cmd Command) RejectCode {
+	if code := a.phase.check(cmd); code != RejectNone {
+		return code
+	}
+	if opensRisk(cmd.Type) && a.blocked.has(cmd.UserID) {
+		return RejectUserBlocked
+	}
+	if cmd.Type == CmdAuction {
+		if _, ok := a.book.(AuctionBook); !ok {
+			return RejectUnsupported
+		}
+	}
+	if isControl(cmd.Type) {
+		// 阶段在这里推进：同一 batch 里后面的命令立刻按新阶段校验
+		if p, ok := targetPhase(cmd.Type); ok {
+			a.phase = p
+		}
+		return RejectNone
+	}
+	if a.symbols == nil {
+		return RejectNone
+	}
+	spec, ok := a.symbols.Get(a.symbol)
+	if !ok {
+		return RejectHalted // 未注册（建 actor 时已拦截，这里只是兜底）
+	}
+	var lastPx int64
+	if lp, ok := a.book.(LastPricer); ok {
+		lastPx, _ = lp.LastPrice()
+	}
+	return spec.check(cmd, lastPx)
+}
+
+// snapshot：在 actor 协程上导出订单簿，与写操作天然一致
+// 快照写失败不致命（cmd WAL 仍完整）：记指标，本轮不动 WAL，下个周期再试；只有 WAL 截断后重开失败才退出
+// cmd WAL 只截到最老保留快照：最新快照坏了还能退回老快照 + 回放它之后的 WAL
+func (a *SymbolActor) snapshot() error {
+	sb, ok := a.book.(BookSnapshotter)
+	if !ok {
+		return nil
+	}
+	s := a.snap
+	sw, segmented := a.wal.(*wal.SegmentedWriter)
+	var walOff int64
+	if segmented {
+		walOff = sw.Offset()
+	}
+	if err := writeSnapshot(s.walDir, s.symbol, a.seq, walOff, symState{phase: a.phase, trades: a.trades, fees: a.fees, stp: a.stp, limits: a.limits, lastPx: bookLastPrice(a.book)}, sb.SnapshotOrders(), a.stops.orders()); err != nil {
+		s.lastSeq = a.seq // 不每个 batch 重试整簿导出（磁盘满时只会更糟）
+		a.metrics.snapshotFailed("write")
+		return nil
+	}
+	s.lastSeq = a.seq
+	pruneSnapshots(s.walDir, s.symbol, s.keep)
+
+	if s.walMode == SnapshotWALKeep || a.wal == nil {
+		return nil
+	}
+	// 分段 WAL：按最老保留快照清理旧段，写端不用重开；清理失败不致命
+	if segmented {
+		if err := s.retainSegments(sw); err != nil {
+			a.metrics.snapshotFailed("retain")
+		}
+		return nil
+	}
+	upTo, ok := oldestSnapshotSeq(s.walDir, s.symbol)
+	if !ok {
+		return nil
+	}
+	if err := a.wal.Close(); err != nil {
+		return err
+	}
+	// 截断失败不致命：原文件还在（rename 是原子的），重开写端接着写，下个周期再试
+	if err := s.compactWAL(upTo, a.cmdCodec); err != nil {
+		a.metrics.snapshotFailed("retain")
+	}
+	w, err := openLogWriter(s.cmdPath, s.walBufSize, nil)
+	if err != nil {
+		return err
+	}
+	a.wal = w
+	return nil
+}
+
 //type actorEmitter struct {
 //	actor *SymbolActor
 //	reqID uint64
@@ -245,15 +497,27 @@ func (a *SymbolActor) Run(ctx context.Context) {
 //	})
 //}
 
+// outboxEmitter：把事件写进 outbox；res 非 nil 时顺带收集给同步调用方（out 可为 nil）
 type outboxEmitter struct {
-	out Outbox
-	seq uint64
-	req uint64
-	idx uint16
-	err error
+	out    Outbox
+	seq    uint64
+	req    uint64
+	idx    uint16
+	err    error
+	res    *Result
+	fees   *FeeSchedule // nil 不算手续费
+	trades *tradeSeq    // 成交号
 }
 
 func (e *outboxEmitter) next() uint16 { i := e.idx; e.idx++; return i }
+func (e *outboxEmitter) emit(ev Event) {
+	if e.res != nil {
+		e.res.add(ev)
+	}
+	if e.out != nil {
+		e.setErr(e.out.Append(ev))
+	}
+}
 func (e *outboxEmitter) setErr(err error) {
 	if e.err == nil && err != nil {
 		e.err = err
@@ -261,33 +525,85 @@ func (e *outboxEmitter) setErr(err error) {
 }
 
 func (e *outboxEmitter) Accepted(reqID uint64, orderID, userID uint64) {
-	e.setErr(e.out.Append(Event{
+	e.emit(Event{
 		Type: EvAccepted, Seq: e.seq, Idx: e.next(), ReqID: reqID,
 		OrderID: orderID, UserID: userID,
-	}))
+	})
 }
-func (e *outboxEmitter) Rejected(reqID uint64, orderID, userID uint64, reason string) {
-	e.setErr(e.out.Append(Event{
+func (e *outboxEmitter) Rejected(reqID uint64, orderID, userID uint64, code RejectCode) {
+	e.emit(Event{
 		Type: EvRejected, Seq: e.seq, Idx: e.next(), ReqID: reqID,
-		OrderID: orderID, UserID: userID,
-	}))
+		OrderID: orderID, UserID: userID, Code: code, Reason: code.String(),
+	})
 }
 func (e *outboxEmitter) Added(reqID uint64, orderID, userID uint64) {
-	e.setErr(e.out.Append(Event{
+	e.emit(Event{
 		Type: EvAdded, Seq: e.seq, Idx: e.next(), ReqID: reqID,
 		OrderID: orderID, UserID: userID,
-	}))
+	})
 }
 func (e *outboxEmitter) Cancelled(reqID uint64, orderID uint64) {
-	e.setErr(e.out.Append(Event{
+	e.emit(Event{
 		Type: EvCancelled, Seq: e.seq, Idx: e.next(), ReqID: reqID,
 		OrderID: orderID,
-	}))
+	})
 }
-func (e *outboxEmitter) Trade(reqID uint64, makerOrderID, takerOrderID uint64, price, qty int64) {
-	e.setErr(e.out.Append(Event{
-		Type: EvTrade, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+func (e *outboxEmitter) Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64) {
+	idx := e.next()
+	ev := Event{
+		Type: EvTrade, Seq: e.seq, Idx: idx, ReqID: reqID,
 		MakerOrderID: makerOrderID, TakerOrderID: takerOrderID,
-		Price: price, Qty: qty,
-	}))
+		Price: price, Qty: qty, TradeID: e.trades.next(),
+		MakerUserID: makerUserID, TakerUserID: takerUserID, TakerSide: takerSide,
+	}
+	e.fees.apply(&ev)
+	e.emit(ev)
+}
+func (e *outboxEmitter) Expired(reqID uint64, orderID, userID uint64, qty int64) {
+	e.emit(Event{
+		Type: EvExpired, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, UserID: userID, Qty: qty,
+	})
+}
+func (e *outboxEmitter) PhaseChanged(reqID uint64, phase Phase) {
+	e.emit(Event{
+		Type: EvPhase, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		Qty: int64(phase),
+	})
+}
+func (e *outboxEmitter) SelfTradePrevented(reqID uint64, orderID, userID, makerOrderID, takerOrderID uint64, qty int64) {
+	e.emit(Event{
+		Type: EvSelfTrade, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, UserID: userID, MakerOrderID: makerOrderID, TakerOrderID: takerOrderID, Qty: qty,
+	})
+}
+func (e *outboxEmitter) StopAccepted(reqID uint64, orderID, userID uint64, stopPrice, qty int64) {
+	e.emit(Event{
+		Type: EvStopNew, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, UserID: userID, Price: stopPrice, Qty: qty,
+	})
+}
+func (e *outboxEmitter) StopTriggered(reqID uint64, orderID, userID uint64, price, qty int64) {
+	e.emit(Event{
+		Type: EvStopFire, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, UserID: userID, Price: price, Qty: qty,
+	})
+}
+func (e *outboxEmitter) StopCancelled(reqID uint64, orderID, userID uint64) {
+	e.emit(Event{
+		Type: EvStopCxl, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, UserID: userID,
+	})
+}
+func (e *outboxEmitter) Replenished(reqID uint64, orderID uint64, qty int64) {
+	e.emit(Event{
+		Type: EvReplenish, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, Qty: qty,
+	})
+}
+func (e *outboxEmitter) Amended(reqID uint64, orderID, userID uint64, price, qty int64) {
+	e.emit(Event{
+		Type: EvAmended, Seq: e.seq, Idx: e.next(), ReqID: reqID,
+		OrderID: orderID, UserID: userID, Price: price, Qty: qty,
+	})
 }
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"testing"
+
+	"gopherex.com/internal/matching"
+)
+
+func amend(book OrderBook, reqID, orderID uint64, price, qty int64) *recEmitter {
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdAmend, ReqID: reqID, OrderID: orderID, UserID: 9, Price: price, Qty: qty}, em)
+	return em
+}
+
+func TestAmend_ReduceKeepsPriority(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 100, 5)
+	seedAsk(book, 2, 100, 5)
+
+	em := amend(book, 10, 1, 0, 2)
+	assertTypes(t, em.types(), EvAmended)
+	if em.evs[0].Price != 100 || em.evs[0].Qty != 2 {
+		t.Fatalf("amended=%+v", em.evs[0])
+	}
+
+	// 1 仍在队首：先吃 1 的 2，再吃 2
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 1, Side: Buy, Price: 100, Qty: 3}, em)
+	assertTypes(t, em.types(), EvAccepted, EvTrade, EvTrade)
+	if em.evs[1].MakerOrderID != 1 || em.evs[1].Qty != 2 || em.evs[2].MakerOrderID != 2 {
+		t.Fatalf("unexpected fills: %+v", em.evs)
+	}
+}
+
+func TestAmend_IncreaseRequeues(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 100, 1)
+	seedAsk(book, 2, 100, 1)
+
+	assertTypes(t, amend(book, 10, 1, 0, 3).types(), EvAmended)
+
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 1, Side: Buy, Price: 100, Qty: 1}, em)
+	assertTypes(t, em.types(), EvAccepted, EvTrade)
+	if em.evs[1].MakerOrderID != 2 {
+		t.Fatalf("increased order should lose priority, maker=%d", em.evs[1].MakerOrderID)
+	}
+}
+
+func TestAmend_RepriceCrossesAndRejects(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 105, 3)
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 1, Side: Buy, Price: 100, Qty: 1}, noopEmitter{})
+
+	// 卖单改价到 100：穿价，按 taker 成交，剩余 2 挂在 100
+	em := amend(book, 10, 1, 100, 0)
+	assertTypes(t, em.types(), EvAmended, EvTrade)
+	if em.evs[1].MakerOrderID != 2 || em.evs[1].TakerOrderID != 1 {
+		t.Fatalf("unexpected trade: %+v", em.evs[1])
+	}
+	b := book.(*HeapBookAdapter).B
+	if o, ok := b.Order(1); !ok || o.Price != 100 || o.Qty != 2 {
+		t.Fatalf("rest after cross: %+v %v", o, ok)
+	}
+
+	assertTypes(t, amend(book, 11, 404, 0, 1).types(), EvRejected)
+	assertTypes(t, amend(book, 12, 1, 0, 0).types(), EvRejected)
+
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdAmend, ReqID: 13, OrderID: 1, UserID: 7, Qty: 1}, em)
+	assertTypes(t, em.types(), EvRejected)
+	if em.evs[0].Code != RejectNotOwner {
+		t.Fatalf("code=%v", em.evs[0].Code)
+	}
+}
+
+// PostOnly 挂单改价穿价：拒单，原单留在簿里；快照后同样生效
+func TestAmend_PostOnlyRepriceCrossRejected(t *testing.T) {
+	for name, book := range map[string]OrderBook{"heap": newHeapBook(), "level": NewLevelBookAdapter(matching.NewLevelOrderBook())} {
+		applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 9, Side: Sell, Price: 105, Qty: 3, PostOnly: true}, noopEmitter{})
+		applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 1, Side: Buy, Price: 100, Qty: 1}, noopEmitter{})
+
+		em := amend(book, 10, 1, 100, 0)
+		assertTypes(t, em.types(), EvRejected)
+		if em.evs[0].Code != RejectPostOnlyCross {
+			t.Fatalf("%s: code=%v", name, em.evs[0].Code)
+		}
+		// 不穿价的改价照常
+		assertTypes(t, amend(book, 11, 1, 101, 0).types(), EvAmended)
+
+		restored := newHeapBook()
+		_, orders, _, err := decodeSnapshot(encodeSnapshot(1, 0, symState{phase: PhaseOpen}, book.(BookSnapshotter).SnapshotOrders(), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored.(BookSnapshotter).RestoreOrders(orders)
+		if em := amend(restored, 12, 1, 99, 0); em.evs[0].Code != RejectPostOnlyCross {
+			t.Fatalf("%s: after snapshot: %+v", name, em.evs)
+		}
+	}
+}
+
+func TestAmend_Codecs(t *testing.T) {
+	in := Command{Type: CmdAmend, ReqID: 7, OrderID: 1001, UserID: 2001, Price: 99, Qty: 3}
+	p, err := BinaryCMDCode{}.Encode(nil, 42, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, out, err := BinaryCMDCode{}.Decode(p)
+	if err != nil || seq != 42 || out != in {
+		t.Fatalf("cmd roundtrip: seq=%d got=%+v err=%v", seq, out, err)
+	}
+
+	ev := Event{Type: EvAmended, Seq: 42, Idx: 0, ReqID: 7, OrderID: 1001, UserID: 2001, Price: 99, Qty: 3}
+	b, err := EvCmdCodec{}.Encode(nil, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := EvCmdCodec{}.Decode(b)
+	if err != nil || got != ev {
+		t.Fatalf("ev roundtrip: got=%+v err=%v", got, err)
+	}
+}
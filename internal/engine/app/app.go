@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	enginev1 "gopherex.com/gen/go/engine/v1"
+	fundsv1 "gopherex.com/gen/go/fund_service/v1"
+	"gopherex.com/internal/engine"
+	"gopherex.com/internal/engine/risk"
+	"gopherex.com/internal/engine/service"
+	"gopherex.com/internal/transport/grpc/interceptors"
+	"gopherex.com/pkg/bootstrap"
+	"gopherex.com/pkg/interceptor"
+	"gopherex.com/pkg/safe"
+	"gopherex.com/pkg/trace"
+)
+
+// Run 启动撮合服务：外层只需传入 ctx 即可。
+func Run(ctx context.Context) error {
+	cfg := &Cfg{}
+
+	validator, err := interceptors.NewValidator()
+	if err != nil {
+		return fmt.Errorf("init validator: %w", err)
+	}
+
+	return bootstrap.Run(ctx, bootstrap.Options{
+		ConfigName: "matching-service",
+		ConfigPtr:  cfg,
+		ServiceName: func(_ interface{}) string {
+			return cfg.Name
+		},
+		GRPCAddr: func(_ interface{}) string {
+			return cfg.Addr
+		},
+		EtcdConfig: func(_ interface{}) *bootstrap.EtcdCfg {
+			return &bootstrap.EtcdCfg{
+				Endpoints:     cfg.Etcd.Endpoints,
+				ServicePrefix: cfg.Etcd.ServicePrefix,
+			}
+		},
+		InitTracer: func(_ interface{}) (func(context.Context) error, error) {
+			if !cfg.OTel.Enabled {
+				return nil, nil
+			}
+			return trace.InitTrace(cfg.Name, cfg.OTel.Addr)
+		},
+		BuildServices: func(c context.Context, _ interface{}, _ bootstrap.Deps) (func(*grpc.Server) error, error) {
+			eng, err := NewEngine(c, cfg)
+			if err != nil {
+				return nil, err
+			}
+			// 服务退出时停掉所有 actor（WAL 在 actor 退出前落盘）
+			go func() {
+				<-c.Done()
+				eng.Stop()
+			}()
+			srv := service.NewEngineService(eng)
+			return func(gs *grpc.Server) error {
+				enginev1.RegisterMatchingServiceServer(gs, srv)
+				return nil
+			}, nil
+		},
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{
+			interceptor.RecoverUnary(),
+			interceptor.ErrorUnary(),
+			interceptor.RequestIDServerUnary(),
+			validator.Unary(),
+		},
+		StreamInterceptors: []grpc.StreamServerInterceptor{
+			validator.Stream(),
+		},
+		StatsHandler: otelgrpc.NewServerHandler(),
+		MetricsAddr:  "0.0.0.0:9092",
+		PprofAddr:    "127.0.0.1:6556",
+	})
+}
+
+// NewEngine 按配置组装引擎：cmd WAL + outbox 必开（订阅回放依赖 outbox），事件由订阅方直接 tail outbox
+// 配了 funds.addr 时挂上资金冻结：下单走 Submit 同步冻结，释放由命名消费者 tail outbox 完成（不开 publisher）
+func NewEngine(ctx context.Context, cfg *Cfg) (*engine.Engine, error) {
+	if cfg.Engine.WALDir == "" {
+		return nil, fmt.Errorf("engine.wal_dir is required")
+	}
+	var reg *engine.SymbolRegistry
+	if len(cfg.Symbols) > 0 {
+		specs := make([]engine.SymbolSpec, 0, len(cfg.Symbols))
+		for _, s := range cfg.Symbols {
+			spec, err := s.spec()
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+		r, err := engine.NewSymbolRegistry(specs...)
+		if err != nil {
+			return nil, err
+		}
+		reg = r
+	}
+	books, err := engine.NewBookFactory(cfg.Engine.Book)
+	if err != nil {
+		return nil, fmt.Errorf("engine.book: %w", err)
+	}
+	ecfg := engine.EngineConfig{
+		WALDir:        cfg.Engine.WALDir,
+		EnableCmdWAL:  true,
+		EnableOutbox:  true,
+		CmdCodec:      engine.BinaryCMDCode{},
+		EvCodec:       engine.EvCmdCodec{},
+		SnapshotEvery: cfg.Engine.SnapshotEvery,
+		SubmitTimeout: time.Duration(cfg.Engine.SubmitTimeoutMs) * time.Millisecond,
+		Symbols:       reg,
+		ActorCfg: engine.ActorConfig{
+			MailboxSize: cfg.Engine.MailboxSize,
+			BatchMax:    cfg.Engine.BatchMax,
+		},
+		BookFactory: books,
+	}
+	guard, err := newFundsGuard(ctx, cfg.Funds, reg)
+	if err != nil {
+		return nil, err
+	}
+	if guard != nil {
+		ecfg.PreTrade = guard
+	}
+	eng := engine.NewEngine(ecfg)
+	if guard != nil {
+		for _, s := range cfg.Symbols {
+			sym := s.Symbol
+			safe.Go(func() { consumeFunds(ctx, guard, eng, sym) })
+		}
+	}
+	return eng, nil
+}
+
+// newFundsGuard：未配置返回 nil；连接随 ctx 结束关闭
+func newFundsGuard(ctx context.Context, cfg FundsCfg, reg *engine.SymbolRegistry) (*risk.FundsGuard, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	if reg == nil {
+		return nil, fmt.Errorf("funds: symbols with base/quote assets are required")
+	}
+	conn, err := grpc.NewClient(cfg.Addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("funds: %w", err)
+	}
+	safe.Go(func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	})
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+	return risk.NewFundsGuard(fundsv1.NewFundServiceClient(conn), reg, timeout), nil
+}
+
+// consumeFunds：Consume 出错（打开消费者失败等）隔一会儿重来，直到 ctx 结束
+func consumeFunds(ctx context.Context, g *risk.FundsGuard, eng *engine.Engine, symbol string) {
+	for {
+		_ = g.Consume(ctx, eng, symbol)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"fmt"
+
+	"gopherex.com/internal/engine"
+	"gopherex.com/internal/matching"
+)
+
+type Cfg struct {
+	Name    string      `yaml:"name" mapstructure:"name"`
+	Addr    string      `yaml:"addr" mapstructure:"addr"`
+	Engine  EngineCfg   `yaml:"engine" mapstructure:"engine"`
+	Symbols []SymbolCfg `yaml:"symbols" mapstructure:"symbols"`
+	Funds   FundsCfg    `yaml:"funds" mapstructure:"funds"`
+	OTel    OTel        `yaml:"otel" mapstructure:"otel"`
+	Etcd    Etcd        `yaml:"etcd" mapstructure:"etcd"`
+}
+
+type EngineCfg struct {
+	WALDir          string `yaml:"wal_dir" mapstructure:"wal_dir"`
+	SnapshotEvery   uint64 `yaml:"snapshot_every" mapstructure:"snapshot_every"`
+	MailboxSize     int    `yaml:"mailbox_size" mapstructure:"mailbox_size"`
+	BatchMax        int    `yaml:"batch_max" mapstructure:"batch_max"`
+	SubmitTimeoutMs int    `yaml:"submit_timeout_ms" mapstructure:"submit_timeout_ms"`
+	Book            string `yaml:"book" mapstructure:"book"` // heap（默认）| skiplist，见 engine.NewBookFactory
+}
+
+// FundsCfg：下单前按名义价值冻结资金（见 risk.FundsGuard）；Addr 为空不接入
+type FundsCfg struct {
+	Addr      string `yaml:"addr" mapstructure:"addr"`             // 资金服务 gRPC 地址
+	TimeoutMs int    `yaml:"timeout_ms" mapstructure:"timeout_ms"` // 单次 Reserve/Release 超时，0 用默认值
+}
+
+// SymbolCfg：启动时注册的交易对（见 engine.SymbolSpec）
+type SymbolCfg struct {
+	Symbol          string  `yaml:"symbol" mapstructure:"symbol"`
+	ID              uint16  `yaml:"id" mapstructure:"id"` // 交易对编号（1..engine.MaxSymbolID，各交易对不同，分配后不能改）
+	TickSize        int64   `yaml:"tick_size" mapstructure:"tick_size"`
+	LotSize         int64   `yaml:"lot_size" mapstructure:"lot_size"`
+	MinNotional     int64   `yaml:"min_notional" mapstructure:"min_notional"`
+	MaxDeviationBps int64   `yaml:"max_deviation_bps" mapstructure:"max_deviation_bps"`
+	Base            string  `yaml:"base" mapstructure:"base"`
+	Quote           string  `yaml:"quote" mapstructure:"quote"`
+	Fees            *FeeCfg `yaml:"fees" mapstructure:"fees"`
+	MaxOpenOrders   int     `yaml:"max_open_orders" mapstructure:"max_open_orders"`
+	MaxOrderRate    int     `yaml:"max_order_rate" mapstructure:"max_order_rate"`
+	MaxOpenNotional int64   `yaml:"max_open_notional" mapstructure:"max_open_notional"`
+	STP             string  `yaml:"stp" mapstructure:"stp"` // 自成交防护：none | cancel_newest | cancel_oldest | cancel_both | decrement
+}
+
+// FeeCfg：费率单位 1e-6，负数为返佣（见 engine.FeeSchedule）
+type FeeCfg struct {
+	Maker      int64         `yaml:"maker" mapstructure:"maker"`
+	Taker      int64         `yaml:"taker" mapstructure:"taker"`
+	FeeInQuote bool          `yaml:"fee_in_quote" mapstructure:"fee_in_quote"`
+	Tiers      []FeeTierCfg  `yaml:"tiers" mapstructure:"tiers"`
+	Users      []UserTierCfg `yaml:"users" mapstructure:"users"`
+}
+
+type FeeTierCfg struct {
+	Tier  uint8 `yaml:"tier" mapstructure:"tier"`
+	Maker int64 `yaml:"maker" mapstructure:"maker"`
+	Taker int64 `yaml:"taker" mapstructure:"taker"`
+}
+
+type UserTierCfg struct {
+	UserID uint64 `yaml:"user_id" mapstructure:"user_id"`
+	Tier   uint8  `yaml:"tier" mapstructure:"tier"`
+}
+
+type OTel struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Addr    string `yaml:"addr" mapstructure:"addr"`
+}
+
+type Etcd struct {
+	Endpoints     []string `yaml:"endpoints" mapstructure:"endpoints"`
+	ServicePrefix string   `yaml:"service_prefix" mapstructure:"service_prefix"`
+}
+
+// SymbolSpec：配置里某个交易对的规则（离线工具复用线上费率表 / STP 模式）
+func (c *Cfg) SymbolSpec(symbol string) (engine.SymbolSpec, error) {
+	for _, s := range c.Symbols {
+		if s.Symbol == symbol {
+			return s.spec()
+		}
+	}
+	return engine.SymbolSpec{}, fmt.Errorf("symbol %s not configured", symbol)
+}
+
+func (c SymbolCfg) spec() (engine.SymbolSpec, error) {
+	if c.ID == 0 || c.ID > engine.MaxSymbolID {
+		return engine.SymbolSpec{}, fmt.Errorf("symbol %s: id must be in [1, %d]", c.Symbol, engine.MaxSymbolID)
+	}
+	stp, ok := matching.ParseSTPMode(c.STP)
+	if !ok {
+		return engine.SymbolSpec{}, fmt.Errorf("symbol %s: unknown stp mode %q", c.Symbol, c.STP)
+	}
+	return engine.SymbolSpec{
+		Symbol:          c.Symbol,
+		ID:              c.ID,
+		TickSize:        c.TickSize,
+		LotSize:         c.LotSize,
+		MinNotional:     c.MinNotional,
+		MaxDeviationBps: c.MaxDeviationBps,
+		Base:            c.Base,
+		Quote:           c.Quote,
+		Fees:            c.Fees.schedule(),
+		MaxOpenOrders:   c.MaxOpenOrders,
+		MaxOrderRate:    c.MaxOrderRate,
+		MaxOpenNotional: c.MaxOpenNotional,
+		STP:             stp,
+	}, nil
+}
+
+func (c *FeeCfg) schedule() *engine.FeeSchedule {
+	if c == nil {
+		return nil
+	}
+	f := &engine.FeeSchedule{
+		FeeRates:   engine.FeeRates{Maker: c.Maker, Taker: c.Taker},
+		FeeInQuote: c.FeeInQuote,
+		Tiers:      make(map[uint8]engine.FeeRates, len(c.Tiers)),
+		UserTiers:  make(map[uint64]uint8, len(c.Users)),
+	}
+	for _, t := range c.Tiers {
+		f.Tiers[t.Tier] = engine.FeeRates{Maker: t.Maker, Taker: t.Taker}
+	}
+	for _, u := range c.Users {
+		f.UserTiers[u.UserID] = u.Tier
+	}
+	return f
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestAuction_HaltAuctionUncrossWithRestart(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	newEng := func() *Engine {
+		return NewEngine(EngineConfig{
+			WALDir:        dir,
+			EnableCmdWAL:  true,
+			EnableOutbox:  true,
+			CmdCodec:      BinaryCMDCode{},
+			EvCodec:       EvCmdCodec{},
+			ActorCfg:      ActorConfig{MailboxSize: 64, BatchMax: 1},
+			SnapshotEvery: 3,
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		})
+	}
+	ctx := context.Background()
+	submit := func(eng *Engine, id uint64, side uint8, price, qty int64, tif TimeInForce) Result {
+		t.Helper()
+		res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: id, OrderID: id, UserID: id, Side: side, Price: price, Qty: qty, TIF: tif})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	eng := newEng()
+	if _, err := eng.Halt(ctx, sym); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := eng.Uncross(ctx, sym); res.Code != RejectWrongPhase {
+		t.Fatalf("uncross outside auction: %+v", res)
+	}
+	res, err := eng.StartAuction(ctx, sym)
+	if err != nil || len(res.Events) != 1 || Phase(res.Events[0].Qty) != PhaseAuction {
+		t.Fatalf("start auction: %+v %v", res, err)
+	}
+	// 竞价收单：交叉也不成交
+	for _, c := range []struct {
+		id    uint64
+		side  uint8
+		price int64
+		qty   int64
+	}{{1, Buy, 102, 5}, {2, Buy, 101, 3}, {3, Sell, 99, 4}} {
+		if res = submit(eng, c.id, c.side, c.price, c.qty, TifGTC); !res.Accepted || len(res.Trades) != 0 {
+			t.Fatalf("auction submit %d: %+v", c.id, res)
+		}
+	}
+	if res = submit(eng, 9, Sell, 99, 1, TifIOC); res.Code != RejectAuctionOrder {
+		t.Fatalf("ioc in auction: %+v", res)
+	}
+	if res, _ = eng.Resume(ctx, sym); res.Code != RejectWrongPhase {
+		t.Fatalf("resume in auction: %+v", res)
+	}
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// 重启：快照 + 回放后仍在竞价，新单照样只挂不撮
+	eng2 := newEng()
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	if res = submit(eng2, 4, Sell, 100, 4, TifGTC); !res.Accepted || len(res.Trades) != 0 {
+		t.Fatalf("auction submit after restart: %+v", res)
+	}
+
+	res, err = eng2.Uncross(ctx, sym)
+	if err != nil || len(res.Trades) != 3 || res.FilledQty != 8 {
+		t.Fatalf("uncross: %+v %v", res, err)
+	}
+	for _, tr := range res.Trades {
+		if tr.Price != 100 {
+			t.Fatalf("trade not at auction price: %+v", tr)
+		}
+	}
+	if last := res.Events[len(res.Events)-1]; last.Type != EvPhase || Phase(last.Qty) != PhaseOpen {
+		t.Fatalf("last event=%+v, want phase open", last)
+	}
+
+	// 回到连续交易：新单正常撮合
+	if res = submit(eng2, 5, Buy, 105, 1, TifGTC); !res.Accepted || len(res.Events) != 2 || res.Events[1].Type != EvAdded {
+		t.Fatalf("continuous submit: %+v", res)
+	}
+	a, _ := eng2.getOrCreateActor(sym)
+	if px, ok := a.book.(LastPricer).LastPrice(); !ok || px != 100 {
+		t.Fatalf("last price=%d/%v, want 100", px, ok)
+	}
+}
+
+// 竞价成交没有主动方：TakerSide=0、双方按 maker 费率；同一用户的买卖单按 STP 模式处理
+func TestAuction_UncrossMakerFeesAndSTP(t *testing.T) {
+	cfg := replTestCfg(t.TempDir())
+	const sym = "BTCUSDT"
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, STP: matching.STPCancelNewest,
+		Fees: &FeeSchedule{FeeRates: FeeRates{Maker: 1000, Taker: 2000}}})
+	f, err := NewBookFactory(BookHeap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BookFactory = f
+	eng := NewEngine(cfg)
+	defer func() {
+		eng.Stop()
+		time.Sleep(20 * time.Millisecond)
+	}()
+	ctx := context.Background()
+	if _, err := eng.Halt(ctx, sym); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.StartAuction(ctx, sym); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Command{
+		{OrderID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 3_000},
+		{OrderID: 2, UserID: 8, Side: Sell, Price: 100, Qty: 4_000},
+		{OrderID: 3, UserID: 7, Side: Buy, Price: 101, Qty: 5_000}, // 比 1 新：撞上 1 时整单撤
+		{OrderID: 4, UserID: 9, Side: Buy, Price: 100, Qty: 4_000},
+	} {
+		c.Type, c.ReqID = CmdSubmitLimit, c.OrderID
+		if res, err := eng.Submit(ctx, sym, c); err != nil || !res.Accepted {
+			t.Fatalf("submit %d: %+v %v", c.OrderID, res, err)
+		}
+	}
+	res, err := eng.Uncross(ctx, sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stp []Event
+	for _, ev := range res.Events {
+		if ev.Type == EvSelfTrade {
+			stp = append(stp, ev)
+		}
+	}
+	if len(stp) != 1 || stp[0].OrderID != 3 || stp[0].Qty != 5_000 {
+		t.Fatalf("stp events=%+v", stp)
+	}
+	if len(res.Trades) != 2 {
+		t.Fatalf("trades=%+v", res.Trades)
+	}
+	for _, tr := range res.Trades {
+		if tr.TakerOrderID != 4 || tr.TakerSide != 0 || tr.MakerUserID == tr.TakerUserID {
+			t.Fatalf("trade=%+v", tr)
+		}
+		// 买方收 base、卖方收 quote，都是 maker 费率 0.1%
+		if tr.TakerFee != FeeAmount(tr.Qty, 1000) || tr.TakerFeeAsset != FeeAssetBase ||
+			tr.MakerFee != FeeAmount(tr.Price*tr.Qty, 1000) || tr.MakerFeeAsset != FeeAssetQuote {
+			t.Fatalf("fees=%+v", tr)
+		}
+	}
+}
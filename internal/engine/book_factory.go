@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"fmt"
+
+	"gopherex.com/internal/matching"
+)
+
+// 订单簿实现（配置 engine.book / engine-replay -book）
+// - heap：价位桶 + lazy 堆取 best（默认）
+// - skiplist：价位桶 + 跳表价位索引，撤空即出索引，深度/快照按序遍历不排序
+// - level / naive：参考实现，只用于离线回放对账（见 RefBookAdapter）
+const (
+	BookHeap     = "heap"
+	BookSkipList = "skiplist"
+	BookLevel    = "level"
+	BookNaive    = "naive"
+)
+
+// NewBookFactory：按名字选订单簿实现；空串按 heap
+// 新簿不带自成交防护，交易对的 STP 模式由 actor 经 CmdSetSTP 落 WAL 后再设（见 SymbolActor.stpChange）
+func NewBookFactory(kind string) (BookFactory, error) {
+	heapBook := func(newBook func() *matching.LevelOrderBookHeap) BookFactory {
+		return func(string) (OrderBook, error) {
+			return NewHeapBookAdapter(newBook()), nil
+		}
+	}
+	switch kind {
+	case BookHeap, "":
+		return heapBook(matching.NewLevelOrderHeapBook), nil
+	case BookSkipList:
+		return heapBook(matching.NewLevelOrderSkipListBook), nil
+	case BookLevel:
+		return func(string) (OrderBook, error) {
+			return NewLevelBookAdapter(matching.NewLevelOrderBook()), nil
+		}, nil
+	case BookNaive:
+		return func(string) (OrderBook, error) {
+			return NewNaiveBookAdapter(matching.NewNaiveOrderBook()), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown book %q (heap | skiplist | level | naive)", kind)
+}
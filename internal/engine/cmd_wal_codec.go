@@ -3,23 +3,42 @@ package engine
 import (
 	"encoding/binary"
 	"errors"
+
+	"gopherex.com/internal/matching"
 )
 
 const (
-	cmdWalVersion = 1
-	cmdRecordLen  = 67
+	// v2：在 v1 末尾追加 tif/flags、reject 码、止损触发价、冰山显示数量、客户端订单号、
+	// 资金冻结 entryset + 冻结数量、引擎时间；CmdSetFees 在定长部分之后再追加费率表（见 appendFeeSchedule），
+	// CmdSetSTP 追加 1 字节 STP 模式
+	// v1 记录仍可解码：新字段视为零值（GTC、未拒、普通单）
+	cmdWalVersion  = 2
+	cmdRecordLen   = 127
+	cmdWalVersion1 = 1
+	cmdRecordLenV1 = 67
 
 	offVer      = 0
 	offType     = 1
-	offSeq      = 2  // uint64
-	offReqID    = 10 // uint64
-	offClientTs = 18 // int64 as uint64
-	offOrderID  = 26 // uint64
-	offUserID   = 34 // uint64
-	offSide     = 42 // uint8
-	offPrice    = 43 // int64 as uint64
-	offQty      = 51 // int64 as uint64
-	offCancelID = 59 // uint64
+	offSeq      = 2   // uint64
+	offReqID    = 10  // uint64
+	offClientTs = 18  // int64 as uint64
+	offOrderID  = 26  // uint64
+	offUserID   = 34  // uint64
+	offSide     = 42  // uint8
+	offPrice    = 43  // int64 as uint64
+	offQty      = 51  // int64 as uint64
+	offCancelID = 59  // uint64
+	offTIF      = 67  // uint8
+	offFlags    = 68  // uint8 bitset
+	offReject   = 69  // uint16
+	offStopPx   = 71  // int64 as uint64
+	offDisplay  = 79  // int64 as uint64
+	offClientID = 87  // uint64
+	offEntrySet = 95  // [16]byte uuid
+	offReserved = 111 // int64 as uint64
+	offEngineTs = 119 // int64 as uint64
+
+	cmdFlagPostOnly = 1 << 0
 )
 
 var (
@@ -59,19 +78,47 @@ func (b BinaryCMDCode) Encode(dst []byte, cmdSeq uint64, cmd Command) ([]byte, e
 	}
 	binary.LittleEndian.PutUint64(dst[offCancelID:offCancelID+8], cancelID)
 
+	dst[offTIF] = byte(cmd.TIF)
+	var flags byte
+	if cmd.PostOnly {
+		flags |= cmdFlagPostOnly
+	}
+	dst[offFlags] = flags
+	binary.LittleEndian.PutUint16(dst[offReject:offReject+2], uint16(cmd.Reject))
+	binary.LittleEndian.PutUint64(dst[offStopPx:offStopPx+8], uint64(cmd.StopPrice))
+	binary.LittleEndian.PutUint64(dst[offDisplay:offDisplay+8], uint64(cmd.DisplayQty))
+	binary.LittleEndian.PutUint64(dst[offClientID:offClientID+8], cmd.ClientOrderID)
+	copy(dst[offEntrySet:offEntrySet+16], cmd.EntrySetID[:])
+	binary.LittleEndian.PutUint64(dst[offReserved:offReserved+8], uint64(cmd.Reserved))
+	binary.LittleEndian.PutUint64(dst[offEngineTs:offEngineTs+8], uint64(cmd.EngineTs))
+	switch cmd.Type {
+	case CmdSetFees:
+		dst = appendFeeSchedule(dst, cmd.Fees)
+	case CmdSetSTP:
+		dst = append(dst, byte(cmd.STP))
+	}
+
 	return dst, nil
 }
 
 func (B BinaryCMDCode) Decode(payload []byte) (cmdSeq uint64, cmd Command, err error) {
-	if len(payload) != cmdRecordLen {
+	if len(payload) == 0 {
 		return 0, Command{}, ErrBadCmdRecordLen
 	}
-	if int(payload[offVer]) != cmdWalVersion {
+	ver := int(payload[offVer])
+	switch {
+	case ver == cmdWalVersion && len(payload) == cmdRecordLen:
+	case ver == cmdWalVersion && len(payload) > cmdRecordLen && CmdType(payload[offType]) == CmdSetFees:
+	case ver == cmdWalVersion && len(payload) == cmdRecordLen+1 && CmdType(payload[offType]) == CmdSetSTP:
+	case ver == cmdWalVersion1 && len(payload) == cmdRecordLenV1:
+	case ver < cmdWalVersion1 || ver > cmdWalVersion:
 		return 0, Command{}, ErrBadCmdVersion
+	default:
+		return 0, Command{}, ErrBadCmdRecordLen
 	}
 
 	ct := CmdType(payload[offType])
-	if ct != CmdSubmitLimit && ct != CmdCancel {
+	if ct < CmdSubmitLimit || ct > CmdSetSTP {
 		return 0, Command{}, ErrBadCmdType
 	}
 
@@ -91,5 +138,31 @@ func (B BinaryCMDCode) Decode(payload []byte) (cmdSeq uint64, cmd Command, err e
 
 	cmd.CancelOrderID = binary.LittleEndian.Uint64(payload[offCancelID : offCancelID+8])
 
+	if ver == cmdWalVersion1 {
+		return cmdSeq, cmd, nil
+	}
+	cmd.TIF = TimeInForce(payload[offTIF])
+	cmd.PostOnly = payload[offFlags]&cmdFlagPostOnly != 0
+	cmd.Reject = RejectCode(binary.LittleEndian.Uint16(payload[offReject : offReject+2]))
+	cmd.StopPrice = int64(binary.LittleEndian.Uint64(payload[offStopPx : offStopPx+8]))
+	cmd.DisplayQty = int64(binary.LittleEndian.Uint64(payload[offDisplay : offDisplay+8]))
+	cmd.ClientOrderID = binary.LittleEndian.Uint64(payload[offClientID : offClientID+8])
+	copy(cmd.EntrySetID[:], payload[offEntrySet:offEntrySet+16])
+	cmd.Reserved = int64(binary.LittleEndian.Uint64(payload[offReserved : offReserved+8]))
+	cmd.EngineTs = int64(binary.LittleEndian.Uint64(payload[offEngineTs : offEngineTs+8]))
+	switch ct {
+	case CmdSetFees:
+		f, n, err := decodeFeeSchedule(payload[cmdRecordLen:])
+		if err != nil || cmdRecordLen+n != len(payload) {
+			return 0, Command{}, ErrBadCmdRecordLen
+		}
+		cmd.Fees = f
+	case CmdSetSTP:
+		if len(payload) != cmdRecordLen+1 {
+			return 0, Command{}, ErrBadCmdRecordLen
+		}
+		cmd.STP = matching.STPMode(payload[cmdRecordLen])
+	}
+
 	return cmdSeq, cmd, nil
 }
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// L2 深度：actor 每个 batch 结束后从订单簿取“变过的价位”，合并进聚合视图并推一条增量
+// - 增量 Seq 每次 +1：消费者发现不连续（或回退，如引擎重启）就重新拉快照
+// - 快照带同一套 Seq：先缓存增量、再拉快照，丢掉 Seq<=快照 Seq 的增量，之后逐条应用
+// - 深度不进 outbox：行情可丢，靠 Seq 断档 + 快照恢复；只含可见数量（冰山隐藏部分不计）
+
+var ErrDepthDisabled = errors.New("depth view disabled")
+
+// DepthLevel：一档深度；增量里 Qty=0 表示该价位删除
+type DepthLevel struct {
+	Side  uint8
+	Price int64
+	Qty   int64
+}
+
+// DepthUpdate：一个 batch 的深度增量（价格 → 新的总量）
+type DepthUpdate struct {
+	Symbol string
+	Seq    uint64 // 深度序号，连续递增
+	CmdSeq uint64 // 截止到的命令 seq
+	Levels []DepthLevel
+}
+
+// DepthSnapshot：前 N 档快照（买盘价格降序，卖盘升序）
+type DepthSnapshot struct {
+	Symbol string
+	Seq    uint64 // 与增量同一序列：Seq 之后的增量可以直接应用
+	CmdSeq uint64
+	Bids   []DepthLevel
+	Asks   []DepthLevel
+}
+
+// DepthSource：能提供深度增量的订单簿（可选能力）
+type DepthSource interface {
+	TrackDepth()
+	// DepthChanges：自上次调用以来变过的价位及当前可见总量（0=价位已空），调用后清空
+	DepthChanges(fn func(side uint8, price, qty int64))
+	// RangeDepth：当前所有价位（建视图时做全量）
+	RangeDepth(fn func(side uint8, price, qty int64))
+}
+
+// DepthSink：深度增量下游（如行情 WS hub），非阻塞；推不出去就丢，消费者靠 Seq 发现
+type DepthSink interface {
+	TryPublishDepth(u DepthUpdate) bool
+}
+
+// ChanDepthBus：最简单的 DepthSink（带缓冲 chan）
+type ChanDepthBus struct {
+	ch      chan DepthUpdate
+	dropped uint64
+}
+
+func NewChanDepthBus(size int) *ChanDepthBus {
+	if size <= 0 {
+		size = 1 << 12
+	}
+	return &ChanDepthBus{ch: make(chan DepthUpdate, size)}
+}
+
+func (b *ChanDepthBus) TryPublishDepth(u DepthUpdate) bool {
+	select {
+	case b.ch <- u:
+		return true
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+		return false
+	}
+}
+
+func (b *ChanDepthBus) C() <-chan DepthUpdate { return b.ch }
+func (b *ChanDepthBus) Dropped() uint64       { return atomic.LoadUint64(&b.dropped) }
+
+// depthView：每个 symbol 一份聚合深度；actor 写，查询方读（读写锁）
+type depthView struct {
+	symbol string
+	src    DepthSource
+	sink   DepthSink
+
+	mu     sync.RWMutex
+	bids   map[int64]int64
+	asks   map[int64]int64
+	seq    uint64
+	cmdSeq uint64
+}
+
+// newDepthView：恢复完成后建视图，当前簿全部价位作为初始状态（Seq=0）
+func newDepthView(symbol string, src DepthSource, sink DepthSink, cmdSeq uint64) *depthView {
+	v := &depthView{
+		symbol: symbol, src: src, sink: sink, cmdSeq: cmdSeq,
+		bids: make(map[int64]int64, 256),
+		asks: make(map[int64]int64, 256),
+	}
+	// 回放期间的变化没有人消费：这里才开跟踪，存量作为初始全量
+	src.TrackDepth()
+	src.RangeDepth(func(side uint8, price, qty int64) { v.levels(side)[price] = qty })
+	return v
+}
+
+func (v *depthView) levels(side uint8) map[int64]int64 {
+	if side == Buy {
+		return v.bids
+	}
+	return v.asks
+}
+
+// flush：batch 结束时调用（actor 协程）；有变化才推增量
+func (v *depthView) flush(cmdSeq uint64) {
+	var lv []DepthLevel
+	v.mu.Lock()
+	v.src.DepthChanges(func(side uint8, price, qty int64) {
+		m := v.levels(side)
+		if old, ok := m[price]; ok && old == qty {
+			return // batch 内变了又变回去
+		} else if !ok && qty == 0 {
+			return
+		}
+		if qty == 0 {
+			delete(m, price)
+		} else {
+			m[price] = qty
+		}
+		lv = append(lv, DepthLevel{Side: side, Price: price, Qty: qty})
+	})
+	v.cmdSeq = cmdSeq
+	if len(lv) == 0 {
+		v.mu.Unlock()
+		return
+	}
+	v.seq++
+	u := DepthUpdate{Symbol: v.symbol, Seq: v.seq, CmdSeq: cmdSeq, Levels: lv}
+	v.mu.Unlock()
+	if v.sink != nil {
+		v.sink.TryPublishDepth(u)
+	}
+}
+
+// snapshot：前 n 档（n<=0 全部）
+func (v *depthView) snapshot(n int) DepthSnapshot {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return DepthSnapshot{
+		Symbol: v.symbol, Seq: v.seq, CmdSeq: v.cmdSeq,
+		Bids: topLevels(v.bids, Buy, n),
+		Asks: topLevels(v.asks, Sell, n),
+	}
+}
+
+func topLevels(m map[int64]int64, side uint8, n int) []DepthLevel {
+	out := make([]DepthLevel, 0, len(m))
+	for p, q := range m {
+		out = append(out, DepthLevel{Side: side, Price: p, Qty: q})
+	}
+	if side == Buy {
+		sort.Slice(out, func(i, j int) bool { return out[i].Price > out[j].Price })
+	} else {
+		sort.Slice(out, func(i, j int) bool { return out[i].Price < out[j].Price })
+	}
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestDepth_SnapshotAndDiffs(t *testing.T) {
+	const sym = "BTCUSDT"
+	bus := NewChanDepthBus(64)
+	eng := NewEngine(EngineConfig{
+		ActorCfg:    ActorConfig{MailboxSize: 64, BatchMax: 8},
+		EnableDepth: true,
+		DepthSink:   bus,
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+	defer eng.Stop()
+	ctx := context.Background()
+	submit := func(id uint64, side uint8, price, qty, display int64) {
+		t.Helper()
+		if _, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: id, OrderID: id, UserID: id, Side: side, Price: price, Qty: qty, DisplayQty: display}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	submit(1, Sell, 100, 2, 0)
+	submit(2, Sell, 101, 3, 0)
+	submit(3, Buy, 99, 1, 0)
+	submit(4, Sell, 102, 10, 2) // 冰山：深度只露 2
+	submit(5, Buy, 100, 2, 0)   // 吃光 100
+
+	var last uint64
+	var got []DepthUpdate
+	for len(got) < 5 {
+		select {
+		case u := <-bus.C():
+			if u.Seq != last+1 {
+				t.Fatalf("depth gap: seq=%d after %d", u.Seq, last)
+			}
+			last = u.Seq
+			got = append(got, u)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout, got %+v", got)
+		}
+	}
+	if lv := got[3].Levels; len(lv) != 1 || lv[0] != (DepthLevel{Side: Sell, Price: 102, Qty: 2}) {
+		t.Fatalf("iceberg diff=%+v", lv)
+	}
+	if lv := got[4].Levels; len(lv) != 1 || lv[0] != (DepthLevel{Side: Sell, Price: 100, Qty: 0}) {
+		t.Fatalf("fill diff=%+v", lv)
+	}
+
+	snap, err := eng.Depth(sym, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Seq != 5 || len(snap.Bids) != 1 || snap.Bids[0].Price != 99 || len(snap.Asks) != 1 || snap.Asks[0] != (DepthLevel{Side: Sell, Price: 101, Qty: 3}) {
+		t.Fatalf("snapshot=%+v", snap)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"gopherex.com/internal/matching"
 	"gopherex.com/pkg/safe"
 	"gopherex.com/pkg/wal"
 )
@@ -29,11 +30,34 @@ type EngineConfig struct {
 	OutboxBufSize   int           // outbox的大小
 	EnablePublisher bool          // 是否开启publisher
 	PublisherPoll   time.Duration //pulibsh的时间
+	EventSink       EventSink     // publisher 的下游（NATS/Redis Streams 等，见 sinks 包）；nil 时发到进程内 bus
 	CmdCodec        CmdCodec
 	EvCodec         EvCodec
 	bus             *ChanBus
+
+	SnapshotEvery   uint64          // 每 N 个 seq 做一次订单簿快照；0 关闭（需开启 cmd WAL）
+	SnapshotKeep    int             // 保留最近几个快照，默认 2
+	SnapshotWALMode SnapshotWALMode // 快照后对已覆盖 cmd WAL 的处理：保留/删除/归档
+
+	WALSegmentBytes int64         // >0：cmd/ev WAL 按段切分，单段大小上限
+	WALSegmentAge   time.Duration // >0：cmd/ev WAL 按段切分，单段存活时长上限
+
+	SubmitTimeout time.Duration // 同步 Submit 的默认超时（ctx 没有 deadline 时生效），默认 5s
+
+	Symbols *SymbolRegistry // 交易对注册表：配置后只接受已注册的 symbol，并按规则校验每条命令
+
+	EnableDepth bool      // 维护 L2 聚合深度（book 需实现 DepthSource），每个 batch 后推增量
+	DepthSink   DepthSink // 深度增量下游，可为 nil（只提供快照查询）
+
+	OutboxIndexEvery uint64 // ev.wal 稀疏索引间隔：每 N 个 seq 记一条 seq→offset，默认 1024（见 outbox_index.go）
+
+	PreTrade PreTradeHook // 下单前风控（资金冻结等），nil 关闭（见 pretrade.go）
+
+	Now func() time.Time // 引擎时钟（命令的 EngineTs，下单频率按它计数），nil 用 time.Now
 }
 
+const defaultSubmitTimeout = 5 * time.Second
+
 type Engine struct {
 	ctx    context.Context         //  ctx
 	cancel context.CancelFunc      //取消事件
@@ -41,6 +65,10 @@ type Engine struct {
 	actors map[string]*SymbolActor // 一一对应
 	bus    *ChanBus                // 这个后面再理解
 	cfg    EngineConfig
+
+	blocked  *userBlocklist // kill-switch 冻结的用户（见 kill_switch.go）
+	readers  evReaders      // 在读 ev.wal 的订阅者/命名消费者（ev 段清理要等它们，见 event_retain.go）
+	replicas replicaHolds   // 在线备库 ack 的 cmd WAL 偏移（cmd 段清理要等它们，见 replication.go）
 }
 
 func NewEngine(cfg EngineConfig) *Engine {
@@ -57,7 +85,28 @@ func NewEngine(cfg EngineConfig) *Engine {
 		actors: make(map[string]*SymbolActor, cfg.EventBusSize),
 		bus:    cfg.bus,
 		cfg:    cfg,
+
+		blocked: newUserBlocklist(cfg.WALDir),
+	}
+}
+
+// segmentOpts：未配置切段时返回 nil（单文件 WAL）
+func (c EngineConfig) segmentOpts() *wal.SegmentOptions {
+	if c.WALSegmentBytes <= 0 && c.WALSegmentAge <= 0 {
+		return nil
 	}
+	return &wal.SegmentOptions{MaxSegmentBytes: c.WALSegmentBytes, MaxSegmentAge: c.WALSegmentAge}
+}
+
+// eventSink：显式配置的 sink 优先，否则用进程内 bus；都没有返回 nil（不起 publisher）
+func (e *Engine) eventSink() EventSink {
+	if e.cfg.EventSink != nil {
+		return e.cfg.EventSink
+	}
+	if e.bus != nil {
+		return e.bus
+	}
+	return nil
 }
 
 // 这个是推送事件
@@ -86,6 +135,11 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 	if e.cfg.BookFactory == nil {
 		return nil, ErrUnknownSym
 	}
+	if e.cfg.Symbols != nil {
+		if _, ok := e.cfg.Symbols.Get(symbol); !ok {
+			return nil, ErrUnknownSym
+		}
+	}
 	book, err := e.cfg.BookFactory(symbol)
 	if err != nil {
 		return nil, err
@@ -103,7 +157,8 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 	cmdPath := cmdWalPath(e.cfg.WALDir, symbol)       // <sym>.cmd.wal
 	evPath := outboxWalPath(e.cfg.WALDir, symbol)     // <sym>.ev.wal
 	curPath := outboxCursorPath(e.cfg.WALDir, symbol) // <sym>.ev.cursor
-	if e.cfg.EnableOutbox && e.cfg.WALDir != "" {
+	seg := e.cfg.segmentOpts()
+	if e.cfg.EnableOutbox && e.cfg.WALDir != "" && seg == nil && !wal.IsSegmented(evPath) {
 		// ✅ 预创建 ev.wal：只要存在即可，不写内容也行
 		if _, err = os.Stat(evPath); os.IsNotExist(err) {
 			f, err := os.OpenFile(evPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
@@ -115,6 +170,8 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 				return nil, err
 			}
 		}
+	}
+	if e.cfg.EnableOutbox && e.cfg.WALDir != "" {
 		// ✅ 也确保 cursor 目录存在（万一你未来 cursorPath 不在 WALDir 根）
 		_ = os.MkdirAll(filepath.Dir(curPath), 0o755)
 	}
@@ -122,6 +179,7 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 	// outbox 里“最后一个完整命令边界”的 seq
 	var lastCompleteSeq uint64
 	var outboxWriter Outbox //实现 Outbox 接口的 writer（FileOutbox）
+	var evOutbox *EventOutbox
 	pubNotify := make(chan struct{}, 1)
 
 	if e.cfg.EnableOutbox && e.cfg.WALDir != "" {
@@ -130,21 +188,62 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 		if err != nil {
 			return nil, err
 		}
-		outboxWriter, err = OpenEventOutbox(evPath, e.cfg.OutboxBufSize, e.cfg.EvCodec)
+		evOutbox, err = openEventOutbox(evPath, e.cfg.OutboxBufSize, e.cfg.EvCodec, seg)
 		if err != nil {
 			return nil, err
 		}
+		if err = evOutbox.enableIndex(e.cfg.OutboxIndexEvery); err != nil {
+			_ = evOutbox.Close()
+			return nil, err
+		}
+		outboxWriter = evOutbox
 	}
 
 	// 4) replay cmd WAL to rebuild book; and if outbox exists,补齐缺失事件（seq > lastCompleteSeq）
-	var lastSeq uint64
+	var lastSeq, snapSeq uint64
+	stops := newStopBook()
+	st := symState{trades: tradeSeq{sym: e.symbolID(symbol)}, limits: newUserLimiter()}
 	if e.cfg.EnableCmdWAL && e.cfg.WALDir != "" {
+		// 先加载最新的有效快照，WAL 只需回放快照之后的尾部
+		h, orders, stopOrders, err := loadLatestSnapshot(e.cfg.WALDir, symbol)
+		if err != nil {
+			_ = closeIfNotNil(outboxWriter)
+			return nil, err
+		}
+		snapSeq = h.seq
+		h.restore(&st)
+		if snapSeq > 0 {
+			sb, ok := book.(BookSnapshotter)
+			if !ok {
+				_ = closeIfNotNil(outboxWriter)
+				return nil, ErrSnapshotUnsupported
+			}
+			sb.RestoreOrders(orders)
+			stops.restore(stopOrders)
+			setBookSTP(book, st.stp)
+			setBookLastPrice(book, st.lastPx)
+		}
+		// 快照停在竞价阶段：簿先回到竞价模式，再回放尾部（否则尾部的新单会被撮合）
+		if st.phase == PhaseAuction {
+			if ab, ok := book.(AuctionBook); ok {
+				ab.StartAuction()
+			}
+		}
 		// 回放所有的事件  lastCompleteSeq 非常重要
-		lastSeq, err = replayCmdWALAndFillOutbox(cmdPath, book, outboxWriter, lastCompleteSeq, e.cfg.CmdCodec)
+		lastSeq, err = replayCmdWAL(cmdPath, book, stops, outboxWriter, snapSeq, lastCompleteSeq, e.cfg.CmdCodec, &st)
 		if err != nil {
 			_ = closeIfNotNil(outboxWriter)
 			return nil, err
 		}
+		// WAL 已被截断/归档时，尾部可能为空
+		if snapSeq > lastSeq {
+			lastSeq = snapSeq
+		}
+		// 退回了老快照（新的坏了）：WAL 至少要覆盖到最新那个快照，否则中间的命令丢了
+		if newest, ok := newestSnapshotSeq(e.cfg.WALDir, symbol); ok && lastSeq < newest {
+			_ = closeIfNotNil(outboxWriter)
+			return nil, fmt.Errorf("%w: replayed to %d, newest snapshot is %d", ErrWALGap, lastSeq, newest)
+		}
 	} else {
 		// 没开 cmd WAL 的话就没法重建簿（Step5 的前提），这里你可以选择：return error 或允许空簿
 		lastSeq = 0
@@ -161,7 +260,7 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 	// 启动完恢复后，打开写端，后面每条新命令都会 Append+Flush（按 batch）到 cmd.wal。
 	var cmdWriter walWriter
 	if e.cfg.EnableCmdWAL && e.cfg.WALDir != "" {
-		cmdWriter, err = wal.OpenWrite(cmdPath, e.cfg.WALBufSize)
+		cmdWriter, err = openLogWriter(cmdPath, e.cfg.WALBufSize, seg)
 		if err != nil {
 			_ = closeIfNotNil(outboxWriter)
 			return nil, err
@@ -171,6 +270,34 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 	a = NewSymbolActor(book, e.cfg.ActorCfg, cmdWriter, outboxWriter, pubNotify, e.cfg.CmdCodec, e.cfg.EvCodec)
 	//保证重启后 seq 连续（新命令从 lastSeq+1 开始）
 	a.seq = lastSeq
+	a.symbol, a.symbols = symbol, e.cfg.Symbols
+	a.blocked = e.blocked
+	a.metrics = newActorMetrics(symbol)
+	a.phase, a.trades, a.fees, a.stp, a.limits = st.phase, st.trades, st.fees, st.stp, st.limits
+	if e.cfg.Now != nil {
+		a.now = e.cfg.Now
+	}
+	a.stops = stops
+	if ds, ok := book.(DepthSource); ok && e.cfg.EnableDepth {
+		a.depth = newDepthView(symbol, ds, e.cfg.DepthSink, lastSeq)
+	}
+	if e.cfg.EnableCmdWAL && e.cfg.SnapshotEvery > 0 {
+		keep := e.cfg.SnapshotKeep
+		if keep <= 0 {
+			keep = defaultSnapshotKeep
+		}
+		a.snap = &snapshotter{
+			walDir:     e.cfg.WALDir,
+			symbol:     symbol,
+			cmdPath:    cmdPath,
+			walBufSize: e.cfg.WALBufSize,
+			every:      e.cfg.SnapshotEvery,
+			keep:       keep,
+			walMode:    e.cfg.SnapshotWALMode,
+			lastSeq:    snapSeq,
+			replicas:   &e.replicas,
+		}
+	}
 	e.actors[symbol] = a
 	// 8) start actor
 	safe.Go(func() {
@@ -178,9 +305,12 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 	})
 
 	// 9) start publisher (per active symbol)
-	//publisher tail ev.wal，读到事件就发布到 bus；读到 EvCmdEnd 就推进 cursor
-	if e.cfg.EnablePublisher && outboxWriter != nil {
-		pub := NewOutboxPublisher(e.ctx, e.bus, evPath, curPath, pubNotify, e.cfg.PublisherPoll, e.cfg.EvCodec)
+	//publisher tail ev.wal，按命令边界攒批发给 sink，sink 确认后推进 cursor
+	if sink := e.eventSink(); e.cfg.EnablePublisher && outboxWriter != nil && sink != nil {
+		pub := NewOutboxPublisher(e.ctx, sink, symbol, evPath, curPath, pubNotify, e.cfg.PublisherPoll, e.cfg.EvCodec)
+		// cursor 之前、命名消费者和订阅者也都读过的 ev 段可以清理
+		pub.retain = func(cursor int64) { _ = e.retainEvents(symbol, evOutbox, cursor) }
+		pub.head, pub.lag = a.durable.Load, newPublisherLag(symbol)
 		safe.Go(func() {
 			pub.Run()
 		})
@@ -189,7 +319,26 @@ func (e *Engine) getOrCreateActor(symbol string) (*SymbolActor, error) {
 }
 
 func (e *Engine) TrySubmit(symbol string, cmd Command) error {
-	if cmd.Type != CmdSubmitLimit {
+	if cmd.Type != CmdSubmitLimit && cmd.Type != CmdSubmitMarket && cmd.Type != CmdSubmitStop {
+		return ErrBadCommand
+	}
+	// 下单前风控要同步调外部服务（资金冻结），不能放在非阻塞路径上：配了就只能走 Submit
+	if e.cfg.PreTrade != nil {
+		return ErrNeedsSubmit
+	}
+	if e.blocked.has(cmd.UserID) {
+		return ErrUserBlocked
+	}
+	a, err := e.getOrCreateActor(symbol)
+	if err != nil {
+		return err
+	}
+	return a.TryEnqueue(cmd)
+}
+
+// TryControl：交易对生命周期命令（停牌/恢复/只撤单/竞价/按用户撤单），与普通命令同样走 cmd WAL
+func (e *Engine) TryControl(symbol string, cmd Command) error {
+	if !isControl(cmd.Type) {
 		return ErrBadCommand
 	}
 	a, err := e.getOrCreateActor(symbol)
@@ -197,8 +346,91 @@ func (e *Engine) TrySubmit(symbol string, cmd Command) error {
 		return err
 	}
 	return a.TryEnqueue(cmd)
+}
+
+// Halt / Resume / CancelOnly：同步切换阶段，返回时 EvPhase 已落 outbox
+func (e *Engine) Halt(ctx context.Context, symbol string) (Result, error) {
+	return e.Submit(ctx, symbol, Command{Type: CmdHalt})
+}
+
+func (e *Engine) Resume(ctx context.Context, symbol string) (Result, error) {
+	return e.Submit(ctx, symbol, Command{Type: CmdResume})
+}
+
+func (e *Engine) CancelOnly(ctx context.Context, symbol string) (Result, error) {
+	return e.Submit(ctx, symbol, Command{Type: CmdCancelOnly})
+}
+
+// StartAuction / Uncross：集合竞价（典型用法：Halt → StartAuction → 收单 → Uncross 复牌）
+// Uncross 的 Result.Trades 即竞价成交
+func (e *Engine) StartAuction(ctx context.Context, symbol string) (Result, error) {
+	return e.Submit(ctx, symbol, Command{Type: CmdAuction})
+}
 
+func (e *Engine) Uncross(ctx context.Context, symbol string) (Result, error) {
+	return e.Submit(ctx, symbol, Command{Type: CmdUncross})
 }
+
+// Depth：L2 深度前 n 档快照（n<=0 全部）；Seq 与 DepthSink 收到的增量同一序列
+func (e *Engine) Depth(symbol string, n int) (DepthSnapshot, error) {
+	a, err := e.getOrCreateActor(symbol)
+	if err != nil {
+		return DepthSnapshot{}, err
+	}
+	if a.depth == nil {
+		return DepthSnapshot{}, ErrDepthDisabled
+	}
+	return a.depth.snapshot(n), nil
+}
+
+// Submit：同步提交，等到该命令的完整事件集合（CmdEnd 落盘）后返回
+// - 结果由 actor 直接回填，publisher 落后或 bus 丢事件都不影响
+// - 超时/取消返回 ctx.Err()；此时命令可能已经执行，以事件流为准
+func (e *Engine) Submit(ctx context.Context, symbol string, cmd Command) (Result, error) {
+	switch cmd.Type {
+	case CmdSubmitLimit, CmdSubmitMarket, CmdSubmitStop, CmdCancel, CmdAmend:
+	default:
+		if !isControl(cmd.Type) {
+			return Result{}, ErrBadCommand
+		}
+	}
+	if opensRisk(cmd.Type) && e.blocked.has(cmd.UserID) {
+		return Result{}, ErrUserBlocked
+	}
+	a, err := e.getOrCreateActor(symbol)
+	if err != nil {
+		return Result{}, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := e.cfg.SubmitTimeout
+		if timeout <= 0 {
+			timeout = defaultSubmitTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := e.preTrade(ctx, symbol, &cmd); err != nil {
+		return Result{}, err
+	}
+
+	reply := make(chan Result, 1)
+	cmd.reply = reply
+	if err := a.Enqueue(ctx, cmd); err != nil {
+		e.compensate(ctx, symbol, cmd)
+		return Result{}, err
+	}
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	case <-e.ctx.Done():
+		return Result{}, ErrEngineStopped
+	}
+}
+
 func (e *Engine) TryCancel(symbol string, cmd Command) error {
 	if cmd.Type != CmdCancel {
 		return ErrBadCommand
@@ -210,6 +442,24 @@ func (e *Engine) TryCancel(symbol string, cmd Command) error {
 	return a.TryEnqueue(cmd)
 }
 
+func (e *Engine) TryAmend(symbol string, cmd Command) error {
+	if cmd.Type != CmdAmend {
+		return ErrBadCommand
+	}
+	// 下单前风控要同步调外部服务（资金冻结），不能放在非阻塞路径上：配了就只能走 Submit
+	if e.cfg.PreTrade != nil {
+		return ErrNeedsSubmit
+	}
+	if e.blocked.has(cmd.UserID) {
+		return ErrUserBlocked
+	}
+	a, err := e.getOrCreateActor(symbol)
+	if err != nil {
+		return err
+	}
+	return a.TryEnqueue(cmd)
+}
+
 func (e *Engine) Stop() { e.cancel() }
 
 func cmdWalPath(dir, symbol string) string {
@@ -225,8 +475,45 @@ func cmdWalPath(dir, symbol string) string {
 	return filepath.Join(dir, string(s)+".wal")
 }
 
-func replayCmdWALAndFillOutbox(cmdPath string, book OrderBook, outbox Outbox, lastCompleteSeq uint64, code CmdCodec) (lastSeq uint64, err error) {
-	_, err = wal.Replay(cmdPath, wal.ReplayOptions{
+// afterSeq：快照已覆盖的 seq，<= afterSeq 的记录直接跳过
+func replayCmdWALAndFillOutbox(cmdPath string, book OrderBook, outbox Outbox, afterSeq, lastCompleteSeq uint64, code CmdCodec) (lastSeq uint64, err error) {
+	return replayCmdWAL(cmdPath, book, newStopBook(), outbox, afterSeq, lastCompleteSeq, code, &symState{})
+}
+
+// symState：簿之外随命令推进的状态；快照保存，回放从快照里的值开始推进，回放完交给 actor
+type symState struct {
+	phase  Phase
+	trades tradeSeq         // 成交号：每笔成交都推进一次，不管要不要补 outbox
+	fees   *FeeSchedule     // 当前生效的费率表（CmdSetFees 换表），补 outbox 时成交事件用它
+	stp    matching.STPMode // 当前自成交防护模式（CmdSetSTP 换，簿本身在 applyCommand 里换），写快照用
+	lastPx int64            // 最新成交价：簿里维护，这里只在写快照 / 快照恢复时中转
+	limits *userLimiter     // 下单频率窗口（按 EngineTs 计数），nil 不重建
+}
+
+// advance：控制命令带来的状态变化 + 下单频率计数（不碰簿）
+func (s *symState) advance(cmd Command) {
+	if cmd.Reject != RejectNone {
+		return
+	}
+	if p, ok := targetPhase(cmd.Type); ok {
+		s.phase = p
+	}
+	switch cmd.Type {
+	case CmdSetFees:
+		s.fees = cmd.Fees
+	case CmdSetSTP:
+		s.stp = cmd.STP
+	}
+	if s.limits != nil && isSubmit(cmd.Type) {
+		s.limits.count(cmd)
+	}
+}
+
+// replayCmdWAL：从 st（快照里的状态）开始回放，同时还原交易阶段、成交号、费率表
+// afterSeq 之后的记录必须从 afterSeq+1 开始逐条连续，否则说明中间的命令丢了（WAL 被截过头），返回 ErrWALGap
+func replayCmdWAL(cmdPath string, book OrderBook, stops *stopBook, outbox Outbox, afterSeq, lastCompleteSeq uint64, code CmdCodec, st *symState) (lastSeq uint64, err error) {
+	next := afterSeq + 1
+	_, err = replayLog(cmdPath, wal.ReplayOptions{
 		AllowTruncatedTail: true,
 	}, func(payload []byte) error {
 		seq, cmd, err := code.Decode(payload) // Step5.2 的 decode
@@ -236,6 +523,14 @@ func replayCmdWALAndFillOutbox(cmdPath string, book OrderBook, outbox Outbox, la
 		if seq > lastSeq {
 			lastSeq = seq
 		}
+		if seq <= afterSeq {
+			return nil
+		}
+		if seq != next {
+			return fmt.Errorf("%w: want seq %d after snapshot %d, got %d", ErrWALGap, next, afterSeq, seq)
+		}
+		next++
+		st.advance(cmd)
 		/**
 		如果 seq <= lastCompleteSeq：
 		outbox 里已经完整存在这些事件了，你 不应该再写 outbox
@@ -247,14 +542,14 @@ func replayCmdWALAndFillOutbox(cmdPath string, book OrderBook, outbox Outbox, la
 		*/
 		// 选择 emitter
 		if outbox == nil || seq <= lastCompleteSeq {
-			applyCommandToBook(book, cmd, noopEmitter{})
+			applyCommand(book, stops, seq, cmd, tradeCounter{trades: &st.trades})
 			return nil
 		}
 
 		// seq > lastCompleteSeq：补齐 outbox
 		// 进行回溯事件
-		em := &outboxEmitter{out: outbox, seq: seq, req: cmd.ReqID}
-		applyCommandToBook(book, cmd, em)
+		em := &outboxEmitter{out: outbox, seq: seq, req: cmd.ReqID, fees: st.fees, trades: &st.trades}
+		applyCommand(book, stops, seq, cmd, em)
 		if em.err != nil {
 			return em.err
 		}
@@ -270,15 +565,106 @@ func replayCmdWALAndFillOutbox(cmdPath string, book OrderBook, outbox Outbox, la
 	return lastSeq, nil
 }
 
+// applyCommandToBook：actor 与 replay 共用同一套校验+执行逻辑，保证重启回放结果一致
 func applyCommandToBook(book OrderBook, cmd Command, emit Emitter) {
+	// 写 WAL 前已按交易对规则拒掉：不碰订单簿
+	if cmd.Reject != RejectNone {
+		orderID := cmd.OrderID
+		if cmd.Type == CmdCancel {
+			orderID = cmd.CancelOrderID
+		}
+		emit.Rejected(cmd.ReqID, orderID, cmd.UserID, cmd.Reject)
+		return
+	}
 	switch cmd.Type {
-	case CmdSubmitLimit:
-		book.SubmitLimit(cmd.ReqID, cmd.OrderID, cmd.UserID, cmd.Side, cmd.Price, cmd.Qty, emit)
+	case CmdSubmitLimit, CmdSubmitMarket:
+		o, ok := orderSpecFromCmd(cmd)
+		if !ok {
+			emit.Rejected(cmd.ReqID, cmd.OrderID, cmd.UserID, RejectBadParams)
+			return
+		}
+		book.Submit(cmd.ReqID, o, emit)
 	case CmdCancel:
-		book.Cancel(cmd.ReqID, cmd.CancelOrderID, emit)
+		if cmd.CancelOrderID == 0 {
+			emit.Rejected(cmd.ReqID, 0, 0, RejectBadParams)
+			return
+		}
+		if !book.Cancel(cmd.ReqID, cmd.CancelOrderID, emit) {
+			// V0 语义：取消不存在也发一个 Rejected（或你可改成 Cancelled(false)）
+			emit.Rejected(cmd.ReqID, cmd.CancelOrderID, 0, RejectOrderNotFound)
+		}
+	case CmdAmend:
+		if cmd.OrderID == 0 || cmd.Price < 0 || cmd.Qty < 0 || (cmd.Price == 0 && cmd.Qty == 0) {
+			emit.Rejected(cmd.ReqID, cmd.OrderID, cmd.UserID, RejectBadParams)
+			return
+		}
+		book.Amend(cmd.ReqID, AmendSpec{OrderID: cmd.OrderID, UserID: cmd.UserID, Price: cmd.Price, Qty: cmd.Qty}, emit)
+	case CmdHalt, CmdResume, CmdCancelOnly, CmdAuction, CmdUncross:
+		// 阶段本身由 actor（precheck）/回放维护，这里只切换簿的竞价模式并产出事件
+		// precheck 保证 CmdAuction/CmdUncross 到这里时 book 一定是 AuctionBook
+		if ab, ok := book.(AuctionBook); ok {
+			switch cmd.Type {
+			case CmdAuction:
+				ab.StartAuction()
+			case CmdUncross:
+				ab.Uncross(cmd.ReqID, emit)
+			}
+		}
+		p, _ := targetPhase(cmd.Type)
+		emit.PhaseChanged(cmd.ReqID, p)
+	case CmdCancelAll:
+		if cmd.UserID == 0 {
+			emit.Rejected(cmd.ReqID, 0, 0, RejectBadParams)
+			return
+		}
+		book.CancelAllForUser(cmd.ReqID, cmd.UserID, emit)
+	case CmdSetFees:
+		// 费率表由 actor / 回放维护（symState），不碰簿、不出事件
+	case CmdSetSTP:
+		// 簿不支持 STP（对照簿）就忽略；不出事件
+		setBookSTP(book, cmd.STP)
 	default:
-		// ignore or emit reject
+		emit.Rejected(cmd.ReqID, cmd.OrderID, cmd.UserID, RejectUnknownCmd)
+	}
+}
+
+// orderSpecFromCmd：校验提交命令并翻译成 OrderSpec
+// - 市价单忽略 Price，不允许 PostOnly，GTC 视为 IOC
+// - PostOnly / 冰山单只对 GTC 限价单有意义（IOC/FOK 不会挂单）
+func orderSpecFromCmd(cmd Command) (OrderSpec, bool) {
+	if cmd.OrderID == 0 || cmd.Qty <= 0 || (cmd.Side != Buy && cmd.Side != Sell) || cmd.TIF > TifFOK {
+		return OrderSpec{}, false
+	}
+	o := OrderSpec{
+		OrderID:  cmd.OrderID,
+		UserID:   cmd.UserID,
+		Side:     cmd.Side,
+		Price:    cmd.Price,
+		Qty:      cmd.Qty,
+		TIF:      cmd.TIF,
+		PostOnly: cmd.PostOnly,
+		Display:  cmd.DisplayQty,
+		ClientID: cmd.ClientOrderID,
+	}
+	// 冰山单只对会挂单的 GTC 限价单有意义
+	if cmd.DisplayQty < 0 || (cmd.DisplayQty > 0 && (cmd.Type == CmdSubmitMarket || cmd.TIF != TifGTC)) {
+		return OrderSpec{}, false
+	}
+	if cmd.Type == CmdSubmitMarket {
+		if cmd.PostOnly {
+			return OrderSpec{}, false
+		}
+		o.Market = true
+		o.Price = 0
+		if o.TIF == TifGTC {
+			o.TIF = TifIOC
+		}
+		return o, true
+	}
+	if cmd.Price <= 0 || (cmd.PostOnly && cmd.TIF != TifGTC) {
+		return OrderSpec{}, false
 	}
+	return o, true
 }
 
 func closeIfNotNil(ob Outbox) error {
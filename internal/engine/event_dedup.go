@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"strconv"
+	"sync"
+)
+
+// 事件全局唯一 ID：(symbol, seq, idx)
+// seq 在 symbol 内单调递增，idx 是同一 seq 内的事件序号，回放/重发都不会变
+
+// EventID：可直接用作下游去重键，形如 "BTCUSDT-42-3"
+func EventID(symbol string, ev Event) string {
+	b := make([]byte, 0, len(symbol)+24)
+	b = append(b, symbol...)
+	b = append(b, '-')
+	b = strconv.AppendUint(b, ev.Seq, 10)
+	b = append(b, '-')
+	b = strconv.AppendUint(b, uint64(ev.Idx), 10)
+	return string(b)
+}
+
+// EventDedup：消费端去重（at-least-once → effectively-once）
+// publisher 只会从 cursor 整段重发，同一 symbol 内事件严格按 (seq, idx) 递增到达，
+// 所以每个 symbol 记一个高水位就够了，不需要保存全部 ID
+type EventDedup struct {
+	mu   sync.Mutex
+	high map[string]evPos
+}
+
+type evPos struct {
+	seq uint64
+	idx uint16
+}
+
+func NewEventDedup() *EventDedup {
+	return &EventDedup{high: make(map[string]evPos)}
+}
+
+// Seen：ev 已处理过返回 true（调用方直接丢弃）；否则记为已处理
+func (d *EventDedup) Seen(symbol string, ev Event) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.high[symbol]
+	if ok && (ev.Seq < h.seq || (ev.Seq == h.seq && ev.Idx <= h.idx)) {
+		return true
+	}
+	d.high[symbol] = evPos{seq: ev.Seq, idx: ev.Idx}
+	return false
+}
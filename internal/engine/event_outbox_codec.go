@@ -6,8 +6,12 @@ import (
 )
 
 const (
-	evWalVersion = 1
-	evRecordLen  = 68
+	// v2：在 v1 末尾追加拒单原因码、成交号、成交双方用户、主动方方向和手续费
+	// v1 记录仍可解码：Code=0，成交号按当时的规则 (seq, idx) 补算（见 legacyTradeID），其余为 0
+	evWalVersion  = 2
+	evRecordLen   = 113
+	evWalVersion1 = 1
+	evRecordLenV1 = 68
 
 	evOffVer   = 0
 	evOffType  = 1  // uint8
@@ -20,6 +24,16 @@ const (
 	evOffTaker = 44 // uint64
 	evOffPrice = 52 // int64 as uint64
 	evOffQty   = 60 // int64 as uint64
+	evOffCode  = 68 // uint16
+	evOffTrade = 70 // uint64
+
+	evOffMakerUser  = 78  // uint64
+	evOffTakerUser  = 86  // uint64
+	evOffTakerSide  = 94  // uint8
+	evOffMakerFee   = 95  // int64 as uint64
+	evOffTakerFee   = 103 // int64 as uint64
+	evOffMakerAsset = 111 // uint8
+	evOffTakerAsset = 112 // uint8
 )
 
 var (
@@ -53,15 +67,31 @@ func (e EvCmdCodec) Encode(dst []byte, ev Event) ([]byte, error) {
 
 	binary.LittleEndian.PutUint64(dst[evOffPrice:evOffPrice+8], uint64(ev.Price))
 	binary.LittleEndian.PutUint64(dst[evOffQty:evOffQty+8], uint64(ev.Qty))
+	binary.LittleEndian.PutUint16(dst[evOffCode:evOffCode+2], uint16(ev.Code))
+	binary.LittleEndian.PutUint64(dst[evOffTrade:evOffTrade+8], ev.TradeID)
+
+	binary.LittleEndian.PutUint64(dst[evOffMakerUser:evOffMakerUser+8], ev.MakerUserID)
+	binary.LittleEndian.PutUint64(dst[evOffTakerUser:evOffTakerUser+8], ev.TakerUserID)
+	dst[evOffTakerSide] = ev.TakerSide
+	binary.LittleEndian.PutUint64(dst[evOffMakerFee:evOffMakerFee+8], uint64(ev.MakerFee))
+	binary.LittleEndian.PutUint64(dst[evOffTakerFee:evOffTakerFee+8], uint64(ev.TakerFee))
+	dst[evOffMakerAsset] = byte(ev.MakerFeeAsset)
+	dst[evOffTakerAsset] = byte(ev.TakerFeeAsset)
 	return dst, nil
 }
 
 func (e EvCmdCodec) Decode(payload []byte) (Event, error) {
-	if len(payload) != evRecordLen {
+	if len(payload) == 0 {
 		return Event{}, ErrBadEvRecordLen
 	}
-	if int(payload[evOffVer]) != evWalVersion {
+	ver := int(payload[evOffVer])
+	switch {
+	case ver == evWalVersion && len(payload) == evRecordLen:
+	case ver == evWalVersion1 && len(payload) == evRecordLenV1:
+	case ver < evWalVersion1 || ver > evWalVersion:
 		return Event{}, ErrBadEvVersion
+	default:
+		return Event{}, ErrBadEvRecordLen
 	}
 
 	var ev Event
@@ -77,5 +107,23 @@ func (e EvCmdCodec) Decode(payload []byte) (Event, error) {
 
 	ev.Price = int64(binary.LittleEndian.Uint64(payload[evOffPrice : evOffPrice+8]))
 	ev.Qty = int64(binary.LittleEndian.Uint64(payload[evOffQty : evOffQty+8]))
+	if ver == evWalVersion1 {
+		if ev.Type == EvTrade {
+			ev.TradeID = legacyTradeID(ev.Seq, ev.Idx)
+		}
+		return ev, nil
+	}
+	ev.Code = RejectCode(binary.LittleEndian.Uint16(payload[evOffCode : evOffCode+2]))
+	if ev.Type == EvRejected {
+		ev.Reason = ev.Code.String()
+	}
+	ev.TradeID = binary.LittleEndian.Uint64(payload[evOffTrade : evOffTrade+8])
+	ev.MakerUserID = binary.LittleEndian.Uint64(payload[evOffMakerUser : evOffMakerUser+8])
+	ev.TakerUserID = binary.LittleEndian.Uint64(payload[evOffTakerUser : evOffTakerUser+8])
+	ev.TakerSide = payload[evOffTakerSide]
+	ev.MakerFee = int64(binary.LittleEndian.Uint64(payload[evOffMakerFee : evOffMakerFee+8]))
+	ev.TakerFee = int64(binary.LittleEndian.Uint64(payload[evOffTakerFee : evOffTakerFee+8]))
+	ev.MakerFeeAsset = FeeAsset(payload[evOffMakerAsset])
+	ev.TakerFeeAsset = FeeAsset(payload[evOffTakerAsset])
 	return ev, nil
 }
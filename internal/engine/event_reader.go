@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"gopherex.com/pkg/wal"
+)
+
+// 按位置读 ev.wal：下游（资金结算/WS/审计）从任意 (seq, idx) 重建状态
+// - 定位：稀疏索引找到 seq 之前最近的命令边界，再向后扫描跳过 (seq, idx) 之前的事件
+// - 只交出完整命令（读到 CmdEnd 才返回），半截命令留在缓冲里等后续写入
+// - 与 publisher 的 cursor 相互独立；多个命名消费者各自持久化自己的位置
+
+var ErrBadConsumerName = errors.New("bad consumer name")
+
+// EventPos：ev.wal 里的事件位置（下一条要读的事件，含）
+type EventPos struct {
+	Seq uint64
+	Idx uint16
+}
+
+// Before：p 在 q 之前
+func (p EventPos) Before(q EventPos) bool {
+	return p.Seq < q.Seq || (p.Seq == q.Seq && p.Idx < q.Idx)
+}
+
+// PosAfter：紧跟 ev 之后的位置
+func PosAfter(ev Event) EventPos { return EventPos{Seq: ev.Seq, Idx: ev.Idx + 1} }
+
+// EventReader：只读，不影响 actor/publisher；不是并发安全的
+type EventReader struct {
+	path  string
+	codec EvCodec
+	from  EventPos
+
+	r       logReader
+	off     int64        // 下一条 record 的偏移
+	held    atomic.Int64 // = off，给 ev 段清理读（见 event_retain.go）
+	release func()       // Close 时从 Engine 的读者登记里注销（可为 nil）
+	checked bool         // 已确认 from 之前的事件没被清理
+	pending []Event
+}
+
+// OpenEventReader：从 from 开始读 walDir 下 symbol 的 ev.wal；from 为零值表示从最早保留的事件开始
+func OpenEventReader(walDir, symbol string, codec EvCodec, from EventPos) (*EventReader, error) {
+	path := outboxWalPath(walDir, symbol)
+	start, err := logStart(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &EventReader{path: path, codec: codec, from: from, off: start, checked: from.Seq == 0}
+	if from.Seq > 0 {
+		size, err := logSize(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		entries := loadOutboxIndex(outboxIndexPath(path), size)
+		if e, ok := seekOutboxIndex(entries, from.Seq, start); ok {
+			r.off, r.checked = e.off, true
+		}
+	}
+	r.held.Store(r.off)
+	return r, nil
+}
+
+// Next：返回下一条完整命令里 >= from 的事件（不含 CmdEnd）；追上末尾返回 io.EOF，之后可继续调用
+// 起点之前的事件已被清理（分段 WAL retain）时返回 ErrEventsTrimmed
+func (r *EventReader) Next() ([]Event, error) {
+	for {
+		if r.r == nil {
+			lr, err := openLogReader(r.path, r.off, wal.ReaderOptions{AllowTruncatedTail: true})
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, io.EOF
+				}
+				return nil, err
+			}
+			r.r = lr
+		}
+		payload, next, err := r.r.Next()
+		if err != nil {
+			// 到末尾就关掉，下次从 off 重新打开（文件增长/切段都能看到）
+			_ = r.r.Close()
+			r.r = nil
+			return nil, err
+		}
+		ev, err := r.codec.Decode(payload)
+		if err != nil {
+			return nil, err
+		}
+		r.off = next
+		if ev.Type == EvCmdEnd {
+			r.held.Store(next) // 半截命令的事件还在 pending 里，只在命令边界放手
+		}
+		if !r.checked {
+			if ev.Seq > r.from.Seq {
+				return nil, ErrEventsTrimmed
+			}
+			r.checked = true
+		}
+		if ev.Type != EvCmdEnd {
+			if !(EventPos{Seq: ev.Seq, Idx: ev.Idx}).Before(r.from) {
+				r.pending = append(r.pending, ev)
+			}
+			continue
+		}
+		if len(r.pending) > 0 {
+			out := r.pending
+			r.pending = nil
+			return out, nil
+		}
+	}
+}
+
+func (r *EventReader) Close() error {
+	if r.release != nil {
+		r.release()
+		r.release = nil
+	}
+	if r.r == nil {
+		return nil
+	}
+	err := r.r.Close()
+	r.r = nil
+	return err
+}
+
+// EventConsumer：命名消费者，位置持久化在 <sym>.ev.<name>.cursor
+// 典型用法：Next 拿一条命令的事件 → 处理 → Ack 最后一个事件；重启后从 Ack 之后继续
+type EventConsumer struct {
+	*EventReader
+	name string
+	path string
+	pos  EventPos
+}
+
+// OpenEventConsumer：name 只允许 [0-9A-Za-z_-]
+// 首次打开就建 cursor 文件：之后 publisher 清理 ev 段时会等它（不再使用的消费者要删掉 cursor 文件）
+func OpenEventConsumer(walDir, symbol, name string, codec EvCodec) (*EventConsumer, error) {
+	if name == "" || safeSym(name) != name {
+		return nil, ErrBadConsumerName
+	}
+	path := consumerCursorPath(walDir, symbol, name)
+	pos := loadConsumerPos(path)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := storeConsumerPos(path, pos); err != nil {
+			return nil, err
+		}
+	}
+	r, err := OpenEventReader(walDir, symbol, codec, pos)
+	if err != nil {
+		return nil, err
+	}
+	return &EventConsumer{EventReader: r, name: name, path: path, pos: pos}, nil
+}
+
+func (c *EventConsumer) Name() string { return c.name }
+
+// Position：已确认位置（下一条要处理的事件）
+func (c *EventConsumer) Position() EventPos { return c.pos }
+
+// Ack：ev 及之前的事件已处理，持久化位置（只前进不后退）
+func (c *EventConsumer) Ack(ev Event) error {
+	pos := PosAfter(ev)
+	if !c.pos.Before(pos) {
+		return nil
+	}
+	if err := storeConsumerPos(c.path, pos); err != nil {
+		return err
+	}
+	c.pos = pos
+	return nil
+}
+
+// OpenConsumer：打开 symbol 的命名消费者（需开启 outbox）；会先确保 actor 已完成 ev.wal 的修复/补齐
+func (e *Engine) OpenConsumer(symbol, name string) (*EventConsumer, error) {
+	if !e.cfg.EnableOutbox || e.cfg.WALDir == "" {
+		return nil, ErrOutboxDisabled
+	}
+	if _, err := e.getOrCreateActor(symbol); err != nil {
+		return nil, err
+	}
+	var c *EventConsumer
+	_, err := e.readers.open(symbol, func() (*EventReader, error) {
+		var err error
+		if c, err = OpenEventConsumer(e.cfg.WALDir, symbol, name, e.cfg.EvCodec); err != nil {
+			return nil, err
+		}
+		return c.EventReader, nil
+	})
+	return c, err
+}
+
+func consumerCursorPath(walDir, symbol, name string) string {
+	return filepath.Join(walDir, safeSym(symbol)+".ev."+name+".cursor")
+}
+
+// consumer cursor 文件：seq(8) + idx(2)，little endian
+func loadConsumerPos(path string) EventPos {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) < 10 {
+		return EventPos{}
+	}
+	return EventPos{Seq: binary.LittleEndian.Uint64(b[:8]), Idx: binary.LittleEndian.Uint16(b[8:10])}
+}
+
+func storeConsumerPos(path string, pos EventPos) error {
+	var b [10]byte
+	binary.LittleEndian.PutUint64(b[:8], pos.Seq)
+	binary.LittleEndian.PutUint16(b[8:], pos.Idx)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b[:], 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
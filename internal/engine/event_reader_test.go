@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func newReaderTestEngine(dir string) *Engine {
+	return NewEngine(EngineConfig{
+		WALDir:           dir,
+		EnableCmdWAL:     true,
+		EnableOutbox:     true,
+		OutboxIndexEvery: 4,
+		CmdCodec:         BinaryCMDCode{},
+		EvCodec:          EvCmdCodec{},
+		ActorCfg:         ActorConfig{MailboxSize: 64, BatchMax: 8},
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+}
+
+// readAll：读到末尾为止
+func readAll(t *testing.T, r interface{ Next() ([]Event, error) }) []Event {
+	t.Helper()
+	var out []Event
+	for {
+		evs, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, evs...)
+	}
+}
+
+func TestEventReader_SeekBySparseIndex(t *testing.T) {
+	const sym = "BTCUSDT"
+	dir := t.TempDir()
+	eng := newReaderTestEngine(dir)
+	defer eng.Stop()
+
+	// 每条命令 Accepted + Added 两个事件
+	for i := uint64(1); i <= 30; i++ {
+		if _, err := eng.Submit(context.Background(), sym, Command{Type: CmdSubmitLimit, ReqID: i, OrderID: i, UserID: 1, Side: Buy, Price: int64(i), Qty: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size, _ := logSize(outboxWalPath(dir, sym))
+	entries := loadOutboxIndex(outboxIndexPath(outboxWalPath(dir, sym)), size)
+	if len(entries) < 6 || entries[0].seq != 4 {
+		t.Fatalf("index entries=%+v", entries)
+	}
+
+	r, err := OpenEventReader(dir, sym, EvCmdCodec{}, EventPos{Seq: 17, Idx: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.off == 0 || !r.checked {
+		t.Fatalf("reader did not seek via index: off=%d", r.off)
+	}
+	evs := readAll(t, r)
+	// seq17 的 idx1 + seq18..30 各两个
+	if len(evs) != 1+13*2 || evs[0].Seq != 17 || evs[0].Idx != 1 || evs[len(evs)-1].Seq != 30 {
+		t.Fatalf("first=%+v last=%+v n=%d", evs[0], evs[len(evs)-1], len(evs))
+	}
+
+	// 追上末尾后继续读到新写入的事件
+	if _, err := eng.Submit(context.Background(), sym, Command{Type: CmdSubmitLimit, ReqID: 31, OrderID: 31, UserID: 1, Side: Buy, Price: 31, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if evs := readAll(t, r); len(evs) != 2 || evs[0].Seq != 31 {
+		t.Fatalf("tail=%+v", evs)
+	}
+
+	// 从头读与从索引读结果一致
+	all := readAll(t, mustReader(t, dir, sym, EventPos{}))
+	if len(all) != 31*2 || all[0].Seq != 1 {
+		t.Fatalf("full scan n=%d", len(all))
+	}
+}
+
+func mustReader(t *testing.T, dir, sym string, from EventPos) *EventReader {
+	t.Helper()
+	r, err := OpenEventReader(dir, sym, EvCmdCodec{}, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+func TestEventConsumer_NamedCursorsSurviveRestart(t *testing.T) {
+	const sym = "BTCUSDT"
+	dir := t.TempDir()
+	eng := newReaderTestEngine(dir)
+	for i := uint64(1); i <= 10; i++ {
+		if _, err := eng.Submit(context.Background(), sym, Command{Type: CmdSubmitLimit, ReqID: i, OrderID: i, UserID: 1, Side: Sell, Price: 100 + int64(i), Qty: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := eng.OpenConsumer(sym, "bad/name"); !errors.Is(err, ErrBadConsumerName) {
+		t.Fatalf("err=%v", err)
+	}
+
+	// settle 处理 3 条命令后确认，audit 只确认到 seq 5 的第一个事件
+	settle, err := eng.OpenConsumer(sym, "settle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		evs, err := settle.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := settle.Ack(evs[len(evs)-1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = settle.Close()
+	audit, err := eng.OpenConsumer(sym, "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range readAll(t, audit) {
+		if ev.Seq == 5 {
+			if err := audit.Ack(ev); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	_ = audit.Close()
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	eng2 := newReaderTestEngine(dir)
+	defer eng2.Stop()
+	settle, err = eng2.OpenConsumer(sym, "settle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer settle.Close()
+	if evs := readAll(t, settle); len(evs) != 7*2 || evs[0].Seq != 4 || evs[0].Idx != 0 {
+		t.Fatalf("settle resumed at %+v n=%d", evs[0], len(evs))
+	}
+	audit, err = eng2.OpenConsumer(sym, "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	if p := audit.Position(); p != (EventPos{Seq: 5, Idx: 1}) {
+		t.Fatalf("audit pos=%+v", p)
+	}
+	if evs := readAll(t, audit); evs[0].Seq != 5 || evs[0].Idx != 1 || len(evs) != 1+5*2 {
+		t.Fatalf("audit resumed at %+v n=%d", evs[0], len(evs))
+	}
+}
+
+func TestOutboxIndex_DropsEntriesPastRepairedEnd(t *testing.T) {
+	path := t.TempDir() + "/X.ev.idx"
+	x, err := openOutboxIndex(path, 2, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq, off := uint64(1), int64(100); seq <= 8; seq, off = seq+1, off+100 {
+		x.mark(seq, off)
+	}
+	if err := x.close(); err != nil {
+		t.Fatal(err)
+	}
+	// seq 2,4,6,8 → off 200,400,600,800；ev.wal 被修复截断到 500
+	x, err = openOutboxIndex(path, 2, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.close()
+	got := loadOutboxIndex(path, 1<<20)
+	if len(got) != 2 || got[1] != (indexEntry{seq: 4, off: 400}) || x.last != 4 {
+		t.Fatalf("entries=%+v last=%d", got, x.last)
+	}
+	if e, ok := seekOutboxIndex(got, 4, 0); !ok || e.seq != 2 {
+		t.Fatalf("seek=%+v %v", e, ok)
+	}
+	if _, ok := seekOutboxIndex(got, 4, 300); ok {
+		t.Fatal("entry before retained start must be ignored")
+	}
+}
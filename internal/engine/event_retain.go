@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// ev 段清理的下限：publisher cursor 之外，命名消费者和在读的订阅者还没读到的段也不能删
+// - 命名消费者：<sym>.ev.<name>.cursor 存在就算注册（OpenEventConsumer 首次打开即创建），删掉文件即注销
+// - 在读的读者（SubscribeEvents / 打开中的 EventConsumer）：按它下一条要读的偏移
+// 位置换算成偏移靠稀疏索引（seq 之前最近的命令边界）；定位不到就退回日志开头，即不清理
+
+// evReaders：按 symbol 登记在读的 EventReader；打开读者和清理串行，读者打开后定位的段不会被删
+type evReaders struct {
+	mu sync.Mutex
+	m  map[string]map[*EventReader]struct{}
+}
+
+// open：在锁内打开读者并登记，读者 Close 时注销
+func (s *evReaders) open(symbol string, open func() (*EventReader, error)) (*EventReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	if s.m == nil {
+		s.m = make(map[string]map[*EventReader]struct{})
+	}
+	if s.m[symbol] == nil {
+		s.m[symbol] = make(map[*EventReader]struct{})
+	}
+	s.m[symbol][r] = struct{}{}
+	r.release = func() {
+		s.mu.Lock()
+		delete(s.m[symbol], r)
+		s.mu.Unlock()
+	}
+	return r, nil
+}
+
+// retainEvents：publisher 推进 cursor 后调用，清到 cursor / 命名消费者 / 在读读者里最靠前的位置
+func (e *Engine) retainEvents(symbol string, box *EventOutbox, cursor int64) error {
+	s := &e.readers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	floor := cursor
+	for r := range s.m[symbol] {
+		floor = min(floor, r.held.Load())
+	}
+	path := outboxWalPath(e.cfg.WALDir, symbol)
+	names, err := filepath.Glob(filepath.Join(e.cfg.WALDir, safeSym(symbol)+".ev.*.cursor"))
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		start, err := logStart(path)
+		if err != nil {
+			return err
+		}
+		size, err := logSize(path)
+		if err != nil {
+			return err
+		}
+		entries := loadOutboxIndex(outboxIndexPath(path), size)
+		for _, name := range names {
+			floor = min(floor, eventPosOffset(entries, loadConsumerPos(name), start))
+		}
+	}
+	return box.Retain(floor)
+}
+
+// eventPosOffset：读到 pos 需要保留的最早偏移（pos 之前最近的已索引命令边界）
+func eventPosOffset(entries []indexEntry, pos EventPos, start int64) int64 {
+	if e, ok := seekOutboxIndex(entries, pos.Seq, start); ok {
+		return e.off
+	}
+	return start
+}
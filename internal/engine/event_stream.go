@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// 事件订阅：用 EventReader tail 该 symbol 的 ev.wal（outbox），与 publisher 互不影响
+// - 先回放 seq>=fromSeq 的历史事件（稀疏索引定位），读到尾部后轮询等新事件
+// - 按命令整段回调，崩溃修复截掉的半截命令不会被推出去
+// - outbox 开启清理时，被清掉的事件无法回放（ErrEventsTrimmed）；订阅期间没读到的段不会被清
+
+var (
+	ErrOutboxDisabled = errors.New("event outbox disabled")
+	ErrEventsTrimmed  = errors.New("events before requested seq have been trimmed")
+)
+
+// SubscribeEvents：fn 返回 error 或 ctx 结束时退出（ctx 结束返回 ctx.Err()）
+func (e *Engine) SubscribeEvents(ctx context.Context, symbol string, fromSeq uint64, fn func(evs []Event) error) error {
+	if !e.cfg.EnableOutbox || e.cfg.WALDir == "" {
+		return ErrOutboxDisabled
+	}
+	// 确保 actor 已启动：ev.wal 已完成修复/补齐
+	if _, err := e.getOrCreateActor(symbol); err != nil {
+		return err
+	}
+	r, err := e.readers.open(symbol, func() (*EventReader, error) {
+		return OpenEventReader(e.cfg.WALDir, symbol, e.cfg.EvCodec, EventPos{Seq: fromSeq})
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return e.tail(ctx, r, fn)
+}
+
+// tail：读到末尾后按 PublisherPoll 轮询
+func (e *Engine) tail(ctx context.Context, r *EventReader, fn func(evs []Event) error) error {
+	poll := e.cfg.PublisherPoll
+	if poll <= 0 {
+		poll = 50 * time.Millisecond
+	}
+	t := time.NewTimer(poll)
+	defer t.Stop()
+	for {
+		evs, err := r.Next()
+		if err == nil {
+			if err := fn(evs); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		t.Reset(poll)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.ctx.Done():
+			return ErrEngineStopped
+		case <-t.C:
+		}
+	}
+}
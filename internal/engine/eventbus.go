@@ -39,3 +39,13 @@ func (b *ChanBus) Publish(ctx context.Context, ev Event) error {
 		return ctx.Err()
 	}
 }
+
+// PublishBatch：ChanBus 作为 EventSink（进程内，入 chan 即视为确认）
+func (b *ChanBus) PublishBatch(ctx context.Context, _ string, evs []Event) error {
+	for _, ev := range evs {
+		if err := b.Publish(ctx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+
+	"gopherex.com/pkg/wal"
+)
+
+// 手续费：成交时按 maker/taker 费率算好放进 EvTrade，下游（结算 SettleTradeReq.fee）直接用，不再自己算
+// - 费率单位 1e-6（FeeRateScale），负数为返佣（通常只给 maker）
+// - 默认各自按收到的资产收：买方收 base（Qty 同单位），卖方收 quote（Price*Qty，与 MinNotional 同口径）
+//   FeeInQuote 时两边都按 quote 收
+// - 收费向上取整、返佣向下取整（不会多返）
+// - 改费率走 cmd WAL：actor 发现注册表里的费率表变了，就在下一个 batch 最前面写一条 CmdSetFees，
+//   之后的成交按新表算；快照保存当时生效的表，重启/备库/离线回放都按 WAL 换表，和线上一致
+
+const FeeRateScale = 1_000_000
+
+// FeeAsset：手续费资产（具体币种见 SymbolSpec.Base / Quote）
+type FeeAsset uint8
+
+const (
+	FeeAssetNone FeeAsset = iota // 没配置费率
+	FeeAssetBase
+	FeeAssetQuote
+)
+
+func (a FeeAsset) String() string {
+	switch a {
+	case FeeAssetBase:
+		return "base"
+	case FeeAssetQuote:
+		return "quote"
+	}
+	return "none"
+}
+
+type FeeRates struct {
+	Maker int64
+	Taker int64
+}
+
+// FeeSchedule：交易对费率表；注册后只读，改费率整份替换（SymbolRegistry.Register）
+type FeeSchedule struct {
+	FeeRates                      // 默认费率
+	Tiers      map[uint8]FeeRates // 等级费率（VIP 等）
+	UserTiers  map[uint64]uint8   // 用户等级；不在表里或等级没配置时用默认费率
+	FeeInQuote bool               // 两边都按 quote 收
+}
+
+// Rates：用户适用的费率（风控冻结预留手续费也用它）
+func (f *FeeSchedule) Rates(userID uint64) FeeRates {
+	if tier, ok := f.UserTiers[userID]; ok {
+		if r, ok := f.Tiers[tier]; ok {
+			return r
+		}
+	}
+	return f.FeeRates
+}
+
+// apply：给成交事件填上双方手续费；f 为 nil 时不收
+// 集合竞价成交 TakerSide=0：Taker 位置是买单，双方都按 maker 费率
+func (f *FeeSchedule) apply(ev *Event) {
+	if f == nil {
+		return
+	}
+	takerSide, takerRate := ev.TakerSide, f.Rates(ev.TakerUserID).Taker
+	if takerSide == 0 {
+		takerSide, takerRate = Buy, f.Rates(ev.TakerUserID).Maker
+	}
+	var makerSide uint8 = Buy
+	if takerSide == Buy {
+		makerSide = Sell
+	}
+	ev.MakerFee, ev.MakerFeeAsset = f.fee(makerSide, f.Rates(ev.MakerUserID).Maker, ev.Price, ev.Qty)
+	ev.TakerFee, ev.TakerFeeAsset = f.fee(takerSide, takerRate, ev.Price, ev.Qty)
+}
+
+func (f *FeeSchedule) fee(side uint8, rate, price, qty int64) (int64, FeeAsset) {
+	if side == Buy && !f.FeeInQuote {
+		return FeeAmount(qty, rate), FeeAssetBase
+	}
+	return notionalFee(price, qty, rate), FeeAssetQuote
+}
+
+// notionalFee：price*qty*rate/FeeRateScale，名义价值超过 int64 时按 128 位算，结果溢出截到 MaxInt64
+func notionalFee(price, qty, rate int64) int64 {
+	if price <= 0 || qty <= 0 || rate == 0 {
+		return 0
+	}
+	hi, lo := bits.Mul64(uint64(price), uint64(qty))
+	if hi == 0 && lo <= math.MaxInt64 {
+		return FeeAmount(int64(lo), rate)
+	}
+	r := uint64(rate)
+	if rate < 0 {
+		r = uint64(-rate)
+	}
+	// notional = a*FeeRateScale + b：fee = a*r + b*r/FeeRateScale
+	if hi >= FeeRateScale {
+		return clampFee(math.MaxInt64, rate)
+	}
+	a, b := bits.Div64(hi, lo, FeeRateScale)
+	ahi, alo := bits.Mul64(a, r)
+	if ahi != 0 || alo > math.MaxInt64 {
+		return clampFee(math.MaxInt64, rate)
+	}
+	phi, plo := bits.Mul64(b, r) // b < FeeRateScale，phi 一定小于 FeeRateScale
+	q, rem := bits.Div64(phi, plo, FeeRateScale)
+	if rate > 0 && rem > 0 {
+		q++
+	}
+	sum := alo + q
+	if sum > math.MaxInt64 {
+		sum = math.MaxInt64
+	}
+	return clampFee(int64(sum), rate)
+}
+
+// FeeAmount：amount*rate/FeeRateScale，中间结果用 128 位，溢出时截到 MaxInt64
+func FeeAmount(amount, rate int64) int64 {
+	if amount <= 0 || rate == 0 {
+		return 0
+	}
+	r := uint64(rate)
+	if rate < 0 {
+		r = uint64(-rate)
+	}
+	hi, lo := bits.Mul64(uint64(amount), r)
+	if hi >= FeeRateScale {
+		return clampFee(math.MaxInt64, rate)
+	}
+	q, rem := bits.Div64(hi, lo, FeeRateScale)
+	if rate > 0 && rem > 0 {
+		q++
+	}
+	if q > math.MaxInt64 {
+		q = math.MaxInt64
+	}
+	return clampFee(int64(q), rate)
+}
+
+func clampFee(v, rate int64) int64 {
+	if rate < 0 {
+		return -v
+	}
+	return v
+}
+
+// equal：内容相同（nil 与空 map 视为相同）；actor 用它判断注册表里的费率是否真的变了
+func (f *FeeSchedule) equal(o *FeeSchedule) bool {
+	if f == nil || o == nil {
+		return f == o
+	}
+	if f.FeeRates != o.FeeRates || f.FeeInQuote != o.FeeInQuote || len(f.Tiers) != len(o.Tiers) || len(f.UserTiers) != len(o.UserTiers) {
+		return false
+	}
+	for k, v := range f.Tiers {
+		if ov, ok := o.Tiers[k]; !ok || ov != v {
+			return false
+		}
+	}
+	for k, v := range f.UserTiers {
+		if ov, ok := o.UserTiers[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// 费率表二进制编码（CmdSetFees 的 WAL 记录尾部 / 快照尾部共用，little endian）：
+//
+//	present(1) | feeInQuote(1) | maker(8) | taker(8) | nTiers(2) | {tier(1) maker(8) taker(8)}* | nUsers(4) | {userID(8) tier(1)}*
+//
+// present=0 表示不收手续费（nil），后面没有内容；tiers / users 按 key 升序，同一份表编码结果唯一
+// 每条 CmdSetFees 都带整份表（含 UserTiers），编码后加上定长部分不能超过 WAL 单条上限：注册时就拒（ErrFeeScheduleTooLarge）
+var (
+	ErrBadFeeSchedule      = errors.New("fees: corrupt schedule")
+	ErrFeeScheduleTooLarge = errors.New("fees: schedule too large for a cmd wal record")
+)
+
+// maxFeeScheduleLen：费率表编码后的上限（wal.DefaultMaxPayload 减去 cmd 记录定长部分）
+const maxFeeScheduleLen = wal.DefaultMaxPayload - cmdRecordLen
+
+// encodedLen：appendFeeSchedule 编码后的字节数（不用真的编码）
+func (f *FeeSchedule) encodedLen() int {
+	if f == nil {
+		return 1
+	}
+	return 1 + 1 + 8 + 8 + 2 + len(f.Tiers)*17 + 4 + len(f.UserTiers)*9
+}
+
+func appendFeeSchedule(dst []byte, f *FeeSchedule) []byte {
+	if f == nil {
+		return append(dst, 0)
+	}
+	var flag byte
+	if f.FeeInQuote {
+		flag = 1
+	}
+	dst = append(dst, 1, flag)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(f.Maker))
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(f.Taker))
+
+	tiers := make([]uint8, 0, len(f.Tiers))
+	for t := range f.Tiers {
+		tiers = append(tiers, t)
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i] < tiers[j] })
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(tiers)))
+	for _, t := range tiers {
+		dst = append(dst, t)
+		dst = binary.LittleEndian.AppendUint64(dst, uint64(f.Tiers[t].Maker))
+		dst = binary.LittleEndian.AppendUint64(dst, uint64(f.Tiers[t].Taker))
+	}
+
+	users := make([]uint64, 0, len(f.UserTiers))
+	for u := range f.UserTiers {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(users)))
+	for _, u := range users {
+		dst = binary.LittleEndian.AppendUint64(dst, u)
+		dst = append(dst, f.UserTiers[u])
+	}
+	return dst
+}
+
+// decodeFeeSchedule：解码一份费率表，返回用掉的字节数
+func decodeFeeSchedule(b []byte) (f *FeeSchedule, n int, err error) {
+	if len(b) < 1 {
+		return nil, 0, ErrBadFeeSchedule
+	}
+	if b[0] == 0 {
+		return nil, 1, nil
+	}
+	const fixed = 1 + 1 + 8 + 8 + 2
+	if b[0] != 1 || len(b) < fixed {
+		return nil, 0, ErrBadFeeSchedule
+	}
+	f = &FeeSchedule{
+		FeeRates: FeeRates{
+			Maker: int64(binary.LittleEndian.Uint64(b[2:10])),
+			Taker: int64(binary.LittleEndian.Uint64(b[10:18])),
+		},
+		FeeInQuote: b[1]&1 != 0,
+	}
+	nTiers := int(binary.LittleEndian.Uint16(b[18:20]))
+	off := fixed
+	if len(b) < off+nTiers*17+4 {
+		return nil, 0, ErrBadFeeSchedule
+	}
+	f.Tiers = make(map[uint8]FeeRates, nTiers)
+	for i := 0; i < nTiers; i++ {
+		f.Tiers[b[off]] = FeeRates{
+			Maker: int64(binary.LittleEndian.Uint64(b[off+1 : off+9])),
+			Taker: int64(binary.LittleEndian.Uint64(b[off+9 : off+17])),
+		}
+		off += 17
+	}
+	nUsers := int(binary.LittleEndian.Uint32(b[off : off+4]))
+	off += 4
+	if nUsers > (len(b)-off)/9 {
+		return nil, 0, ErrBadFeeSchedule
+	}
+	f.UserTiers = make(map[uint64]uint8, nUsers)
+	for i := 0; i < nUsers; i++ {
+		f.UserTiers[binary.LittleEndian.Uint64(b[off:off+8])] = b[off+8]
+		off += 9
+	}
+	return f, off, nil
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"gopherex.com/pkg/wal"
+)
+
+func TestFee_Amount(t *testing.T) {
+	cases := []struct {
+		amount, rate, want int64
+	}{
+		{1000, 500, 1},   // 0.5 → 收费向上取整
+		{10_000, 500, 5}, // 整除
+		{1000, -50, 0},   // 返佣 0.05 → 向下取整
+		{100_000, -50, -5},
+		{0, 500, 0},
+		{1000, 0, 0},
+		{math.MaxInt64, FeeRateScale * 2, math.MaxInt64}, // 溢出截断
+	}
+	for _, c := range cases {
+		if got := FeeAmount(c.amount, c.rate); got != c.want {
+			t.Fatalf("FeeAmount(%d, %d)=%d, want %d", c.amount, c.rate, got, c.want)
+		}
+	}
+}
+
+func TestFee_NotionalOverflow(t *testing.T) {
+	cases := []struct {
+		price, qty, rate, want int64
+	}{
+		{1e10, 1e10, 1000, 1e17}, // 名义价值 1e20 超过 int64
+		{1e10, 1e10, -1000, -1e17},
+		{3, 1, 500, 1}, // 不溢出时和 FeeAmount 一致
+		{math.MaxInt64, math.MaxInt64, 1, math.MaxInt64},
+		{math.MaxInt64, math.MaxInt64, -1, math.MinInt64 + 1},
+	}
+	for _, c := range cases {
+		if got := notionalFee(c.price, c.qty, c.rate); got != c.want {
+			t.Fatalf("notionalFee(%d, %d, %d)=%d, want %d", c.price, c.qty, c.rate, got, c.want)
+		}
+	}
+}
+
+func TestFee_TradeEventsCarryFees(t *testing.T) {
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	spec := SymbolSpec{Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT", Fees: &FeeSchedule{
+		FeeRates:  FeeRates{Maker: 1000, Taker: 2000},
+		Tiers:     map[uint8]FeeRates{1: {Maker: -100, Taker: 1500}},
+		UserTiers: map[uint64]uint8{7: 1, 8: 9}, // 8 的等级没配置，用默认费率
+	}}
+	cfg.Symbols = newTestRegistry(t, spec)
+	eng := NewEngine(cfg)
+	ctx := context.Background()
+
+	// 7（VIP1）挂卖单，8 吃：maker 卖方收 quote 并拿返佣，taker 买方按 base 收
+	if _, err := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 7, Side: Sell, Price: 20_000, Qty: 10_000}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Buy, Price: 20_000, Qty: 4_000})
+	if err != nil || len(res.Trades) != 1 {
+		t.Fatalf("taker: %+v %v", res, err)
+	}
+	tr := res.Trades[0]
+	if tr.MakerUserID != 7 || tr.TakerUserID != 8 || tr.TakerSide != Buy {
+		t.Fatalf("parties=%+v", tr)
+	}
+	if tr.MakerFee != -8_000 || tr.MakerFeeAsset != FeeAssetQuote || tr.TakerFee != 8 || tr.TakerFeeAsset != FeeAssetBase {
+		t.Fatalf("fees=%+v", tr)
+	}
+	if spec.AssetOf(tr.MakerFeeAsset) != "USDT" || spec.AssetOf(tr.TakerFeeAsset) != "BTC" {
+		t.Fatal("asset codes")
+	}
+
+	// 改费率（整份替换）：之后的成交按新费率，两边都收 quote
+	spec.Fees = &FeeSchedule{FeeRates: FeeRates{Maker: 0, Taker: 1000}, FeeInQuote: true}
+	if err := cfg.Symbols.Register(spec); err != nil {
+		t.Fatal(err)
+	}
+	res, err = eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitMarket, ReqID: 3, OrderID: 3, UserID: 9, Side: Buy, Qty: 1_000})
+	if err != nil || len(res.Trades) != 1 {
+		t.Fatalf("market: %+v %v", res, err)
+	}
+	if tr := res.Trades[0]; tr.MakerFee != 0 || tr.MakerFeeAsset != FeeAssetQuote || tr.TakerFee != 20_000 || tr.TakerFeeAsset != FeeAssetQuote {
+		t.Fatalf("fees after change=%+v", tr)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// 手续费落在 ev.wal 里：读出来和同步结果一致（seq 1 是启动后第一个 batch 写的 CmdSetFees）
+	rec, err := RecordedEvents(dir, "BTCUSDT", EvCmdCodec{}, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Event
+	for _, ev := range rec {
+		if ev.Type == EvTrade {
+			got = ev
+		}
+	}
+	if got != tr {
+		t.Fatalf("recorded=%+v, want %+v", got, tr)
+	}
+}
+
+func TestFee_EvCodec(t *testing.T) {
+	ev := Event{Seq: 3, Idx: 1, Type: EvTrade, MakerOrderID: 1, TakerOrderID: 2, Price: 100, Qty: 1, TradeID: TradeID(2, 1),
+		MakerUserID: 5, TakerUserID: 6, TakerSide: Sell, MakerFee: -1, TakerFee: 2, MakerFeeAsset: FeeAssetBase, TakerFeeAsset: FeeAssetQuote}
+	p, _ := EvCmdCodec{}.Encode(nil, ev)
+	if out, err := (EvCmdCodec{}).Decode(p); err != nil || out != ev {
+		t.Fatalf("roundtrip: %+v %v", out, err)
+	}
+	v1 := append([]byte(nil), p[:evRecordLenV1]...)
+	v1[evOffVer] = evWalVersion1
+	out, err := EvCmdCodec{}.Decode(v1)
+	if err != nil || out.TradeID != legacyTradeID(3, 1) || out.MakerUserID != 0 || out.MakerFee != 0 || out.TakerFeeAsset != FeeAssetNone {
+		t.Fatalf("v1 decode: %+v %v", out, err)
+	}
+}
+
+func TestFee_RefillUsesLoggedFees(t *testing.T) {
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	spec := SymbolSpec{Symbol: "BTCUSDT", Fees: &FeeSchedule{FeeRates: FeeRates{Maker: 1000, Taker: 2000}, FeeInQuote: true}}
+	cfg.Symbols = newTestRegistry(t, spec)
+	eng := NewEngine(cfg)
+	ctx := context.Background()
+	if _, err := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 20}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Buy, Price: 100, Qty: 10})
+	if err != nil || len(res.Trades) != 1 {
+		t.Fatalf("taker: %+v %v", res, err)
+	}
+	want := res.Trades[0]
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// outbox 丢了、重启前改了费率：补出来的成交仍按当时（WAL 里）的费率
+	if err := os.Remove(outboxWalPath(dir, "BTCUSDT")); err != nil {
+		t.Fatal(err)
+	}
+	spec.Fees = &FeeSchedule{FeeRates: FeeRates{Maker: 0, Taker: 5000}, FeeInQuote: true}
+	if err := cfg.Symbols.Register(spec); err != nil {
+		t.Fatal(err)
+	}
+	eng = NewEngine(cfg)
+	res, err = eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 9, Side: Buy, Price: 100, Qty: 10})
+	if err != nil || len(res.Trades) != 1 {
+		t.Fatalf("after restart: %+v %v", res, err)
+	}
+	if tr := res.Trades[0]; tr.MakerFee != 0 || tr.TakerFee != 5 {
+		t.Fatalf("fees after restart=%+v", tr)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	rec, err := RecordedEvents(dir, "BTCUSDT", EvCmdCodec{}, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Event
+	for _, ev := range rec {
+		if ev.Type == EvTrade {
+			got = ev
+		}
+	}
+	if got != want {
+		t.Fatalf("refilled=%+v, want %+v", got, want)
+	}
+}
+
+func TestFee_CmdCodecSetFees(t *testing.T) {
+	f := &FeeSchedule{FeeRates: FeeRates{Maker: -100, Taker: 2000}, FeeInQuote: true,
+		Tiers: map[uint8]FeeRates{2: {Maker: 0, Taker: 500}, 1: {Maker: 50, Taker: 900}}, UserTiers: map[uint64]uint8{9: 2, 3: 1}}
+	for _, fees := range []*FeeSchedule{f, nil} {
+		p, _ := BinaryCMDCode{}.Encode(nil, 5, Command{Type: CmdSetFees, Fees: fees})
+		seq, out, err := BinaryCMDCode{}.Decode(p)
+		if err != nil || seq != 5 || out.Type != CmdSetFees || !out.Fees.equal(fees) {
+			t.Fatalf("roundtrip %+v: %+v %v", fees, out, err)
+		}
+		if len(p) != cmdRecordLen+fees.encodedLen() {
+			t.Fatalf("len=%d, encodedLen=%d", len(p), fees.encodedLen())
+		}
+		if _, _, err := (BinaryCMDCode{}).Decode(p[:len(p)-1]); err == nil {
+			t.Fatal("truncated fee schedule decoded")
+		}
+	}
+	// v1 记录不能带费率表
+	p, _ := BinaryCMDCode{}.Encode(nil, 5, Command{Type: CmdSetFees, Fees: f})
+	p[offVer] = cmdWalVersion1
+	if _, _, err := (BinaryCMDCode{}).Decode(p); err == nil {
+		t.Fatal("v1 set-fees decoded")
+	}
+}
+
+// 费率表随每条 CmdSetFees 落 WAL：写不进一条记录的表注册时就拒
+func TestFee_RejectsOversizeSchedule(t *testing.T) {
+	users := func(n int) *FeeSchedule {
+		f := &FeeSchedule{Tiers: map[uint8]FeeRates{1: {Taker: 500}}, UserTiers: make(map[uint64]uint8, n)}
+		for i := 0; i < n; i++ {
+			f.UserTiers[uint64(i)] = 1
+		}
+		return f
+	}
+	fit := (maxFeeScheduleLen - users(0).encodedLen()) / 9
+	reg := newTestRegistry(t, SymbolSpec{Symbol: "BTCUSDT", Fees: users(fit)})
+	spec, _ := reg.Get("BTCUSDT")
+	p, _ := BinaryCMDCode{}.Encode(nil, 1, Command{Type: CmdSetFees, Fees: spec.Fees})
+	if len(p) > wal.DefaultMaxPayload {
+		t.Fatalf("accepted schedule encodes to %d bytes", len(p))
+	}
+	if err := reg.Register(SymbolSpec{Symbol: "BTCUSDT", Fees: users(fit + 1)}); !errors.Is(err, ErrFeeScheduleTooLarge) {
+		t.Fatalf("oversize: %v", err)
+	}
+	if s, _ := reg.Get("BTCUSDT"); len(s.Fees.UserTiers) != fit {
+		t.Fatal("oversize schedule replaced the registered one")
+	}
+}
+
+func TestFee_SnapshotKeepsFees(t *testing.T) {
+	f := &FeeSchedule{FeeRates: FeeRates{Maker: 10, Taker: 20}, UserTiers: map[uint64]uint8{1: 1}}
+	b := encodeSnapshot(5, 0, symState{phase: PhaseOpen, fees: f}, []RestingOrder{{OrderID: 1, UserID: 2, Side: Buy, Price: 9, Qty: 1}}, nil)
+	h, orders, _, err := decodeSnapshot(b)
+	if err != nil || !h.fees.equal(f) || len(orders) != 1 {
+		t.Fatalf("decode: %+v %v", h, err)
+	}
+	var st symState
+	h.restore(&st)
+	if st.fees != h.fees {
+		t.Fatal("restore fees")
+	}
+}
@@ -0,0 +1,84 @@
+// Package fencing：主备切换用的单写者租约（engine.Lease 的实现）
+package fencing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"gopherex.com/internal/engine"
+)
+
+var _ engine.Lease = (*EtcdLease)(nil)
+
+var ErrNotHeld = errors.New("fencing: lease not held")
+
+// EtcdLease：etcd session（带 TTL 的 lease）+ election
+// - 进程挂掉/网络断开时 session 的 lease 过期，key 自动删除，备库 Campaign 才能当选
+// - token = 当选 key 的 create revision：etcd 全局单调递增，后当选的一定更大，直接作为 fencing token
+type EtcdLease struct {
+	cli *clientv3.Client
+	key string // 选举前缀，如 /gopherex/matching/leader
+	id  string // 本节点标识（写进 leader key 的 value，排查用）
+	ttl int    // 秒
+
+	mu   sync.Mutex
+	sess *concurrency.Session
+	elec *concurrency.Election
+}
+
+func NewEtcdLease(cli *clientv3.Client, key, id string, ttl time.Duration) *EtcdLease {
+	sec := int(ttl / time.Second)
+	if sec <= 0 {
+		sec = 5
+	}
+	return &EtcdLease{cli: cli, key: key, id: id, ttl: sec}
+}
+
+// Acquire：阻塞到当选；session 不绑 ctx（ctx 只控制等待），当选后靠 keepalive 续约
+func (l *EtcdLease) Acquire(ctx context.Context) (uint64, error) {
+	sess, err := concurrency.NewSession(l.cli, concurrency.WithTTL(l.ttl))
+	if err != nil {
+		return 0, err
+	}
+	elec := concurrency.NewElection(sess, l.key)
+	if err := elec.Campaign(ctx, l.id); err != nil {
+		_ = sess.Close()
+		return 0, err
+	}
+	l.mu.Lock()
+	l.sess, l.elec = sess, elec
+	l.mu.Unlock()
+	return uint64(elec.Rev()), nil
+}
+
+// Done：session 的 lease 过期/被撤销后关闭；没拿到租约时返回已关闭的 channel
+func (l *EtcdLease) Done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sess == nil {
+		c := make(chan struct{})
+		close(c)
+		return c
+	}
+	return l.sess.Done()
+}
+
+func (l *EtcdLease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	sess, elec := l.sess, l.elec
+	l.sess, l.elec = nil, nil
+	l.mu.Unlock()
+	if sess == nil {
+		return ErrNotHeld
+	}
+	err := elec.Resign(ctx)
+	if cerr := sess.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"testing"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestIceberg_EventsAndParams(t *testing.T) {
+	book := newHeapBook()
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 9, Side: Sell, Price: 100, Qty: 6, DisplayQty: 2}, em)
+	assertTypes(t, em.types(), EvAccepted, EvAdded)
+
+	// 吃 3：可见 2 → 补 2 → 再吃 1
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Buy, Price: 100, Qty: 3}, em)
+	assertTypes(t, em.types(), EvAccepted, EvTrade, EvReplenish, EvTrade)
+	if ev := em.evs[2]; ev.OrderID != 1 || ev.Qty != 2 {
+		t.Fatalf("replenish=%+v", ev)
+	}
+	if d := book.(*HeapBookAdapter).B.Depth(matching.Sell, 1); d[0].Qty != 1 {
+		t.Fatalf("depth=%+v, want only displayed qty", d)
+	}
+
+	// 改单数量是剩余总量（可见 1 + 隐藏 2）
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdAmend, ReqID: 3, OrderID: 1, UserID: 9, Qty: 2}, em)
+	assertTypes(t, em.types(), EvAmended)
+	if o, _ := book.(*HeapBookAdapter).B.Order(1); o.Qty != 1 || o.Reserve != 1 {
+		t.Fatalf("after amend: %+v", o)
+	}
+
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 4, OrderID: 4, UserID: 8, Side: Buy, Price: 99, Qty: 3, TIF: TifIOC, DisplayQty: 1}, em)
+	assertTypes(t, em.types(), EvRejected)
+}
+
+func TestIceberg_CodecAndSnapshot(t *testing.T) {
+	in := Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, Side: Buy, Price: 100, Qty: 10, DisplayQty: 3}
+	p, _ := BinaryCMDCode{}.Encode(nil, 7, in)
+	if _, out, err := (BinaryCMDCode{}).Decode(p); err != nil || out != in {
+		t.Fatalf("cmd roundtrip: %+v %v", out, err)
+	}
+	v1 := append([]byte(nil), p[:cmdRecordLenV1]...)
+	v1[offVer] = cmdWalVersion1
+	if _, out, err := (BinaryCMDCode{}).Decode(v1); err != nil || out.DisplayQty != 0 {
+		t.Fatalf("cmd v1 decode: %+v %v", out, err)
+	}
+
+	// 快照保留当前可见片与隐藏量：恢复后队列状态一致
+	src := newHeapBook().(*HeapBookAdapter)
+	src.B.Add(&matching.Order{ID: 1, UserID: 9, Side: matching.Sell, Price: 100, Qty: 10, Display: 4})
+	src.B.MatchEmit(&matching.Order{ID: 2, Side: matching.Buy, Price: 100, Qty: 1}, false, nil, nil)
+
+	_, orders, _, err := decodeSnapshot(encodeSnapshot(1, 0, symState{phase: PhaseOpen}, src.SnapshotOrders(), nil))
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("decode: %+v %v", orders, err)
+	}
+	dst := newHeapBook().(*HeapBookAdapter)
+	dst.RestoreOrders(orders)
+	if o, _ := dst.B.Order(1); o.Qty != 3 || o.Reserve != 6 || o.Display != 4 {
+		t.Fatalf("restored iceberg: %+v", o)
+	}
+}
@@ -0,0 +1,111 @@
+package engine
+
+import "strconv"
+
+// 引擎生成的 ID：高位是交易对编号（SymbolSpec.ID），跨 symbol 不冲突；低 48 位只由 WAL 决定，回放/备库/离线重放都得到同样的值
+// - 订单号：下单命令 OrderID=0 时在写 cmd WAL 前分配（随命令落 WAL）
+//   布局 1(1) | symbolID(15) | seq(48)，最高位置 1 与调用方自带的订单号分开；seq 超过 48 位后不再分配（RejectIDExhausted）
+// - 成交号：symbolID(15) | 成交序号(48)，成交序号是该 symbol 的第几笔成交（随快照保存，回放按同样顺序递增）
+// 编号 0 只给测试/未配置的交易对用，线上每个交易对必须配不同的编号，且分配后不能改
+
+const (
+	engineOrderIDBit = uint64(1) << 63
+	idSeqBits        = 48
+	idSeqMask        = uint64(1)<<idSeqBits - 1
+	MaxSymbolID      = 1<<15 - 1
+	// 成交序号到这里就拒新单：一条命令最多 65536 个事件（Event.Idx 是 uint16），一个 batch 的成交数远小于余下的 2^32
+	tradeSeqLimit = idSeqMask - 1<<32
+)
+
+// EngineOrderID：symbol 编号 + seq 对应的引擎订单号
+func EngineOrderID(symbolID uint16, seq uint64) uint64 {
+	return engineOrderIDBit | uint64(symbolID&MaxSymbolID)<<idSeqBits | seq&idSeqMask
+}
+
+// IsEngineOrderID：调用方自带的订单号不能落在这个号段
+func IsEngineOrderID(id uint64) bool { return id&engineOrderIDBit != 0 }
+
+// TradeID：symbol 编号 + 该 symbol 的第 n 笔成交
+func TradeID(symbolID uint16, n uint64) uint64 {
+	return uint64(symbolID&MaxSymbolID)<<idSeqBits | n&idSeqMask
+}
+
+// legacyTradeID：v1 ev 记录没存成交号，按当时的规则 seq(高 48 位) + idx(低 16 位) 补算
+func legacyTradeID(seq uint64, idx uint16) uint64 { return seq<<16 | uint64(idx) }
+
+// tradeSeq：成交号生成状态；actor、重启回放、备库、离线回放各持一份，按同样的命令顺序递增
+type tradeSeq struct {
+	sym uint16 // 交易对编号
+	n   uint64 // 已有成交笔数
+}
+
+func (t *tradeSeq) next() uint64 {
+	t.n++
+	return TradeID(t.sym, t.n)
+}
+
+// FillID：全局唯一成交号，形如 "BTCUSDT-T281474976710657"；结算侧 settled_fills.fill_id 直接用它做幂等键
+func FillID(symbol string, ev Event) string {
+	b := make([]byte, 0, len(symbol)+22)
+	b = append(b, symbol...)
+	b = append(b, "-T"...)
+	b = strconv.AppendUint(b, ev.TradeID, 10)
+	return string(b)
+}
+
+// symbolID：交易对编号（没配置注册表时为 0）；和费率一样在建 actor 时读一次
+func (e *Engine) symbolID(symbol string) uint16 {
+	if e.cfg.Symbols == nil {
+		return 0
+	}
+	spec, _ := e.cfg.Symbols.Get(symbol)
+	return spec.ID
+}
+
+// ClientOrderBook：能按 (userID, clientOrderID) 查挂单的簿（可选能力）
+// 不实现时客户端订单号只和止损单、同 batch 的新单查重
+type ClientOrderBook interface {
+	ClientOrder(userID, clientOrderID uint64) (RestingOrder, bool)
+}
+
+type clientKey struct {
+	user, client uint64
+}
+
+func isSubmit(t CmdType) bool {
+	return t == CmdSubmitLimit || t == CmdSubmitMarket || t == CmdSubmitStop
+}
+
+// assignOrderID：下单命令没带订单号就按 seq 生成；自带的落在引擎号段直接拒
+func assignOrderID(cmd *Command, symbolID uint16, seq uint64) RejectCode {
+	if !isSubmit(cmd.Type) {
+		return RejectNone
+	}
+	if cmd.OrderID == 0 {
+		if seq > idSeqMask {
+			return RejectIDExhausted
+		}
+		cmd.OrderID = EngineOrderID(symbolID, seq)
+		return RejectNone
+	}
+	if IsEngineOrderID(cmd.OrderID) {
+		return RejectBadParams
+	}
+	return RejectNone
+}
+
+// dupClientOrder：客户端订单号是否和该用户未完结的订单重复
+// 未完结 = 簿里的挂单 + 未触发的止损单 + 同一 batch 里已通过校验的新单（还没 apply）
+// 结果码随命令落 WAL，回放不再查重；订单结束后同一个客户端订单号可以复用
+func (a *SymbolActor) dupClientOrder(cmd Command) bool {
+	k := clientKey{user: cmd.UserID, client: cmd.ClientOrderID}
+	if _, ok := a.pendingClients[k]; ok {
+		return true
+	}
+	if cb, ok := a.book.(ClientOrderBook); ok {
+		if _, ok := cb.ClientOrder(cmd.UserID, cmd.ClientOrderID); ok {
+			return true
+		}
+	}
+	return a.stops.hasClient(cmd.UserID, cmd.ClientOrderID)
+}
@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIDs_GeneratedOrderAndTradeIDs(t *testing.T) {
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	cfg.ActorCfg.BatchMax = 1
+	cfg.SnapshotEvery, cfg.SnapshotKeep = 2, 8
+	const sym = "BTCUSDT"
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, ID: 1}, SymbolSpec{Symbol: "ETHUSDT", ID: 2})
+	ctx := context.Background()
+
+	eng := NewEngine(cfg)
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 1, UserID: 1, Side: Sell, Price: 100, Qty: 5, ClientOrderID: 7})
+	if err != nil || !res.Accepted || res.OrderID != EngineOrderID(1, 1) {
+		t.Fatalf("generated: %+v %v", res, err)
+	}
+	// 另一个交易对同一个 seq：编号不同，订单号不冲突
+	res, err = eng.Submit(ctx, "ETHUSDT", Command{Type: CmdSubmitLimit, ReqID: 1, UserID: 1, Side: Sell, Price: 100, Qty: 5})
+	if err != nil || res.OrderID != EngineOrderID(2, 1) || res.OrderID == EngineOrderID(1, 1) {
+		t.Fatalf("other symbol: %+v %v", res, err)
+	}
+	res, err = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 2, Side: Buy, Price: 100, Qty: 2})
+	if err != nil || len(res.Trades) != 1 {
+		t.Fatalf("taker: %+v %v", res, err)
+	}
+	if tr := res.Trades[0]; tr.MakerOrderID != EngineOrderID(1, 1) || tr.TradeID != TradeID(1, 1) || FillID(sym, tr) != "BTCUSDT-T281474976710657" {
+		t.Fatalf("trade=%+v", tr)
+	}
+	// 调用方自带的订单号不能落在引擎号段
+	res, err = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: EngineOrderID(1, 99), UserID: 3, Side: Buy, Price: 90, Qty: 1})
+	if err != nil || !res.Rejected || res.Code != RejectBadParams {
+		t.Fatalf("engine id range: %+v %v", res, err)
+	}
+	res, err = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 4, UserID: 3, Side: Buy, Price: 90, Qty: 1})
+	if err != nil || res.OrderID != EngineOrderID(1, 4) {
+		t.Fatalf("seq 4: %+v %v", res, err)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// 重启（快照 + WAL 尾部）后挂单的订单号、客户端订单号不变，成交序号接着快照往下分配
+	eng2 := NewEngine(cfg)
+	defer func() {
+		eng2.Stop()
+		time.Sleep(20 * time.Millisecond)
+	}()
+	o, ok, err := eng2.Order(ctx, sym, EngineOrderID(1, 1))
+	if err != nil || !ok || o.Qty != 3 || o.ClientOrderID != 7 {
+		t.Fatalf("after restart: %+v %v %v", o, ok, err)
+	}
+	if _, ok, _ := eng2.Order(ctx, sym, EngineOrderID(1, 4)); !ok {
+		t.Fatal("seq 4 order lost")
+	}
+	res, err = eng2.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 5, OrderID: 5, UserID: 2, Side: Buy, Price: 100, Qty: 1})
+	if err != nil || len(res.Trades) != 1 || res.Trades[0].TradeID != TradeID(1, 2) {
+		t.Fatalf("trade after restart: %+v %v", res, err)
+	}
+	full := replayWith(t, ReplayConfig{WALDir: dir, Symbol: sym, SymbolID: 1}, replTestCfg("").BookFactory)
+	rec, err := RecordedEvents(dir, sym, EvCmdCodec{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := DiffEvents(full.Events, rec); i >= 0 {
+		t.Fatalf("replay differs at #%d: %+v", i, full.Events[i])
+	}
+	tail := replayWith(t, ReplayConfig{WALDir: dir, Symbol: sym, SymbolID: 1, Snapshot: ReplayLatestSnapshot}, replTestCfg("").BookFactory)
+	if tail.FromSeq == 0 || DiffEvents(tail.Events, rec[len(rec)-len(tail.Events):]) >= 0 {
+		t.Fatalf("replay from snapshot %d differs", tail.FromSeq)
+	}
+}
+
+// 号段用完：不再分配订单号，也不再接新单（撤单照常）
+func TestIDs_Exhausted(t *testing.T) {
+	cmd := Command{Type: CmdSubmitLimit, UserID: 1, Side: Buy, Price: 1, Qty: 1}
+	if code := assignOrderID(&cmd, 1, idSeqMask+1); code != RejectIDExhausted || cmd.OrderID != 0 {
+		t.Fatalf("seq overflow: code=%v id=%d", code, cmd.OrderID)
+	}
+	if code := assignOrderID(&cmd, 1, idSeqMask); code != RejectNone || cmd.OrderID != EngineOrderID(1, idSeqMask) || !IsEngineOrderID(cmd.OrderID) {
+		t.Fatalf("last seq: code=%v id=%x", code, cmd.OrderID)
+	}
+
+	// 成交序号从快照恢复到上限
+	dir := t.TempDir()
+	orders := []RestingOrder{{OrderID: 1, UserID: 1, Side: Buy, Price: 90, Qty: 1}}
+	if err := writeSnapshot(dir, "BTCUSDT", 1, 0, symState{phase: PhaseOpen, trades: tradeSeq{n: tradeSeqLimit}}, orders, nil); err != nil {
+		t.Fatal(err)
+	}
+	eng := NewEngine(replTestCfg(dir))
+	defer func() {
+		eng.Stop()
+		time.Sleep(20 * time.Millisecond)
+	}()
+	ctx := context.Background()
+	res, err := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 1, Side: Buy, Price: 90, Qty: 1})
+	if err != nil || res.Code != RejectIDExhausted {
+		t.Fatalf("trade seq exhausted: %+v %v", res, err)
+	}
+	if res, _ := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdCancel, ReqID: 3, CancelOrderID: 1}); res.Rejected {
+		t.Fatalf("cancel: %+v", res)
+	}
+}
+
+func TestIDs_DuplicateClientOrderID(t *testing.T) {
+	cfg := replTestCfg(t.TempDir())
+	cfg.ActorCfg.BatchMax = 8
+	eng := NewEngine(cfg)
+	defer func() {
+		eng.Stop()
+		time.Sleep(20 * time.Millisecond)
+	}()
+	ctx := context.Background()
+	const sym = "BTCUSDT"
+
+	// 同一 batch 里两单同一个客户端订单号：后一单拒
+	cmds := []Command{
+		{Type: CmdSubmitLimit, ReqID: 1, UserID: 1, Side: Buy, Price: 90, Qty: 1, ClientOrderID: 5},
+		{Type: CmdSubmitLimit, ReqID: 2, UserID: 1, Side: Buy, Price: 91, Qty: 1, ClientOrderID: 5},
+		{Type: CmdSubmitLimit, ReqID: 3, UserID: 2, Side: Buy, Price: 92, Qty: 1, ClientOrderID: 5},
+	}
+	results := make([]chan Result, len(cmds))
+	for i, c := range cmds {
+		results[i] = make(chan Result, 1)
+		go func(i int, c Command) {
+			res, err := eng.Submit(ctx, sym, c)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] <- res
+		}(i, c)
+		time.Sleep(time.Millisecond)
+	}
+	var accepted, dup int
+	for _, ch := range results {
+		switch res := <-ch; {
+		case res.Accepted:
+			accepted++
+		case res.Code == RejectDupClientOrderID:
+			dup++
+		}
+	}
+	if accepted != 2 || dup != 1 {
+		t.Fatalf("accepted=%d dup=%d", accepted, dup)
+	}
+
+	// 止损单也占客户端订单号
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitStop, ReqID: 4, UserID: 2, Side: Buy, StopPrice: 200, Qty: 1, ClientOrderID: 6})
+	if err != nil || !res.Accepted {
+		t.Fatalf("stop: %+v %v", res, err)
+	}
+	res, _ = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 5, UserID: 2, Side: Buy, Price: 80, Qty: 1, ClientOrderID: 6})
+	if res.Code != RejectDupClientOrderID {
+		t.Fatalf("dup with stop: %+v", res)
+	}
+
+	// 订单结束后可以复用
+	if _, err := eng.Submit(ctx, sym, Command{Type: CmdCancelAll, ReqID: 6, UserID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 7, UserID: 1, Side: Buy, Price: 90, Qty: 1, ClientOrderID: 5})
+	if err != nil || !res.Accepted {
+		t.Fatalf("reuse after cancel: %+v %v", res, err)
+	}
+}
+
+func TestIDs_CodecCompat(t *testing.T) {
+	in := Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: EngineOrderID(0, 7), UserID: 3, Side: Buy, Price: 100, Qty: 10, ClientOrderID: 42}
+	p, _ := BinaryCMDCode{}.Encode(nil, 7, in)
+	if _, out, err := (BinaryCMDCode{}).Decode(p); err != nil || out != in {
+		t.Fatalf("cmd roundtrip: %+v %v", out, err)
+	}
+	v1 := append([]byte(nil), p[:cmdRecordLenV1]...)
+	v1[offVer] = cmdWalVersion1
+	if _, out, err := (BinaryCMDCode{}).Decode(v1); err != nil || out.ClientOrderID != 0 || out.OrderID != in.OrderID {
+		t.Fatalf("cmd v1 decode: %+v %v", out, err)
+	}
+
+	ev := Event{Seq: 9, Idx: 2, Type: EvTrade, ReqID: 1, MakerOrderID: 1, TakerOrderID: EngineOrderID(0, 9), Price: 100, Qty: 1, TradeID: legacyTradeID(9, 2)}
+	p, err := EvCmdCodec{}.Encode(nil, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := (EvCmdCodec{}).Decode(p); err != nil || out != ev {
+		t.Fatalf("roundtrip: %+v %v", out, err)
+	}
+	// v1 记录没有成交号：按当时的规则 (seq, idx) 推出来
+	v1 = append([]byte(nil), p[:evRecordLenV1]...)
+	v1[evOffVer] = evWalVersion1
+	if out, err := (EvCmdCodec{}).Decode(v1); err != nil || out != ev {
+		t.Fatalf("v1 decode: %+v %v", out, err)
+	}
+}
@@ -1,21 +1,64 @@
 package engine
 
+import (
+	"context"
+
+	"gopherex.com/internal/matching"
+)
+
 type OrderBook interface {
-	SubmitLimit(reqID, orderID, userID uint64, side uint8, price, qty int64, emit Emitter)
+	Submit(reqID uint64, o OrderSpec, emit Emitter)
 	Cancel(reqID, orderID uint64, emit Emitter) bool
+	// Amend：结果（Amended/Rejected/穿价产生的 Trade）都由 book 自己 emit
+	Amend(reqID uint64, a AmendSpec, emit Emitter)
+	// CancelAllForUser：撤掉该用户所有挂单（每单一个 Cancelled），返回撤单数
+	CancelAllForUser(reqID, userID uint64, emit Emitter) int
 }
+
+// AuctionBook：支持集合竞价的订单簿（可选能力）
+// 竞价期间 Submit 只挂单不撮合；Uncross 成交结果以 Trade 事件 emit
+type AuctionBook interface {
+	StartAuction()
+	Uncross(reqID uint64, emit Emitter) (price, qty int64)
+}
+
+// STPBook：支持自成交防护的订单簿（可选能力）
+// 模式只经 WAL 里的 CmdSetSTP / 快照换，保证回放和线上撮合一致
+type STPBook interface {
+	SetSTP(mode matching.STPMode)
+}
+
+// LastPriceBook：能设回最新成交价的订单簿（可选能力，快照恢复用；价格带校验和竞价参考价读它）
+type LastPriceBook interface {
+	SetLastPrice(px int64)
+}
+
 type Emitter interface {
 	Accepted(reqID uint64, orderID, userID uint64)
-	Rejected(reqID uint64, orderID, userID uint64, reason string)
+	Rejected(reqID uint64, orderID, userID uint64, code RejectCode)
 	Added(reqID uint64, orderID, userID uint64)
 	Cancelled(reqID uint64, orderID uint64)
-	Trade(reqID uint64, makerOrderID, takerOrderID uint64, price, qty int64)
+	// Trade：takerSide 是主动方方向；集合竞价没有主动方为 0（taker 位置放买单）；成交双方用户用于算手续费
+	Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64)
+	Expired(reqID uint64, orderID, userID uint64, qty int64)
+	Amended(reqID uint64, orderID, userID uint64, price, qty int64)
+	// SelfTradePrevented：STP 从 orderID 上撤掉/减掉 qty（资金侧据此解冻）
+	SelfTradePrevented(reqID uint64, orderID, userID, makerOrderID, takerOrderID uint64, qty int64)
+	PhaseChanged(reqID uint64, phase Phase)
+	// 止损单：受理 / 触发（price=触发成交价）/ 触发前撤单
+	StopAccepted(reqID uint64, orderID, userID uint64, stopPrice, qty int64)
+	StopTriggered(reqID uint64, orderID, userID uint64, price, qty int64)
+	StopCancelled(reqID uint64, orderID, userID uint64)
+	// Replenished：冰山单可见部分吃完，补了 qty 张重新排队（只给可见数量，不暴露隐藏量）
+	Replenished(reqID uint64, orderID uint64, qty int64)
 }
 
-// EventSink：下游“可能慢”，所以只提供 TryPublish（非阻塞）
-// V1：关键事件会接 WAL/专用通道
+// EventSink：OutboxPublisher 的下游（进程内 ChanBus / NATS / Redis Streams ...）
+// - PublishBatch 阻塞到下游确认整批才返回 nil，publisher 随后才推进 .ev.cursor
+// - 返回 error：publisher 回到 cursor 重发（at-least-once），下游按 EventID 去重
+// - 一批只含同一 symbol 的完整命令，按 (Seq, Idx) 升序
 type EventSink interface {
-	TryPublish(ev Event)
+	PublishBatch(ctx context.Context, symbol string, evs []Event) error
 }
 
 type CmdCodec interface {
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// 风控 kill-switch：冻结用户 + 跨交易对撤掉其全部挂单
+// - 冻结名单是引擎级状态（所有 actor 共享），不进 cmd WAL / 快照；配了 WALDir 时每次变化整份写到 WALDir/blocked_users，
+//   NewEngine 时读回来，重启后仍然冻结
+// - 备库不复制这个文件：切主后用旧主上的 BlockedUsers（或风控自己的名单）在新主上重新 KillUser
+// - 拦截分两层：Engine 入口直接返回 ErrUserBlocked（不占 mailbox/WAL）；
+//   actor precheck 再兜一次，拦住冻结前已经入队的提交（拒单码随命令落 WAL，回放一致）
+// - 撤单走各 actor 的 CmdCancelAll：同样写 cmd WAL，每张被撤的单一个 Cancelled/StopCancelled 事件
+
+// userBlocklist：冻结用户集合（并发安全）；path 非空时每次变化落盘
+type userBlocklist struct {
+	mu   sync.RWMutex
+	m    map[uint64]struct{}
+	path string
+}
+
+// newUserBlocklist：walDir 非空时从 walDir/blocked_users 读回上次的名单（没有文件就是空名单）
+func newUserBlocklist(walDir string) *userBlocklist {
+	l := &userBlocklist{m: make(map[uint64]struct{})}
+	if walDir == "" {
+		return l
+	}
+	l.path = blockedUsersPath(walDir)
+	for _, u := range loadBlockedUsers(l.path) {
+		l.m[u] = struct{}{}
+	}
+	return l
+}
+
+// add/remove：先改内存再落盘；落盘失败时内存里已生效，返回错误让调用方重试（整份重写，重试是幂等的）
+func (l *userBlocklist) add(userID uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.m[userID] = struct{}{}
+	return l.store()
+}
+
+func (l *userBlocklist) remove(userID uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.m, userID)
+	return l.store()
+}
+
+// list：冻结的用户（升序）
+func (l *userBlocklist) list() []uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.sorted()
+}
+
+// store：持 mu 调用
+func (l *userBlocklist) store() error {
+	if l.path == "" {
+		return nil
+	}
+	return storeBlockedUsers(l.path, l.sorted())
+}
+
+func (l *userBlocklist) sorted() []uint64 {
+	out := make([]uint64, 0, len(l.m))
+	for u := range l.m {
+		out = append(out, u)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func (l *userBlocklist) has(userID uint64) bool {
+	if l == nil || userID == 0 {
+		return false
+	}
+	l.mu.RLock()
+	_, ok := l.m[userID]
+	l.mu.RUnlock()
+	return ok
+}
+
+// opensRisk：会新增风险敞口的命令（冻结用户不允许）；撤单/控制命令不受影响
+func opensRisk(t CmdType) bool {
+	switch t {
+	case CmdSubmitLimit, CmdSubmitMarket, CmdSubmitStop, CmdAmend:
+		return true
+	}
+	return false
+}
+
+// MassCancelResult：跨交易对撤单的汇总结果
+type MassCancelResult struct {
+	Cancelled int               // 撤掉的订单数（含未触发的止损单）
+	Symbols   map[string]Result // 每个交易对上 CmdCancelAll 的结果
+}
+
+// CancelAllForUser：在所有交易对上撤掉该用户的挂单与止损单，全部 actor 处理完才返回
+// 交易对范围 = 已启动的 actor ∪ 注册表里的交易对（后者会触发恢复，保证磁盘上的挂单也被撤）
+// 部分交易对失败时仍返回其余结果，error 汇总失败的交易对
+func (e *Engine) CancelAllForUser(ctx context.Context, userID uint64) (MassCancelResult, error) {
+	if userID == 0 {
+		return MassCancelResult{}, ErrBadCommand
+	}
+	syms := e.allSymbols()
+	res := MassCancelResult{Symbols: make(map[string]Result, len(syms))}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, sym := range syms {
+		wg.Add(1)
+		go func(sym string) {
+			defer wg.Done()
+			r, err := e.Submit(ctx, sym, Command{Type: CmdCancelAll, UserID: userID})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", sym, err))
+				return
+			}
+			res.Symbols[sym] = r
+			for _, ev := range r.Events {
+				if ev.Type == EvCancelled || ev.Type == EvStopCxl {
+					res.Cancelled++
+				}
+			}
+		}(sym)
+	}
+	wg.Wait()
+	return res, errors.Join(errs...)
+}
+
+// KillUser：先冻结再撤单（顺序不能反：否则撤单之后、冻结之前的新单会漏掉）
+// 名单落盘失败时冻结仍在内存里生效、照常撤单，错误和撤单错误一起返回（重启前要重试）
+func (e *Engine) KillUser(ctx context.Context, userID uint64) (MassCancelResult, error) {
+	if userID == 0 {
+		return MassCancelResult{}, ErrBadCommand
+	}
+	errStore := e.blocked.add(userID)
+	res, err := e.CancelAllForUser(ctx, userID)
+	if errStore != nil {
+		err = errors.Join(fmt.Errorf("kill-switch: persist blocklist: %w", errStore), err)
+	}
+	return res, err
+}
+
+// UnblockUser：解除冻结（已撤的单不会恢复）；名单落盘失败时内存里已解除，返回错误
+func (e *Engine) UnblockUser(userID uint64) error {
+	if err := e.blocked.remove(userID); err != nil {
+		return fmt.Errorf("kill-switch: persist blocklist: %w", err)
+	}
+	return nil
+}
+
+func (e *Engine) UserBlocked(userID uint64) bool { return e.blocked.has(userID) }
+
+// BlockedUsers：当前冻结的用户（升序）：切主/迁移时拿去在新引擎上重新 KillUser
+func (e *Engine) BlockedUsers() []uint64 { return e.blocked.list() }
+
+func blockedUsersPath(walDir string) string {
+	return filepath.Join(walDir, "blocked_users")
+}
+
+// blocked_users 文件：userID(8) 依次排列，little endian；tmp + rename 整份替换
+func loadBlockedUsers(path string) []uint64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	out := make([]uint64, 0, len(b)/8)
+	for ; len(b) >= 8; b = b[8:] {
+		out = append(out, binary.LittleEndian.Uint64(b[:8]))
+	}
+	return out
+}
+
+func storeBlockedUsers(path string, users []uint64) error {
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	b := make([]byte, 0, 8*len(users))
+	for _, u := range users {
+		b = binary.LittleEndian.AppendUint64(b, u)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// allSymbols：已启动的 actor + 注册表里的交易对（去重）
+func (e *Engine) allSymbols() []string {
+	seen := make(map[string]struct{})
+	var out []string
+	e.mu.RLock()
+	for sym := range e.actors {
+		seen[sym] = struct{}{}
+		out = append(out, sym)
+	}
+	e.mu.RUnlock()
+	if e.cfg.Symbols != nil {
+		for _, sym := range e.cfg.Symbols.Symbols() {
+			if _, ok := seen[sym]; !ok {
+				out = append(out, sym)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestKillSwitch_CancelAcrossSymbolsAndBlock(t *testing.T) {
+	dir := t.TempDir()
+	newCfg := func() EngineConfig {
+		return EngineConfig{
+			WALDir:       dir,
+			EnableCmdWAL: true,
+			CmdCodec:     BinaryCMDCode{},
+			ActorCfg:     ActorConfig{MailboxSize: 64, BatchMax: 8},
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		}
+	}
+	ctx := context.Background()
+	eng := NewEngine(newCfg())
+	for _, c := range []struct {
+		sym string
+		cmd Command
+	}{
+		{"BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 7, Side: Sell, Price: 101, Qty: 1}},
+		{"BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Sell, Price: 102, Qty: 1}},
+		{"BTCUSDT", Command{Type: CmdSubmitStop, ReqID: 3, OrderID: 3, UserID: 7, Side: Buy, StopPrice: 110, Qty: 1}},
+		{"ETHUSDT", Command{Type: CmdSubmitLimit, ReqID: 4, OrderID: 4, UserID: 7, Side: Buy, Price: 50, Qty: 2}},
+	} {
+		if _, err := eng.Submit(ctx, c.sym, c.cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := eng.KillUser(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Cancelled != 3 || len(res.Symbols) != 2 {
+		t.Fatalf("mass cancel=%+v", res)
+	}
+	if !eng.UserBlocked(7) {
+		t.Fatal("user 7 should be blocked")
+	}
+
+	// 入口拦截；撤单不受影响
+	_, err = eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 5, OrderID: 5, UserID: 7, Side: Buy, Price: 99, Qty: 1})
+	if !errors.Is(err, ErrUserBlocked) {
+		t.Fatalf("submit err=%v", err)
+	}
+	if err := eng.TrySubmit("BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 5, OrderID: 5, UserID: 7, Side: Buy, Price: 99, Qty: 1}); !errors.Is(err, ErrUserBlocked) {
+		t.Fatalf("try submit err=%v", err)
+	}
+	// 冻结前已入队的提交：actor precheck 兜底拒单
+	a, _ := eng.getOrCreateActor("BTCUSDT")
+	reply := make(chan Result, 1)
+	if err := a.Enqueue(ctx, Command{Type: CmdSubmitLimit, ReqID: 6, OrderID: 6, UserID: 7, Side: Buy, Price: 99, Qty: 1, reply: reply}); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-reply; !r.Rejected || r.Code != RejectUserBlocked {
+		t.Fatalf("in-flight submit=%+v", r)
+	}
+
+	if err := eng.UnblockUser(7); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := eng.Submit(ctx, "ETHUSDT", Command{Type: CmdSubmitLimit, ReqID: 7, OrderID: 7, UserID: 7, Side: Buy, Price: 49, Qty: 1}); err != nil || !r.Accepted {
+		t.Fatalf("after unblock=%+v err=%v", r, err)
+	}
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// 撤单写进了 cmd WAL：重启回放后 user 7 在 BTC 上没有挂单，user 8 的还在
+	eng2 := NewEngine(newCfg())
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	open, err := eng2.OpenOrders(ctx, "BTCUSDT", 7)
+	if err != nil || len(open) != 0 {
+		t.Fatalf("user 7 after replay=%+v err=%v", open, err)
+	}
+	if open, _ := eng2.OpenOrders(ctx, "BTCUSDT", 8); len(open) != 1 {
+		t.Fatalf("user 8 after replay=%+v", open)
+	}
+}
+
+// 冻结名单落盘：重启后仍然冻结，解除后重启也不再冻结
+func TestKillSwitch_BlockSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	ctx := context.Background()
+	restart := func(eng *Engine) *Engine {
+		eng.Stop()
+		time.Sleep(20 * time.Millisecond)
+		return NewEngine(cfg)
+	}
+	eng := NewEngine(cfg)
+	for _, u := range []uint64{9, 7} {
+		if _, err := eng.KillUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eng = restart(eng)
+	if got := eng.BlockedUsers(); !slices.Equal(got, []uint64{7, 9}) {
+		t.Fatalf("blocked after restart=%v", got)
+	}
+	if _, err := eng.Submit(ctx, "BTCUSDT", Command{Type: CmdSubmitLimit, ReqID: 1, UserID: 7, Side: Buy, Price: 99, Qty: 1}); !errors.Is(err, ErrUserBlocked) {
+		t.Fatalf("submit after restart err=%v", err)
+	}
+
+	if err := eng.UnblockUser(7); err != nil {
+		t.Fatal(err)
+	}
+	eng = restart(eng)
+	defer func() {
+		eng.Stop()
+		time.Sleep(20 * time.Millisecond)
+	}()
+	if eng.UserBlocked(7) || !eng.UserBlocked(9) {
+		t.Fatalf("blocked after unblock + restart=%v", eng.BlockedUsers())
+	}
+}
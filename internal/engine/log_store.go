@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"os"
+
+	"gopherex.com/pkg/wal"
+)
+
+// cmd.wal / ev.wal 可以是单文件，也可以是分段 WAL（<path>.manifest + 段文件）
+// - 写端：按配置决定；磁盘上已经是分段布局时始终按分段打开，避免两种布局混写
+// - 读端/修复：按磁盘布局自动识别，切换配置不影响读历史数据
+
+// logReader：单文件 Reader 与 SegmentedReader 的公共部分
+type logReader interface {
+	Next() (payload []byte, nextOffset int64, err error)
+	Close() error
+	TruncatedTail() bool
+	LastGoodOffset() int64
+}
+
+func openLogWriter(path string, bufSize int, seg *wal.SegmentOptions) (walWriter, error) {
+	if seg == nil && !wal.IsSegmented(path) {
+		return wal.OpenWrite(path, bufSize)
+	}
+	var opts wal.SegmentOptions
+	if seg != nil {
+		opts = *seg
+	}
+	opts.BufSize = bufSize
+	return wal.OpenSegmented(path, opts)
+}
+
+func openLogReader(path string, off int64, opts wal.ReaderOptions) (logReader, error) {
+	if wal.IsSegmented(path) {
+		return wal.OpenSegmentedReader(path, off, opts)
+	}
+	return wal.OpenReader(path, off, opts)
+}
+
+func replayLog(path string, opts wal.ReplayOptions, onRecord func(payload []byte) error) (wal.ReplayStats, error) {
+	if wal.IsSegmented(path) {
+		return wal.ReplaySegmented(path, opts, onRecord)
+	}
+	return wal.Replay(path, opts, onRecord)
+}
+
+func truncateLog(path string, off int64) error {
+	if wal.IsSegmented(path) {
+		return wal.TruncateSegmentedTo(path, off)
+	}
+	return wal.TruncateTo(path, off)
+}
+
+// logSize：日志逻辑末尾偏移；不存在返回 os.ErrNotExist
+func logSize(path string) (int64, error) {
+	if wal.IsSegmented(path) {
+		return wal.SegmentedSize(path)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// logStart：日志当前最早可读的逻辑偏移（分段 WAL 清理过旧段后 > 0）
+func logStart(path string) (int64, error) {
+	if !wal.IsSegmented(path) {
+		return 0, nil
+	}
+	m, err := wal.LoadManifest(path)
+	if err != nil || len(m.Segments) == 0 {
+		return 0, err
+	}
+	return m.Segments[0].First, nil
+}
+
+func logExists(path string) bool {
+	_, err := logSize(path)
+	return err == nil
+}
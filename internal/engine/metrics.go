@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopherex.com/pkg/metrics"
+)
+
+// actor 指标（pkg/metrics，按 symbol 打标签）
+// 建 actor 时把各 collector 的 label 取好，热路径上不再查 label；nil 表示不采集（直接 NewSymbolActor 的测试）
+type actorMetrics struct {
+	symbol      string
+	batchSize   prometheus.Observer
+	cmdFlush    prometheus.Observer
+	evFlush     prometheus.Observer
+	cmdLatency  prometheus.Observer
+	mailbox     prometheus.Gauge
+	mailboxFull prometheus.Counter
+}
+
+func newActorMetrics(symbol string) *actorMetrics {
+	return &actorMetrics{
+		symbol:      symbol,
+		batchSize:   metrics.EngineBatchSize.WithLabelValues(symbol),
+		cmdFlush:    metrics.EngineWALFlushDuration.WithLabelValues(symbol, "cmd"),
+		evFlush:     metrics.EngineWALFlushDuration.WithLabelValues(symbol, "ev"),
+		cmdLatency:  metrics.EngineCmdLatency.WithLabelValues(symbol),
+		mailbox:     metrics.EngineMailboxDepth.WithLabelValues(symbol),
+		mailboxFull: metrics.EngineMailboxFull.WithLabelValues(symbol),
+	}
+}
+
+func (m *actorMetrics) batch(n, queued int) {
+	if m == nil {
+		return
+	}
+	m.batchSize.Observe(float64(n))
+	m.mailbox.Set(float64(queued))
+}
+
+func (m *actorMetrics) full() {
+	if m != nil {
+		m.mailboxFull.Inc()
+	}
+}
+
+func (m *actorMetrics) walFlushed(start time.Time) {
+	if m != nil {
+		m.cmdFlush.Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *actorMetrics) outboxFlushed(start time.Time) {
+	if m != nil {
+		m.evFlush.Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *actorMetrics) reject(code RejectCode) {
+	metrics.EngineRejects.WithLabelValues(m.symbol, code.String()).Inc()
+}
+
+// snapshotFailed：快照写入 / WAL 段清理失败（stage: write | retain）
+func (m *actorMetrics) snapshotFailed(stage string) {
+	if m != nil {
+		metrics.EngineSnapshotFailures.WithLabelValues(m.symbol, stage).Inc()
+	}
+}
+
+// durable：batch 的事件已落盘，按入队时间统计每条命令的延迟
+func (m *actorMetrics) durable(batch []Command, now time.Time) {
+	if m == nil {
+		return
+	}
+	ns := now.UnixNano()
+	for i := range batch {
+		if t := batch[i].enqAt; t > 0 {
+			m.cmdLatency.Observe(float64(ns-t) / 1e9)
+		}
+	}
+}
+
+// rejectCounter：按原因统计拒单（只包 actor 在线路径的 emitter，回放补齐不计数）
+type rejectCounter struct {
+	Emitter
+	m *actorMetrics
+}
+
+func (e rejectCounter) Rejected(reqID uint64, orderID, userID uint64, code RejectCode) {
+	e.m.reject(code)
+	e.Emitter.Rejected(reqID, orderID, userID, code)
+}
+
+// publisherLag：sink 已确认位置与 outbox 末尾的差距（字节 / 命令数）
+type publisherLag struct {
+	bytes prometheus.Gauge
+	seq   prometheus.Gauge
+}
+
+func newPublisherLag(symbol string) *publisherLag {
+	return &publisherLag{
+		bytes: metrics.EnginePublisherLagBytes.WithLabelValues(symbol),
+		seq:   metrics.EnginePublisherLagSeq.WithLabelValues(symbol),
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/metrics"
+)
+
+func histCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := o.(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetrics_ActorAndPublisher(t *testing.T) {
+	const sym = "METRICSUSDT" // 指标是进程级的，用独立 symbol 避免和其他用例串
+	dir := t.TempDir()
+	sink := &flakySink{fail: 1 << 30, curPath: outboxCursorPath(dir, sym)}
+	eng := NewEngine(EngineConfig{
+		WALDir:          dir,
+		EnableCmdWAL:    true,
+		EnableOutbox:    true,
+		EnablePublisher: true,
+		PublisherPoll:   5 * time.Millisecond,
+		EventSink:       sink,
+		CmdCodec:        BinaryCMDCode{},
+		EvCodec:         EvCmdCodec{},
+		ActorCfg:        ActorConfig{MailboxSize: 64, BatchMax: 8},
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+	defer eng.Stop()
+
+	// 指标是累计值（-count>1 时会叠加），按增量断言
+	batch0 := histCount(t, metrics.EngineBatchSize.WithLabelValues(sym))
+	cmdFlush0 := histCount(t, metrics.EngineWALFlushDuration.WithLabelValues(sym, "cmd"))
+	evFlush0 := histCount(t, metrics.EngineWALFlushDuration.WithLabelValues(sym, "ev"))
+	latency0 := histCount(t, metrics.EngineCmdLatency.WithLabelValues(sym))
+	rejects0 := testutil.ToFloat64(metrics.EngineRejects.WithLabelValues(sym, RejectOrderNotFound.String()))
+
+	ctx := context.Background()
+	for i := uint64(1); i <= 3; i++ {
+		if _, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: i, OrderID: i, UserID: 1, Side: Buy, Price: 100, Qty: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := eng.Submit(ctx, sym, Command{Type: CmdCancel, ReqID: 4, UserID: 1, CancelOrderID: 99}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := histCount(t, metrics.EngineBatchSize.WithLabelValues(sym)) - batch0; n != 4 {
+		t.Fatalf("batch samples=%d", n)
+	}
+	if n := histCount(t, metrics.EngineWALFlushDuration.WithLabelValues(sym, "cmd")) - cmdFlush0; n != 4 {
+		t.Fatalf("cmd flush samples=%d", n)
+	}
+	if n := histCount(t, metrics.EngineWALFlushDuration.WithLabelValues(sym, "ev")) - evFlush0; n != 4 {
+		t.Fatalf("ev flush samples=%d", n)
+	}
+	if n := histCount(t, metrics.EngineCmdLatency.WithLabelValues(sym)) - latency0; n != 4 {
+		t.Fatalf("latency samples=%d", n)
+	}
+	if v := testutil.ToFloat64(metrics.EngineRejects.WithLabelValues(sym, RejectOrderNotFound.String())) - rejects0; v != 1 {
+		t.Fatalf("rejects=%v", v)
+	}
+
+	// sink 一直失败：lag = 全部 4 条命令
+	waitGauge := func(g prometheus.Gauge, ok func(float64) bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !ok(testutil.ToFloat64(g)) {
+			if time.Now().After(deadline) {
+				t.Fatalf("gauge=%v", testutil.ToFloat64(g))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitGauge(metrics.EnginePublisherLagSeq.WithLabelValues(sym), func(v float64) bool { return v == 4 })
+	waitGauge(metrics.EnginePublisherLagBytes.WithLabelValues(sym), func(v float64) bool { return v > 0 })
+
+	// sink 恢复后追平
+	sink.mu.Lock()
+	sink.fail = 0
+	sink.mu.Unlock()
+	waitGauge(metrics.EnginePublisherLagSeq.WithLabelValues(sym), func(v float64) bool { return v == 0 })
+	waitGauge(metrics.EnginePublisherLagBytes.WithLabelValues(sym), func(v float64) bool { return v == 0 })
+}
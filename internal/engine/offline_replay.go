@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 离线回放（事故排查）：把某个 symbol 的 cmd WAL（可选先加载快照）喂给任意 BookFactory，
+// 拿到完整事件流和最终簿；只读，不写 WAL/outbox/快照
+// 回放复用引擎恢复路径（replayCmdWAL + applyCommand），结果应与线上逐字节一致：
+// 和录制的 ev.wal 比对能证明确定性，和另一种簿实现比对能定位撮合差异
+
+type ReplayConfig struct {
+	WALDir      string
+	Symbol      string
+	BookFactory BookFactory
+	CmdCodec    CmdCodec
+	// Snapshot：空表示从 WAL 头开始；"latest" 用 WALDir 里最新的有效快照；其他值是快照文件路径
+	// WAL 头部被清理过时必须给快照，否则结果不完整
+	Snapshot string
+	UntilSeq uint64 // >0 时只回放到该 seq（含）
+	// Fees：起始费率表（和线上配置一致时才能和老 WAL 比对）；快照里有费率表时以快照为准，之后按 WAL 里的 CmdSetFees 换；nil 不算
+	Fees *FeeSchedule
+	// SymbolID：交易对编号（SymbolSpec.ID），成交号的高位；和线上配置一致时才能和 ev.wal 比对
+	SymbolID uint16
+}
+
+type ReplayResult struct {
+	FromSeq uint64  // 快照 seq（0 表示没有快照）；Events 从 FromSeq+1 开始
+	LastSeq uint64  // 最后回放的 seq
+	Phase   Phase   // 最终交易阶段
+	Events  []Event // 按 (Seq, Idx) 顺序，不含 CmdEnd
+	Orders  []RestingOrder
+	Stops   []StopOrder
+}
+
+const ReplayLatestSnapshot = "latest"
+
+var errReplayDone = errors.New("replay: until seq reached")
+
+// Replay：按 cfg 回放一遍；book 不支持快照时只能从 WAL 头开始
+func Replay(cfg ReplayConfig) (*ReplayResult, error) {
+	if cfg.WALDir == "" || cfg.Symbol == "" || cfg.BookFactory == nil || cfg.CmdCodec == nil {
+		return nil, errors.New("replay: WALDir/Symbol/BookFactory/CmdCodec required")
+	}
+	book, err := cfg.BookFactory(cfg.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	res := &ReplayResult{}
+	stops := newStopBook()
+	st := symState{trades: tradeSeq{sym: cfg.SymbolID}, fees: cfg.Fees}
+
+	if cfg.Snapshot != "" {
+		h, orders, stopOrders, err := readReplaySnapshot(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if h.seq > 0 {
+			sb, ok := book.(BookSnapshotter)
+			if !ok {
+				return nil, ErrSnapshotUnsupported
+			}
+			sb.RestoreOrders(orders)
+			stops.restore(stopOrders)
+			h.restore(&st)
+			setBookSTP(book, st.stp)
+			setBookLastPrice(book, st.lastPx)
+			res.FromSeq = h.seq
+			if st.phase == PhaseAuction {
+				if ab, ok := book.(AuctionBook); ok {
+					ab.StartAuction()
+				}
+			}
+		}
+	}
+
+	// 收集型 outbox：lastCompleteSeq=0，每条命令都走 outboxEmitter，事件序号与线上一致
+	out := &collectOutbox{}
+	code := cfg.CmdCodec
+	if cfg.UntilSeq > 0 {
+		code = untilCodec{CmdCodec: code, until: cfg.UntilSeq}
+	}
+	_, err = replayCmdWAL(cmdWalPath(cfg.WALDir, cfg.Symbol), book, stops, out, res.FromSeq, 0, code, &st)
+	if err != nil && !errors.Is(err, errReplayDone) {
+		return nil, err
+	}
+	res.LastSeq, res.Phase, res.Events = max(out.last, res.FromSeq), st.phase, out.events
+	if sb, ok := book.(BookSnapshotter); ok {
+		res.Orders = sb.SnapshotOrders()
+	}
+	res.Stops = stops.orders()
+	return res, nil
+}
+
+func readReplaySnapshot(cfg ReplayConfig) (h snapHeader, orders []RestingOrder, stops []StopOrder, err error) {
+	if cfg.Snapshot == ReplayLatestSnapshot {
+		return loadLatestSnapshot(cfg.WALDir, cfg.Symbol)
+	}
+	b, err := os.ReadFile(cfg.Snapshot)
+	if err != nil {
+		return snapHeader{}, nil, nil, err
+	}
+	h, orders, stops, err = decodeSnapshot(b)
+	if err != nil {
+		return snapHeader{}, nil, nil, fmt.Errorf("%s: %w", cfg.Snapshot, err)
+	}
+	return h, orders, stops, nil
+}
+
+// collectOutbox：只在内存里收集事件
+type collectOutbox struct {
+	last   uint64
+	events []Event
+}
+
+func (o *collectOutbox) Append(ev Event) error {
+	o.events = append(o.events, ev)
+	return nil
+}
+
+func (o *collectOutbox) AppendCmdEnd(seq uint64) error {
+	o.last = seq
+	return nil
+}
+
+func (o *collectOutbox) Flush() error { return nil }
+func (o *collectOutbox) Close() error { return nil }
+
+// untilCodec：解码到 seq > until 就中止回放（在 apply 之前，簿停在 until）
+type untilCodec struct {
+	CmdCodec
+	until uint64
+}
+
+func (c untilCodec) Decode(payload []byte) (uint64, Command, error) {
+	seq, cmd, err := c.CmdCodec.Decode(payload)
+	if err == nil && seq > c.until {
+		return 0, Command{}, errReplayDone
+	}
+	return seq, cmd, err
+}
+
+// RecordedEvents：读 ev.wal 里 (fromSeq, untilSeq] 的事件（untilSeq=0 读到末尾），用来和回放结果比对
+func RecordedEvents(walDir, symbol string, codec EvCodec, fromSeq, untilSeq uint64) ([]Event, error) {
+	r, err := OpenEventReader(walDir, symbol, codec, EventPos{Seq: fromSeq + 1})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var out []Event
+	for {
+		evs, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if untilSeq > 0 && evs[0].Seq > untilSeq {
+			return out, nil
+		}
+		out = append(out, evs...)
+	}
+}
+
+// DiffEvents：第一处不同的下标；完全一致返回 -1（长度不同时返回较短一方的长度）
+func DiffEvents(a, b []Event) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
+
+// DiffOrders：同 DiffEvents，比较最终簿（两边都是价格优先 + 同价 FIFO 顺序）
+// 入队序号（Seq）的数值随簿实现而不同（没挂上簿的单也可能占号），不比
+func DiffOrders(a, b []RestingOrder) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, y := a[i], b[i]
+		x.Seq, y.Seq = 0, 0
+		if x != y {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
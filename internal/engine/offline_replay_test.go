@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// 覆盖对照簿支持的公共子集：限价/市价、IOC/FOK、PostOnly、改单（含穿价）、撤单、全撤、止损
+var replayCmds = []Command{
+	{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 1, Side: Sell, Price: 101, Qty: 5},
+	{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 2, Side: Sell, Price: 102, Qty: 5},
+	{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 3, Side: Buy, Price: 99, Qty: 4},
+	{Type: CmdSubmitLimit, ReqID: 4, OrderID: 4, UserID: 3, Side: Buy, Price: 98, Qty: 4},
+	{Type: CmdSubmitStop, ReqID: 5, OrderID: 5, UserID: 5, Side: Buy, StopPrice: 102, Price: 103, Qty: 2},
+	{Type: CmdSubmitLimit, ReqID: 6, OrderID: 6, UserID: 6, Side: Buy, Price: 102, Qty: 7},
+	{Type: CmdSubmitLimit, ReqID: 7, OrderID: 7, UserID: 7, Side: Sell, Price: 99, Qty: 1, PostOnly: true},
+	{Type: CmdSubmitLimit, ReqID: 8, OrderID: 8, UserID: 8, Side: Sell, Price: 90, Qty: 20, TIF: TifFOK},
+	{Type: CmdSubmitMarket, ReqID: 9, OrderID: 9, UserID: 9, Side: Sell, Qty: 3},
+	{Type: CmdAmend, ReqID: 10, OrderID: 4, UserID: 3, Qty: 2},
+	{Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 11, Side: Sell, Price: 105, Qty: 3},
+	{Type: CmdAmend, ReqID: 12, OrderID: 4, UserID: 3, Price: 106},
+	{Type: CmdSubmitLimit, ReqID: 13, OrderID: 13, UserID: 3, Side: Buy, Price: 97, Qty: 1},
+	{Type: CmdSubmitLimit, ReqID: 14, OrderID: 14, UserID: 14, Side: Buy, Price: 96, Qty: 2, TIF: TifIOC},
+	{Type: CmdCancelAll, ReqID: 15, UserID: 3},
+	{Type: CmdCancel, ReqID: 16, UserID: 1, CancelOrderID: 99},
+	{Type: CmdSubmitLimit, ReqID: 17, OrderID: 17, UserID: 17, Side: Sell, Price: 110, Qty: 2},
+}
+
+func recordReplayWAL(t *testing.T, snapshotEvery uint64) string {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	cfg.SnapshotEvery, cfg.SnapshotKeep = snapshotEvery, 8
+	eng := NewEngine(cfg)
+	for _, c := range replayCmds {
+		if _, err := eng.Submit(context.Background(), "BTCUSDT", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+	return dir
+}
+
+func replayWith(t *testing.T, cfg ReplayConfig, f BookFactory) *ReplayResult {
+	t.Helper()
+	cfg.BookFactory, cfg.CmdCodec = f, BinaryCMDCode{}
+	res, err := Replay(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestReplay_MatchesRecordedAndReferenceBooks(t *testing.T) {
+	dir := recordReplayWAL(t, 0)
+	cfg := ReplayConfig{WALDir: dir, Symbol: "BTCUSDT"}
+
+	heap := replayWith(t, cfg, replTestCfg("").BookFactory)
+	if heap.LastSeq != uint64(len(replayCmds)) {
+		t.Fatalf("last seq=%d", heap.LastSeq)
+	}
+	rec, err := RecordedEvents(dir, "BTCUSDT", EvCmdCodec{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := DiffEvents(heap.Events, rec); i >= 0 {
+		t.Fatalf("replay differs from ev.wal at #%d: %+v", i, heap.Events[i])
+	}
+	if len(heap.Stops) != 0 {
+		t.Fatalf("stop not triggered: %+v", heap.Stops)
+	}
+
+	for _, name := range []string{BookSkipList, BookLevel, BookNaive} {
+		f, err := NewBookFactory(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := replayWith(t, cfg, f)
+		if i := DiffEvents(heap.Events, ref.Events); i >= 0 {
+			t.Fatalf("%s events differ at #%d: heap=%+v", name, i, heap.Events[i])
+		}
+		if i := DiffOrders(heap.Orders, ref.Orders); i >= 0 {
+			t.Fatalf("%s book differs at #%d: heap=%+v %s=%+v", name, i, heap.Orders, name, ref.Orders)
+		}
+	}
+}
+
+func TestReplay_FromSnapshotUntilSeq(t *testing.T) {
+	dir := recordReplayWAL(t, 5)
+	full := replayWith(t, ReplayConfig{WALDir: dir, Symbol: "BTCUSDT", UntilSeq: 12}, replTestCfg("").BookFactory)
+	if full.LastSeq != 12 || full.Events[len(full.Events)-1].Seq != 12 {
+		t.Fatalf("until: last=%d", full.LastSeq)
+	}
+
+	snap := snapshotPath(dir, "BTCUSDT", 5)
+	part := replayWith(t, ReplayConfig{WALDir: dir, Symbol: "BTCUSDT", Snapshot: snap, UntilSeq: 12}, replTestCfg("").BookFactory)
+	if part.FromSeq != 5 || part.Events[0].Seq != 6 {
+		t.Fatalf("from=%d first=%+v", part.FromSeq, part.Events[0])
+	}
+	if i := DiffOrders(full.Orders, part.Orders); i >= 0 {
+		t.Fatalf("book differs at #%d: full=%+v part=%+v", i, full.Orders, part.Orders)
+	}
+	rec, err := RecordedEvents(dir, "BTCUSDT", EvCmdCodec{}, part.FromSeq, part.LastSeq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := DiffEvents(part.Events, rec); i >= 0 {
+		t.Fatalf("differs from ev.wal at #%d", i)
+	}
+
+	latest := replayWith(t, ReplayConfig{WALDir: dir, Symbol: "BTCUSDT", Snapshot: ReplayLatestSnapshot}, replTestCfg("").BookFactory)
+	if latest.FromSeq != 15 || latest.LastSeq != uint64(len(replayCmds)) {
+		t.Fatalf("latest from=%d last=%d", latest.FromSeq, latest.LastSeq)
+	}
+}
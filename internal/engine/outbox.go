@@ -19,13 +19,19 @@ type Outbox interface {
 
 type EventOutbox struct {
 	path   string
-	w      *wal.Writer
+	w      walWriter // *wal.Writer 或 *wal.SegmentedWriter
 	codec  EvCodec
 	binBuf []byte
+	idx    *outboxIndex // 稀疏 seq→offset 索引，nil 表示不维护
 }
 
 func OpenEventOutbox(path string, bufSize int, codec EvCodec) (*EventOutbox, error) {
-	wr, err := wal.OpenWrite(path, bufSize)
+	return openEventOutbox(path, bufSize, codec, nil)
+}
+
+// openEventOutbox：seg != nil 时 ev.wal 按段切分
+func openEventOutbox(path string, bufSize int, codec EvCodec, seg *wal.SegmentOptions) (*EventOutbox, error) {
+	wr, err := openLogWriter(path, bufSize, seg)
 	if err != nil {
 		return nil, err
 	}
@@ -49,18 +55,76 @@ func (o *EventOutbox) Append(ev Event) error {
 
 func (o *EventOutbox) AppendCmdEnd(seq uint64) error {
 	ev := Event{Type: EvCmdEnd, Seq: seq, Idx: 0}
-	return o.Append(ev)
+	if err := o.Append(ev); err != nil {
+		return err
+	}
+	if o.idx != nil {
+		o.idx.mark(seq, o.w.(offsetWriter).Offset())
+	}
+	return nil
+}
+
+// Flush：先落 ev.wal 再写索引
+func (o *EventOutbox) Flush() error {
+	if err := o.w.Flush(); err != nil {
+		return err
+	}
+	if o.idx != nil {
+		return o.idx.flush()
+	}
+	return nil
 }
 
-func (o *EventOutbox) Flush() error { return o.w.Flush() }
-func (o *EventOutbox) Close() error { return o.w.Close() }
+func (o *EventOutbox) Close() error {
+	err := o.w.Close()
+	if o.idx != nil {
+		if ierr := o.idx.close(); err == nil {
+			err = ierr
+		}
+	}
+	return err
+}
+
+type offsetWriter interface {
+	Offset() int64
+}
+
+// enableIndex：维护 <sym>.ev.idx（在 ScanAndRepairOutbox 之后调用，越过修复后末尾的旧条目会被丢弃）
+func (o *EventOutbox) enableIndex(every uint64) error {
+	ow, ok := o.w.(offsetWriter)
+	if !ok {
+		return nil
+	}
+	x, err := openOutboxIndex(outboxIndexPath(o.path), every, ow.Offset())
+	if err != nil {
+		return err
+	}
+	o.idx = x
+	return nil
+}
+
+// Retain：删除 cursor 之前的 ev 段（单文件布局下无操作）；cursor 由调用方取所有读者里最靠前的（见 Engine.retainEvents）
+// 用 cursor-1：保留 cursor 前最后一个 CmdEnd 所在的段，重启扫描要靠它确定 lastCompleteSeq
+// 可在 publisher 协程调用，SegmentedWriter 内部有锁
+func (o *EventOutbox) Retain(cursor int64) error {
+	sw, ok := o.w.(*wal.SegmentedWriter)
+	if !ok || cursor <= 0 {
+		return nil
+	}
+	_, err := sw.Retain(cursor-1, wal.RetainOptions{})
+	return err
+}
 
 func ScanAndRepairOutbox(path string, codec EvCodec) (lastCompleteSeq uint64, lastCompleteOffset int64, err error) {
 	// 文件不存在：正常
-	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+	if !logExists(path) {
 		return 0, 0, nil
 	}
-	r, err := wal.OpenReader(path, 0, wal.ReaderOptions{
+	start, err := logStart(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := openLogReader(path, start, wal.ReaderOptions{
 		AllowTruncatedTail: true,
 	})
 	if err != nil {
@@ -91,16 +155,16 @@ func ScanAndRepairOutbox(path string, codec EvCodec) (lastCompleteSeq uint64, la
 
 	// 1) 如果尾部半写：先截断到最后一条“完整 record”
 	if r.TruncatedTail() {
-		if err := wal.TruncateTo(path, r.LastGoodOffset()); err != nil {
+		if err := truncateLog(path, r.LastGoodOffset()); err != nil {
 			return 0, 0, err
 		}
 	}
 
 	// 2) 再把 “没有 CmdEnd 的残留事件” 截断掉（命令边界一致性）
 	if lastCompleteOffset > 0 {
-		st, e := os.Stat(path)
-		if e == nil && st.Size() > lastCompleteOffset {
-			if err := wal.TruncateTo(path, lastCompleteOffset); err != nil {
+		size, e := logSize(path)
+		if e == nil && size > lastCompleteOffset {
+			if err := truncateLog(path, lastCompleteOffset); err != nil {
 				return 0, 0, err
 			}
 		}
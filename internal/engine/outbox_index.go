@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ev.wal 的稀疏索引：<sym>.ev.idx，每条 16 字节 (seq, off)，little endian
+// - 含义：seq 及之前命令的事件都在 off 之前（off 是 seq 那条 CmdEnd 之后的命令边界）
+// - 每隔 every 个 seq 记一条，在 outbox Flush 之后再写，索引永远不会领先于已落盘的 ev.wal
+// - 只是定位提示：丢失/损坏时读端退化为从头扫描，结果不变
+
+const (
+	outboxIndexRecLen       = 16
+	defaultOutboxIndexEvery = 1024
+)
+
+type indexEntry struct {
+	seq uint64
+	off int64
+}
+
+func outboxIndexPath(evPath string) string {
+	return strings.TrimSuffix(evPath, ".wal") + ".idx"
+}
+
+// outboxIndex：写端（挂在 EventOutbox 上，只在 actor 协程里调用）
+type outboxIndex struct {
+	f       *os.File
+	every   uint64
+	last    uint64       // 最后一条索引的 seq
+	pending []indexEntry // 等 outbox flush 后再写
+}
+
+// openOutboxIndex：丢弃越过 size（修复截断后的 ev.wal 末尾）或乱序的条目
+func openOutboxIndex(path string, every uint64, size int64) (*outboxIndex, error) {
+	if every == 0 {
+		every = defaultOutboxIndexEvery
+	}
+	entries := loadOutboxIndex(path, size)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	end := int64(len(entries) * outboxIndexRecLen)
+	if err := f.Truncate(end); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	x := &outboxIndex{f: f, every: every}
+	if n := len(entries); n > 0 {
+		x.last = entries[n-1].seq
+	}
+	return x, nil
+}
+
+// mark：seq 的 CmdEnd 已写入，off 为其后的偏移
+func (x *outboxIndex) mark(seq uint64, off int64) {
+	if seq < x.last+x.every {
+		return
+	}
+	x.last = seq
+	x.pending = append(x.pending, indexEntry{seq: seq, off: off})
+}
+
+func (x *outboxIndex) flush() error {
+	if len(x.pending) == 0 {
+		return nil
+	}
+	buf := make([]byte, 0, len(x.pending)*outboxIndexRecLen)
+	for _, e := range x.pending {
+		buf = binary.LittleEndian.AppendUint64(buf, e.seq)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.off))
+	}
+	x.pending = x.pending[:0]
+	_, err := x.f.Write(buf)
+	return err
+}
+
+func (x *outboxIndex) close() error {
+	err := x.flush()
+	if cerr := x.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// loadOutboxIndex：读出有效前缀（seq 严格递增、off 不超过 size）；文件不存在返回空
+func loadOutboxIndex(path string, size int64) []indexEntry {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	out := make([]indexEntry, 0, len(b)/outboxIndexRecLen)
+	for len(b) >= outboxIndexRecLen {
+		e := indexEntry{
+			seq: binary.LittleEndian.Uint64(b[:8]),
+			off: int64(binary.LittleEndian.Uint64(b[8:16])),
+		}
+		if e.off > size || (len(out) > 0 && (e.seq <= out[len(out)-1].seq || e.off < out[len(out)-1].off)) {
+			break
+		}
+		out = append(out, e)
+		b = b[outboxIndexRecLen:]
+	}
+	return out
+}
+
+// seekOutboxIndex：找 seq < fromSeq 的最后一条、且仍在 [start, size] 内的条目
+func seekOutboxIndex(entries []indexEntry, fromSeq uint64, start int64) (indexEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].seq >= fromSeq })
+	if i == 0 || entries[i-1].off < start {
+		return indexEntry{}, false
+	}
+	return entries[i-1], true
+}
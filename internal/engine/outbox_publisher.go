@@ -3,7 +3,6 @@ package engine
 import (
 	"context"
 	"io"
-	"os"
 	"time"
 
 	"gopherex.com/pkg/wal"
@@ -11,59 +10,99 @@ import (
 
 type OutboxPublisher struct {
 	ctx        context.Context
-	bus        *ChanBus
+	sink       EventSink
+	symbol     string
 	evPath     string
 	cursorPath string
 	notify     <-chan struct{}
 	evCodec    EvCodec
 	poll       time.Duration
+	batch      int                // 攒够多少事件就发一批（只在命令边界切），读到尾部也会发
+	retain     func(cursor int64) // cursor 推进后回调：清理不再需要的 ev 段（可为 nil）
+	head       func() uint64      // outbox 已落盘的最大 seq（算 lag 用，可为 nil）
+	lag        *publisherLag      // nil 表示不采集
 }
 
-func NewOutboxPublisher(ctx context.Context, bus *ChanBus, evPath, cursorPath string, notify <-chan struct{}, poll time.Duration, evcode EvCodec) *OutboxPublisher {
+const defaultPublishBatch = 256
+
+func NewOutboxPublisher(ctx context.Context, sink EventSink, symbol, evPath, cursorPath string, notify <-chan struct{}, poll time.Duration, evcode EvCodec) *OutboxPublisher {
 	if poll <= 0 {
 		poll = 50 * time.Millisecond
 	}
 	return &OutboxPublisher{
-		ctx: ctx, bus: bus,
+		ctx: ctx, sink: sink, symbol: symbol,
 		evPath:     evPath,
 		cursorPath: cursorPath,
 		notify:     notify,
 		poll:       poll,
 		evCodec:    evcode,
+		batch:      defaultPublishBatch,
 	}
 }
 
+// Run：tail ev.wal，按命令边界攒批交给 sink，sink 确认后才推进 cursor（at-least-once）
+// 崩溃/sink 出错都从 cursor 重发：下游可能收到重复事件，用 EventID(symbol, seq, idx) 去重
 func (p *OutboxPublisher) Run() {
 	// 先读取
 	committedOff := loadCursor(p.cursorPath)
-	off := committedOff
 	// cursor 可能大于文件大小（比如修复/截断过），需要矫正
-	if st, err := os.Stat(p.evPath); err == nil && off > st.Size() {
-		off = st.Size()
-		committedOff = off
-		if err = storeCursor(p.cursorPath, off); err != nil {
+	if size, err := logSize(p.evPath); err == nil && committedOff > size {
+		committedOff = size
+		if err = storeCursor(p.cursorPath, committedOff); err != nil {
 			return
 		}
 	}
-	open := func() (*wal.Reader, error) {
-		return wal.OpenReader(p.evPath, off, wal.ReaderOptions{AllowTruncatedTail: true})
-	}
+	// off：下一条要读的位置；committedOff：已落盘的 cursor（只推进到命令边界）
+	off := committedOff
+	// pending：已读未确认的事件；pending[:ready] 是完整命令，readyOff 是它们之后的命令边界
+	pending := make([]Event, 0, p.batch)
+	ready, readyOff := 0, committedOff
+	// committedSeq/readySeq：与 committedOff/readyOff 对应的命令 seq（只用于 lag 指标）
+	var committedSeq, readySeq uint64
+	seqKnown := false
 
-	r, err := open()
-	if err != nil {
-		// 文件不存在就等
-		for err != nil && os.IsNotExist(err) {
-			p.wait()
-			if p.ctx.Err() != nil {
-				return
+	var r logReader
+	defer func() {
+		if r != nil {
+			_ = r.Close()
+		}
+	}()
+	// reopen：EOF/错误后从 off 重新打开（尾部半写的 header 不会被吞掉）
+	reopen := func(at int64) {
+		if r != nil {
+			_ = r.Close()
+			r = nil
+		}
+		off = at
+		p.wait()
+	}
+	// rollback：丢掉未确认的事件，回到 cursor 重读
+	rollback := func() {
+		pending, ready, readyOff, readySeq = pending[:0], 0, committedOff, committedSeq
+		p.observeLag(committedOff, committedSeq)
+		reopen(committedOff)
+	}
+	// commit：完整命令交给 sink，确认后推进 cursor；未完成的命令留在 pending
+	commit := func() error {
+		if readyOff == committedOff {
+			return nil
+		}
+		if ready > 0 {
+			if err := p.sink.PublishBatch(p.ctx, p.symbol, pending[:ready]); err != nil {
+				return err
 			}
-			r, err = open()
 		}
-		if err != nil {
-			return
+		// cursor 落盘失败不回滚：事件已确认，下次成功时一并推进
+		if err := storeCursor(p.cursorPath, readyOff); err == nil {
+			committedOff, committedSeq = readyOff, readySeq
+			if p.retain != nil {
+				p.retain(committedOff)
+			}
 		}
+		pending = append(pending[:0], pending[ready:]...)
+		ready = 0
+		return nil
 	}
-	defer r.Close()
 
 	for {
 		select {
@@ -71,41 +110,71 @@ func (p *OutboxPublisher) Run() {
 			return
 		default:
 		}
+		if r == nil {
+			var err error
+			r, err = openLogReader(p.evPath, off, wal.ReaderOptions{AllowTruncatedTail: true})
+			if err != nil {
+				// 文件不存在/暂时打不开：等一会再试
+				r = nil
+				p.wait()
+				continue
+			}
+		}
+
 		payload, nextOff, err := r.Next()
 		if err != nil {
-			_ = r.Close()
 			if err == io.EOF {
-				p.wait()
+				// 读到尾：先把完整命令发出去，再等新事件
+				if err := commit(); err != nil {
+					rollback()
+					continue
+				}
+				p.observeLag(committedOff, committedSeq)
+				reopen(off)
 				continue
 			}
-			// 真错误：等一会重试
-			off = committedOff
-			p.wait()
+			// 真错误：回滚到 cursor 重读（at-least-once）
+			rollback()
 			continue
 		}
 
 		ev, err := p.evCodec.Decode(payload)
 		if err != nil {
-			off = committedOff
-			_ = r.Close()
-			p.wait()
+			rollback()
 			continue
 		}
+		off = nextOff
+		// 重启后 cursor 只有偏移：cursor 之后的第一条事件的前一个 seq 就是已确认的 seq
+		if !seqKnown && ev.Seq > 0 {
+			committedSeq, readySeq, seqKnown = ev.Seq-1, ev.Seq-1, true
+		}
 
-		// CmdEnd：不发布，但把 cursor 落盘（断点只推进到命令边界）
+		// CmdEnd：不发布，只标记命令边界（cursor 只推进到这里）
 		if ev.Type == EvCmdEnd {
-			_ = storeCursor(p.cursorPath, off)
+			ready, readyOff, readySeq = len(pending), off, ev.Seq
+			if ready >= p.batch {
+				if err := commit(); err != nil {
+					rollback()
+				}
+			}
 			continue
 		}
+		pending = append(pending, ev)
+	}
+}
 
-		// 关键事件：阻塞发布（Publisher 不在撮合线程里，允许阻塞）
-		if err := p.bus.Publish(p.ctx, ev); err != nil {
-			_ = r.Close()
-			off = committedOff // ✅ 回滚，避免丢
-			p.wait()
-			continue
+// observeLag：读到尾或 sink 失败时更新 lag（sink 长时间不可用时 lag 持续上涨）
+func (p *OutboxPublisher) observeLag(committedOff int64, committedSeq uint64) {
+	if p.lag == nil {
+		return
+	}
+	if size, err := logSize(p.evPath); err == nil && size >= committedOff {
+		p.lag.bytes.Set(float64(size - committedOff))
+	}
+	if p.head != nil {
+		if h := p.head(); h >= committedSeq {
+			p.lag.seq.Set(float64(h - committedSeq))
 		}
-		off = nextOff
 	}
 }
 
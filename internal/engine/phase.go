@@ -0,0 +1,87 @@
+package engine
+
+// Phase：交易对的交易阶段（actor 状态）
+// 只通过控制命令切换，命令走 cmd WAL，回放/快照都能还原
+type Phase uint8
+
+const (
+	PhaseOpen       Phase = iota // 连续交易
+	PhaseCancelOnly              // 只允许撤单
+	PhaseHalted                  // 停牌：只接受控制命令
+	PhaseAuction                 // 集合竞价：只收 GTC 限价单不撮合，Uncross 后回到连续交易
+)
+
+var phaseNames = [...]string{
+	PhaseOpen:       "open",
+	PhaseCancelOnly: "cancel_only",
+	PhaseHalted:     "halted",
+	PhaseAuction:    "auction",
+}
+
+func (p Phase) String() string {
+	if int(p) < len(phaseNames) {
+		return phaseNames[p]
+	}
+	return "unknown"
+}
+
+// isControl：控制命令在任何阶段都放行，也不受注册表规则约束
+// CancelAllForUser 算控制面：停牌时也要能给风控撤单
+func isControl(t CmdType) bool {
+	switch t {
+	case CmdHalt, CmdResume, CmdCancelOnly, CmdCancelAll, CmdAuction, CmdUncross:
+		return true
+	}
+	return false
+}
+
+// targetPhase：阶段切换命令的目标阶段
+func targetPhase(t CmdType) (Phase, bool) {
+	switch t {
+	case CmdHalt:
+		return PhaseHalted, true
+	case CmdResume:
+		return PhaseOpen, true
+	case CmdCancelOnly:
+		return PhaseCancelOnly, true
+	case CmdAuction:
+		return PhaseAuction, true
+	case CmdUncross:
+		return PhaseOpen, true
+	}
+	return 0, false
+}
+
+// check：当前阶段是否允许该命令
+// 竞价中的簿可能是交叉的，只能经 Uncross 离开竞价（要停牌先 Uncross）
+func (p Phase) check(cmd Command) RejectCode {
+	t := cmd.Type
+	if p == PhaseAuction {
+		switch t {
+		case CmdSubmitMarket, CmdSubmitStop:
+			return RejectAuctionOrder
+		case CmdSubmitLimit:
+			if cmd.TIF != TifGTC || cmd.PostOnly {
+				return RejectAuctionOrder
+			}
+		case CmdHalt, CmdResume, CmdCancelOnly, CmdAuction:
+			return RejectWrongPhase
+		}
+		return RejectNone
+	}
+	if t == CmdUncross {
+		return RejectWrongPhase
+	}
+	if isControl(t) {
+		return RejectNone
+	}
+	switch p {
+	case PhaseHalted:
+		return RejectHalted
+	case PhaseCancelOnly:
+		if t != CmdCancel {
+			return RejectCancelOnly
+		}
+	}
+	return RejectNone
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestPhase_HaltCancelOnlyAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	newEng := func(bus *ChanBus) *Engine {
+		return NewEngine(EngineConfig{
+			WALDir:          dir,
+			EnableCmdWAL:    true,
+			EnableOutbox:    true,
+			EnablePublisher: true,
+			PublisherPoll:   5 * time.Millisecond,
+			bus:             bus,
+			CmdCodec:        BinaryCMDCode{},
+			EvCodec:         EvCmdCodec{},
+			ActorCfg:        ActorConfig{MailboxSize: 64, BatchMax: 8},
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		})
+	}
+	ctx := context.Background()
+	submit := func(eng *Engine, id uint64, user uint64, price int64) Result {
+		t.Helper()
+		res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: id, OrderID: id, UserID: user, Side: Sell, Price: price, Qty: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	bus := NewChanBus(1 << 10)
+	eng := newEng(bus)
+	submit(eng, 1, 7, 101)
+	submit(eng, 2, 7, 102)
+	submit(eng, 3, 8, 103)
+
+	res, err := eng.Halt(ctx, sym)
+	if err != nil || len(res.Events) != 1 || res.Events[0].Type != EvPhase || Phase(res.Events[0].Qty) != PhaseHalted {
+		t.Fatalf("halt: %+v %v", res, err)
+	}
+	// 阶段事件经 outbox → publisher 上 bus
+	if ev := waitEventType(t, bus.C(), uint8(EvPhase), 2*time.Second); Phase(ev.Qty) != PhaseHalted {
+		t.Fatalf("bus phase=%v", Phase(ev.Qty))
+	}
+	if res = submit(eng, 4, 7, 104); res.Code != RejectHalted {
+		t.Fatalf("want halted, got %+v", res)
+	}
+	if res, _ = eng.Submit(ctx, sym, Command{Type: CmdCancel, CancelOrderID: 1}); res.Code != RejectHalted {
+		t.Fatalf("cancel while halted: %+v", res)
+	}
+
+	// 只撤单：撤单放行，下单拒绝；按用户撤单是控制命令
+	if _, err = eng.CancelOnly(ctx, sym); err != nil {
+		t.Fatal(err)
+	}
+	if res = submit(eng, 5, 7, 105); res.Code != RejectCancelOnly {
+		t.Fatalf("want cancel_only, got %+v", res)
+	}
+	if res, _ = eng.Submit(ctx, sym, Command{Type: CmdCancel, CancelOrderID: 3}); len(res.Events) != 1 || res.Events[0].Type != EvCancelled {
+		t.Fatalf("cancel in cancel-only: %+v", res)
+	}
+	res, _ = eng.Submit(ctx, sym, Command{Type: CmdCancelAll, UserID: 7})
+	if len(res.Events) != 2 || res.Events[0].Type != EvCancelled || res.Events[1].Type != EvCancelled {
+		t.Fatalf("cancel all: %+v", res)
+	}
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// 重启：阶段从 WAL 回放得到，仍是只撤单
+	eng2 := newEng(NewChanBus(1 << 10))
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	if res = submit(eng2, 6, 7, 106); res.Code != RejectCancelOnly {
+		t.Fatalf("after restart want cancel_only, got %+v", res)
+	}
+	if _, err = eng2.Resume(ctx, sym); err != nil {
+		t.Fatal(err)
+	}
+	if res = submit(eng2, 7, 7, 107); !res.Accepted {
+		t.Fatalf("after resume want accepted, got %+v", res)
+	}
+	a, _ := eng2.getOrCreateActor(sym)
+	if p, ok := a.book.(*HeapBookAdapter).B.BestAsk(); !ok || p != 107 {
+		t.Fatalf("best ask=%d/%v, want 107 (others cancelled)", p, ok)
+	}
+}
+
+func TestPhase_SnapshotKeepsPhase(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeSnapshot(dir, "X", 5, 0, symState{phase: PhaseHalted}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	h, _, _, err := loadLatestSnapshot(dir, "X")
+	if err != nil || h.phase != PhaseHalted {
+		t.Fatalf("phase=%v err=%v", h.phase, err)
+	}
+}
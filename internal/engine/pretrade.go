@@ -0,0 +1,39 @@
+package engine
+
+import "context"
+
+// 下单前风控（pre-trade）：Submit 在入队前调用，典型实现是按订单名义价值冻结资金（见 risk.FundsGuard）
+// - Reserve 会阻塞在外部服务上，只在带 ctx 的 Submit 里调；配了 hook 时 TrySubmit / TryAmend 直接返回 ErrNeedsSubmit
+// - 只管下单命令（限价/市价/止损）和改单；撤单、控制命令不经过
+//   改单加价/加量时实现方按差额补冻结，改单被拒或入队失败时同样要退回
+// - Reserve 返回 error 则直接拒（不占 mailbox/WAL，也没有事件）；可以改写 cmd：
+//   回填 EntrySetID / Reserved（随命令落 WAL，对账用），或把市价买单换成带保护价的 IOC 限价单
+// - 入队失败（mailbox 满 / 超时 / 引擎停止）时调用 Compensate 撤销 Reserve 的副作用
+// - 入队之后的释放由实现方消费事件流完成（拒单/撤单/过期/成交后按剩余冻结释放）
+//   已入队但引擎停止前没处理的命令不会有事件，需要资金侧按 entryset 对账兜底
+
+// PreTradeHook：实现需并发安全（各 symbol 的提交并发调用）
+type PreTradeHook interface {
+	Reserve(ctx context.Context, symbol string, cmd *Command) error
+	Compensate(ctx context.Context, symbol string, cmd Command)
+}
+
+func (e *Engine) preTrade(ctx context.Context, symbol string, cmd *Command) error {
+	if e.cfg.PreTrade == nil || !preTraded(cmd.Type) {
+		return nil
+	}
+	return e.cfg.PreTrade.Reserve(ctx, symbol, cmd)
+}
+
+// compensate：调用方的 ctx 可能已经结束（入队失败多半就是因为它），补偿不能跟着取消
+func (e *Engine) compensate(ctx context.Context, symbol string, cmd Command) {
+	if e.cfg.PreTrade == nil || !preTraded(cmd.Type) {
+		return
+	}
+	e.cfg.PreTrade.Compensate(context.WithoutCancel(ctx), symbol, cmd)
+}
+
+// preTraded：经过 PreTradeHook 的命令
+func preTraded(t CmdType) bool {
+	return isSubmit(t) || t == CmdAmend
+}
@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPreTrade_CmdCodec(t *testing.T) {
+	cmd := Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 2, UserID: 3, Side: Buy, Price: 100, Qty: 4, ClientOrderID: 9,
+		EntrySetID: uuid.MustParse("0190a6f4-7a2b-7c3d-8e4f-5a6b7c8d9e0f"), Reserved: 401}
+	p, _ := BinaryCMDCode{}.Encode(nil, 7, cmd)
+	if seq, out, err := (BinaryCMDCode{}).Decode(p); err != nil || seq != 7 || out.EntrySetID != cmd.EntrySetID || out.Reserved != 401 {
+		t.Fatalf("roundtrip: %+v %v", out, err)
+	}
+	v1 := append([]byte(nil), p[:cmdRecordLenV1]...)
+	v1[offVer] = cmdWalVersion1
+	_, out, err := BinaryCMDCode{}.Decode(v1)
+	if err != nil || out.Qty != 4 || out.EntrySetID != uuid.Nil || out.Reserved != 0 {
+		t.Fatalf("v1 decode: %+v %v", out, err)
+	}
+}
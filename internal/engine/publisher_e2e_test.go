@@ -46,7 +46,7 @@ func assertNoEvent(t *testing.T, ch <-chan Event, d time.Duration) {
 
 func TestE2E_Publisher_SubmitToTradeFlow(t *testing.T) {
 	const sym = "BTCUSDT"
-	dir := "./logs/"
+	dir := t.TempDir()
 
 	bus := NewChanBus(1 << 16)
 
@@ -74,6 +74,10 @@ func TestE2E_Publisher_SubmitToTradeFlow(t *testing.T) {
 	}
 
 	eng := NewEngine(cfg)
+	t.Cleanup(func() {
+		eng.Stop()
+		time.Sleep(50 * time.Millisecond) // 等 actor/publisher 退出再清理 TempDir
+	})
 
 	// 1) Submit 两笔对手单，确保撮合出 Trade
 	if err := eng.TrySubmit(sym, Command{
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+// flakySink：前 fail 次返回错误；记录每次失败时 cursor 是否被推进
+type flakySink struct {
+	mu        sync.Mutex
+	fail      int
+	curPath   string
+	leaked    bool // 失败期间 cursor 被推进过
+	delivered []Event
+}
+
+func (s *flakySink) PublishBatch(_ context.Context, _ string, evs []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		if loadCursor(s.curPath) != 0 {
+			s.leaked = true
+		}
+		return errors.New("sink down")
+	}
+	s.delivered = append(s.delivered, evs...)
+	return nil
+}
+
+func (s *flakySink) events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.delivered...)
+}
+
+func TestPublisher_SinkAckAdvancesCursor(t *testing.T) {
+	const sym = "BTCUSDT"
+	dir := t.TempDir()
+	newCfg := func(sink EventSink) EngineConfig {
+		return EngineConfig{
+			WALDir:          dir,
+			EnableCmdWAL:    true,
+			EnableOutbox:    true,
+			EnablePublisher: true,
+			PublisherPoll:   5 * time.Millisecond,
+			EventSink:       sink,
+			CmdCodec:        BinaryCMDCode{},
+			EvCodec:         EvCmdCodec{},
+			ActorCfg:        ActorConfig{MailboxSize: 64, BatchMax: 8},
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		}
+	}
+	waitDelivered := func(s *flakySink, n int) []Event {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if evs := s.events(); len(evs) >= n {
+				return evs
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("delivered=%+v, want %d", s.events(), n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	sink := &flakySink{fail: 2, curPath: outboxCursorPath(dir, sym)}
+	eng := NewEngine(newCfg(sink))
+	var want int
+	for _, c := range []Command{
+		{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 1, Side: Sell, Price: 100, Qty: 1},
+		{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 2, Side: Buy, Price: 100, Qty: 1},
+	} {
+		r, err := eng.Submit(context.Background(), sym, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += len(r.Events)
+	}
+	evs := waitDelivered(sink, want)
+	if sink.leaked {
+		t.Fatal("cursor advanced before sink ack")
+	}
+	// 失败的批次整段重发：确认过的事件按 (seq, idx) 严格递增、不重复
+	if len(evs) != want || evs[0].Seq != 1 || evs[len(evs)-1].Seq != 2 {
+		t.Fatalf("delivered=%+v", evs)
+	}
+	for i := 1; i < len(evs); i++ {
+		if p, c := evs[i-1], evs[i]; c.Seq < p.Seq || (c.Seq == p.Seq && c.Idx <= p.Idx) {
+			t.Fatalf("out of order at %d: %+v after %+v", i, c, p)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// 重启：cursor 之前的事件不再投递
+	sink2 := &flakySink{curPath: outboxCursorPath(dir, sym)}
+	eng2 := NewEngine(newCfg(sink2))
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	if _, err := eng2.Submit(context.Background(), sym, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 3, Side: Buy, Price: 99, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range waitDelivered(sink2, 1) {
+		if ev.Seq != 3 {
+			t.Fatalf("redelivered acked event after restart: %+v", ev)
+		}
+	}
+}
+
+func TestEventDedup_HighWater(t *testing.T) {
+	d := NewEventDedup()
+	seq := []Event{{Seq: 1, Idx: 0}, {Seq: 1, Idx: 1}, {Seq: 1, Idx: 0}, {Seq: 2, Idx: 0}, {Seq: 1, Idx: 1}}
+	want := []bool{false, false, true, false, true}
+	for i, ev := range seq {
+		if got := d.Seen("BTCUSDT", ev); got != want[i] {
+			t.Fatalf("event %d seen=%v", i, got)
+		}
+	}
+	if d.Seen("ETHUSDT", Event{Seq: 1}) {
+		t.Fatal("symbols must be tracked separately")
+	}
+	if id := EventID("BTCUSDT", Event{Seq: 42, Idx: 3}); id != "BTCUSDT-42-3" {
+		t.Fatalf("id=%s", id)
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"errors"
+)
+
+// 只读查询：走 actor 的查询通道，在 actor 协程的 batch 间隙执行
+// - 看到的是某个 batch 结束后的完整状态（和写操作严格串行，不会读到撮合一半的簿）
+// - 不分配 seq、不写 WAL；结果带 Seq（截止到的命令 seq）方便和事件流对齐
+
+var ErrQueryUnsupported = errors.New("book does not support queries")
+
+// QueryBook：支持只读查询的订单簿（可选能力）
+type QueryBook interface {
+	Order(orderID uint64) (RestingOrder, bool)
+	UserOrders(userID uint64) []RestingOrder
+	// BestLevels：最优买/卖价位及可见总量，Qty=0 表示该侧为空
+	BestLevels() (bid, ask DepthLevel)
+	// TopLevels：前 n 档（n<=0 全部），买盘价格降序、卖盘升序
+	TopLevels(n int) (bids, asks []DepthLevel)
+}
+
+// OpenOrder：查询返回的未完结订单（挂单或未触发的止损单）
+type OpenOrder struct {
+	OrderID       uint64
+	UserID        uint64
+	Side          uint8
+	Price         int64 // 止损市价单为 0
+	Qty           int64 // 剩余总量（冰山单含隐藏部分）
+	Visible       int64 // 盘口可见数量；止损单未入簿，为 0
+	StopPrice     int64 // >0 表示未触发的止损单
+	ClientOrderID uint64
+}
+
+// BBO：最优买卖价及可见数量（Qty=0 表示该侧为空）
+type BBO struct {
+	Symbol string
+	Seq    uint64
+	BidPx  int64
+	BidQty int64
+	AskPx  int64
+	AskQty int64
+}
+
+func openFromResting(o RestingOrder) OpenOrder {
+	return OpenOrder{OrderID: o.OrderID, UserID: o.UserID, Side: o.Side, Price: o.Price, Qty: o.Qty + o.Reserve, Visible: o.Qty, ClientOrderID: o.ClientOrderID}
+}
+
+func openFromStop(s StopOrder) OpenOrder {
+	return OpenOrder{OrderID: s.OrderID, UserID: s.UserID, Side: s.Side, Price: s.Price, Qty: s.Qty, StopPrice: s.StopPrice, ClientOrderID: s.ClientOrderID}
+}
+
+// query：查询通道里的一条请求；done 在 fn 执行完后关闭
+type query struct {
+	fn   func(a *SymbolActor)
+	done chan struct{}
+}
+
+// query：按 Submit 的超时规则跑一次只读查询；book 不支持查询返回 ErrQueryUnsupported
+func (e *Engine) query(ctx context.Context, symbol string, fn func(a *SymbolActor, qb QueryBook)) error {
+	a, err := e.getOrCreateActor(symbol)
+	if err != nil {
+		return err
+	}
+	qb, ok := a.book.(QueryBook)
+	if !ok {
+		return ErrQueryUnsupported
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := e.cfg.SubmitTimeout
+		if timeout <= 0 {
+			timeout = defaultSubmitTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	q := query{fn: func(a *SymbolActor) { fn(a, qb) }, done: make(chan struct{})}
+	select {
+	case a.q <- q:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.ctx.Done():
+		return ErrEngineStopped
+	}
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.ctx.Done():
+		return ErrEngineStopped
+	}
+}
+
+// Order：按订单号查未完结订单（先查订单簿，再查止损触发簿）；已成交/已撤返回 ok=false
+func (e *Engine) Order(ctx context.Context, symbol string, orderID uint64) (OpenOrder, bool, error) {
+	var o OpenOrder
+	var ok bool
+	err := e.query(ctx, symbol, func(a *SymbolActor, qb QueryBook) {
+		if r, found := qb.Order(orderID); found {
+			o, ok = openFromResting(r), true
+			return
+		}
+		for _, s := range a.stops.orders() {
+			if s.OrderID == orderID {
+				o, ok = openFromStop(s), true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return OpenOrder{}, false, err // 超时后 fn 仍可能执行：不读它写的变量
+	}
+	return o, ok, nil
+}
+
+// OpenOrders：该用户在 symbol 上的全部未完结订单（挂单按订单号升序，之后是止损单）
+func (e *Engine) OpenOrders(ctx context.Context, symbol string, userID uint64) ([]OpenOrder, error) {
+	var out []OpenOrder
+	err := e.query(ctx, symbol, func(a *SymbolActor, qb QueryBook) {
+		rs := qb.UserOrders(userID)
+		out = make([]OpenOrder, 0, len(rs))
+		for _, r := range rs {
+			out = append(out, openFromResting(r))
+		}
+		for _, s := range a.stops.orders() {
+			if s.UserID == userID {
+				out = append(out, openFromStop(s))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BestBidOffer：最优买卖价及可见数量
+func (e *Engine) BestBidOffer(ctx context.Context, symbol string) (BBO, error) {
+	var r BBO
+	err := e.query(ctx, symbol, func(a *SymbolActor, qb QueryBook) {
+		bid, ask := qb.BestLevels()
+		r = BBO{Symbol: symbol, Seq: a.seq, BidPx: bid.Price, BidQty: bid.Qty, AskPx: ask.Price, AskQty: ask.Qty}
+	})
+	if err != nil {
+		return BBO{}, err
+	}
+	return r, nil
+}
+
+// TopLevels：直接从订单簿取前 n 档（不依赖 EnableDepth）；CmdSeq 为截止的命令 seq，Seq 不填
+func (e *Engine) TopLevels(ctx context.Context, symbol string, n int) (DepthSnapshot, error) {
+	var r DepthSnapshot
+	err := e.query(ctx, symbol, func(a *SymbolActor, qb QueryBook) {
+		bids, asks := qb.TopLevels(n)
+		r = DepthSnapshot{Symbol: symbol, CmdSeq: a.seq, Bids: bids, Asks: asks}
+	})
+	if err != nil {
+		return DepthSnapshot{}, err
+	}
+	return r, nil
+}
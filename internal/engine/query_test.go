@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestQuery_OrdersBBOAndLevels(t *testing.T) {
+	const sym = "BTCUSDT"
+	eng := NewEngine(EngineConfig{
+		ActorCfg: ActorConfig{MailboxSize: 64, BatchMax: 8},
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+	defer eng.Stop()
+	ctx := context.Background()
+	for _, c := range []Command{
+		{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 7, Side: Sell, Price: 101, Qty: 3},
+		{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Sell, Price: 100, Qty: 2},
+		{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 7, Side: Buy, Price: 99, Qty: 10, DisplayQty: 4},
+		{Type: CmdSubmitStop, ReqID: 4, OrderID: 4, UserID: 7, Side: Buy, StopPrice: 105, Qty: 1},
+	} {
+		if _, err := eng.Submit(ctx, sym, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 异步提交紧跟查询：查询排在同一 actor 里，看到的也是写完之后的簿
+	if err := eng.TrySubmit(sym, Command{Type: CmdSubmitLimit, ReqID: 5, OrderID: 5, UserID: 9, Side: Buy, Price: 100, Qty: 2}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok, err := eng.Order(ctx, sym, 2); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("order 2 still open")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	o, ok, err := eng.Order(ctx, sym, 3)
+	if err != nil || !ok || o.Qty != 10 || o.Visible != 4 || o.StopPrice != 0 {
+		t.Fatalf("order 3=%+v ok=%v err=%v", o, ok, err)
+	}
+	if o, ok, _ := eng.Order(ctx, sym, 4); !ok || o.StopPrice != 105 || o.Visible != 0 {
+		t.Fatalf("stop order=%+v ok=%v", o, ok)
+	}
+
+	open, err := eng.OpenOrders(ctx, sym, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 3 || open[0].OrderID != 1 || open[1].OrderID != 3 || open[2].OrderID != 4 {
+		t.Fatalf("open orders=%+v", open)
+	}
+
+	bbo, err := eng.BestBidOffer(ctx, sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bbo.Seq != 5 || bbo.BidPx != 99 || bbo.BidQty != 4 || bbo.AskPx != 101 || bbo.AskQty != 3 {
+		t.Fatalf("bbo=%+v", bbo)
+	}
+
+	lv, err := eng.TopLevels(ctx, sym, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lv.CmdSeq != 5 || len(lv.Bids) != 1 || len(lv.Asks) != 1 || lv.Asks[0].Price != 101 {
+		t.Fatalf("levels=%+v", lv)
+	}
+
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := eng.BestBidOffer(ctx, sym); !errors.Is(err, ErrEngineStopped) {
+		t.Fatalf("err after stop=%v", err)
+	}
+}
@@ -4,8 +4,28 @@ package engine
 // 回放只相当于回放状态 不复用事件
 type noopEmitter struct{}
 
-func (noopEmitter) Accepted(reqID uint64, orderID, userID uint64)                           {}
-func (noopEmitter) Rejected(reqID uint64, orderID, userID uint64, reason string)            {}
-func (noopEmitter) Added(reqID uint64, orderID, userID uint64)                              {}
-func (noopEmitter) Cancelled(reqID uint64, orderID uint64)                                  {}
-func (noopEmitter) Trade(reqID uint64, makerOrderID, takerOrderID uint64, price, qty int64) {}
+func (noopEmitter) Accepted(reqID uint64, orderID, userID uint64)                  {}
+func (noopEmitter) Rejected(reqID uint64, orderID, userID uint64, code RejectCode) {}
+func (noopEmitter) Added(reqID uint64, orderID, userID uint64)                     {}
+func (noopEmitter) Cancelled(reqID uint64, orderID uint64)                         {}
+func (noopEmitter) Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64) {
+}
+func (noopEmitter) Expired(reqID uint64, orderID, userID uint64, qty int64)        {}
+func (noopEmitter) Amended(reqID uint64, orderID, userID uint64, price, qty int64) {}
+func (noopEmitter) PhaseChanged(reqID uint64, phase Phase)                         {}
+func (noopEmitter) SelfTradePrevented(reqID uint64, orderID, userID, makerOrderID, takerOrderID uint64, qty int64) {
+}
+func (noopEmitter) StopAccepted(reqID uint64, orderID, userID uint64, stopPrice, qty int64) {}
+func (noopEmitter) StopTriggered(reqID uint64, orderID, userID uint64, price, qty int64)    {}
+func (noopEmitter) StopCancelled(reqID uint64, orderID, userID uint64)                      {}
+func (noopEmitter) Replenished(reqID uint64, orderID uint64, qty int64)                     {}
+
+// tradeCounter：不出事件，只推进成交序号（回放已落 outbox 的命令、备库、没开 outbox 的 actor）
+type tradeCounter struct {
+	noopEmitter
+	trades *tradeSeq
+}
+
+func (c tradeCounter) Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64) {
+	c.trades.next()
+}
@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopherex.com/pkg/safe"
+	"gopherex.com/pkg/wal"
+)
+
+// 主备复制：备库通过 TCP tail 主库每个 symbol 的 cmd WAL，原样写入本地 WAL 并 apply 到自己的簿
+// 协议（一条连接对应一个 symbol，帧格式复用 pkg/wal 的 len+crc）：
+//   备 → 主：hello = from(8) + epoch(8) + symbol；from 是备库本地 cmd WAL 的末尾偏移，epoch 是备库见过的最大 fencing token
+//   主 → 备：resp  = token(8) + status(1) + msg；status=0 后连续发送 cmd WAL 记录，空帧是心跳
+//   备 → 主：ack   = off(8)，本地 WAL flush 后的末尾偏移
+// - 主只发已 flush 的 WAL（actor 先落 WAL 再 apply），备库收到的永远是主库的持久化前缀
+// - fencing：单写者由 Lease（etcd/redis）保证；token 单调递增，备库拒绝 token 小于已见 epoch 的旧主
+// - 主的 cmd WAL 要保留备库还没拉到的部分：分段 WAL 快照后清理旧段时不越过在线备库 ack 的最小偏移；
+//   单文件 WAL 的截断/归档会改写偏移，开了就拒绝提供复制。断线期间被清掉的部分备库只能重新拉全量
+
+var (
+	ErrFenced          = errors.New("replication: primary fenced (stale token)")
+	ErrReplicaDiverged = errors.New("replication: replica offset not in primary wal")
+	ErrReplCompaction  = errors.New("replication: single-file cmd wal compaction rewrites offsets (use SnapshotWALKeep or segmented wal)")
+)
+
+// Lease：单写者租约（实现见 fencing 包）
+type Lease interface {
+	// Acquire：阻塞到拿到租约；token 单调递增，用作 fencing token
+	Acquire(ctx context.Context) (token uint64, err error)
+	// Done：租约丢失（过期/被抢/主动释放）后关闭
+	Done() <-chan struct{}
+	Release(ctx context.Context) error
+}
+
+const (
+	replPoll      = 5 * time.Millisecond
+	replHeartbeat = time.Second
+	replTimeout   = 5 * replHeartbeat // 备库超过这么久没收到任何帧就重连
+
+	replOK    byte = 0
+	replError byte = 1
+)
+
+// FenceWith：租约丢失立即停掉引擎（所有 actor 退出，不再写 WAL）
+func (e *Engine) FenceWith(lease Lease) {
+	safe.Go(func() {
+		select {
+		case <-lease.Done():
+			e.Stop()
+		case <-e.ctx.Done():
+		}
+	})
+}
+
+// ServeReplication：在 ln 上给备库提供 cmd WAL；ctx 结束或引擎停止时关闭 ln 并返回
+// token 是本主库持有租约时拿到的 fencing token
+func (e *Engine) ServeReplication(ctx context.Context, ln net.Listener, token uint64) error {
+	if !e.cfg.EnableCmdWAL || e.cfg.WALDir == "" {
+		return errors.New("replication: cmd wal disabled")
+	}
+	if e.cfg.SnapshotEvery > 0 && e.cfg.SnapshotWALMode != SnapshotWALKeep && e.cfg.segmentOpts() == nil {
+		return ErrReplCompaction
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	safe.Go(func() {
+		select {
+		case <-ctx.Done():
+		case <-e.ctx.Done():
+		}
+		_ = ln.Close()
+	})
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || e.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		safe.Go(func() {
+			defer wg.Done()
+			defer conn.Close()
+			_ = e.serveReplica(ctx, conn, token)
+		})
+	}
+}
+
+func (e *Engine) serveReplica(ctx context.Context, conn net.Conn, token uint64) error {
+	_ = conn.SetReadDeadline(time.Now().Add(replTimeout))
+	hello, err := wal.ReadFrame(conn, 1<<10)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	if len(hello) < 16 {
+		return replReply(conn, token, replError, "bad hello")
+	}
+	from := int64(binary.LittleEndian.Uint64(hello[:8]))
+	epoch := binary.LittleEndian.Uint64(hello[8:16])
+	symbol := string(hello[16:])
+
+	if epoch > token {
+		return replReply(conn, token, replError, ErrFenced.Error())
+	}
+	if e.cfg.Symbols != nil {
+		if _, ok := e.cfg.Symbols.Get(symbol); !ok {
+			return replReply(conn, token, replError, ErrUnknownSym.Error())
+		}
+	}
+	path := cmdWalPath(e.cfg.WALDir, symbol)
+	// 先登记再检查 from：检查通过后 from 之后的段就不会再被清掉
+	held, release := e.replicas.add(symbol, from)
+	defer release()
+	start, err := logStart(path)
+	if err != nil {
+		return replReply(conn, token, replError, err.Error())
+	}
+	size, err := logSize(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return replReply(conn, token, replError, err.Error())
+	}
+	if from < start || from > size {
+		return replReply(conn, token, replError, fmt.Sprintf("%s: from=%d wal=[%d,%d]", ErrReplicaDiverged, from, start, size))
+	}
+	if err := replReply(conn, token, replOK, ""); err != nil {
+		return err
+	}
+	// 备库的 ack：只前进
+	// 读出错（备库断开）立刻释放保留并关掉连接：不等发送端写失败，断开后的快照就不再被它挡住
+	safe.Go(func() {
+		for {
+			b, err := wal.ReadFrame(conn, 16)
+			if err != nil {
+				release()
+				_ = conn.Close()
+				return
+			}
+			if len(b) == 8 {
+				if off := int64(binary.LittleEndian.Uint64(b)); off > held.Load() {
+					held.Store(off)
+				}
+			}
+		}
+	})
+
+	bw := bufio.NewWriterSize(conn, 1<<16)
+	off, idle := from, time.Now()
+	var r logReader
+	defer func() {
+		if r != nil {
+			_ = r.Close()
+		}
+	}()
+	for {
+		if r == nil {
+			if r, err = openLogReader(path, off, wal.ReaderOptions{AllowTruncatedTail: true}); err != nil {
+				r = nil
+			}
+		}
+		if r != nil {
+			payload, next, err := r.Next()
+			if err == nil {
+				if err := wal.WriteFrame(bw, payload); err != nil {
+					return err
+				}
+				off, idle = next, time.Now()
+				continue
+			}
+			_ = r.Close()
+			r = nil
+			if err != io.EOF {
+				return err
+			}
+		}
+		// 追到末尾：先把攒的帧发出去，长时间没数据发心跳
+		if time.Since(idle) >= replHeartbeat {
+			if err := wal.WriteFrame(bw, nil); err != nil {
+				return err
+			}
+			idle = time.Now()
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.ctx.Done():
+			return ErrEngineStopped
+		case <-time.After(replPoll):
+		}
+	}
+}
+
+// replicaHolds：每条复制连接上备库 ack 到的偏移；快照清理分段 cmd WAL 时不越过最小的那个
+type replicaHolds struct {
+	mu sync.Mutex
+	m  map[string]map[*atomic.Int64]struct{}
+}
+
+func (h *replicaHolds) add(symbol string, off int64) (*atomic.Int64, func()) {
+	held := new(atomic.Int64)
+	held.Store(off)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.m == nil {
+		h.m = make(map[string]map[*atomic.Int64]struct{})
+	}
+	if h.m[symbol] == nil {
+		h.m[symbol] = make(map[*atomic.Int64]struct{})
+	}
+	h.m[symbol][held] = struct{}{}
+	var once sync.Once
+	return held, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.m[symbol], held)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// oldest：该 symbol 在线备库里最靠前的 ack 偏移和连接数（没有在线备库时 n=0）
+func (h *replicaHolds) oldest(symbol string) (off int64, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for held := range h.m[symbol] {
+		if n == 0 || held.Load() < off {
+			off = held.Load()
+		}
+		n++
+	}
+	return off, n
+}
+
+// retain：把 upTo 压到该 symbol 在线备库里最靠前的 ack 偏移再调 fn；持锁调用，清理期间新连上的备库等清完再检查 from
+func (h *replicaHolds) retain(symbol string, upTo int64, fn func(upTo int64) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for held := range h.m[symbol] {
+		upTo = min(upTo, held.Load())
+	}
+	return fn(upTo)
+}
+
+func replAck(conn net.Conn, off int64) error {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(off))
+	_ = conn.SetWriteDeadline(time.Now().Add(replTimeout))
+	return wal.WriteFrame(conn, b[:])
+}
+
+func replReply(conn net.Conn, token uint64, status byte, msg string) error {
+	b := make([]byte, 9, 9+len(msg))
+	binary.LittleEndian.PutUint64(b[:8], token)
+	b[8] = status
+	b = append(b, msg...)
+	return wal.WriteFrame(conn, b)
+}
@@ -0,0 +1,340 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/wal"
+)
+
+// memLeaseStore：进程内的单写者租约（模拟 etcd：token 单调递增，同一时刻只有一个持有者）
+type memLeaseStore struct {
+	mu     sync.Mutex
+	token  uint64
+	holder *memLease
+}
+
+type memLease struct {
+	s    *memLeaseStore
+	done chan struct{}
+}
+
+func (s *memLeaseStore) node() *memLease { return &memLease{s: s} }
+
+func (l *memLease) Acquire(ctx context.Context) (uint64, error) {
+	for {
+		l.s.mu.Lock()
+		if l.s.holder == nil {
+			l.s.token++
+			l.s.holder, l.done = l, make(chan struct{})
+			tok := l.s.token
+			l.s.mu.Unlock()
+			return tok, nil
+		}
+		l.s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (l *memLease) Done() <-chan struct{} { return l.done }
+
+// Release：也用来模拟租约过期
+func (l *memLease) Release(context.Context) error {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	if l.s.holder == l {
+		l.s.holder = nil
+		close(l.done)
+	}
+	return nil
+}
+
+func replTestCfg(dir string) EngineConfig {
+	return EngineConfig{
+		WALDir:       dir,
+		EnableCmdWAL: true,
+		EnableOutbox: true,
+		CmdCodec:     BinaryCMDCode{},
+		EvCodec:      EvCmdCodec{},
+		ActorCfg:     ActorConfig{MailboxSize: 64, BatchMax: 8},
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	}
+}
+
+func bookOrders(t *testing.T, e *Engine, sym string) []RestingOrder {
+	t.Helper()
+	var out []RestingOrder
+	if err := e.query(context.Background(), sym, func(a *SymbolActor, _ QueryBook) {
+		out = a.book.(BookSnapshotter).SnapshotOrders()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return sortedOrders(out)
+}
+
+func sortedOrders(o []RestingOrder) []RestingOrder {
+	sort.Slice(o, func(i, j int) bool { return o[i].OrderID < o[j].OrderID })
+	return o
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplication_StandbyTailsAndPromotes(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx := context.Background()
+	leases := &memLeaseStore{}
+
+	// 主库：拿租约 → 起引擎 → 对外提供复制
+	pLease := leases.node()
+	token, err := pLease.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := NewEngine(replTestCfg(t.TempDir()))
+	primary.FenceWith(pLease)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- primary.ServeReplication(ctx, ln, token) }()
+
+	submit := func(e *Engine, c Command) Result {
+		t.Helper()
+		r, err := e.Submit(ctx, sym, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	// 备库启动前已有历史
+	submit(primary, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 1, Side: Sell, Price: 101, Qty: 5})
+	submit(primary, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 2, Side: Buy, Price: 99, Qty: 3})
+
+	sdir := t.TempDir()
+	sb, err := NewStandby(StandbyConfig{
+		Primary: ln.Addr().String(), WALDir: sdir, Symbols: []string{sym},
+		BookFactory: replTestCfg("").BookFactory, CmdCodec: BinaryCMDCode{}, Retry: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Start()
+
+	submit(primary, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 3, Side: Buy, Price: 101, Qty: 2})
+	submit(primary, Command{Type: CmdAmend, ReqID: 4, OrderID: 2, UserID: 2, Qty: 1})
+	submit(primary, Command{Type: CmdSubmitStop, ReqID: 5, OrderID: 5, UserID: 5, Side: Sell, StopPrice: 90, Price: 89, Qty: 1})
+	waitFor(t, "standby catch up", func() bool { return sb.Seq(sym) == 5 })
+	if sb.Epoch() != token {
+		t.Fatalf("epoch=%d token=%d", sb.Epoch(), token)
+	}
+	want := bookOrders(t, primary, sym)
+	if got := sortedOrders(sb.reps[sym].book.(BookSnapshotter).SnapshotOrders()); len(got) != len(want) || len(got) != 2 {
+		t.Fatalf("standby book=%+v primary=%+v", got, want)
+	} else {
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("standby book=%+v primary=%+v", got, want)
+			}
+		}
+	}
+
+	// 主库"宕机"：租约过期 → 引擎被 fence 停掉
+	_ = pLease.Release(ctx)
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "primary fenced", func() bool {
+		_, err := primary.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 9, OrderID: 9, UserID: 9, Side: Buy, Price: 1, Qty: 1})
+		return errors.Is(err, ErrEngineStopped)
+	})
+
+	// 提升：拿到更大的 token，热簿写快照后起引擎，seq 连续
+	cfg := replTestCfg("")
+	eng, newToken, err := sb.Promote(ctx, leases.node(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Stop()
+	if newToken <= token {
+		t.Fatalf("token %d not above old %d", newToken, token)
+	}
+	if seqs, _ := listSnapshots(sdir, sym); len(seqs) != 1 || seqs[0] != 5 {
+		t.Fatalf("snapshots=%v", seqs)
+	}
+	if got := bookOrders(t, eng, sym); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("promoted book=%+v want=%+v", got, want)
+	}
+	// 止损单随快照带过来
+	if _, ok, _ := eng.Order(ctx, sym, 5); !ok {
+		t.Fatal("stop order lost on promotion")
+	}
+	r := submit(eng, Command{Type: CmdSubmitLimit, ReqID: 6, OrderID: 6, UserID: 6, Side: Sell, Price: 99, Qty: 1})
+	if r.Seq != 6 || r.FilledQty != 1 {
+		t.Fatalf("after promote: %+v", r)
+	}
+}
+
+func TestReplication_StandbyRejectsStalePrimary(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 旧主：token=3
+	old := NewEngine(replTestCfg(t.TempDir()))
+	defer old.Stop()
+	if _, err := old.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 1, Side: Buy, Price: 10, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = old.ServeReplication(ctx, ln, 3) }()
+
+	sb, err := NewStandby(StandbyConfig{
+		Primary: ln.Addr().String(), WALDir: t.TempDir(), Symbols: []string{sym},
+		BookFactory: replTestCfg("").BookFactory, CmdCodec: BinaryCMDCode{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Stop()
+	// 备库已经见过 token=5 的新主
+	sb.epoch.Store(5)
+	err = sb.session(ctx, sb.reps[sym])
+	if err == nil || !strings.Contains(err.Error(), ErrFenced.Error()) {
+		t.Fatalf("err=%v", err)
+	}
+	if sb.Seq(sym) != 0 || sb.Epoch() != 5 {
+		t.Fatalf("stale primary data applied: seq=%d epoch=%d", sb.Seq(sym), sb.Epoch())
+	}
+}
+
+// 备库落后时主库做快照：分段 cmd WAL 的清理停在备库 ack 的位置，落后的备库还能续传；备库都跟上后才清
+func TestReplication_SnapshotHoldsWALForLaggingStandby(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	cfg.WALSegmentBytes = 256
+	cfg.SnapshotEvery = 5
+	cfg.SnapshotKeep = 1
+	cfg.SnapshotWALMode = SnapshotWALTruncate
+	primary := NewEngine(cfg)
+	defer func() {
+		primary.Stop()
+		time.Sleep(50 * time.Millisecond) // 等 actor 关闭文件再清理 TempDir
+	}()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = primary.ServeReplication(ctx, ln, 1) }()
+	submit := func(from, to uint64) {
+		t.Helper()
+		for i := from; i <= to; i++ {
+			if _, err := primary.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: i, OrderID: i, UserID: i, Side: Sell, Price: 100 + int64(i), Qty: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	firstOff := func() int64 {
+		m, err := wal.LoadManifest(cmdWalPath(dir, sym))
+		if err != nil || len(m.Segments) == 0 {
+			t.Fatalf("manifest: %+v %v", m, err)
+		}
+		return m.Segments[0].First
+	}
+
+	submit(1, 1) // 先有 cmd WAL
+	// 落后的备库：从头拉，收到响应后既不读也不 ack
+	lag, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lag.Close()
+	hello := make([]byte, 16, 16+len(sym))
+	hello = append(hello, sym...)
+	if err := wal.WriteFrame(lag, hello); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := wal.ReadFrame(lag, 0); err != nil || len(resp) < 9 || resp[8] != replOK {
+		t.Fatalf("hello: %q %v", resp, err)
+	}
+
+	// seq 31 返回时 actor 已做完 seq 30 的快照和清理
+	submit(2, 31)
+	if seqs, _ := listSnapshots(dir, sym); len(seqs) != 1 || seqs[0] != 30 {
+		t.Fatalf("snapshots=%v", seqs)
+	}
+	if off := firstOff(); off != 0 {
+		t.Fatalf("cmd wal trimmed to %d while a standby is at 0", off)
+	}
+
+	// 另一个新备库也能从头追上
+	sb, err := NewStandby(StandbyConfig{
+		Primary: ln.Addr().String(), WALDir: t.TempDir(), Symbols: []string{sym},
+		BookFactory: replTestCfg("").BookFactory, CmdCodec: BinaryCMDCode{}, Retry: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Start()
+	defer sb.Stop()
+	waitFor(t, "standby catch up to 31", func() bool { return sb.Seq(sym) == 31 })
+
+	// 落后的备库下线、另一个跟上并 ack：下次快照清到最老快照
+	// 先等主库释放掉线备库的保留、在线备库 ack 到末尾，再触发快照
+	size, err := logSize(cmdWalPath(dir, sym))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = lag.Close()
+	waitFor(t, "lagging standby hold released", func() bool {
+		off, n := primary.replicas.oldest(sym)
+		return n == 1 && off == size
+	})
+	submit(32, 40)
+	waitFor(t, "cmd wal trimmed after standbys caught up", func() bool { return firstOff() > 0 })
+	waitFor(t, "standby catch up to 40", func() bool { return sb.Seq(sym) == 40 })
+}
+
+func TestReplication_RefusesSingleFileCompaction(t *testing.T) {
+	cfg := replTestCfg(t.TempDir())
+	cfg.SnapshotEvery = 5
+	cfg.SnapshotWALMode = SnapshotWALArchive
+	eng := NewEngine(cfg)
+	defer eng.Stop()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := eng.ServeReplication(context.Background(), ln, 1); !errors.Is(err, ErrReplCompaction) {
+		t.Fatalf("err=%v", err)
+	}
+}
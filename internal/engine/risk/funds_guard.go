@@ -0,0 +1,584 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fundsv1 "gopherex.com/gen/go/fund_service/v1"
+	"gopherex.com/internal/engine"
+)
+
+// FundsGuard：下单前按订单名义价值冻结资金（engine.PreTradeHook），订单结束时释放剩余冻结
+// - 买单冻结 quote：Price*Qty（FeeInQuote 时再加按 maker/taker 较高费率预留的手续费）
+//   市价买单必须带保护价（Price），按保护价换成 IOC 限价单；stop-market 买单没法估算，直接拒
+// - 卖单冻结 base：Qty
+// - 释放靠事件流，两种接法：Sink(next) 包在 publisher 的 EventSink 外面（需开启 EnablePublisher），
+//   或 Consume 以命名消费者 "funds" 直接 tail outbox（不需要 publisher，只要 EnableOutbox）
+//   拒单/撤单/过期/STP/改小量时释放对应部分，成交按实际用量扣减，订单结束时释放剩余
+// - 改单加价/加量按差额补冻结：改单成功（EvAmended）时并入订单的冻结，被拒/入队失败时退回
+//   改小/降价在 EvAmended 时释放多出的部分；不在跟踪里的订单（重启前下的）改单不补
+// - 状态只在内存：重启后之前的订单不再跟踪，需要资金侧按 entryset 对账
+
+// FundsClient：FundsGuard 用到的资金服务能力（fundsv1.FundServiceClient 满足；测试用内存实现）
+type FundsClient interface {
+	Reserve(ctx context.Context, in *fundsv1.ReserveReq, opts ...grpc.CallOption) (*fundsv1.ReserveResp, error)
+	Release(ctx context.Context, in *fundsv1.ReleaseReq, opts ...grpc.CallOption) (*fundsv1.ReleaseResp, error)
+}
+
+var _ FundsClient = (fundsv1.FundServiceClient)(nil)
+
+var _ engine.PreTradeHook = (*FundsGuard)(nil)
+
+const (
+	defaultFundsTimeout = 2 * time.Second
+	consumePoll         = 20 * time.Millisecond  // Consume 追上末尾后的轮询间隔
+	consumeRetry        = 200 * time.Millisecond // 释放失败后原地重试的间隔
+)
+
+// FundsConsumer：Consume 用的命名消费者（cursor 在 <sym>.ev.funds.cursor）
+const FundsConsumer = "funds"
+
+type FundsGuard struct {
+	client  FundsClient
+	symbols *engine.SymbolRegistry // 资产代码与费率
+	timeout time.Duration
+
+	mu    sync.Mutex
+	books map[string]*holdBook
+
+	compensateErrs atomic.Uint64
+}
+
+func NewFundsGuard(client FundsClient, symbols *engine.SymbolRegistry, timeout time.Duration) *FundsGuard {
+	if timeout <= 0 {
+		timeout = defaultFundsTimeout
+	}
+	return &FundsGuard{client: client, symbols: symbols, timeout: timeout, books: make(map[string]*holdBook)}
+}
+
+// hold：一张单的冻结
+type hold struct {
+	entrySet uuid.UUID
+	userID   uint64
+	asset    string
+	side     uint8
+	price    int64 // 买单：冻结所按的限价
+	feeRate  int64 // 买单：额外预留的手续费率（1e-6）
+	qty      int64 // 未结数量（未成交/撤/过期）
+	amount   int64 // 当前冻结
+}
+
+// need：剩余数量还需要的冻结
+func (h *hold) need() (int64, bool) {
+	if h.side == engine.Sell {
+		return h.qty, true
+	}
+	n, ok := mulQuote(h.price, h.qty)
+	if !ok {
+		return 0, false
+	}
+	fee := engine.FeeAmount(n, h.feeRate)
+	if n > math.MaxInt64-fee {
+		return 0, false
+	}
+	return n + fee, true
+}
+
+type reqKey struct {
+	userID uint64
+	reqID  uint64
+}
+
+// amendHold：改单补的冻结（amount 为差额），等改单的结果
+type amendHold struct {
+	order uint64
+	h     hold
+}
+
+// holdBook：一个 symbol 的冻结
+// pending 按 (user, req) 等 Accepted/StopNew 拿到订单号，open 按订单号，amends 按改单的 (user, req)
+type holdBook struct {
+	mu      sync.Mutex
+	pending map[reqKey]*hold
+	open    map[uint64]*hold
+	amends  map[reqKey]amendHold
+	seq     uint64 // 已处理到的事件 (seq, idx)：publisher 重发时跳过
+	idx     uint16
+}
+
+func (g *FundsGuard) book(symbol string) *holdBook {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b := g.books[symbol]
+	if b == nil {
+		b = &holdBook{pending: make(map[reqKey]*hold), open: make(map[uint64]*hold), amends: make(map[reqKey]amendHold)}
+		g.books[symbol] = b
+	}
+	return b
+}
+
+// Reserve：冻结成功后回填 cmd.EntrySetID / Reserved；ReqID 在同一用户未受理的下单里必须唯一
+func (g *FundsGuard) Reserve(ctx context.Context, symbol string, cmd *engine.Command) error {
+	if cmd.Type == engine.CmdAmend {
+		return g.reserveAmend(ctx, symbol, cmd)
+	}
+	if cmd.UserID == 0 || cmd.ReqID == 0 || cmd.Qty <= 0 {
+		return fmt.Errorf("%w: user_id/req_id/qty required", engine.ErrPreTrade)
+	}
+	spec, ok := g.symbols.Get(symbol)
+	if !ok || spec.Base == "" || spec.Quote == "" {
+		return fmt.Errorf("%w: %s has no base/quote asset", engine.ErrPreTrade, symbol)
+	}
+	h := &hold{userID: cmd.UserID, side: cmd.Side, qty: cmd.Qty}
+	switch cmd.Side {
+	case engine.Buy:
+		if cmd.Price <= 0 {
+			return fmt.Errorf("%w: buy order needs a price to reserve against", engine.ErrPreTrade)
+		}
+		if cmd.Type == engine.CmdSubmitMarket {
+			cmd.Type = engine.CmdSubmitLimit
+			if cmd.TIF == engine.TifGTC {
+				cmd.TIF = engine.TifIOC
+			}
+		}
+		h.asset, h.price = spec.Quote, cmd.Price
+		if spec.Fees != nil && spec.Fees.FeeInQuote {
+			r := spec.Fees.Rates(cmd.UserID)
+			h.feeRate = max(r.Maker, r.Taker, 0)
+		}
+	case engine.Sell:
+		h.asset = spec.Base
+	default:
+		return fmt.Errorf("%w: bad side", engine.ErrPreTrade)
+	}
+	amount, ok := h.need()
+	if !ok {
+		return fmt.Errorf("%w: notional overflow", engine.ErrPreTrade)
+	}
+
+	// 先占住 (user, req)，冻结失败再删
+	b, key := g.book(symbol), reqKey{cmd.UserID, cmd.ReqID}
+	b.mu.Lock()
+	if _, dup := b.pending[key]; dup {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: duplicate req_id %d", engine.ErrPreTrade, cmd.ReqID)
+	}
+	b.pending[key] = h
+	b.mu.Unlock()
+
+	cctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	resp, err := g.client.Reserve(cctx, &fundsv1.ReserveReq{
+		IdempotencyKey: fmt.Sprintf("rsv:%s:%d:%d", symbol, cmd.UserID, cmd.ReqID),
+		UserId:         cmd.UserID,
+		Asset:          h.asset,
+		Amount:         amount,
+		RefId:          fmt.Sprintf("%s:%d", symbol, cmd.ReqID),
+	})
+	var id uuid.UUID
+	if err == nil {
+		id, err = uuid.Parse(resp.GetEntrysetId())
+		if err != nil {
+			// 冻结已成功但拿不到 entryset：原样退回，当作失败
+			h.amount = amount
+			g.release(ctx, fmt.Sprintf("cmp:%s:%d:%d", symbol, cmd.UserID, cmd.ReqID), h, amount)
+			err = fmt.Errorf("funds: bad entryset_id %q: %w", resp.GetEntrysetId(), err)
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		delete(b.pending, key)
+		return reserveErr(err)
+	}
+	h.entrySet, h.amount = id, amount
+	cmd.EntrySetID, cmd.Reserved = id, amount
+	return nil
+}
+
+// reserveAmend：改单后剩余数量所需超过当前冻结（含同一订单还在路上的改单补冻）时补差额
+func (g *FundsGuard) reserveAmend(ctx context.Context, symbol string, cmd *engine.Command) error {
+	if cmd.UserID == 0 || cmd.ReqID == 0 {
+		return fmt.Errorf("%w: user_id/req_id required", engine.ErrPreTrade)
+	}
+	b, key := g.book(symbol), reqKey{cmd.UserID, cmd.ReqID}
+	b.mu.Lock()
+	cur, ok := b.open[cmd.OrderID]
+	if !ok || cur.userID != cmd.UserID || cmd.Price < 0 || cmd.Qty < 0 {
+		// 不在跟踪里 / 参数不对：不补，引擎照常处理（多半拒掉）
+		b.mu.Unlock()
+		return nil
+	}
+	if _, dup := b.amends[key]; dup {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: duplicate req_id %d", engine.ErrPreTrade, cmd.ReqID)
+	}
+	nh := *cur
+	if cmd.Qty > 0 {
+		nh.qty = cmd.Qty
+	}
+	if nh.side == engine.Buy && cmd.Price > 0 {
+		nh.price = cmd.Price
+	}
+	need, ok := nh.need()
+	if !ok {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: notional overflow", engine.ErrPreTrade)
+	}
+	have := cur.amount
+	for _, a := range b.amends {
+		if a.order == cmd.OrderID {
+			have += a.h.amount
+		}
+	}
+	extra := need - have
+	if extra <= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	a := amendHold{order: cmd.OrderID, h: hold{userID: cur.userID, asset: cur.asset, side: cur.side}}
+	b.amends[key] = a
+	b.mu.Unlock()
+
+	cctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	resp, err := g.client.Reserve(cctx, &fundsv1.ReserveReq{
+		IdempotencyKey: fmt.Sprintf("amd:%s:%d:%d", symbol, cmd.UserID, cmd.ReqID),
+		UserId:         cmd.UserID,
+		Asset:          a.h.asset,
+		Amount:         extra,
+		RefId:          fmt.Sprintf("%s:%d", symbol, cmd.OrderID),
+	})
+	var id uuid.UUID
+	if err == nil {
+		id, err = uuid.Parse(resp.GetEntrysetId())
+		if err != nil {
+			a.h.amount = extra
+			g.release(ctx, fmt.Sprintf("cmp:%s:%d:%d", symbol, cmd.UserID, cmd.ReqID), &a.h, extra)
+			err = fmt.Errorf("funds: bad entryset_id %q: %w", resp.GetEntrysetId(), err)
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		delete(b.amends, key)
+		return reserveErr(err)
+	}
+	a.h.entrySet, a.h.amount = id, extra
+	b.amends[key] = a
+	cmd.EntrySetID, cmd.Reserved = id, extra
+	return nil
+}
+
+// reserveErr：资金不足这类业务拒绝归到 ErrPreTrade；连接/超时等原样返回（gRPC status 透传给调用方）
+func reserveErr(err error) error {
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.FailedPrecondition, codes.InvalidArgument, codes.OutOfRange:
+			return fmt.Errorf("%w: %s", engine.ErrPreTrade, st.Message())
+		}
+	}
+	return err
+}
+
+// Compensate：命令没进 mailbox，整笔退回（改单只退补的差额）
+func (g *FundsGuard) Compensate(ctx context.Context, symbol string, cmd engine.Command) {
+	b, key := g.book(symbol), reqKey{cmd.UserID, cmd.ReqID}
+	b.mu.Lock()
+	var h *hold
+	if cmd.Type == engine.CmdAmend {
+		if a, ok := b.amends[key]; ok && a.h.entrySet == cmd.EntrySetID {
+			delete(b.amends, key)
+			h = &a.h
+		}
+	} else if p, ok := b.pending[key]; ok && p.entrySet == cmd.EntrySetID {
+		delete(b.pending, key)
+		h = p
+	}
+	b.mu.Unlock()
+	if h == nil {
+		return
+	}
+	if err := g.release(ctx, fmt.Sprintf("cmp:%s:%d:%d", symbol, cmd.UserID, cmd.ReqID), h, h.amount); err != nil {
+		g.compensateErrs.Add(1)
+	}
+}
+
+// CompensateErrors：补偿释放失败的次数（这部分冻结只能靠对账退回）
+func (g *FundsGuard) CompensateErrors() uint64 { return g.compensateErrs.Load() }
+
+// Held：订单当前的冻结（测试/排查用）
+func (g *FundsGuard) Held(symbol string, orderID uint64) (int64, bool) {
+	b := g.book(symbol)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.open[orderID]
+	if !ok {
+		return 0, false
+	}
+	return h.amount, true
+}
+
+func (g *FundsGuard) release(ctx context.Context, key string, h *hold, amount int64) error {
+	if amount <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	_, err := g.client.Release(ctx, &fundsv1.ReleaseReq{
+		IdempotencyKey: key,
+		UserId:         h.userID,
+		Asset:          h.asset,
+		Amount:         amount,
+		RefId:          h.entrySet.String(),
+	})
+	return err
+}
+
+// Sink：先按事件释放冻结，再交给 next（nil 时只做释放）
+// 释放失败返回 error，publisher 从 cursor 重发；已处理的事件按 (seq, idx) 跳过，释放用幂等键
+func (g *FundsGuard) Sink(next engine.EventSink) engine.EventSink {
+	return guardSink{g: g, next: next}
+}
+
+type guardSink struct {
+	g    *FundsGuard
+	next engine.EventSink
+}
+
+func (s guardSink) PublishBatch(ctx context.Context, symbol string, evs []engine.Event) error {
+	b := s.g.book(symbol)
+	for _, ev := range evs {
+		if err := s.g.apply(ctx, symbol, b, ev); err != nil {
+			return err
+		}
+	}
+	if s.next == nil {
+		return nil
+	}
+	return s.next.PublishBatch(ctx, symbol, evs)
+}
+
+// Consume：不开 publisher 时的释放通道，按命名消费者 FundsConsumer 读 symbol 的事件并释放冻结
+// 释放失败原地重试（幂等键不变），整条命令处理完才 Ack；ctx 结束返回 ctx.Err()
+func (g *FundsGuard) Consume(ctx context.Context, eng *engine.Engine, symbol string) error {
+	c, err := eng.OpenConsumer(symbol, FundsConsumer)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	b := g.book(symbol)
+	for {
+		evs, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			if err := sleepCtx(ctx, consumePoll); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, ev := range evs {
+			for g.apply(ctx, symbol, b, ev) != nil {
+				if err := sleepCtx(ctx, consumeRetry); err != nil {
+					return err
+				}
+			}
+		}
+		if err := c.Ack(evs[len(evs)-1]); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// change：一个事件对一张单的影响；释放全部成功后才写回，失败重发时状态不变
+type change struct {
+	pend  reqKey // 非零：从 pending 移走
+	amend reqKey // 非零：从 amends 移走
+	order uint64 // 写回 open 的订单号
+	h     hold
+	done  bool  // 订单结束：不再跟踪
+	rel   int64 // 要释放的数量
+}
+
+func (g *FundsGuard) apply(ctx context.Context, symbol string, b *holdBook, ev engine.Event) error {
+	b.mu.Lock()
+	if ev.Seq < b.seq || (ev.Seq == b.seq && ev.Idx <= b.idx) {
+		b.mu.Unlock()
+		return nil
+	}
+	changes := b.plan(ev)
+	b.mu.Unlock()
+
+	for _, c := range changes {
+		key := fmt.Sprintf("rel:%s:%d:%d:%d", symbol, ev.Seq, ev.Idx, c.order)
+		if err := g.release(ctx, key, &c.h, c.rel); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range changes {
+		if c.pend != (reqKey{}) {
+			delete(b.pending, c.pend)
+		}
+		if c.amend != (reqKey{}) {
+			delete(b.amends, c.amend)
+		}
+		if c.order == 0 {
+			continue // 只退改单补冻，不涉及订单
+		}
+		if c.done {
+			delete(b.open, c.order)
+			continue
+		}
+		h := c.h
+		h.amount -= c.rel
+		b.open[c.order] = &h
+	}
+	b.seq, b.idx = ev.Seq, ev.Idx
+	return nil
+}
+
+// plan：只读当前状态，算出事件带来的变化（调用方持有 b.mu）
+func (b *holdBook) plan(ev engine.Event) []change {
+	switch ev.Type {
+	case engine.EvAccepted, engine.EvStopNew:
+		// 触发后的止损单也会 Accepted，它早已在 open 里，pending 查不到
+		k := reqKey{ev.UserID, ev.ReqID}
+		if h, ok := b.pending[k]; ok {
+			return []change{{pend: k, order: ev.OrderID, h: *h}}
+		}
+	case engine.EvRejected:
+		// 还没受理的下单整笔退；改单被拒只退补的差额；撤单被拒不影响已有冻结
+		k := reqKey{ev.UserID, ev.ReqID}
+		if h, ok := b.pending[k]; ok {
+			return []change{{pend: k, order: ev.OrderID, h: *h, done: true, rel: h.amount}}
+		}
+		if a, ok := b.amends[k]; ok {
+			return []change{{amend: k, h: a.h, rel: a.h.amount}}
+		}
+	case engine.EvCancelled, engine.EvStopCxl:
+		if h, ok := b.open[ev.OrderID]; ok {
+			return []change{{order: ev.OrderID, h: *h, done: true, rel: h.amount}}
+		}
+	case engine.EvExpired, engine.EvSelfTrade:
+		if h, ok := b.open[ev.OrderID]; ok {
+			nh := *h
+			nh.qty -= ev.Qty
+			return []change{settle(ev.OrderID, nh)}
+		}
+	case engine.EvAmended:
+		// 改单补的冻结并入订单，再按改后的价格/剩余数量释放多出的部分
+		k := reqKey{ev.UserID, ev.ReqID}
+		a, amended := b.amends[k]
+		h, ok := b.open[ev.OrderID]
+		if !ok {
+			if amended {
+				return []change{{amend: k, h: a.h, rel: a.h.amount}}
+			}
+			return nil
+		}
+		nh := *h
+		nh.qty = ev.Qty
+		if nh.side == engine.Buy {
+			nh.price = ev.Price
+		}
+		if amended {
+			nh.amount += a.h.amount
+		}
+		c := settle(ev.OrderID, nh)
+		if amended {
+			c.amend = k
+		}
+		return []change{c}
+	case engine.EvTrade:
+		var out []change
+		for _, id := range []uint64{ev.MakerOrderID, ev.TakerOrderID} {
+			h, ok := b.open[id]
+			if !ok {
+				continue
+			}
+			nh := *h
+			nh.qty -= ev.Qty
+			nh.amount -= used(ev, id == ev.TakerOrderID, nh.side)
+			if nh.amount < 0 {
+				nh.amount = 0
+			}
+			out = append(out, settle(id, nh))
+		}
+		return out
+	}
+	return nil
+}
+
+// used：一笔成交从冻结里实际用掉的数量
+// 卖方交出 base；买方付出 quote，FeeInQuote 时手续费也从 quote 扣（返佣不抵扣）
+func used(ev engine.Event, taker bool, side uint8) int64 {
+	if side == engine.Sell {
+		return ev.Qty
+	}
+	fee, asset := ev.MakerFee, ev.MakerFeeAsset
+	if taker {
+		fee, asset = ev.TakerFee, ev.TakerFeeAsset
+	}
+	// 溢出按用光算：冻结本来就放不下这么大的数，不能回绕成负数把冻结加回去
+	n, ok := mulQuote(ev.Price, ev.Qty)
+	if !ok {
+		return math.MaxInt64
+	}
+	if asset == engine.FeeAssetQuote && fee > 0 {
+		if n > math.MaxInt64-fee {
+			return math.MaxInt64
+		}
+		n += fee
+	}
+	return n
+}
+
+// mulQuote：price*qty（参数都 >= 0），按 128 位乘，超出 int64 返回 ok=false
+func mulQuote(price, qty int64) (int64, bool) {
+	hi, lo := bits.Mul64(uint64(price), uint64(qty))
+	if hi != 0 || lo > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(lo), true
+}
+
+// settle：订单结束释放全部，否则释放超出剩余所需的部分（成交价优于限价、改小等）
+func settle(orderID uint64, h hold) change {
+	c := change{order: orderID, h: h}
+	if h.qty <= 0 {
+		c.done, c.rel = true, h.amount
+		return c
+	}
+	if need, ok := h.need(); ok && h.amount > need {
+		c.rel = h.amount - need
+	}
+	return c
+}
@@ -0,0 +1,396 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fundsv1 "gopherex.com/gen/go/fund_service/v1"
+	"gopherex.com/internal/engine"
+	"gopherex.com/internal/matching"
+)
+
+// memFunds：内存版资金服务；可用余额 limit（按用户+资产），按幂等键去重
+type memFunds struct {
+	mu          sync.Mutex
+	limit       map[string]int64
+	reserved    map[string]int64 // 幂等键 → 数量
+	released    map[string]int64
+	failRelease int // 前几次释放返回 Unavailable
+	conflict    bool
+}
+
+func newMemFunds() *memFunds {
+	return &memFunds{limit: map[string]int64{}, reserved: map[string]int64{}, released: map[string]int64{}}
+}
+
+func (f *memFunds) Reserve(_ context.Context, in *fundsv1.ReserveReq, _ ...grpc.CallOption) (*fundsv1.ReserveResp, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if l, ok := f.limit[fmt.Sprintf("%d:%s", in.UserId, in.Asset)]; ok && in.Amount > l {
+		return nil, status.Error(codes.FailedPrecondition, "insufficient balance")
+	}
+	f.reserved[in.IdempotencyKey] = in.Amount
+	return &fundsv1.ReserveResp{EntrysetId: uuid.NewSHA1(uuid.NameSpaceOID, []byte(in.IdempotencyKey)).String()}, nil
+}
+
+func (f *memFunds) Release(_ context.Context, in *fundsv1.ReleaseReq, _ ...grpc.CallOption) (*fundsv1.ReleaseResp, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failRelease > 0 {
+		f.failRelease--
+		return nil, status.Error(codes.Unavailable, "funds down")
+	}
+	if a, ok := f.released[in.IdempotencyKey]; ok && a != in.Amount {
+		f.conflict = true
+	}
+	f.released[in.IdempotencyKey] = in.Amount
+	return &fundsv1.ReleaseResp{EntrysetId: in.RefId}, nil
+}
+
+// sum：按键前缀汇总释放数量
+func (f *memFunds) sum(prefix string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for k, a := range f.released {
+		if strings.HasPrefix(k, prefix) {
+			n += a
+		}
+	}
+	return n
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newGuardEngine：pub=false 时不开 publisher，释放要靠 Consume
+func newGuardEngine(t *testing.T, funds *memFunds, book engine.BookFactory, mailbox int, pub bool) (*engine.Engine, *FundsGuard) {
+	reg, err := engine.NewSymbolRegistry(engine.SymbolSpec{Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT",
+		Fees: &engine.FeeSchedule{FeeRates: engine.FeeRates{Taker: 1000}, FeeInQuote: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewFundsGuard(funds, reg, time.Second)
+	if book == nil {
+		book = func(string) (engine.OrderBook, error) {
+			return engine.NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		}
+	}
+	eng := engine.NewEngine(engine.EngineConfig{
+		WALDir:          t.TempDir(),
+		EnableCmdWAL:    true,
+		EnableOutbox:    true,
+		EnablePublisher: pub,
+		PublisherPoll:   5 * time.Millisecond,
+		EventSink:       g.Sink(nil),
+		CmdCodec:        engine.BinaryCMDCode{},
+		EvCodec:         engine.EvCmdCodec{},
+		Symbols:         reg,
+		PreTrade:        g,
+		ActorCfg:        engine.ActorConfig{MailboxSize: mailbox, BatchMax: 8},
+		BookFactory:     book,
+	})
+	t.Cleanup(eng.Stop)
+	return eng, g
+}
+
+func TestFundsGuard_ReserveAndRelease(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx := context.Background()
+	funds := newMemFunds()
+	funds.failRelease = 1 // 第一笔释放失败：publisher 重发，不能重复扣减
+	eng, g := newGuardEngine(t, funds, nil, 64, true)
+
+	// 卖单冻结 base = Qty
+	sell, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 1, UserID: 1, Side: engine.Sell, Price: 100, Qty: 10})
+	if err != nil || !sell.Accepted {
+		t.Fatalf("sell: %+v %v", sell, err)
+	}
+	if funds.reserved["rsv:BTCUSDT:1:1"] != 10 {
+		t.Fatalf("reserved=%v", funds.reserved)
+	}
+
+	// 买单冻结 quote = 4*110 + 0.1% 手续费（向上取整）；按 100 成交用掉 400+1，剩 40 释放
+	buy, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 2, UserID: 2, Side: engine.Buy, Price: 110, Qty: 4})
+	if err != nil || buy.FilledQty != 4 {
+		t.Fatalf("buy: %+v %v", buy, err)
+	}
+	if funds.reserved["rsv:BTCUSDT:2:2"] != 441 {
+		t.Fatalf("reserved=%v", funds.reserved)
+	}
+	waitFor(t, "buyer release", func() bool { return funds.sum("rel:") == 40 })
+	if held, ok := g.Held(sym, sell.OrderID); !ok || held != 6 {
+		t.Fatalf("seller held=%d %v", held, ok)
+	}
+
+	// 撤单释放剩余
+	if _, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdCancel, ReqID: 3, UserID: 1, CancelOrderID: sell.OrderID}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "cancel release", func() bool { return funds.sum("rel:") == 46 })
+	if _, ok := g.Held(sym, sell.OrderID); ok {
+		t.Fatal("cancelled order still tracked")
+	}
+
+	// 市价买单：没有保护价直接拒；带保护价按 IOC 限价单冻结，没对手盘整单过期全部释放
+	_, err = eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitMarket, ReqID: 4, UserID: 2, Side: engine.Buy, Qty: 2})
+	if !errors.Is(err, engine.ErrPreTrade) {
+		t.Fatalf("market without price: %v", err)
+	}
+	mkt, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitMarket, ReqID: 5, UserID: 2, Side: engine.Buy, Price: 105, Qty: 2})
+	if err != nil || !mkt.Accepted || mkt.Events[len(mkt.Events)-1].Type != engine.EvExpired {
+		t.Fatalf("market: %+v %v", mkt, err)
+	}
+	waitFor(t, "expire release", func() bool { return funds.sum("rel:") == 46+211 })
+
+	// 余额不足：ErrPreTrade，不入队；占住的 req 放掉，可以重试
+	funds.mu.Lock()
+	funds.limit["3:USDT"] = 150
+	funds.mu.Unlock()
+	bad := engine.Command{Type: engine.CmdSubmitLimit, ReqID: 6, UserID: 3, Side: engine.Buy, Price: 100, Qty: 2}
+	if _, err := eng.Submit(ctx, sym, bad); !errors.Is(err, engine.ErrPreTrade) {
+		t.Fatalf("insufficient: %v", err)
+	}
+	bad.Qty = 1
+	if res, err := eng.Submit(ctx, sym, bad); err != nil || !res.Accepted {
+		t.Fatalf("retry: %+v %v", res, err)
+	}
+
+	funds.mu.Lock()
+	defer funds.mu.Unlock()
+	if funds.conflict {
+		t.Fatal("same release key with different amounts")
+	}
+}
+
+func TestFundsGuard_AmendReservesDifference(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx := context.Background()
+	funds := newMemFunds()
+	eng, g := newGuardEngine(t, funds, nil, 64, true)
+	amend := func(req uint64, id uint64, price, qty int64) (engine.Result, error) {
+		return eng.Submit(ctx, sym, engine.Command{Type: engine.CmdAmend, ReqID: req, UserID: 2, OrderID: id, Price: price, Qty: qty})
+	}
+	held := func(id uint64, want int64) {
+		t.Helper()
+		waitFor(t, fmt.Sprintf("held=%d", want), func() bool {
+			n, ok := g.Held(sym, id)
+			return ok && n == want
+		})
+	}
+
+	// 只挂单的买单 2@100：冻结 200 + 手续费 1
+	buy, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 1, UserID: 2, Side: engine.Buy, Price: 100, Qty: 2, PostOnly: true})
+	if err != nil || !buy.Accepted {
+		t.Fatalf("buy: %+v %v", buy, err)
+	}
+	held(buy.OrderID, 201)
+	if _, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 1, UserID: 1, Side: engine.Sell, Price: 130, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 加量 5：补 501-201
+	if _, err := amend(2, buy.OrderID, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	if funds.reserved["amd:BTCUSDT:2:2"] != 300 {
+		t.Fatalf("reserved=%v", funds.reserved)
+	}
+	held(buy.OrderID, 501)
+
+	// 加价到 120：补 601-501
+	if _, err := amend(3, buy.OrderID, 120, 0); err != nil {
+		t.Fatal(err)
+	}
+	if funds.reserved["amd:BTCUSDT:2:3"] != 100 {
+		t.Fatalf("reserved=%v", funds.reserved)
+	}
+	held(buy.OrderID, 601)
+
+	// 余额不够补：改单直接拒，不入队
+	funds.mu.Lock()
+	funds.limit["2:USDT"] = 50
+	funds.mu.Unlock()
+	if _, err := amend(4, buy.OrderID, 0, 10); !errors.Is(err, engine.ErrPreTrade) {
+		t.Fatalf("insufficient amend: %v", err)
+	}
+	funds.mu.Lock()
+	delete(funds.limit, "2:USDT")
+	funds.mu.Unlock()
+
+	// 引擎拒了改单（只做 maker 会吃到 130 的卖单）：补的 5*130+1-601 退回
+	if _, err := amend(5, buy.OrderID, 130, 0); err != nil {
+		t.Fatal(err)
+	}
+	if funds.reserved["amd:BTCUSDT:2:5"] != 50 {
+		t.Fatalf("reserved=%v", funds.reserved)
+	}
+	waitFor(t, "amend reject release", func() bool { return funds.sum("rel:") == 50 })
+	held(buy.OrderID, 601)
+
+	// 改小：多出的部分释放
+	if _, err := amend(6, buy.OrderID, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	held(buy.OrderID, 121)
+	if got := funds.sum("rel:"); got != 50+480 {
+		t.Fatalf("released=%d", got)
+	}
+
+	// 止损市价买单没有保护价，没法估算冻结
+	_, err = eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitStop, ReqID: 7, UserID: 2, Side: engine.Buy, StopPrice: 150, Qty: 1})
+	if !errors.Is(err, engine.ErrPreTrade) {
+		t.Fatalf("stop-market buy: %v", err)
+	}
+}
+
+func TestFundsGuard_ConsumeWithoutPublisher(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	funds := newMemFunds()
+	funds.failRelease = 1 // 第一笔释放失败：原地重试
+	eng, g := newGuardEngine(t, funds, nil, 64, false)
+	done := make(chan error, 1)
+	go func() { done <- g.Consume(ctx, eng, sym) }()
+
+	sell, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 1, UserID: 1, Side: engine.Sell, Price: 100, Qty: 10})
+	if err != nil || !sell.Accepted {
+		t.Fatalf("sell: %+v %v", sell, err)
+	}
+	// 买 4 @110 按 100 成交：冻结 441，用掉 401，释放 40；撤卖单释放剩余 6
+	if _, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 2, UserID: 2, Side: engine.Buy, Price: 110, Qty: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdCancel, ReqID: 3, UserID: 1, CancelOrderID: sell.OrderID}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "releases via consumer", func() bool { return funds.sum("rel:") == 46 })
+	// 位置持久化在命名消费者的 cursor 里：重启后从撤单之后接着读
+	waitFor(t, "funds consumer acked", func() bool {
+		c, err := eng.OpenConsumer(sym, FundsConsumer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		return c.Position().Seq >= 3
+	})
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("consume: %v", err)
+	}
+}
+
+func TestFundsGuard_UsedOverflowSaturates(t *testing.T) {
+	ev := engine.Event{Type: engine.EvTrade, Price: math.MaxInt64 / 2, Qty: 4, TakerFee: 10, TakerFeeAsset: engine.FeeAssetQuote}
+	if n := used(ev, true, engine.Buy); n != math.MaxInt64 {
+		t.Fatalf("used=%d, want saturated", n)
+	}
+	ev.Price, ev.Qty, ev.TakerFee = 100, 3, math.MaxInt64
+	if n := used(ev, true, engine.Buy); n != math.MaxInt64 {
+		t.Fatalf("used with fee=%d, want saturated", n)
+	}
+
+	// 成交回绕不能把冻结加回去：整笔用光，订单未结数量还在
+	b := &holdBook{pending: map[reqKey]*hold{}, open: map[uint64]*hold{
+		1: {userID: 2, side: engine.Buy, price: 100, qty: 10, amount: 1000},
+	}}
+	cs := b.plan(engine.Event{Type: engine.EvTrade, TakerOrderID: 1, Price: math.MaxInt64 / 2, Qty: 4})
+	if len(cs) != 1 || cs[0].h.amount != 0 || cs[0].h.qty != 6 {
+		t.Fatalf("plan=%+v", cs)
+	}
+}
+
+// gatedBook：用户 99 的单进簿前停住 actor，用来把 mailbox 塞满
+type gatedBook struct {
+	*engine.HeapBookAdapter
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (b *gatedBook) Submit(reqID uint64, o engine.OrderSpec, emit engine.Emitter) {
+	if o.UserID == 99 {
+		b.entered <- struct{}{}
+		<-b.gate
+	}
+	b.HeapBookAdapter.Submit(reqID, o, emit)
+}
+
+func TestFundsGuard_CompensateOnEnqueueFailure(t *testing.T) {
+	const sym = "BTCUSDT"
+	ctx := context.Background()
+	funds := newMemFunds()
+	gb := &gatedBook{
+		HeapBookAdapter: engine.NewHeapBookAdapter(matching.NewLevelOrderHeapBook()),
+		entered:         make(chan struct{}), gate: make(chan struct{}),
+	}
+	eng, _ := newGuardEngine(t, funds, func(string) (engine.OrderBook, error) { return gb, nil }, 1, true)
+
+	// 配了 PreTradeHook：非阻塞提交不等资金服务，直接拒，不冻结
+	if err := eng.TrySubmit(sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 9, UserID: 1, Side: engine.Sell, Price: 100, Qty: 1}); !errors.Is(err, engine.ErrNeedsSubmit) {
+		t.Fatalf("TrySubmit with hook: %v", err)
+	}
+	if len(funds.reserved) != 0 {
+		t.Fatalf("TrySubmit reserved %v", funds.reserved)
+	}
+
+	// 用户 99 的单卡住 actor，req 2 占满 mailbox
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, c := range []engine.Command{
+		{Type: engine.CmdSubmitLimit, ReqID: 1, UserID: 99, Side: engine.Sell, Price: 100, Qty: 1},
+		{Type: engine.CmdSubmitLimit, ReqID: 2, UserID: 1, Side: engine.Sell, Price: 100, Qty: 1},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = eng.Submit(ctx, sym, c)
+		}()
+		if c.UserID == 99 {
+			<-gb.entered
+		}
+	}
+	waitFor(t, "req 2 reserved", func() bool {
+		funds.mu.Lock()
+		defer funds.mu.Unlock()
+		_, ok := funds.reserved["rsv:BTCUSDT:1:2"]
+		return ok
+	})
+	time.Sleep(20 * time.Millisecond) // 冻结之后马上入队
+
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err := eng.Submit(tctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 3, UserID: 1, Side: engine.Sell, Price: 100, Qty: 5})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline, got %v", err)
+	}
+	if got := funds.sum("cmp:BTCUSDT:1:3"); got != 5 {
+		t.Fatalf("compensated=%d", got)
+	}
+	close(gb.gate)
+
+	// 补偿过的 req 可以重新下
+	if res, err := eng.Submit(ctx, sym, engine.Command{Type: engine.CmdSubmitLimit, ReqID: 3, UserID: 1, Side: engine.Sell, Price: 100, Qty: 5}); err != nil || !res.Accepted {
+		t.Fatalf("retry: %+v %v", res, err)
+	}
+}
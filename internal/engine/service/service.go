@@ -0,0 +1,212 @@
+// Package service：撮合引擎的 gRPC 接口（api/engine/v1）
+// 参数校验由 protovalidate 拦截器完成，这里只做转换和错误码映射；
+// 业务拒单（RejectCode）不是 RPC 错误，通过 CommandResult.rejected/reject_code 返回
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	enginev1 "gopherex.com/gen/go/engine/v1"
+	"gopherex.com/internal/engine"
+)
+
+type EngineService struct {
+	enginev1.UnimplementedMatchingServiceServer
+	eng   *engine.Engine
+	reqID atomic.Uint64 // 调用方没带 req_id 时分配（只用于追踪，不参与幂等）
+}
+
+func NewEngineService(eng *engine.Engine) *EngineService {
+	return &EngineService{eng: eng}
+}
+
+func (s *EngineService) nextReqID(id uint64) uint64 {
+	if id != 0 {
+		return id
+	}
+	return s.reqID.Add(1)
+}
+
+func (s *EngineService) SubmitOrder(ctx context.Context, req *enginev1.SubmitOrderReq) (*enginev1.CommandResult, error) {
+	cmd := engine.Command{
+		ReqID:         s.nextReqID(req.GetReqId()),
+		OrderID:       req.GetOrderId(),
+		UserID:        req.GetUserId(),
+		Side:          uint8(req.GetSide()),
+		Price:         req.GetPrice(),
+		Qty:           req.GetQty(),
+		TIF:           engine.TimeInForce(req.GetTif()),
+		PostOnly:      req.GetPostOnly(),
+		StopPrice:     req.GetStopPrice(),
+		DisplayQty:    req.GetDisplayQty(),
+		ClientOrderID: req.GetClientOrderId(),
+	}
+	switch req.GetType() {
+	case enginev1.OrderType_ORDER_TYPE_LIMIT:
+		cmd.Type = engine.CmdSubmitLimit
+	case enginev1.OrderType_ORDER_TYPE_MARKET:
+		cmd.Type = engine.CmdSubmitMarket
+	case enginev1.OrderType_ORDER_TYPE_STOP:
+		cmd.Type = engine.CmdSubmitStop
+	default:
+		return nil, status.Error(codes.InvalidArgument, "unknown order type")
+	}
+	return s.submit(ctx, req.GetSymbol(), cmd)
+}
+
+func (s *EngineService) CancelOrder(ctx context.Context, req *enginev1.CancelOrderReq) (*enginev1.CommandResult, error) {
+	return s.submit(ctx, req.GetSymbol(), engine.Command{
+		Type:          engine.CmdCancel,
+		ReqID:         s.nextReqID(req.GetReqId()),
+		UserID:        req.GetUserId(),
+		CancelOrderID: req.GetOrderId(),
+	})
+}
+
+func (s *EngineService) AmendOrder(ctx context.Context, req *enginev1.AmendOrderReq) (*enginev1.CommandResult, error) {
+	return s.submit(ctx, req.GetSymbol(), engine.Command{
+		Type:    engine.CmdAmend,
+		ReqID:   s.nextReqID(req.GetReqId()),
+		UserID:  req.GetUserId(),
+		OrderID: req.GetOrderId(),
+		Price:   req.GetPrice(),
+		Qty:     req.GetQty(),
+	})
+}
+
+func (s *EngineService) submit(ctx context.Context, symbol string, cmd engine.Command) (*enginev1.CommandResult, error) {
+	res, err := s.eng.Submit(ctx, symbol, cmd)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toResult(s.spec(symbol), res), nil
+}
+
+func (s *EngineService) QueryOrder(ctx context.Context, req *enginev1.QueryOrderReq) (*enginev1.QueryOrderResp, error) {
+	o, ok, err := s.eng.Order(ctx, req.GetSymbol(), req.GetOrderId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if !ok {
+		return &enginev1.QueryOrderResp{}, nil
+	}
+	return &enginev1.QueryOrderResp{Found: true, Order: &enginev1.Order{
+		OrderId:       o.OrderID,
+		UserId:        o.UserID,
+		Side:          enginev1.Side(o.Side),
+		Price:         o.Price,
+		Qty:           o.Qty,
+		Visible:       o.Visible,
+		StopPrice:     o.StopPrice,
+		ClientOrderId: o.ClientOrderID,
+	}}, nil
+}
+
+// SubscribeEvents：客户端断开（stream ctx 结束）是正常退出
+func (s *EngineService) SubscribeEvents(req *enginev1.SubscribeEventsReq, stream enginev1.MatchingService_SubscribeEventsServer) error {
+	ctx := stream.Context()
+	symbol := req.GetSymbol()
+	spec := s.spec(symbol)
+	err := s.eng.SubscribeEvents(ctx, symbol, req.GetFromSeq(), func(evs []engine.Event) error {
+		for _, ev := range evs {
+			if err := stream.Send(toEvent(spec, ev)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return toStatus(err)
+}
+
+// spec：交易对规则（手续费资产代码）；未注册时只有 Symbol
+func (s *EngineService) spec(symbol string) engine.SymbolSpec {
+	spec, ok := s.eng.SymbolSpec(symbol)
+	if !ok {
+		spec.Symbol = symbol
+	}
+	return spec
+}
+
+func toResult(spec engine.SymbolSpec, r engine.Result) *enginev1.CommandResult {
+	out := &enginev1.CommandResult{
+		Seq:       r.Seq,
+		Accepted:  r.Accepted,
+		Rejected:  r.Rejected,
+		FilledQty: r.FilledQty,
+		OrderId:   r.OrderID,
+		Events:    make([]*enginev1.Event, 0, len(r.Events)),
+	}
+	if r.Rejected {
+		out.RejectCode = r.Code.String()
+	}
+	for _, ev := range r.Events {
+		out.Events = append(out.Events, toEvent(spec, ev))
+	}
+	return out
+}
+
+func toEvent(spec engine.SymbolSpec, ev engine.Event) *enginev1.Event {
+	out := &enginev1.Event{
+		Symbol:       spec.Symbol,
+		Id:           engine.EventID(spec.Symbol, ev),
+		Type:         enginev1.EventType(ev.Type),
+		Seq:          ev.Seq,
+		Idx:          uint32(ev.Idx),
+		ReqId:        ev.ReqID,
+		OrderId:      ev.OrderID,
+		UserId:       ev.UserID,
+		MakerOrderId: ev.MakerOrderID,
+		TakerOrderId: ev.TakerOrderID,
+		Price:        ev.Price,
+		Qty:          ev.Qty,
+	}
+	switch ev.Type {
+	case engine.EvRejected:
+		out.RejectCode = ev.Code.String()
+	case engine.EvTrade:
+		out.TradeId, out.FillId = ev.TradeID, engine.FillID(spec.Symbol, ev)
+		out.MakerUserId, out.TakerUserId, out.TakerSide = ev.MakerUserID, ev.TakerUserID, enginev1.Side(ev.TakerSide)
+		out.MakerFee, out.MakerFeeAsset = ev.MakerFee, spec.AssetOf(ev.MakerFeeAsset)
+		out.TakerFee, out.TakerFeeAsset = ev.TakerFee, spec.AssetOf(ev.TakerFeeAsset)
+	}
+	return out
+}
+
+// toStatus：引擎错误 → gRPC 状态码
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, engine.ErrUnknownSym):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, engine.ErrBadCommand):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, engine.ErrEngineBusy):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, engine.ErrUserBlocked):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, engine.ErrPreTrade):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, engine.ErrEngineStopped):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, engine.ErrQueryUnsupported), errors.Is(err, engine.ErrOutboxDisabled):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, engine.ErrEventsTrimmed):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
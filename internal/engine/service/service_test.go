@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	enginev1 "gopherex.com/gen/go/engine/v1"
+	"gopherex.com/internal/engine"
+	"gopherex.com/internal/matching"
+	"gopherex.com/internal/transport/grpc/interceptors"
+)
+
+func newTestClient(t *testing.T) enginev1.MatchingServiceClient {
+	t.Helper()
+	spec := engine.SymbolSpec{Symbol: "BTCUSDT", TickSize: 1, LotSize: 1, Base: "BTC", Quote: "USDT",
+		Fees: &engine.FeeSchedule{FeeRates: engine.FeeRates{Taker: 1000}}}
+	reg, err := engine.NewSymbolRegistry(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.NewEngine(engine.EngineConfig{
+		WALDir:        t.TempDir(),
+		EnableCmdWAL:  true,
+		EnableOutbox:  true,
+		PublisherPoll: 5 * time.Millisecond,
+		CmdCodec:      engine.BinaryCMDCode{},
+		EvCodec:       engine.EvCmdCodec{},
+		Symbols:       reg,
+		BookFactory: func(symbol string) (engine.OrderBook, error) {
+			return engine.NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+	t.Cleanup(eng.Stop)
+
+	v, err := interceptors.NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer(grpc.ChainUnaryInterceptor(v.Unary()), grpc.ChainStreamInterceptor(v.Stream()))
+	enginev1.RegisterMatchingServiceServer(gs, NewEngineService(eng))
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return enginev1.NewMatchingServiceClient(conn)
+}
+
+func limit(orderID, userID uint64, side enginev1.Side, price, qty int64) *enginev1.SubmitOrderReq {
+	return &enginev1.SubmitOrderReq{
+		Symbol: "BTCUSDT", OrderId: orderID, UserId: userID,
+		Side: side, Type: enginev1.OrderType_ORDER_TYPE_LIMIT, Price: price, Qty: qty,
+	}
+}
+
+func TestService_ValidateAndErrors(t *testing.T) {
+	cli := newTestClient(t)
+	ctx := context.Background()
+
+	bad := []*enginev1.SubmitOrderReq{
+		limit(1, 0, enginev1.Side_SIDE_BUY, 100, 1),         // user_id 必填
+		limit(1, 1, enginev1.Side_SIDE_UNSPECIFIED, 100, 1), // side 必填
+		limit(1, 1, enginev1.Side_SIDE_BUY, 0, 1),           // 限价单 price > 0
+		{Symbol: "BTCUSDT", OrderId: 1, UserId: 1, Side: enginev1.Side_SIDE_BUY, // 止损单缺 stop_price
+			Type: enginev1.OrderType_ORDER_TYPE_STOP, Price: 100, Qty: 1},
+	}
+	for i, req := range bad {
+		_, err := cli.SubmitOrder(ctx, req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("case %d: err=%v, want InvalidArgument", i, err)
+		}
+	}
+	if _, err := cli.AmendOrder(ctx, &enginev1.AmendOrderReq{Symbol: "BTCUSDT", OrderId: 1, UserId: 1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("amend without price/qty: err=%v", err)
+	}
+
+	req := limit(1, 1, enginev1.Side_SIDE_BUY, 100, 1)
+	req.Symbol = "ETHUSDT"
+	if _, err := cli.SubmitOrder(ctx, req); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown symbol: err=%v, want NotFound", err)
+	}
+}
+
+func TestService_SubmitQueryAmendCancel(t *testing.T) {
+	cli := newTestClient(t)
+	ctx := context.Background()
+
+	// seq 1 是引擎启动后写的 CmdSetFees
+	res, err := cli.SubmitOrder(ctx, limit(1, 7, enginev1.Side_SIDE_SELL, 100, 5))
+	if err != nil || !res.Accepted || res.Seq != 2 {
+		t.Fatalf("submit: res=%+v err=%v", res, err)
+	}
+	if res.Events[0].Id != "BTCUSDT-2-0" {
+		t.Fatalf("event id=%q", res.Events[0].Id)
+	}
+
+	res, err = cli.SubmitOrder(ctx, limit(2, 8, enginev1.Side_SIDE_BUY, 100, 2))
+	if err != nil || res.FilledQty != 2 || res.OrderId != 2 {
+		t.Fatalf("taker: res=%+v err=%v", res, err)
+	}
+	if tr := res.Events[1]; tr.Type != enginev1.EventType_EVENT_TYPE_TRADE || tr.TradeId != engine.TradeID(0, 1) || tr.FillId != "BTCUSDT-T1" {
+		t.Fatalf("trade=%+v", tr)
+	}
+	if tr := res.Events[1]; tr.MakerUserId != 7 || tr.TakerUserId != 8 || tr.TakerFee != 1 || tr.TakerFeeAsset != "BTC" || tr.MakerFeeAsset != "USDT" {
+		t.Fatalf("fees=%+v", tr)
+	}
+
+	// order_id=0 由引擎生成；client_order_id 重复拒单
+	req := limit(0, 9, enginev1.Side_SIDE_BUY, 90, 1)
+	req.ClientOrderId = 42
+	res, err = cli.SubmitOrder(ctx, req)
+	if err != nil || !res.Accepted || res.OrderId != engine.EngineOrderID(0, res.Seq) {
+		t.Fatalf("engine order id: res=%+v err=%v", res, err)
+	}
+	gen := res.OrderId
+	res, err = cli.SubmitOrder(ctx, req)
+	if err != nil || !res.Rejected || res.RejectCode != "dup_client_order_id" {
+		t.Fatalf("dup client order id: res=%+v err=%v", res, err)
+	}
+	q, err := cli.QueryOrder(ctx, &enginev1.QueryOrderReq{Symbol: "BTCUSDT", OrderId: gen})
+	if err != nil || !q.Found || q.Order.ClientOrderId != 42 {
+		t.Fatalf("query generated: %+v err=%v", q, err)
+	}
+
+	q, err = cli.QueryOrder(ctx, &enginev1.QueryOrderReq{Symbol: "BTCUSDT", OrderId: 1})
+	if err != nil || !q.Found || q.Order.Qty != 3 || q.Order.Side != enginev1.Side_SIDE_SELL {
+		t.Fatalf("query: %+v err=%v", q, err)
+	}
+
+	res, err = cli.AmendOrder(ctx, &enginev1.AmendOrderReq{Symbol: "BTCUSDT", OrderId: 1, UserId: 7, Qty: 1})
+	if err != nil || res.Rejected || res.Events[0].Type != enginev1.EventType_EVENT_TYPE_AMENDED {
+		t.Fatalf("amend: res=%+v err=%v", res, err)
+	}
+
+	// 业务拒单不是 RPC 错误
+	res, err = cli.CancelOrder(ctx, &enginev1.CancelOrderReq{Symbol: "BTCUSDT", OrderId: 99, UserId: 7})
+	if err != nil || !res.Rejected || res.RejectCode == "" {
+		t.Fatalf("cancel unknown: res=%+v err=%v", res, err)
+	}
+	res, err = cli.CancelOrder(ctx, &enginev1.CancelOrderReq{Symbol: "BTCUSDT", OrderId: 1, UserId: 7})
+	if err != nil || res.Rejected || res.Events[0].Type != enginev1.EventType_EVENT_TYPE_CANCELLED {
+		t.Fatalf("cancel: res=%+v err=%v", res, err)
+	}
+	q, err = cli.QueryOrder(ctx, &enginev1.QueryOrderReq{Symbol: "BTCUSDT", OrderId: 1})
+	if err != nil || q.Found {
+		t.Fatalf("query after cancel: %+v err=%v", q, err)
+	}
+}
+
+func TestService_SubscribeEventsFromSeq(t *testing.T) {
+	cli := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := uint64(1); i <= 3; i++ {
+		if _, err := cli.SubmitOrder(ctx, limit(i, 1, enginev1.Side_SIDE_BUY, int64(100+i), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream, err := cli.SubscribeEvents(ctx, &enginev1.SubscribeEventsReq{Symbol: "BTCUSDT", FromSeq: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 历史：seq 3、4 各一个 Accepted + Added（seq 1 是 CmdSetFees，不出事件）
+	var got []*enginev1.Event
+	for len(got) < 4 {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ev)
+	}
+	if got[0].Seq != 3 || got[0].Type != enginev1.EventType_EVENT_TYPE_ACCEPTED || got[3].Seq != 4 {
+		t.Fatalf("replay=%+v", got)
+	}
+
+	// 订阅之后的新命令继续推送
+	if _, err := cli.SubmitOrder(ctx, limit(4, 2, enginev1.Side_SIDE_SELL, 103, 1)); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Seq != 5 || ev.Id != "BTCUSDT-5-0" {
+		t.Fatalf("live=%+v", ev)
+	}
+}
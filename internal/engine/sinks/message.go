@@ -0,0 +1,27 @@
+// Package sinks：OutboxPublisher 的跨进程下游（engine.EventSink 实现）
+// 都是 at-least-once：PublishBatch 返回 nil 才算下游确认，publisher 才推进 .ev.cursor；
+// 消费端按 Message.ID（symbol-seq-idx）或 engine.EventDedup 去重
+package sinks
+
+import (
+	"encoding/json"
+
+	"gopherex.com/internal/engine"
+)
+
+// Message：线上的事件载荷（JSON）
+type Message struct {
+	ID     string       `json:"id"` // engine.EventID：去重键
+	Symbol string       `json:"symbol"`
+	Event  engine.Event `json:"ev"`
+}
+
+func Encode(symbol string, ev engine.Event) ([]byte, error) {
+	return json.Marshal(Message{ID: engine.EventID(symbol, ev), Symbol: symbol, Event: ev})
+}
+
+func Decode(b []byte) (Message, error) {
+	var m Message
+	err := json.Unmarshal(b, &m)
+	return m, err
+}
@@ -0,0 +1,62 @@
+package sinks
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"gopherex.com/internal/engine"
+)
+
+// NatsConn：NATSSink 用到的连接能力（*nats.Conn 满足；测试用内存实现）
+type NatsConn interface {
+	Publish(subj string, data []byte) error
+	FlushTimeout(timeout time.Duration) error
+}
+
+var _ NatsConn = (*nats.Conn)(nil)
+
+const defaultNatsAckTimeout = 2 * time.Second
+
+// NATSSink：每个 symbol 一个 subject（<prefix>.<symbol>）
+// 确认 = 整批 Publish 之后 FlushTimeout 收到服务端 PONG（服务端已按序收下这批消息）
+// core NATS 不持久化：需要重放能力时消费端应接 JetStream 或改用 RedisStreamSink
+type NATSSink struct {
+	conn       NatsConn
+	prefix     string
+	ackTimeout time.Duration
+}
+
+func NewNATSSink(conn NatsConn, prefix string, ackTimeout time.Duration) *NATSSink {
+	if prefix == "" {
+		prefix = "gopherex.events"
+	}
+	if ackTimeout <= 0 {
+		ackTimeout = defaultNatsAckTimeout
+	}
+	return &NATSSink{conn: conn, prefix: prefix, ackTimeout: ackTimeout}
+}
+
+func (s *NATSSink) Subject(symbol string) string { return s.prefix + "." + symbol }
+
+func (s *NATSSink) PublishBatch(ctx context.Context, symbol string, evs []engine.Event) error {
+	subj := s.Subject(symbol)
+	for _, ev := range evs {
+		data, err := Encode(symbol, ev)
+		if err != nil {
+			return err
+		}
+		if err := s.conn.Publish(subj, data); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	timeout := s.ackTimeout
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) < timeout {
+		timeout = time.Until(dl)
+	}
+	return s.conn.FlushTimeout(timeout)
+}
@@ -0,0 +1,67 @@
+package sinks
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"gopherex.com/internal/engine"
+)
+
+// StreamAdder：RedisStreamSink 用到的命令（*redis.Client 满足；测试用内存实现）
+type StreamAdder interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+}
+
+var _ StreamAdder = (*redis.Client)(nil)
+
+// RedisStreamSink：每个 symbol 一个 stream（<prefix>:<symbol>）
+// 条目 ID 直接用 "<seq>-<idx>"：重发的事件 ID 不大于 stream 末尾，Redis 会拒绝 XADD，
+// 这里把这种拒绝当作“已确认”，所以 stream 里天然没有重复
+type RedisStreamSink struct {
+	rdb    StreamAdder
+	prefix string
+	maxLen int64 // >0：MAXLEN ~ 裁剪
+}
+
+func NewRedisStreamSink(rdb StreamAdder, prefix string, maxLen int64) *RedisStreamSink {
+	if prefix == "" {
+		prefix = "gopherex:events"
+	}
+	return &RedisStreamSink{rdb: rdb, prefix: prefix, maxLen: maxLen}
+}
+
+func (s *RedisStreamSink) Stream(symbol string) string { return s.prefix + ":" + symbol }
+
+// StreamID：事件在 stream 里的条目 ID
+func StreamID(ev engine.Event) string {
+	return strconv.FormatUint(ev.Seq, 10) + "-" + strconv.FormatUint(uint64(ev.Idx), 10)
+}
+
+func (s *RedisStreamSink) PublishBatch(ctx context.Context, symbol string, evs []engine.Event) error {
+	stream := s.Stream(symbol)
+	for _, ev := range evs {
+		data, err := Encode(symbol, ev)
+		if err != nil {
+			return err
+		}
+		err = s.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			ID:     StreamID(ev),
+			MaxLen: s.maxLen,
+			Approx: s.maxLen > 0,
+			Values: []any{"id", engine.EventID(symbol, ev), "data", data},
+		}).Err()
+		if err != nil && !isStaleID(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStaleID：XADD 的 ID 不大于 stream 末尾（该事件已经写过）
+func isStaleID(err error) bool {
+	return strings.Contains(err.Error(), "equal or smaller than the target stream top item")
+}
@@ -0,0 +1,131 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+
+	"gopherex.com/internal/engine"
+)
+
+// memNats：内存版 NATS 连接，failFlush>0 时前几次确认超时
+type memNats struct {
+	msgs      map[string][][]byte
+	failFlush int
+}
+
+func (c *memNats) Publish(subj string, data []byte) error {
+	c.msgs[subj] = append(c.msgs[subj], data)
+	return nil
+}
+
+func (c *memNats) FlushTimeout(time.Duration) error {
+	if c.failFlush > 0 {
+		c.failFlush--
+		return nats.ErrTimeout
+	}
+	return nil
+}
+
+// memStreams：内存版 Redis Streams，和 Redis 一样拒绝不递增的条目 ID
+type memStreams struct {
+	streams map[string][]redis.XMessage
+}
+
+func (m *memStreams) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	cmd := redis.NewStringCmd(ctx)
+	s := m.streams[a.Stream]
+	if n := len(s); n > 0 && !idLess(s[n-1].ID, a.ID) {
+		cmd.SetErr(errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item"))
+		return cmd
+	}
+	kv := a.Values.([]any)
+	vals := make(map[string]any, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		vals[kv[i].(string)] = kv[i+1]
+	}
+	m.streams[a.Stream] = append(s, redis.XMessage{ID: a.ID, Values: vals})
+	cmd.SetVal(a.ID)
+	return cmd
+}
+
+func idLess(a, b string) bool {
+	pa, pb := strings.SplitN(a, "-", 2), strings.SplitN(b, "-", 2)
+	ma, _ := strconv.ParseUint(pa[0], 10, 64)
+	mb, _ := strconv.ParseUint(pb[0], 10, 64)
+	if ma != mb {
+		return ma < mb
+	}
+	sa, _ := strconv.ParseUint(pa[1], 10, 64)
+	sb, _ := strconv.ParseUint(pb[1], 10, 64)
+	return sa < sb
+}
+
+func evs(seq uint64, n int) []engine.Event {
+	out := make([]engine.Event, n)
+	for i := range out {
+		out[i] = engine.Event{Type: engine.EvAccepted, Seq: seq, Idx: uint16(i), OrderID: seq}
+	}
+	return out
+}
+
+func TestRedisStreamSink_RedeliveryIsIdempotent(t *testing.T) {
+	m := &memStreams{streams: map[string][]redis.XMessage{}}
+	s := NewRedisStreamSink(m, "", 0)
+	ctx := context.Background()
+
+	if err := s.PublishBatch(ctx, "BTCUSDT", evs(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	// publisher 从 cursor 重发：前两条已经在 stream 里，只追加新的
+	if err := s.PublishBatch(ctx, "BTCUSDT", append(evs(1, 2), evs(2, 1)...)); err != nil {
+		t.Fatal(err)
+	}
+	got := m.streams[s.Stream("BTCUSDT")]
+	if len(got) != 3 || got[2].ID != "2-0" {
+		t.Fatalf("stream=%+v", got)
+	}
+	msg, err := Decode(got[1].Values["data"].([]byte))
+	if err != nil || msg.ID != "BTCUSDT-1-1" || msg.Event.Idx != 1 || got[1].Values["id"] != msg.ID {
+		t.Fatalf("entry=%+v msg=%+v err=%v", got[1], msg, err)
+	}
+}
+
+func TestNATSSink_AckFailureThenDedup(t *testing.T) {
+	c := &memNats{msgs: map[string][][]byte{}, failFlush: 1}
+	s := NewNATSSink(c, "", time.Second)
+	ctx := context.Background()
+
+	if err := s.PublishBatch(ctx, "ETHUSDT", evs(1, 2)); !errors.Is(err, nats.ErrTimeout) {
+		t.Fatalf("first batch err=%v", err)
+	}
+	// 未确认 → publisher 重发同一批，再带上后面的命令
+	if err := s.PublishBatch(ctx, "ETHUSDT", append(evs(1, 2), evs(2, 2)...)); err != nil {
+		t.Fatal(err)
+	}
+	raw := c.msgs[s.Subject("ETHUSDT")]
+	if len(raw) != 6 {
+		t.Fatalf("published=%d", len(raw))
+	}
+	dd := engine.NewEventDedup()
+	var ids []string
+	for _, b := range raw {
+		m, err := Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dd.Seen(m.Symbol, m.Event) {
+			ids = append(ids, m.ID)
+		}
+	}
+	want := []string{"ETHUSDT-1-0", "ETHUSDT-1-1", "ETHUSDT-2-0", "ETHUSDT-2-1"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Fatalf("deduped=%v", ids)
+	}
+}
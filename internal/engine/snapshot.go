@@ -0,0 +1,542 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/wal"
+)
+
+// 快照：某个 seq 之后订单簿里所有挂单（按价格优先 + 同价 FIFO 顺序）
+// 启动时加载最新的有效快照，只回放 seq > 快照 seq 的 cmd WAL 尾部
+//
+// 文件格式（little endian）：
+//
+//	header: magic(4) "GXSN" | ver(1) | seq(8) | walOff(8) | count(4) | phase(1) | stopCount(4) | trades(8) | stp(1) | lastPx(8)
+//	record: orderID(8) | userID(8) | side(1) | price(8) | qty(8) | display(8) | reserve(8) | clientID(8) | flags(1) | qseq(8)
+//	stop:   seq(8) | reqID(8) | orderID(8) | userID(8) | side(1) | stopPrice(8) | price(8) | qty(8) | tif(1) | clientID(8)
+//	fees:   费率表（见 appendFeeSchedule，变长）
+//	rate:   count(4) | {userID(8) | sec(8) | n(4)}*，按 userID 升序
+//	trailer: crc32(4)，覆盖 header+records+stops+fees+rate
+//
+// walOff：快照时 cmd WAL 的逻辑偏移（分段 WAL 用来决定哪些段可以清理；0 表示未知）
+// phase：快照时的交易阶段
+// trades：快照时该 symbol 的成交笔数（成交号从这里接着分配，见 ids.go）
+// stp：快照时生效的自成交防护模式（之后的变化在 WAL 里的 CmdSetSTP）
+// lastPx：快照时的最新成交价（价格带 / 市价单名义价值校验、竞价参考价用），0 表示还没有成交
+// fees：快照时生效的费率表（之后的变化在 WAL 里的 CmdSetFees）
+// rate：快照时当前秒的下单频率窗口（见 user_limits.go），之后按 WAL 里的 EngineTs 接着计数
+// qty：当前可见数量；冰山单另有 display（每片）/ reserve（隐藏剩余），恢复后队列状态与快照时一致
+// flags：bit0 = PostOnly
+// qseq：簿内入队序号（见 matching.Order.Seq），竞价 STP 按它判断新旧，恢复后原样保留
+// v1 没有 lastPx，仍可解码（视为还没有成交）
+const (
+	snapMagic       = "GXSN"
+	snapVersion     = 2
+	snapVersion1    = 1
+	snapHeaderLen   = 47
+	snapHeaderLenV1 = 39
+	snapRecordLen   = 66
+	snapStopLen     = 66
+	snapCRCLen      = 4
+
+	snapFlagPostOnly = 1 << 0
+
+	defaultSnapshotKeep = 2
+)
+
+var (
+	ErrBadSnapshot         = errors.New("snapshot: corrupt file")
+	ErrSnapshotUnsupported = errors.New("snapshot: book does not support snapshot")
+	ErrWALGap              = errors.New("snapshot: cmd WAL does not continue from snapshot")
+)
+
+// 快照后对已覆盖 cmd WAL 的处理方式
+type SnapshotWALMode uint8
+
+const (
+	SnapshotWALKeep     SnapshotWALMode = iota // 保留（默认，最安全）
+	SnapshotWALTruncate                        // 删除最老保留快照已覆盖的部分
+	SnapshotWALArchive                         // 最老保留快照已覆盖的部分挪到 <sym>.wal.<seq>.archive
+)
+
+// setBookLastPrice / bookLastPrice：快照恢复 / 写快照时的最新成交价（簿不支持就忽略 / 视为 0）
+func setBookLastPrice(book OrderBook, px int64) {
+	if lb, ok := book.(LastPriceBook); ok {
+		lb.SetLastPrice(px)
+	}
+}
+
+func bookLastPrice(book OrderBook) int64 {
+	if lp, ok := book.(LastPricer); ok {
+		px, _ := lp.LastPrice()
+		return px
+	}
+	return 0
+}
+
+// setBookSTP：把簿换成给定的 STP 模式（快照恢复 / CmdSetSTP；簿不支持 STP 就忽略）
+func setBookSTP(book OrderBook, mode matching.STPMode) {
+	if sb, ok := book.(STPBook); ok {
+		sb.SetSTP(mode)
+	}
+}
+
+// RestingOrder：快照里的一条挂单
+type RestingOrder struct {
+	OrderID       uint64
+	UserID        uint64
+	Side          uint8
+	Price         int64
+	Qty           int64 // 可见数量
+	Display       int64 // 冰山单每片显示数量，0 表示普通单
+	Reserve       int64 // 冰山单隐藏的剩余数量
+	ClientOrderID uint64
+	PostOnly      bool   // 改单穿价时要拒
+	Seq           uint64 // 簿内入队序号（竞价 STP 判断新旧用）
+}
+
+// BookSnapshotter：支持快照的订单簿（可选能力，OrderBook 不强制实现）
+type BookSnapshotter interface {
+	SnapshotOrders() []RestingOrder
+	// RestoreOrders：在空簿上按顺序恢复挂单
+	RestoreOrders(orders []RestingOrder)
+}
+
+func snapshotPath(walDir, symbol string, seq uint64) string {
+	return filepath.Join(walDir, fmt.Sprintf("%s.snap.%020d", safeSym(symbol), seq))
+}
+
+func encodeSnapshot(seq uint64, walOff int64, st symState, orders []RestingOrder, stops []StopOrder) []byte {
+	n := snapHeaderLen + len(orders)*snapRecordLen + len(stops)*snapStopLen
+	buf := make([]byte, n, n+64+snapCRCLen) // 费率表/频率窗口变长，64 字节够放不带等级的表
+	copy(buf[0:4], snapMagic)
+	buf[4] = snapVersion
+	binary.LittleEndian.PutUint64(buf[5:13], seq)
+	binary.LittleEndian.PutUint64(buf[13:21], uint64(walOff))
+	binary.LittleEndian.PutUint32(buf[21:25], uint32(len(orders)))
+	buf[25] = byte(st.phase)
+	binary.LittleEndian.PutUint32(buf[26:30], uint32(len(stops)))
+	binary.LittleEndian.PutUint64(buf[30:38], st.trades.n)
+	buf[38] = byte(st.stp)
+	binary.LittleEndian.PutUint64(buf[39:47], uint64(st.lastPx))
+
+	off := snapHeaderLen
+	for _, o := range orders {
+		binary.LittleEndian.PutUint64(buf[off:off+8], o.OrderID)
+		binary.LittleEndian.PutUint64(buf[off+8:off+16], o.UserID)
+		buf[off+16] = o.Side
+		binary.LittleEndian.PutUint64(buf[off+17:off+25], uint64(o.Price))
+		binary.LittleEndian.PutUint64(buf[off+25:off+33], uint64(o.Qty))
+		binary.LittleEndian.PutUint64(buf[off+33:off+41], uint64(o.Display))
+		binary.LittleEndian.PutUint64(buf[off+41:off+49], uint64(o.Reserve))
+		binary.LittleEndian.PutUint64(buf[off+49:off+57], o.ClientOrderID)
+		if o.PostOnly {
+			buf[off+57] |= snapFlagPostOnly
+		}
+		binary.LittleEndian.PutUint64(buf[off+58:off+66], o.Seq)
+		off += snapRecordLen
+	}
+	for _, s := range stops {
+		binary.LittleEndian.PutUint64(buf[off:off+8], s.Seq)
+		binary.LittleEndian.PutUint64(buf[off+8:off+16], s.ReqID)
+		binary.LittleEndian.PutUint64(buf[off+16:off+24], s.OrderID)
+		binary.LittleEndian.PutUint64(buf[off+24:off+32], s.UserID)
+		buf[off+32] = s.Side
+		binary.LittleEndian.PutUint64(buf[off+33:off+41], uint64(s.StopPrice))
+		binary.LittleEndian.PutUint64(buf[off+41:off+49], uint64(s.Price))
+		binary.LittleEndian.PutUint64(buf[off+49:off+57], uint64(s.Qty))
+		buf[off+57] = byte(s.TIF)
+		binary.LittleEndian.PutUint64(buf[off+58:off+66], s.ClientOrderID)
+		off += snapStopLen
+	}
+	buf = appendFeeSchedule(buf, st.fees)
+	buf = appendRateWindows(buf, st.limits)
+	return binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+}
+
+// snapHeader：快照头部
+type snapHeader struct {
+	seq    uint64
+	walOff int64
+	phase  Phase
+	trades uint64                // 成交笔数
+	stp    matching.STPMode      // 自成交防护模式
+	lastPx int64                 // 最新成交价（v2 起）
+	fees   *FeeSchedule          // 生效的费率表（decodeSnapshot 填）
+	rate   map[uint64]rateWindow // 下单频率窗口（decodeSnapshot 填）
+	n      int                   // 挂单条数
+	nStop  int                   // 止损单条数
+	len    int                   // header 字节数（随版本不同）
+}
+
+// restore：把快照里簿之外的状态交给 st；没有快照时 st 不变（费率表从 nil 开始，按 WAL 里的 CmdSetFees 换）
+func (h snapHeader) restore(st *symState) {
+	if h.seq == 0 {
+		return
+	}
+	st.phase, st.trades.n, st.fees, st.stp, st.lastPx = h.phase, h.trades, h.fees, h.stp, h.lastPx
+	if st.limits != nil {
+		st.limits.restore(h.rate)
+	}
+}
+
+func appendRateWindows(dst []byte, l *userLimiter) []byte {
+	if l == nil {
+		return binary.LittleEndian.AppendUint32(dst, 0)
+	}
+	w := l.window()
+	users := make([]uint64, 0, len(w))
+	for u := range w {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(users)))
+	for _, u := range users {
+		dst = binary.LittleEndian.AppendUint64(dst, u)
+		dst = binary.LittleEndian.AppendUint64(dst, uint64(w[u].sec))
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(w[u].n))
+	}
+	return dst
+}
+
+const snapRateLen = 20
+
+func decodeRateWindows(b []byte) (map[uint64]rateWindow, bool) {
+	if len(b) < 4 {
+		return nil, false
+	}
+	n := int(binary.LittleEndian.Uint32(b[0:4]))
+	if len(b) != 4+n*snapRateLen {
+		return nil, false
+	}
+	out := make(map[uint64]rateWindow, n)
+	for off := 4; off < len(b); off += snapRateLen {
+		out[binary.LittleEndian.Uint64(b[off:off+8])] = rateWindow{
+			sec: int64(binary.LittleEndian.Uint64(b[off+8 : off+16])),
+			n:   int(binary.LittleEndian.Uint32(b[off+16 : off+20])),
+		}
+	}
+	return out, true
+}
+
+// decodeSnapshotHeader：只解析 header（不校验 crc）
+func decodeSnapshotHeader(b []byte) (h snapHeader, err error) {
+	if len(b) < snapHeaderLenV1 || string(b[0:4]) != snapMagic {
+		return h, ErrBadSnapshot
+	}
+	switch b[4] {
+	case snapVersion1:
+		h.len = snapHeaderLenV1
+	case snapVersion:
+		if len(b) < snapHeaderLen {
+			return h, ErrBadSnapshot
+		}
+		h.len = snapHeaderLen
+		h.lastPx = int64(binary.LittleEndian.Uint64(b[39:47]))
+	default:
+		return h, ErrBadSnapshot
+	}
+	h.seq = binary.LittleEndian.Uint64(b[5:13])
+	h.walOff = int64(binary.LittleEndian.Uint64(b[13:21]))
+	h.n = int(binary.LittleEndian.Uint32(b[21:25]))
+	h.phase = Phase(b[25])
+	h.nStop = int(binary.LittleEndian.Uint32(b[26:30]))
+	h.trades = binary.LittleEndian.Uint64(b[30:38])
+	h.stp = matching.STPMode(b[38])
+	return h, nil
+}
+
+func decodeSnapshot(b []byte) (h snapHeader, orders []RestingOrder, stops []StopOrder, err error) {
+	h, err = decodeSnapshotHeader(b)
+	if err != nil || len(b) < h.len+snapCRCLen {
+		return snapHeader{}, nil, nil, ErrBadSnapshot
+	}
+	body := len(b) - snapCRCLen
+	if crc32.ChecksumIEEE(b[:body]) != binary.LittleEndian.Uint32(b[body:]) {
+		return snapHeader{}, nil, nil, ErrBadSnapshot
+	}
+	tail := h.len + h.n*snapRecordLen + h.nStop*snapStopLen
+	if tail > body {
+		return snapHeader{}, nil, nil, ErrBadSnapshot
+	}
+	f, n, err := decodeFeeSchedule(b[tail:body])
+	if err != nil {
+		return snapHeader{}, nil, nil, ErrBadSnapshot
+	}
+	rate, ok := decodeRateWindows(b[tail+n : body])
+	if !ok {
+		return snapHeader{}, nil, nil, ErrBadSnapshot
+	}
+	h.fees, h.rate = f, rate
+
+	orders = make([]RestingOrder, h.n)
+	off := h.len
+	for i := range orders {
+		orders[i] = RestingOrder{
+			OrderID: binary.LittleEndian.Uint64(b[off : off+8]),
+			UserID:  binary.LittleEndian.Uint64(b[off+8 : off+16]),
+			Side:    b[off+16],
+			Price:   int64(binary.LittleEndian.Uint64(b[off+17 : off+25])),
+			Qty:     int64(binary.LittleEndian.Uint64(b[off+25 : off+33])),
+
+			Display:       int64(binary.LittleEndian.Uint64(b[off+33 : off+41])),
+			Reserve:       int64(binary.LittleEndian.Uint64(b[off+41 : off+49])),
+			ClientOrderID: binary.LittleEndian.Uint64(b[off+49 : off+57]),
+			PostOnly:      b[off+57]&snapFlagPostOnly != 0,
+			Seq:           binary.LittleEndian.Uint64(b[off+58 : off+66]),
+		}
+		off += snapRecordLen
+	}
+	stops = make([]StopOrder, h.nStop)
+	for i := range stops {
+		stops[i] = StopOrder{
+			Seq:       binary.LittleEndian.Uint64(b[off : off+8]),
+			ReqID:     binary.LittleEndian.Uint64(b[off+8 : off+16]),
+			OrderID:   binary.LittleEndian.Uint64(b[off+16 : off+24]),
+			UserID:    binary.LittleEndian.Uint64(b[off+24 : off+32]),
+			Side:      b[off+32],
+			StopPrice: int64(binary.LittleEndian.Uint64(b[off+33 : off+41])),
+			Price:     int64(binary.LittleEndian.Uint64(b[off+41 : off+49])),
+			Qty:       int64(binary.LittleEndian.Uint64(b[off+49 : off+57])),
+			TIF:       TimeInForce(b[off+57]),
+
+			ClientOrderID: binary.LittleEndian.Uint64(b[off+58 : off+66]),
+		}
+		off += snapStopLen
+	}
+	return h, orders, stops, nil
+}
+
+// writeSnapshot：tmp + fsync + rename，保证崩溃时要么旧快照、要么完整新快照
+func writeSnapshot(walDir, symbol string, seq uint64, walOff int64, st symState, orders []RestingOrder, stops []StopOrder) error {
+	path := snapshotPath(walDir, symbol, seq)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(encodeSnapshot(seq, walOff, st, orders, stops)); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	syncDir(walDir)
+	return nil
+}
+
+// listSnapshots：返回该 symbol 的所有快照 seq（降序，最新在前）
+func listSnapshots(walDir, symbol string) ([]uint64, error) {
+	prefix := safeSym(symbol) + ".snap."
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	seqs := make([]uint64, 0, 4)
+	for _, ent := range entries {
+		name := ent.Name()
+		if ent.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimPrefix(name, prefix), 10, 64)
+		if err != nil {
+			continue // .tmp 等
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] > seqs[j] })
+	return seqs, nil
+}
+
+// loadLatestSnapshot：从新到旧尝试，返回第一个校验通过的快照；没有快照返回 h.seq=0
+func loadLatestSnapshot(walDir, symbol string) (h snapHeader, orders []RestingOrder, stops []StopOrder, err error) {
+	seqs, err := listSnapshots(walDir, symbol)
+	if err != nil {
+		return snapHeader{}, nil, nil, err
+	}
+	for _, s := range seqs {
+		b, err := os.ReadFile(snapshotPath(walDir, symbol, s))
+		if err != nil {
+			continue
+		}
+		h, orders, stops, err := decodeSnapshot(b)
+		if err != nil || h.seq != s {
+			continue // 坏快照：退回上一个
+		}
+		return h, orders, stops, nil
+	}
+	return snapHeader{}, nil, nil, nil
+}
+
+// oldestSnapshotWALOffset：最老的保留快照对应的 cmd WAL 偏移
+// 回退到最老快照时，WAL 必须从这里开始还在；ok=false 表示无法确定（不要清理）
+func oldestSnapshotWALOffset(walDir, symbol string) (walOff int64, ok bool) {
+	seqs, err := listSnapshots(walDir, symbol)
+	if err != nil || len(seqs) == 0 {
+		return 0, false
+	}
+	f, err := os.Open(snapshotPath(walDir, symbol, seqs[len(seqs)-1]))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	var hdr [snapHeaderLen]byte
+	n, _ := f.Read(hdr[:])
+	h, err := decodeSnapshotHeader(hdr[:n])
+	if err != nil || h.walOff <= 0 {
+		return 0, false
+	}
+	return h.walOff, true
+}
+
+// pruneSnapshots：只保留最近 keep 个快照
+func pruneSnapshots(walDir, symbol string, keep int) {
+	seqs, err := listSnapshots(walDir, symbol)
+	if err != nil || len(seqs) <= keep {
+		return
+	}
+	for _, s := range seqs[keep:] {
+		_ = os.Remove(snapshotPath(walDir, symbol, s))
+	}
+}
+
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// snapshotter：actor 持有，负责“每 N 个 seq 做一次快照 + 处理已覆盖的 cmd WAL”
+type snapshotter struct {
+	walDir     string
+	symbol     string
+	cmdPath    string
+	walBufSize int
+	every      uint64
+	keep       int
+	walMode    SnapshotWALMode
+	lastSeq    uint64        // 最近一次快照的 seq
+	replicas   *replicaHolds // 在线备库：分段 WAL 不清它们还没 ack 的段（nil 不管）
+}
+
+func (s *snapshotter) due(seq uint64) bool {
+	return s != nil && s.every > 0 && seq-s.lastSeq >= s.every
+}
+
+// retainSegments：分段 cmd WAL，清理最老保留快照之前、且在线备库都已 ack 的段
+func (s *snapshotter) retainSegments(sw *wal.SegmentedWriter) error {
+	upTo, ok := oldestSnapshotWALOffset(s.walDir, s.symbol)
+	if !ok {
+		return nil
+	}
+	retain := func(upTo int64) error {
+		_, err := sw.Retain(upTo, wal.RetainOptions{Archive: s.walMode == SnapshotWALArchive})
+		return err
+	}
+	if s.replicas == nil {
+		return retain(upTo)
+	}
+	return s.replicas.retain(s.symbol, upTo, retain)
+}
+
+// newestSnapshotSeq：最新快照文件的 seq（不管能不能解码）；没有快照返回 ok=false
+func newestSnapshotSeq(walDir, symbol string) (seq uint64, ok bool) {
+	seqs, err := listSnapshots(walDir, symbol)
+	if err != nil || len(seqs) == 0 {
+		return 0, false
+	}
+	return seqs[0], true
+}
+
+// oldestSnapshotSeq：最老的保留快照的 seq；没有快照返回 ok=false
+func oldestSnapshotSeq(walDir, symbol string) (seq uint64, ok bool) {
+	seqs, err := listSnapshots(walDir, symbol)
+	if err != nil || len(seqs) == 0 {
+		return 0, false
+	}
+	return seqs[len(seqs)-1], true
+}
+
+// compactWAL：单文件 cmd WAL，去掉 seq <= upTo 的记录（upTo 是最老保留快照的 seq）
+// 最新快照坏了要退回老快照时，老快照之后的记录必须还在，所以不能按最新快照截
+// 保留部分写到 tmp 再 rename；归档模式把去掉的部分写成 <sym>.wal.<upTo>.archive
+// 调用方保证写端已关闭
+func (s *snapshotter) compactWAL(upTo uint64, code CmdCodec) (err error) {
+	tmp := s.cmdPath + ".compact"
+	keep, err := wal.OpenWrite(tmp, s.walBufSize)
+	if err != nil {
+		return err
+	}
+	var arch *wal.Writer
+	archPath := fmt.Sprintf("%s.%020d.archive", s.cmdPath, upTo)
+	dropped := 0
+	defer func() {
+		if arch != nil {
+			if cerr := arch.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if keep != nil {
+			_ = keep.Close()
+		}
+		if err != nil || dropped == 0 {
+			_ = os.Remove(tmp)
+		}
+	}()
+	_, err = replayLog(s.cmdPath, wal.ReplayOptions{AllowTruncatedTail: true}, func(payload []byte) error {
+		seq, _, err := code.Decode(payload)
+		if err != nil {
+			return err
+		}
+		if seq > upTo {
+			return keep.Append(payload)
+		}
+		dropped++
+		if s.walMode != SnapshotWALArchive {
+			return nil
+		}
+		if arch == nil {
+			_ = os.Remove(archPath) // 上次失败留下的半截归档
+			if arch, err = wal.OpenWrite(archPath, s.walBufSize); err != nil {
+				return err
+			}
+		}
+		return arch.Append(payload)
+	})
+	if err != nil || dropped == 0 {
+		return err
+	}
+	err = keep.Close()
+	keep = nil
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.cmdPath); err != nil {
+		return err
+	}
+	syncDir(s.walDir)
+	return nil
+}
@@ -0,0 +1,301 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/metrics"
+	"gopherex.com/pkg/wal"
+)
+
+func TestSnapshot_EncodeDecodeAndFallback(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+
+	orders := []RestingOrder{
+		{OrderID: 1, UserID: 9, Side: Sell, Price: 101, Qty: 3},
+		{OrderID: 2, UserID: 8, Side: Buy, Price: 99, Qty: 4, Seq: 5},
+	}
+	if err := writeSnapshot(dir, sym, 10, 0, symState{phase: PhaseOpen, trades: tradeSeq{n: 3}}, orders[:1], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSnapshot(dir, sym, 20, 0, symState{phase: PhaseCancelOnly, trades: tradeSeq{n: 7}}, orders, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	h, got, _, err := loadLatestSnapshot(dir, sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.seq != 20 || h.phase != PhaseCancelOnly || h.trades != 7 || len(got) != 2 || got[1] != orders[1] {
+		t.Fatalf("unexpected snapshot %+v orders=%+v", h, got)
+	}
+
+	// 破坏最新快照：应退回 seq=10
+	path := snapshotPath(dir, sym, 20)
+	b, _ := os.ReadFile(path)
+	b[snapHeaderLen] ^= 0xFF
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h, got, _, err = loadLatestSnapshot(dir, sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.seq != 10 || h.trades != 3 || len(got) != 1 {
+		t.Fatalf("expected fallback to seq=10, got %+v orders=%+v", h, got)
+	}
+}
+
+func waitFile(t *testing.T, path string, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting file %s", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSnapshot_RestartReplaysOnlyTail(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+
+	newCfg := func() EngineConfig {
+		return EngineConfig{
+			WALDir:          dir,
+			EnableCmdWAL:    true,
+			CmdCodec:        BinaryCMDCode{},
+			EvCodec:         EvCmdCodec{},
+			ActorCfg:        ActorConfig{MailboxSize: 64, BatchMax: 1},
+			SnapshotEvery:   2,
+			SnapshotWALMode: SnapshotWALArchive,
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		}
+	}
+
+	// Run #1：两条挂单触发 seq=2 快照（并归档 WAL），第三条只在 WAL 尾部
+	eng := NewEngine(newCfg())
+	submits := []Command{
+		{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 9, Side: Sell, Price: 101, Qty: 3},
+		{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Buy, Price: 99, Qty: 4},
+	}
+	for _, c := range submits {
+		if err := eng.TrySubmit(sym, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFile(t, snapshotPath(dir, sym, 2), 2*time.Second)
+	waitFile(t, cmdWalPath(dir, sym)+".00000000000000000002.archive", 2*time.Second)
+
+	if err := eng.TrySubmit(sym, Command{
+		Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 8, Side: Buy, Price: 100, Qty: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitFile(t, cmdWalPath(dir, sym), 2*time.Second)
+	time.Sleep(50 * time.Millisecond)
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// Run #2：快照 + WAL 尾部恢复
+	eng2 := NewEngine(newCfg())
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond) // 等 actor 退出再清理 TempDir
+	}()
+	a, err := eng2.getOrCreateActor(sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.seq != 3 {
+		t.Fatalf("seq after recovery=%d, want 3", a.seq)
+	}
+	b := a.book.(*HeapBookAdapter).B
+	if p, ok := b.BestAsk(); !ok || p != 101 {
+		t.Fatalf("best ask=%d/%v, want 101", p, ok)
+	}
+	if p, ok := b.BestBid(); !ok || p != 100 {
+		t.Fatalf("best bid=%d/%v, want 100", p, ok)
+	}
+}
+
+// 最新成交价随快照恢复：重启后价格带校验立即生效，不用等下一笔成交
+func TestSnapshot_RestartKeepsLastPrice(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	cfg := replTestCfg(dir)
+	cfg.SnapshotEvery = 2
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, MaxDeviationBps: 1000})
+	ctx := context.Background()
+
+	eng := NewEngine(cfg)
+	for _, c := range []Command{
+		{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 9, Side: Sell, Price: 100, Qty: 2},
+		{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Buy, Price: 100, Qty: 1},
+	} {
+		if _, err := eng.Submit(ctx, sym, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFile(t, snapshotPath(dir, sym, 2), 2*time.Second)
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if h, _, _, err := loadLatestSnapshot(dir, sym); err != nil || h.seq != 2 || h.lastPx != 100 {
+		t.Fatalf("snapshot: %+v %v", h, err)
+	}
+
+	eng = NewEngine(cfg)
+	defer func() {
+		eng.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 8, Side: Buy, Price: 150, Qty: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Events) != 1 || res.Events[0].Type != EvRejected || res.Events[0].Code != RejectPriceBand {
+		t.Fatalf("want band reject after restart: %+v", res.Events)
+	}
+
+	// v1 快照没有 lastPx：按还没有成交解码
+	b := encodeSnapshot(5, 0, symState{lastPx: 100}, nil, nil)
+	v1 := append(append([]byte(nil), b[:snapHeaderLenV1]...), b[snapHeaderLen:len(b)-snapCRCLen]...)
+	v1[4] = snapVersion1
+	v1 = binary.LittleEndian.AppendUint32(v1, crc32.ChecksumIEEE(v1))
+	if h, _, _, err := decodeSnapshot(v1); err != nil || h.seq != 5 || h.lastPx != 0 {
+		t.Fatalf("v1 decode: %+v %v", h, err)
+	}
+}
+
+func TestSnapshot_WriteFailureKeepsWAL(t *testing.T) {
+	const sym = "SNAPFAILUSDT" // 指标是进程级的，用独立 symbol
+	dir := t.TempDir()
+	// 临时文件位置被目录占住：快照写不进去
+	if err := os.MkdirAll(snapshotPath(dir, sym, 2)+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := replTestCfg(dir)
+	cfg.ActorCfg.BatchMax = 1
+	cfg.SnapshotEvery, cfg.SnapshotWALMode = 2, SnapshotWALTruncate
+	eng := NewEngine(cfg)
+	defer eng.Stop()
+	fails0 := testutil.ToFloat64(metrics.EngineSnapshotFailures.WithLabelValues(sym, "write"))
+
+	for i := uint64(1); i <= 3; i++ {
+		if _, err := eng.Submit(context.Background(), sym, Command{Type: CmdSubmitLimit, ReqID: i, UserID: 1, Side: Buy, Price: 100, Qty: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := testutil.ToFloat64(metrics.EngineSnapshotFailures.WithLabelValues(sym, "write")) - fails0; v != 1 {
+		t.Fatalf("write failures=%v, want 1 (no retry before the next period)", v)
+	}
+	// 没有新快照覆盖：cmd WAL 不能被截断
+	if _, err := os.Stat(cmdWalPath(dir, sym)); err != nil {
+		t.Fatalf("cmd wal removed: %v", err)
+	}
+	rec, err := RecordedEvents(dir, sym, EvCmdCodec{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := replayWith(t, ReplayConfig{WALDir: dir, Symbol: sym}, cfg.BookFactory)
+	if res.LastSeq != 3 || DiffEvents(res.Events, rec) >= 0 {
+		t.Fatalf("replay after failed snapshot: last=%d", res.LastSeq)
+	}
+}
+
+func TestSnapshot_TruncateKeepsWALFromOldestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	cfg := replTestCfg(dir)
+	cfg.ActorCfg.BatchMax = 1
+	cfg.SnapshotEvery, cfg.SnapshotKeep, cfg.SnapshotWALMode = 2, 2, SnapshotWALTruncate
+
+	// seq 2、4 各做一次快照；seq 4 时只截到最老保留快照 2，WAL 里还有 3..5
+	eng := NewEngine(cfg)
+	for i := uint64(1); i <= 5; i++ {
+		if _, err := eng.Submit(context.Background(), sym, Command{Type: CmdSubmitLimit, ReqID: i, UserID: 1, Side: Buy, Price: int64(90 + i), Qty: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := bookOrders(t, eng, sym)
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if seqs, _ := listSnapshots(dir, sym); len(seqs) != 2 || seqs[0] != 4 || seqs[1] != 2 {
+		t.Fatalf("snapshots=%v, want [4 2]", seqs)
+	}
+	var first uint64
+	_, _ = wal.Replay(cmdWalPath(dir, sym), wal.ReplayOptions{}, func(p []byte) error {
+		if seq, _, _ := (BinaryCMDCode{}).Decode(p); first == 0 {
+			first = seq
+		}
+		return nil
+	})
+	if first != 3 {
+		t.Fatalf("first WAL seq=%d, want 3", first)
+	}
+
+	// 最新快照坏了：退回 seq=2 + 回放 3..5
+	path := snapshotPath(dir, sym, 4)
+	b, _ := os.ReadFile(path)
+	b[snapHeaderLen] ^= 0xFF
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng2 := NewEngine(cfg)
+	if _, err := eng2.getOrCreateActor(sym); err != nil {
+		t.Fatal(err)
+	}
+	if got := bookOrders(t, eng2, sym); len(got) != len(want) {
+		t.Fatalf("recovered %d orders, want %d", len(got), len(want))
+	}
+	eng2.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// 老快照也没了：WAL 从 3 开始接不上，启动必须失败而不是丢命令
+	if err := os.Remove(snapshotPath(dir, sym, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEngine(cfg).getOrCreateActor(sym); !errors.Is(err, ErrWALGap) {
+		t.Fatalf("err=%v, want ErrWALGap", err)
+	}
+}
+
+func TestReplay_WALGapAfterSnapshot(t *testing.T) {
+	cmdPath := filepath.Join(t.TempDir(), "BTCUSDT.wal")
+	w, err := wal.OpenWrite(cmdPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seq := range []uint64{5, 6, 8} {
+		p, _ := BinaryCMDCode{}.Encode(nil, seq, Command{Type: CmdSubmitLimit, ReqID: seq, OrderID: seq, UserID: 1, Side: Buy, Price: 100, Qty: 1})
+		if err := w.Append(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 快照在 3：WAL 从 5 开始，4 丢了
+	if _, err := replayCmdWALAndFillOutbox(cmdPath, newHeapBook(), nil, 3, 0, BinaryCMDCode{}); !errors.Is(err, ErrWALGap) {
+		t.Fatalf("err=%v, want ErrWALGap", err)
+	}
+	// 快照在 4：5、6 接上了，7 丢了
+	if _, err := replayCmdWALAndFillOutbox(cmdPath, newHeapBook(), nil, 4, 0, BinaryCMDCode{}); !errors.Is(err, ErrWALGap) {
+		t.Fatalf("err=%v, want ErrWALGap", err)
+	}
+}
@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopherex.com/pkg/safe"
+	"gopherex.com/pkg/wal"
+)
+
+// 备库：每个 symbol 一条复制连接，收到的 cmd WAL 记录先原样写本地 WAL（偏移与主库一致），
+// 再用 tradeCounter apply 到本地簿（不产生事件、不写 outbox，只推进成交序号）
+// 提升（Promote）：拿到租约 → 停复制 → 把热簿写成快照 → 用本地 WALDir 起一个普通 Engine（加载快照，不用重放历史）
+// 注意：主库崩溃前已写 outbox 但还没发布的事件，备库不会补发（备库没有 outbox），下游需要能容忍
+
+type StandbyConfig struct {
+	Primary     string   // 主库复制地址 host:port
+	WALDir      string   // 本地 cmd WAL 副本目录；提升后作为引擎的 WALDir
+	Symbols     []string // 复制的交易对
+	BookFactory BookFactory
+	CmdCodec    CmdCodec
+	Retry       time.Duration // 断线重连间隔，默认 200ms
+}
+
+type Standby struct {
+	cfg    StandbyConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	reps   map[string]*replica
+	epoch  atomic.Uint64 // 见过的最大 fencing token
+}
+
+// replica：单个 symbol 的副本状态，只在它自己的复制协程里改
+type replica struct {
+	symbol string
+	path   string
+	book   OrderBook
+	stops  *stopBook
+	st     symState // 交易阶段 / 成交笔数 / 费率表 / STP 模式 / 频率窗口（写快照用），不出事件
+	w      walWriter
+	seq    atomic.Uint64
+	err    atomic.Value // 最近一次复制错误（string），排查用
+}
+
+// NewStandby：从本地 WAL 副本恢复各 symbol 的簿（尾部半写的记录会被截掉），不启动复制
+func NewStandby(cfg StandbyConfig) (*Standby, error) {
+	if cfg.WALDir == "" || cfg.BookFactory == nil || cfg.CmdCodec == nil {
+		return nil, errors.New("standby: WALDir/BookFactory/CmdCodec required")
+	}
+	if cfg.Retry <= 0 {
+		cfg.Retry = 200 * time.Millisecond
+	}
+	if err := os.MkdirAll(cfg.WALDir, 0o755); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Standby{cfg: cfg, ctx: ctx, cancel: cancel, reps: make(map[string]*replica, len(cfg.Symbols))}
+	for _, sym := range cfg.Symbols {
+		r, err := s.recover(sym)
+		if err != nil {
+			s.closeReplicas()
+			return nil, fmt.Errorf("standby %s: %w", sym, err)
+		}
+		s.reps[sym] = r
+	}
+	return s, nil
+}
+
+func (s *Standby) recover(symbol string) (*replica, error) {
+	book, err := s.cfg.BookFactory(symbol)
+	if err != nil {
+		return nil, err
+	}
+	r := &replica{symbol: symbol, path: cmdWalPath(s.cfg.WALDir, symbol), book: book, stops: newStopBook(), st: symState{limits: newUserLimiter()}}
+	st, err := replayLog(r.path, wal.ReplayOptions{AllowTruncatedTail: true}, func(payload []byte) error {
+		seq, cmd, err := s.cfg.CmdCodec.Decode(payload)
+		if err != nil {
+			return err
+		}
+		r.apply(seq, cmd)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if st.TruncatedTail {
+		if err := truncateLog(r.path, st.LastGoodOffset); err != nil {
+			return nil, err
+		}
+	}
+	if r.w, err = wal.OpenWrite(r.path, 0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *replica) apply(seq uint64, cmd Command) {
+	r.st.advance(cmd)
+	applyCommand(r.book, r.stops, seq, cmd, tradeCounter{trades: &r.st.trades})
+	r.seq.Store(seq)
+}
+
+// Start：每个 symbol 起一个复制协程（断线自动重连）
+func (s *Standby) Start() {
+	for _, r := range s.reps {
+		s.wg.Add(1)
+		safe.Go(func() {
+			defer s.wg.Done()
+			for s.ctx.Err() == nil {
+				if err := s.session(s.ctx, r); err != nil {
+					r.err.Store(err.Error())
+				}
+				select {
+				case <-s.ctx.Done():
+				case <-time.After(s.cfg.Retry):
+				}
+			}
+		})
+	}
+}
+
+// Seq：该 symbol 已应用的最大 seq
+func (s *Standby) Seq(symbol string) uint64 {
+	if r := s.reps[symbol]; r != nil {
+		return r.seq.Load()
+	}
+	return 0
+}
+
+// LastError：该 symbol 最近一次复制断开的原因（排查用）
+func (s *Standby) LastError(symbol string) string {
+	if r := s.reps[symbol]; r != nil {
+		if v, ok := r.err.Load().(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Epoch：见过的最大 fencing token
+func (s *Standby) Epoch() uint64 { return s.epoch.Load() }
+
+// Stop：停止复制并关闭本地 WAL（不提升）
+func (s *Standby) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.closeReplicas()
+}
+
+func (s *Standby) closeReplicas() {
+	for _, r := range s.reps {
+		if r.w != nil {
+			_ = r.w.Close()
+			r.w = nil
+		}
+	}
+}
+
+// session：一次复制连接，返回即断开
+func (s *Standby) session(ctx context.Context, r *replica) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Primary)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	from := r.w.(offsetWriter).Offset()
+	hello := make([]byte, 16, 16+len(r.symbol))
+	binary.LittleEndian.PutUint64(hello[:8], uint64(from))
+	binary.LittleEndian.PutUint64(hello[8:16], s.epoch.Load())
+	hello = append(hello, r.symbol...)
+	if err := wal.WriteFrame(conn, hello); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(conn, 1<<16)
+	_ = conn.SetReadDeadline(time.Now().Add(replTimeout))
+	resp, err := wal.ReadFrame(br, 0)
+	if err != nil {
+		return err
+	}
+	if len(resp) < 9 {
+		return errors.New("replication: bad response")
+	}
+	token := binary.LittleEndian.Uint64(resp[:8])
+	if resp[8] != replOK {
+		return fmt.Errorf("replication: primary: %s", resp[9:])
+	}
+	// 旧主（token 比见过的小）：不接它的数据
+	if token < s.epoch.Load() {
+		return ErrFenced
+	}
+	s.epoch.Store(token)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(replTimeout))
+		payload, err := wal.ReadFrame(br, 0)
+		if err != nil {
+			return err
+		}
+		if len(payload) == 0 {
+			continue // 心跳
+		}
+		seq, cmd, err := s.cfg.CmdCodec.Decode(payload)
+		if err != nil {
+			return err
+		}
+		if last := r.seq.Load(); last != 0 && seq != last+1 {
+			return fmt.Errorf("replication: seq gap %d -> %d", last, seq)
+		}
+		// 先落本地 WAL 再 apply；这一段收完（缓冲读空）再 flush，相当于主库的一个 batch
+		if err := r.w.Append(payload); err != nil {
+			return err
+		}
+		r.apply(seq, cmd)
+		if br.Buffered() == 0 {
+			if err := r.w.Flush(); err != nil {
+				return err
+			}
+			// 落盘后才 ack：主库据此保留还没拉到的 WAL
+			if err := replAck(conn, r.w.(offsetWriter).Offset()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Promote：提升为主库
+// 1) 阻塞拿租约（旧主的租约过期/释放后才能拿到） 2) 停复制 3) 热簿写快照 4) 用本地 WALDir 起引擎并受租约保护
+// cfg 的 WALDir/EnableCmdWAL 会被覆盖；返回时所有复制的 symbol 已经加载完成，可以直接接单
+func (s *Standby) Promote(ctx context.Context, lease Lease, cfg EngineConfig) (*Engine, uint64, error) {
+	token, err := lease.Acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.cancel()
+	s.wg.Wait()
+	for _, r := range s.reps {
+		if err := r.w.Flush(); err != nil {
+			return nil, 0, err
+		}
+		if seq := r.seq.Load(); seq > 0 {
+			if sb, ok := r.book.(BookSnapshotter); ok {
+				walOff := r.w.(offsetWriter).Offset()
+				st := r.st
+				st.lastPx = bookLastPrice(r.book)
+				if err := writeSnapshot(s.cfg.WALDir, r.symbol, seq, walOff, st, sb.SnapshotOrders(), r.stops.orders()); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+	}
+	s.closeReplicas()
+	if token > s.epoch.Load() {
+		s.epoch.Store(token)
+	}
+
+	cfg.WALDir, cfg.EnableCmdWAL = s.cfg.WALDir, true
+	if cfg.CmdCodec == nil {
+		cfg.CmdCodec = s.cfg.CmdCodec
+	}
+	if cfg.BookFactory == nil {
+		cfg.BookFactory = s.cfg.BookFactory
+	}
+	eng := NewEngine(cfg)
+	eng.FenceWith(lease)
+	for sym := range s.reps {
+		if _, err := eng.getOrCreateActor(sym); err != nil {
+			eng.Stop()
+			return nil, 0, err
+		}
+	}
+	return eng, token, nil
+}
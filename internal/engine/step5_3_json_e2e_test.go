@@ -27,65 +27,68 @@ func waitTrade(t *testing.T, ch <-chan Event, timeout time.Duration) Event {
 }
 
 func TestStep5_3_E2E_JSONWalDump(t *testing.T) {
-	const sym = "BTCUSDT"
-	walDir := "./logs/"
-
-	// Run1：开 cmdWAL + outbox，但不开 publisher（模拟“先把事实写进 outbox”，不发给下游）
-	{
-
-		cfg := EngineConfig{
-			WALDir: walDir,
+	writeStep5_3WAL(t, t.TempDir())
+}
 
-			EnableCmdWAL:    true,
-			EnableOutbox:    true,
-			EnablePublisher: false, // 关键：Run1 不发
+// writeStep5_3WAL：开 cmdWAL + outbox，但不开 publisher（模拟“先把事实写进 outbox”，不发给下游）
+func writeStep5_3WAL(t *testing.T, walDir string) {
+	t.Helper()
+	const sym = "BTCUSDT"
 
-			// 你已实现的注入点：用 JSON 让 payload 可读
-			CmdCodec: JSONCmdCodec{Version: 1},
-			EvCodec:  JSONEvCodec{Version: 1},
+	cfg := EngineConfig{
+		WALDir: walDir,
 
-			ActorCfg: ActorConfig{MailboxSize: 4096, BatchMax: 256},
+		EnableCmdWAL:    true,
+		EnableOutbox:    true,
+		EnablePublisher: false, // 关键：不发
 
-			// 用你现有的 LevelOrderBookHeap（通过 Adapter）
-			BookFactory: func(symbol string) (OrderBook, error) {
-				// 这里假设你已经写好了 HeapBookAdapter，并实现了新的 OrderBook 接口（带 reqID）
-				return &HeapBookAdapter{B: matching.NewLevelOrderHeapBook()}, nil
-			},
-		}
+		// 你已实现的注入点：用 JSON 让 payload 可读
+		CmdCodec: JSONCmdCodec{Version: 1},
+		EvCodec:  JSONEvCodec{Version: 1},
 
-		eng := NewEngine(cfg) // 按你仓库实际构造函数名
-
-		// 两笔单撮合成交
-		if err := eng.TrySubmit(sym, Command{
-			Type:    CmdSubmitLimit,
-			ReqID:   5,
-			OrderID: 1005,
-			UserID:  2001,
-			Side:    Buy,
-			Price:   90,
-			Qty:     100,
-		}); err != nil {
-			t.Fatal(err)
-		}
-		if err := eng.TrySubmit(sym, Command{
-			Type:    CmdSubmitLimit,
-			ReqID:   6,
-			OrderID: 1006,
-			UserID:  2002,
-			Side:    Sell,
-			Price:   89,
-			Qty:     20,
-		}); err != nil {
-			t.Fatal(err)
-		}
+		ActorCfg: ActorConfig{MailboxSize: 4096, BatchMax: 256},
 
-		// 让 actor 有时间跑完 batch（也可以更严谨：等待某个条件）
-		time.Sleep(80 * time.Millisecond)
+		// 用你现有的 LevelOrderBookHeap（通过 Adapter）
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return &HeapBookAdapter{B: matching.NewLevelOrderHeapBook()}, nil
+		},
+	}
 
+	eng := NewEngine(cfg) // 按你仓库实际构造函数名
+
+	// 两笔单撮合成交
+	if err := eng.TrySubmit(sym, Command{
+		Type:    CmdSubmitLimit,
+		ReqID:   5,
+		OrderID: 1005,
+		UserID:  2001,
+		Side:    Buy,
+		Price:   90,
+		Qty:     100,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.TrySubmit(sym, Command{
+		Type:    CmdSubmitLimit,
+		ReqID:   6,
+		OrderID: 1006,
+		UserID:  2002,
+		Side:    Sell,
+		Price:   89,
+		Qty:     20,
+	}); err != nil {
+		t.Fatal(err)
 	}
+
+	// 让 actor 有时间跑完 batch（也可以更严谨：等待某个条件）
+	time.Sleep(80 * time.Millisecond)
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond) // 等 actor 关闭文件
 }
-func TestReader(t *testing.T) {
-	DumpCmdWALPretty(t, cmdWalPath("./logs/", "BTCUSDT"))
-	DumpEvWALPretty(t, outboxWalPath("./logs/", "BTCUSDT"))
 
+func TestReader(t *testing.T) {
+	dir := t.TempDir()
+	writeStep5_3WAL(t, dir)
+	DumpCmdWALPretty(t, cmdWalPath(dir, "BTCUSDT"))
+	DumpEvWALPretty(t, outboxWalPath(dir, "BTCUSDT"))
 }
@@ -22,16 +22,20 @@ type mockBook struct {
 	cancelCalls uint64
 }
 
-func (m *mockBook) SubmitLimit(reqID, orderID, userID uint64, side uint8, price, qty int64, emit Emitter) {
+func (m *mockBook) Submit(reqID uint64, o OrderSpec, emit Emitter) {
 	atomic.AddUint64(&m.submitCalls, 1)
 	// 这里不重要：随便发一个 Added，便于 outbox 看得见
-	emit.Added(reqID, orderID, userID)
+	emit.Added(reqID, o.OrderID, o.UserID)
 }
 func (m *mockBook) Cancel(reqID, orderID uint64, emit Emitter) bool {
 	atomic.AddUint64(&m.cancelCalls, 1)
 	emit.Cancelled(reqID, orderID)
 	return true
 }
+func (m *mockBook) CancelAllForUser(reqID, userID uint64, emit Emitter) int { return 0 }
+func (m *mockBook) Amend(reqID uint64, a AmendSpec, emit Emitter) {
+	emit.Amended(reqID, a.OrderID, a.UserID, a.Price, a.Qty)
+}
 
 type failingWal struct {
 	appendErr       error
@@ -223,7 +227,7 @@ func TestFail_Outbox_AppendFail_ActorExit_AfterWAL(t *testing.T) {
 }
 
 func TestFail_InvalidCmd_WritesRejectedAndCmdEnd(t *testing.T) {
-	dir := "./logs/"
+	dir := t.TempDir()
 	sym := "BTCUSDT"
 	evPath := outboxWalPath(dir, sym)
 
@@ -271,7 +275,7 @@ func TestFail_InvalidCmd_WritesRejectedAndCmdEnd(t *testing.T) {
 }
 
 func TestFail_Outbox_ScanRepair_TruncatedTail(t *testing.T) {
-	dir := "./logs/"
+	dir := t.TempDir()
 	sym := "BTCUSDT"
 	evPath := outboxWalPath(dir, sym)
 
@@ -313,7 +317,7 @@ func TestFail_Outbox_ScanRepair_TruncatedTail(t *testing.T) {
 }
 
 func TestFail_CmdWAL_ChecksumMismatch_ReplayError(t *testing.T) {
-	dir := "./logs/"
+	dir := t.TempDir()
 	sym := "BTCUSDT"
 	cmdPath := cmdWalPath(dir, sym)
 
@@ -353,7 +357,7 @@ func TestFail_CmdWAL_ChecksumMismatch_ReplayError(t *testing.T) {
 	// replay：应报 ErrChecksumMismatch（或你 wal 包对应的错误）
 	book := &mockBook{}
 	var cmdCode = JSONCmdCodec{Version: 1}
-	_, err = replayCmdWALAndFillOutbox(cmdPath, book, nil, 0, cmdCode /*lastCompleteSeq*/)
+	_, err = replayCmdWALAndFillOutbox(cmdPath, book, nil, 0, 0, cmdCode /*lastCompleteSeq*/)
 	if err == nil {
 		t.Fatalf("expected replay error on checksum mismatch")
 	}
@@ -0,0 +1,255 @@
+package engine
+
+import "sort"
+
+// 止损单（stop-market / stop-limit）
+// - 挂在 actor 的触发簿里，不进 matching 的订单簿（触发前对盘口不可见）
+// - 触发条件只看受理之后的成交价（Emitter.Trade）：买 成交价>=StopPrice，卖 成交价<=StopPrice
+//   不依赖“当前最新价”这种额外状态，回放 cmd WAL 天然得到同样的触发
+// - 触发后在同一 seq 内作为 taker 提交（Price=0 市价，否则限价）；成交可能继续触发（级联）
+
+// StopOrder：触发簿里的一条止损单（也是快照记录）
+type StopOrder struct {
+	Seq           uint64 // 受理 seq：同一轮触发按它排序
+	ReqID         uint64 // 原始请求号：触发后的事件沿用
+	OrderID       uint64
+	UserID        uint64
+	Side          uint8
+	StopPrice     int64
+	Price         int64 // 0 = stop-market
+	Qty           int64
+	TIF           TimeInForce
+	ClientOrderID uint64
+}
+
+func (s StopOrder) spec() OrderSpec {
+	o := OrderSpec{OrderID: s.OrderID, UserID: s.UserID, Side: s.Side, Price: s.Price, Qty: s.Qty, TIF: s.TIF, ClientID: s.ClientOrderID}
+	if s.Price == 0 {
+		o.Market = true
+		if o.TIF == TifGTC {
+			o.TIF = TifIOC
+		}
+	}
+	return o
+}
+
+// stopBook：买单按 StopPrice 升序、卖单按降序（同价按受理顺序），触发只看队头
+type stopBook struct {
+	buys  []StopOrder
+	sells []StopOrder
+	users map[uint64]userLoad // 按用户累计的单数/名义价值（用户级限额用），增删时维护
+}
+
+func newStopBook() *stopBook { return &stopBook{users: make(map[uint64]userLoad)} }
+
+// load：一张止损单占用的额度（止损市价单按触发价算）
+func (s StopOrder) load() userLoad {
+	px := s.Price
+	if px == 0 {
+		px = s.StopPrice
+	}
+	return userLoad{orders: 1, notional: mulNotional(px, s.Qty)}
+}
+
+func (b *stopBook) removed(s StopOrder) {
+	u := b.users[s.UserID].minus(s.load())
+	if u.orders == 0 {
+		delete(b.users, s.UserID)
+		return
+	}
+	b.users[s.UserID] = u
+}
+
+func (b *stopBook) add(s StopOrder) {
+	b.users[s.UserID] = b.users[s.UserID].plus(s.load())
+	if s.Side == Buy {
+		i := sort.Search(len(b.buys), func(i int) bool { return b.buys[i].StopPrice > s.StopPrice })
+		b.buys = insertStop(b.buys, i, s)
+		return
+	}
+	i := sort.Search(len(b.sells), func(i int) bool { return b.sells[i].StopPrice < s.StopPrice })
+	b.sells = insertStop(b.sells, i, s)
+}
+
+func insertStop(q []StopOrder, i int, s StopOrder) []StopOrder {
+	q = append(q, StopOrder{})
+	copy(q[i+1:], q[i:])
+	q[i] = s
+	return q
+}
+
+// cancel：按订单号撤（撤单命令先查触发簿，再查订单簿）
+func (b *stopBook) cancel(orderID uint64) (StopOrder, bool) {
+	for _, q := range []*[]StopOrder{&b.buys, &b.sells} {
+		for i, s := range *q {
+			if s.OrderID == orderID {
+				*q = append((*q)[:i], (*q)[i+1:]...)
+				b.removed(s)
+				return s, true
+			}
+		}
+	}
+	return StopOrder{}, false
+}
+
+// cancelUser：撤掉该用户所有止损单，按受理顺序返回
+func (b *stopBook) cancelUser(userID uint64) []StopOrder {
+	var out []StopOrder
+	for _, q := range []*[]StopOrder{&b.buys, &b.sells} {
+		keep := (*q)[:0]
+		for _, s := range *q {
+			if s.UserID == userID {
+				out = append(out, s)
+			} else {
+				keep = append(keep, s)
+			}
+		}
+		*q = keep
+	}
+	delete(b.users, userID)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+// fire：取出被 [lo, hi] 成交价区间触发的止损单，按受理顺序返回
+func (b *stopBook) fire(hi, lo int64) []StopOrder {
+	var out []StopOrder
+	n := 0
+	for n < len(b.buys) && b.buys[n].StopPrice <= hi {
+		n++
+	}
+	out = append(out, b.buys[:n]...)
+	b.buys = b.buys[n:]
+	n = 0
+	for n < len(b.sells) && b.sells[n].StopPrice >= lo {
+		n++
+	}
+	out = append(out, b.sells[:n]...)
+	b.sells = b.sells[n:]
+	for _, s := range out {
+		b.removed(s)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+// hasClient：该用户是否有同一客户端订单号的止损单（触发簿通常很小，直接扫）
+func (b *stopBook) hasClient(userID, clientOrderID uint64) bool {
+	for _, q := range [][]StopOrder{b.buys, b.sells} {
+		for _, s := range q {
+			if s.UserID == userID && s.ClientOrderID == clientOrderID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// userLoad：该用户未触发止损单的数量与名义价值（止损市价单按触发价算）
+func (b *stopBook) userLoad(userID uint64) userLoad { return b.users[userID] }
+
+func (b *stopBook) len() int { return len(b.buys) + len(b.sells) }
+
+// orders：快照导出（买单在前，各自按触发优先级）
+func (b *stopBook) orders() []StopOrder {
+	out := make([]StopOrder, 0, b.len())
+	out = append(out, b.buys...)
+	return append(out, b.sells...)
+}
+
+// restore：在空触发簿上恢复（add 会重新排序，顺序无关）
+func (b *stopBook) restore(orders []StopOrder) {
+	for _, s := range orders {
+		b.add(s)
+	}
+}
+
+// tradeRange：包一层 Emitter，记录区间内成交价的最高/最低（触发用）
+type tradeRange struct {
+	Emitter
+	hi, lo int64
+	n      int
+}
+
+func (t *tradeRange) Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64) {
+	if t.n == 0 || price > t.hi {
+		t.hi = price
+	}
+	if t.n == 0 || price < t.lo {
+		t.lo = price
+	}
+	t.n++
+	t.Emitter.Trade(reqID, makerOrderID, takerOrderID, makerUserID, takerUserID, takerSide, price, qty)
+}
+
+// take：取出并清空当前区间
+func (t *tradeRange) take() (hi, lo int64, ok bool) {
+	hi, lo, ok = t.hi, t.lo, t.n > 0
+	t.hi, t.lo, t.n = 0, 0, 0
+	return hi, lo, ok
+}
+
+// stopFromCmd：校验止损单参数（触发价必填；限价单才允许 TIF/价格，且不支持 PostOnly）
+func stopFromCmd(seq uint64, cmd Command) (StopOrder, bool) {
+	if cmd.OrderID == 0 || cmd.Qty <= 0 || (cmd.Side != Buy && cmd.Side != Sell) ||
+		cmd.StopPrice <= 0 || cmd.Price < 0 || cmd.TIF > TifFOK || cmd.PostOnly {
+		return StopOrder{}, false
+	}
+	return StopOrder{
+		Seq: seq, ReqID: cmd.ReqID, OrderID: cmd.OrderID, UserID: cmd.UserID, Side: cmd.Side,
+		StopPrice: cmd.StopPrice, Price: cmd.Price, Qty: cmd.Qty, TIF: cmd.TIF, ClientOrderID: cmd.ClientOrderID,
+	}, true
+}
+
+// applyCommand：actor 与 replay 的统一入口 = applyCommandToBook + 止损单
+// stops 为 nil 时等同 applyCommandToBook（止损单按未知命令拒）
+func applyCommand(book OrderBook, stops *stopBook, seq uint64, cmd Command, emit Emitter) {
+	if stops == nil {
+		applyCommandToBook(book, cmd, emit)
+		return
+	}
+	tr := &tradeRange{Emitter: emit}
+	switch {
+	case cmd.Reject != RejectNone:
+		applyCommandToBook(book, cmd, emit)
+		return
+	case cmd.Type == CmdSubmitStop:
+		s, ok := stopFromCmd(seq, cmd)
+		if !ok {
+			emit.Rejected(cmd.ReqID, cmd.OrderID, cmd.UserID, RejectBadParams)
+			return
+		}
+		stops.add(s)
+		emit.StopAccepted(cmd.ReqID, s.OrderID, s.UserID, s.StopPrice, s.Qty)
+		return
+	case cmd.Type == CmdCancel && cmd.CancelOrderID != 0:
+		if s, ok := stops.cancel(cmd.CancelOrderID); ok {
+			emit.StopCancelled(cmd.ReqID, s.OrderID, s.UserID)
+			return
+		}
+		applyCommandToBook(book, cmd, tr)
+	case cmd.Type == CmdCancelAll && cmd.UserID != 0:
+		applyCommandToBook(book, cmd, tr)
+		for _, s := range stops.cancelUser(cmd.UserID) {
+			emit.StopCancelled(cmd.ReqID, s.OrderID, s.UserID)
+		}
+	default:
+		applyCommandToBook(book, cmd, tr)
+	}
+
+	// 级联触发：每一轮只看上一轮产生的成交
+	for {
+		hi, lo, ok := tr.take()
+		if !ok || stops.len() == 0 {
+			return
+		}
+		for _, s := range stops.fire(hi, lo) {
+			px := lo
+			if s.Side == Buy {
+				px = hi
+			}
+			emit.StopTriggered(s.ReqID, s.OrderID, s.UserID, px, s.Qty)
+			book.Submit(s.ReqID, s.spec(), tr)
+		}
+	}
+}
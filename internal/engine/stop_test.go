@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestStop_TriggerAndCascade(t *testing.T) {
+	book, stops := newHeapBook(), newStopBook()
+	seedAsk(book, 1, 100, 1)
+	seedAsk(book, 2, 101, 2)
+	run := func(seq uint64, cmd Command) *recEmitter {
+		em := &recEmitter{}
+		applyCommand(book, stops, seq, cmd, em)
+		return em
+	}
+
+	// stop-market 100 买 2；stop-limit 101 买 1 @105
+	em := run(1, Command{Type: CmdSubmitStop, ReqID: 10, OrderID: 10, UserID: 7, Side: Buy, StopPrice: 100, Qty: 2})
+	assertTypes(t, em.types(), EvStopNew)
+	run(2, Command{Type: CmdSubmitStop, ReqID: 11, OrderID: 11, UserID: 7, Side: Buy, StopPrice: 101, Price: 105, Qty: 1})
+
+	// 成交 @100 → 触发 10（吃掉 101 的 2 张）→ 成交 @101 → 级联触发 11（无对手盘，挂单）
+	em = run(3, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 8, Side: Buy, Price: 100, Qty: 1})
+	assertTypes(t, em.types(),
+		EvAccepted, EvTrade,
+		EvStopFire, EvAccepted, EvTrade,
+		EvStopFire, EvAccepted, EvAdded,
+	)
+	if ev := em.evs[2]; ev.OrderID != 10 || ev.Price != 100 || ev.ReqID != 10 {
+		t.Fatalf("first trigger=%+v", ev)
+	}
+	if ev := em.evs[4]; ev.TakerOrderID != 10 || ev.Price != 101 || ev.Qty != 2 {
+		t.Fatalf("stop fill=%+v", ev)
+	}
+	if ev := em.evs[5]; ev.OrderID != 11 || ev.Price != 101 {
+		t.Fatalf("cascade trigger=%+v", ev)
+	}
+	if stops.len() != 0 {
+		t.Fatalf("stops left=%d", stops.len())
+	}
+}
+
+func TestStop_CancelAndParams(t *testing.T) {
+	book, stops := newHeapBook(), newStopBook()
+	run := func(cmd Command) *recEmitter {
+		em := &recEmitter{}
+		applyCommand(book, stops, cmd.ReqID, cmd, em)
+		return em
+	}
+	run(Command{Type: CmdSubmitStop, ReqID: 1, OrderID: 1, UserID: 7, Side: Sell, StopPrice: 90, Qty: 1})
+	run(Command{Type: CmdSubmitStop, ReqID: 2, OrderID: 2, UserID: 7, Side: Buy, StopPrice: 110, Qty: 1})
+	run(Command{Type: CmdSubmitStop, ReqID: 3, OrderID: 3, UserID: 8, Side: Buy, StopPrice: 120, Qty: 1})
+
+	em := run(Command{Type: CmdCancel, ReqID: 4, CancelOrderID: 3})
+	assertTypes(t, em.types(), EvStopCxl)
+	em = run(Command{Type: CmdCancelAll, ReqID: 5, UserID: 7})
+	assertTypes(t, em.types(), EvStopCxl, EvStopCxl)
+	if em.evs[0].OrderID != 1 || em.evs[1].OrderID != 2 {
+		t.Fatalf("cancel-all order: %+v", em.evs)
+	}
+
+	em = run(Command{Type: CmdSubmitStop, ReqID: 6, OrderID: 6, UserID: 7, Side: Buy, Qty: 1})
+	assertTypes(t, em.types(), EvRejected)
+	if em.evs[0].Code != RejectBadParams {
+		t.Fatalf("missing stop price: %+v", em.evs[0])
+	}
+}
+
+func TestStop_SnapshotRestartTriggers(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	newEng := func() *Engine {
+		return NewEngine(EngineConfig{
+			WALDir:        dir,
+			EnableCmdWAL:  true,
+			EnableOutbox:  true,
+			CmdCodec:      BinaryCMDCode{},
+			EvCodec:       EvCmdCodec{},
+			ActorCfg:      ActorConfig{MailboxSize: 64, BatchMax: 1},
+			SnapshotEvery: 2,
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		})
+	}
+	ctx := context.Background()
+
+	// Run #1：止损单 + 两档卖单，seq=2 时快照（止损单在快照里），seq=3 只在 WAL 尾部
+	eng := newEng()
+	for _, c := range []Command{
+		{Type: CmdSubmitStop, ReqID: 1, OrderID: 1, UserID: 7, Side: Buy, StopPrice: 100, Qty: 1},
+		{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 9, Side: Sell, Price: 100, Qty: 1},
+		{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 9, Side: Sell, Price: 102, Qty: 1},
+	} {
+		if res, err := eng.Submit(ctx, sym, c); err != nil || !res.Accepted {
+			t.Fatalf("submit %d: %+v %v", c.OrderID, res, err)
+		}
+	}
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	eng2 := newEng()
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	res, err := eng2.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 4, OrderID: 4, UserID: 8, Side: Buy, Price: 100, Qty: 1})
+	if err != nil || len(res.Trades) != 2 {
+		t.Fatalf("after restart: %+v %v", res, err)
+	}
+	if tr := res.Trades[1]; tr.TakerOrderID != 1 || tr.Price != 102 {
+		t.Fatalf("stop fill=%+v", tr)
+	}
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestSTP_EmitsExplicitEvents(t *testing.T) {
+	hb := matching.NewLevelOrderHeapBook()
+	hb.SetSTP(matching.STPDecrement)
+	book := NewHeapBookAdapter(hb)
+	seedAsk(book, 1, 100, 2) // user 9
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Sell, Price: 100, Qty: 2}, noopEmitter{})
+
+	// 同一用户买 3：与 1 互减 2（1 被撤光），剩 1 与 2 成交
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 10, OrderID: 10, UserID: 9, Side: Buy, Price: 100, Qty: 3}, em)
+	assertTypes(t, em.types(), EvAccepted, EvSelfTrade, EvSelfTrade, EvTrade)
+	if em.evs[1].OrderID != 1 || em.evs[1].Qty != 2 || em.evs[2].OrderID != 10 || em.evs[2].Qty != 2 {
+		t.Fatalf("unexpected stp events: %+v", em.evs[1:3])
+	}
+	if em.evs[1].MakerOrderID != 1 || em.evs[1].TakerOrderID != 10 {
+		t.Fatalf("stp pair not set: %+v", em.evs[1])
+	}
+
+	// cancel newest：taker 剩余被撤，不应再有 Added/Expired
+	hb.SetSTP(matching.STPCancelNewest)
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 8, Side: Buy, Price: 100, Qty: 5}, em)
+	assertTypes(t, em.types(), EvAccepted, EvSelfTrade)
+	if em.evs[1].OrderID != 11 || em.evs[1].Qty != 5 {
+		t.Fatalf("unexpected stp event: %+v", em.evs[1])
+	}
+}
+
+// FOK + STP：自己的挂单不算可成交量，不够就整单过期，不会先互减再部分成交
+func TestSTP_FOKAllOrNothing(t *testing.T) {
+	hb := matching.NewLevelOrderHeapBook()
+	hb.SetSTP(matching.STPDecrement)
+	book := NewHeapBookAdapter(hb)
+	seedAsk(book, 1, 100, 5) // user 9
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Sell, Price: 101, Qty: 5}, noopEmitter{})
+
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 10, OrderID: 10, UserID: 9, Side: Buy, Price: 101, Qty: 6, TIF: TifFOK}, em)
+	assertTypes(t, em.types(), EvAccepted, EvExpired)
+	if o, ok := hb.Order(1); !ok || o.Qty != 5 {
+		t.Fatalf("own maker touched: %+v %v", o, ok)
+	}
+
+	// CancelOldest：自己的单会被撤掉，只要别人的量够就整单成交
+	hb.SetSTP(matching.STPCancelOldest)
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 9, Side: Buy, Price: 101, Qty: 5, TIF: TifFOK}, em)
+	assertTypes(t, em.types(), EvAccepted, EvSelfTrade, EvTrade)
+	if em.evs[2].Qty != 5 || em.evs[2].Price != 101 {
+		t.Fatalf("trade: %+v", em.evs[2])
+	}
+}
+
+// STP 模式按交易对配置，actor 经 CmdSetSTP 落 WAL 后设到簿上
+func TestSTP_ConfiguredPerSymbol(t *testing.T) {
+	cfg := replTestCfg(t.TempDir())
+	cfg.Symbols = newTestRegistry(t,
+		SymbolSpec{Symbol: "BTCUSDT", STP: matching.STPCancelNewest},
+		SymbolSpec{Symbol: "ETHUSDT"},
+	)
+	f, err := NewBookFactory(BookSkipList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BookFactory = f
+	eng := NewEngine(cfg)
+	defer eng.Stop()
+
+	for sym, want := range map[string]EventType{"BTCUSDT": EvSelfTrade, "ETHUSDT": EvTrade} {
+		submit := func(c Command) Result {
+			res, err := eng.Submit(context.Background(), sym, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res
+		}
+		submit(Command{Type: CmdSubmitLimit, ReqID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 1})
+		res := submit(Command{Type: CmdSubmitLimit, ReqID: 2, UserID: 7, Side: Buy, Price: 100, Qty: 1})
+		if len(res.Events) < 2 || res.Events[1].Type != want {
+			t.Fatalf("%s: %+v", sym, res.Events)
+		}
+	}
+}
+
+// 运行中改 STP：改动经 CmdSetSTP 落 WAL，重启（快照 + WAL 尾）和离线回放都按当时的模式撮合
+func TestSTP_ChangeLoggedAndReplayed(t *testing.T) {
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	cfg.SnapshotEvery = 3
+	spec := SymbolSpec{Symbol: "BTCUSDT"}
+	cfg.Symbols = newTestRegistry(t, spec)
+	ctx := context.Background()
+	submit := func(eng *Engine, c Command) Result {
+		t.Helper()
+		res, err := eng.Submit(ctx, "BTCUSDT", c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	eng := NewEngine(cfg)
+	submit(eng, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 5})
+	if res := submit(eng, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 7, Side: Buy, Price: 100, Qty: 1}); len(res.Trades) != 1 {
+		t.Fatalf("stp none: %+v", res.Events)
+	}
+	spec.STP = matching.STPCancelNewest
+	if err := cfg.Symbols.Register(spec); err != nil {
+		t.Fatal(err)
+	}
+	if res := submit(eng, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 7, Side: Buy, Price: 100, Qty: 1}); len(res.Trades) != 0 {
+		t.Fatalf("stp cancel newest: %+v", res.Events)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// 重启前把注册表改回去：簿仍按 WAL / 快照里的模式，直到 actor 写下新的 CmdSetSTP
+	spec.STP = matching.STPNone
+	if err := cfg.Symbols.Register(spec); err != nil {
+		t.Fatal(err)
+	}
+	eng = NewEngine(cfg)
+	a, err := eng.getOrCreateActor("BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.stp != matching.STPCancelNewest {
+		t.Fatalf("stp after restart=%d", a.stp)
+	}
+	if res := submit(eng, Command{Type: CmdSubmitLimit, ReqID: 4, OrderID: 4, UserID: 7, Side: Buy, Price: 100, Qty: 1}); len(res.Trades) != 1 {
+		t.Fatalf("stp back to none: %+v", res.Events)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	f, err := NewBookFactory(BookHeap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := Replay(ReplayConfig{WALDir: dir, Symbol: "BTCUSDT", BookFactory: f, CmdCodec: BinaryCMDCode{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := RecordedEvents(dir, "BTCUSDT", EvCmdCodec{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := DiffEvents(full.Events, rec); i >= 0 {
+		t.Fatalf("replay differs at %d", i)
+	}
+}
+
+func TestSTP_CmdCodecSetSTP(t *testing.T) {
+	cmd := Command{Type: CmdSetSTP, STP: matching.STPDecrement}
+	p, err := BinaryCMDCode{}.Encode(nil, 9, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, out, err := BinaryCMDCode{}.Decode(p)
+	if err != nil || seq != 9 || out.Type != CmdSetSTP || out.STP != matching.STPDecrement {
+		t.Fatalf("roundtrip: %d %+v %v", seq, out, err)
+	}
+	if _, _, err := (BinaryCMDCode{}).Decode(p[:len(p)-1]); err == nil {
+		t.Fatal("truncated CmdSetSTP decoded")
+	}
+}
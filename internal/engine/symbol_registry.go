@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+
+	"gopherex.com/internal/matching"
+)
+
+// 交易对状态：零值 Open，兼容未配置的情况
+type SymbolStatus uint8
+
+const (
+	SymbolOpen       SymbolStatus = iota // 正常交易
+	SymbolCancelOnly                     // 只允许撤单
+	SymbolHalted                         // 停牌：所有命令都拒
+)
+
+// SymbolSpec：交易对规则；各项为 0 表示不校验
+type SymbolSpec struct {
+	Symbol          string
+	ID              uint16 // 交易对编号（1..MaxSymbolID）：引擎订单号/成交号的高位；建 actor 时生效，分配后不能改（见 ids.go）
+	TickSize        int64  // 价格最小变动单位：Price % TickSize == 0
+	LotSize         int64  // 数量最小变动单位：Qty % LotSize == 0
+	MinNotional     int64  // 最小名义价值 Price*Qty（市价单用最新成交价估算）
+	MaxDeviationBps int64  // 相对最新成交价的最大偏离（万分比），没有成交价时不校验
+	Status          SymbolStatus
+	Base, Quote     string       // 资产代码（手续费资产 / 结算）
+	Fees            *FeeSchedule // 手续费率；nil 不收
+	// 自成交防护模式：actor 发现变化时写一条 CmdSetSTP，从 WAL 里这条命令起生效
+	STP matching.STPMode
+
+	// 单用户限额（见 user_limits.go）
+	MaxOpenOrders   int   // 未完结订单数（挂单 + 未触发止损单）
+	MaxOrderRate    int   // 每秒下单数（按引擎时钟 Command.EngineTs）
+	MaxOpenNotional int64 // 未完结订单名义价值 Price*Qty 之和
+}
+
+// SymbolRegistry：交易对注册表（并发安全，运行时可改状态/规则）
+// 校验在 actor 写 cmd WAL 之前做，结果码随命令一起落 WAL，回放不再依赖注册表
+type SymbolRegistry struct {
+	mu    sync.RWMutex
+	specs map[string]SymbolSpec
+}
+
+// ErrBadSymbolID：交易对编号超出 ids.go 给的位宽、和别的交易对重复，或者已注册的交易对想改编号
+var ErrBadSymbolID = errors.New("symbol registry: bad symbol id")
+
+func NewSymbolRegistry(specs ...SymbolSpec) (*SymbolRegistry, error) {
+	r := &SymbolRegistry{specs: make(map[string]SymbolSpec, len(specs))}
+	for _, s := range specs {
+		if err := r.Register(s); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register：新增或覆盖规则；编号不合法（ErrBadSymbolID）或费率表写不进一条 cmd WAL 记录（ErrFeeScheduleTooLarge）时不注册
+func (r *SymbolRegistry) Register(spec SymbolSpec) error {
+	if n := spec.Fees.encodedLen(); n > maxFeeScheduleLen {
+		return fmt.Errorf("%w: %s %d bytes > %d", ErrFeeScheduleTooLarge, spec.Symbol, n, maxFeeScheduleLen)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkID(spec); err != nil {
+		return err
+	}
+	r.specs[spec.Symbol] = spec
+	return nil
+}
+
+// checkID：编号 <= MaxSymbolID；非 0 编号各交易对不同；已注册的交易对不能改编号（actor 建好后编号就定了）
+// 编号 0 只给测试/未配置的交易对用（见 ids.go），不查重
+func (r *SymbolRegistry) checkID(spec SymbolSpec) error {
+	if spec.ID > MaxSymbolID {
+		return fmt.Errorf("%w: %s id %d > %d", ErrBadSymbolID, spec.Symbol, spec.ID, MaxSymbolID)
+	}
+	if old, ok := r.specs[spec.Symbol]; ok && old.ID != spec.ID {
+		return fmt.Errorf("%w: %s id %d cannot change to %d", ErrBadSymbolID, spec.Symbol, old.ID, spec.ID)
+	}
+	if spec.ID == 0 {
+		return nil
+	}
+	for sym, s := range r.specs {
+		if sym != spec.Symbol && s.ID == spec.ID {
+			return fmt.Errorf("%w: %s id %d already used by %s", ErrBadSymbolID, spec.Symbol, spec.ID, sym)
+		}
+	}
+	return nil
+}
+
+func (r *SymbolRegistry) Get(symbol string) (SymbolSpec, bool) {
+	r.mu.RLock()
+	s, ok := r.specs[symbol]
+	r.mu.RUnlock()
+	return s, ok
+}
+
+// AssetOf：手续费资产对应的资产代码
+func (s SymbolSpec) AssetOf(a FeeAsset) string {
+	switch a {
+	case FeeAssetBase:
+		return s.Base
+	case FeeAssetQuote:
+		return s.Quote
+	}
+	return ""
+}
+
+// Symbols：当前注册的全部交易对（无序）
+func (r *SymbolRegistry) Symbols() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.specs))
+	for s := range r.specs {
+		out = append(out, s)
+	}
+	return out
+}
+
+func (r *SymbolRegistry) SetStatus(symbol string, status SymbolStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.specs[symbol]
+	if !ok {
+		return ErrUnknownSym
+	}
+	s.Status = status
+	r.specs[symbol] = s
+	return nil
+}
+
+// LastPricer：能提供最新成交价的订单簿（价格带/市价单名义价值校验用，可选能力）
+type LastPricer interface {
+	LastPrice() (price int64, ok bool)
+}
+
+// check：按规则校验一条命令；lastPx<=0 表示没有最新成交价
+func (s SymbolSpec) check(cmd Command, lastPx int64) RejectCode {
+	switch s.Status {
+	case SymbolHalted:
+		return RejectHalted
+	case SymbolCancelOnly:
+		if cmd.Type != CmdCancel {
+			return RejectCancelOnly
+		}
+	}
+
+	switch cmd.Type {
+	case CmdSubmitLimit:
+		if code := s.checkPrice(cmd.Price, lastPx); code != RejectNone {
+			return code
+		}
+		if code := s.checkQty(cmd.Qty); code != RejectNone {
+			return code
+		}
+		if cmd.DisplayQty > 0 {
+			if code := s.checkQty(cmd.DisplayQty); code != RejectNone {
+				return code
+			}
+		}
+		return s.checkNotional(cmd.Price, cmd.Qty)
+	case CmdSubmitMarket:
+		if code := s.checkQty(cmd.Qty); code != RejectNone {
+			return code
+		}
+		if lastPx > 0 {
+			return s.checkNotional(lastPx, cmd.Qty)
+		}
+	case CmdSubmitStop:
+		// 触发价/限价只校验 tick；价格带与名义价值在触发时按当时行情才有意义，不校验
+		if s.TickSize > 0 && (cmd.StopPrice%s.TickSize != 0 || cmd.Price%s.TickSize != 0) {
+			return RejectTickSize
+		}
+		return s.checkQty(cmd.Qty)
+	case CmdAmend:
+		// 改单只校验给出的新值（0 表示不改）
+		if cmd.Price > 0 {
+			if code := s.checkPrice(cmd.Price, lastPx); code != RejectNone {
+				return code
+			}
+		}
+		if cmd.Qty > 0 {
+			return s.checkQty(cmd.Qty)
+		}
+	}
+	return RejectNone
+}
+
+func (s SymbolSpec) checkPrice(price, lastPx int64) RejectCode {
+	if s.TickSize > 0 && price%s.TickSize != 0 {
+		return RejectTickSize
+	}
+	if s.MaxDeviationBps > 0 && lastPx > 0 {
+		diff := price - lastPx
+		if diff < 0 {
+			diff = -diff
+		}
+		if cmpMul(diff, 10000, lastPx, s.MaxDeviationBps) > 0 {
+			return RejectPriceBand
+		}
+	}
+	return RejectNone
+}
+
+func (s SymbolSpec) checkQty(qty int64) RejectCode {
+	if s.LotSize > 0 && qty%s.LotSize != 0 {
+		return RejectLotSize
+	}
+	return RejectNone
+}
+
+func (s SymbolSpec) checkNotional(price, qty int64) RejectCode {
+	if s.MinNotional > 0 && price > 0 && qty > 0 && cmpMul(price, qty, s.MinNotional, 1) < 0 {
+		return RejectMinNotional
+	}
+	return RejectNone
+}
+
+// SymbolSpec：交易对规则；没配注册表或未注册时 ok=false
+func (e *Engine) SymbolSpec(symbol string) (SymbolSpec, bool) {
+	if e.cfg.Symbols == nil {
+		return SymbolSpec{}, false
+	}
+	return e.cfg.Symbols.Get(symbol)
+}
+
+// cmpMul：比较 a*b 与 c*d（参数都 >= 0），按 128 位乘积比，大价格/大数量不会溢出翻转
+func cmpMul(a, b, c, d int64) int {
+	hi1, lo1 := bits.Mul64(uint64(a), uint64(b))
+	hi2, lo2 := bits.Mul64(uint64(c), uint64(d))
+	switch {
+	case hi1 != hi2:
+		if hi1 > hi2 {
+			return 1
+		}
+		return -1
+	case lo1 != lo2:
+		if lo1 > lo2 {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func newTestRegistry(t testing.TB, specs ...SymbolSpec) *SymbolRegistry {
+	t.Helper()
+	r, err := NewSymbolRegistry(specs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSymbolSpec_Check(t *testing.T) {
+	spec := SymbolSpec{Symbol: "BTCUSDT", TickSize: 5, LotSize: 2, MinNotional: 1000, MaxDeviationBps: 1000}
+	big := SymbolSpec{Symbol: "BTCUSDT", MinNotional: 1000, MaxDeviationBps: 1000}
+	limit := func(price, qty int64) Command {
+		return Command{Type: CmdSubmitLimit, OrderID: 1, Side: Buy, Price: price, Qty: qty}
+	}
+	cases := []struct {
+		name   string
+		spec   SymbolSpec
+		cmd    Command
+		lastPx int64
+		want   RejectCode
+	}{
+		{"ok", spec, limit(100, 10), 0, RejectNone},
+		{"tick", spec, limit(101, 10), 0, RejectTickSize},
+		{"lot", spec, limit(100, 3), 0, RejectLotSize},
+		{"notional", spec, limit(100, 4), 0, RejectMinNotional},
+		{"band ok", spec, limit(110, 10), 100, RejectNone},
+		{"band", spec, limit(115, 10), 100, RejectPriceBand},
+		{"market notional by last", spec, Command{Type: CmdSubmitMarket, Qty: 2}, 100, RejectMinNotional},
+		{"market no last", spec, Command{Type: CmdSubmitMarket, Qty: 2}, 0, RejectNone},
+		{"stop tick", spec, Command{Type: CmdSubmitStop, StopPrice: 103, Qty: 2}, 0, RejectTickSize},
+		{"stop ignores band", spec, Command{Type: CmdSubmitStop, StopPrice: 200, Price: 205, Qty: 2}, 100, RejectNone},
+		{"amend qty only", spec, Command{Type: CmdAmend, OrderID: 1, Qty: 3}, 0, RejectLotSize},
+		{"halted cancel", SymbolSpec{Status: SymbolHalted}, Command{Type: CmdCancel, CancelOrderID: 1}, 0, RejectHalted},
+		{"cancel only submit", SymbolSpec{Status: SymbolCancelOnly}, limit(100, 10), 0, RejectCancelOnly},
+		{"cancel only cancel", SymbolSpec{Status: SymbolCancelOnly}, Command{Type: CmdCancel, CancelOrderID: 1}, 0, RejectNone},
+		// 大价格/大数量：乘积超过 int64 也不能翻转判断
+		{"big band ok", big, limit(1<<60+1<<55, 1), 1 << 60, RejectNone},
+		{"big band", big, limit(1<<60+1<<58, 1), 1 << 60, RejectPriceBand},
+		{"big notional", big, limit(1<<40, 1<<30), 0, RejectNone},
+	}
+	for _, c := range cases {
+		if got := c.spec.check(c.cmd, c.lastPx); got != c.want {
+			t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSymbolRegistry_EngineRejectsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	reg := newTestRegistry(t, SymbolSpec{Symbol: sym, TickSize: 5, LotSize: 1})
+	newEng := func() *Engine {
+		return NewEngine(EngineConfig{
+			WALDir:       dir,
+			EnableCmdWAL: true,
+			EnableOutbox: true,
+			CmdCodec:     BinaryCMDCode{},
+			EvCodec:      EvCmdCodec{},
+			ActorCfg:     ActorConfig{MailboxSize: 64, BatchMax: 8},
+			Symbols:      reg,
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		})
+	}
+	ctx := context.Background()
+
+	eng := newEng()
+	if err := eng.TrySubmit("DOGEUSDT", Command{Type: CmdSubmitLimit, OrderID: 1, Side: Buy, Price: 5, Qty: 1}); !errors.Is(err, ErrUnknownSym) {
+		t.Fatalf("want ErrUnknownSym, got %v", err)
+	}
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 1, Side: Sell, Price: 102, Qty: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Rejected || res.Code != RejectTickSize || res.Events[0].Reason != "tick_size" {
+		t.Fatalf("want tick_size reject, got %+v", res)
+	}
+	if res, _ = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 1, Side: Sell, Price: 100, Qty: 1}); !res.Accepted {
+		t.Fatalf("want accepted, got %+v", res)
+	}
+
+	_ = reg.SetStatus(sym, SymbolCancelOnly)
+	if res, _ = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 1, Side: Sell, Price: 95, Qty: 1}); res.Code != RejectCancelOnly {
+		t.Fatalf("want cancel_only, got %+v", res)
+	}
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// 重启时规则已放开：回放仍按 WAL 里记录的拒单码，簿里只有订单 2
+	_ = reg.SetStatus(sym, SymbolOpen)
+	if err := reg.Register(SymbolSpec{Symbol: sym}); err != nil {
+		t.Fatal(err)
+	}
+	eng2 := newEng()
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	a, err := eng2.getOrCreateActor(sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := a.book.(*HeapBookAdapter).B
+	if p, ok := b.BestAsk(); !ok || p != 100 {
+		t.Fatalf("best ask=%d/%v, want 100", p, ok)
+	}
+	if _, ok := b.Order(1); ok {
+		t.Fatalf("rejected order 1 must not be replayed into the book")
+	}
+}
+
+func TestCodecs_RejectCodeAndCompat(t *testing.T) {
+	in := Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, Side: Buy, Price: 101, Qty: 1, Reject: RejectTickSize}
+	p, _ := BinaryCMDCode{}.Encode(nil, 9, in)
+	if _, out, err := (BinaryCMDCode{}).Decode(p); err != nil || out != in {
+		t.Fatalf("cmd roundtrip: %+v %v", out, err)
+	}
+	v1 := append([]byte(nil), p[:cmdRecordLenV1]...)
+	v1[offVer] = cmdWalVersion1
+	if _, out, err := (BinaryCMDCode{}).Decode(v1); err != nil || out.Reject != RejectNone {
+		t.Fatalf("cmd v1 decode: %+v %v", out, err)
+	}
+
+	ev := Event{Type: EvRejected, Seq: 9, ReqID: 1, OrderID: 1, Code: RejectPriceBand, Reason: "price_band"}
+	b, _ := EvCmdCodec{}.Encode(nil, ev)
+	if got, err := (EvCmdCodec{}).Decode(b); err != nil || got != ev {
+		t.Fatalf("ev roundtrip: %+v %v", got, err)
+	}
+	v1 = append([]byte(nil), b[:evRecordLenV1]...)
+	v1[evOffVer] = evWalVersion1
+	if got, err := (EvCmdCodec{}).Decode(v1); err != nil || got.Code != RejectNone || got.OrderID != 1 {
+		t.Fatalf("ev v1 decode: %+v %v", got, err)
+	}
+}
+
+func TestSymbolRegistry_RejectsBadID(t *testing.T) {
+	if _, err := NewSymbolRegistry(SymbolSpec{Symbol: "BTCUSDT", ID: 1}, SymbolSpec{Symbol: "ETHUSDT", ID: 1}); !errors.Is(err, ErrBadSymbolID) {
+		t.Fatalf("duplicate id: %v", err)
+	}
+	if _, err := NewSymbolRegistry(SymbolSpec{Symbol: "BTCUSDT", ID: MaxSymbolID + 1}); !errors.Is(err, ErrBadSymbolID) {
+		t.Fatalf("id too wide: %v", err)
+	}
+
+	reg := newTestRegistry(t, SymbolSpec{Symbol: "BTCUSDT", ID: 1}, SymbolSpec{Symbol: "A"}, SymbolSpec{Symbol: "B"})
+	if err := reg.Register(SymbolSpec{Symbol: "ETHUSDT", ID: 1}); !errors.Is(err, ErrBadSymbolID) {
+		t.Fatalf("register duplicate id: %v", err)
+	}
+	if _, ok := reg.Get("ETHUSDT"); ok {
+		t.Fatal("rejected spec registered")
+	}
+	if err := reg.Register(SymbolSpec{Symbol: "BTCUSDT", ID: 2}); !errors.Is(err, ErrBadSymbolID) {
+		t.Fatalf("change id: %v", err)
+	}
+	// 覆盖规则（编号不变）照常
+	if err := reg.Register(SymbolSpec{Symbol: "BTCUSDT", ID: 1, TickSize: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := reg.Get("BTCUSDT"); s.TickSize != 5 {
+		t.Fatalf("spec=%+v", s)
+	}
+	if err := reg.Register(SymbolSpec{Symbol: "ETHUSDT", ID: MaxSymbolID}); err != nil {
+		t.Fatal(err)
+	}
+}
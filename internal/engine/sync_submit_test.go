@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+)
+
+func TestSubmit_WaitsForCmdEnd(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	// bus 只有 1 个槽位且没人消费：publisher 很快阻塞，Submit 不能依赖它
+	bus := NewChanBus(1)
+	eng := NewEngine(EngineConfig{
+		WALDir:          dir,
+		EnableCmdWAL:    true,
+		EnableOutbox:    true,
+		EnablePublisher: true,
+		PublisherPoll:   5 * time.Millisecond,
+		bus:             bus,
+		CmdCodec:        BinaryCMDCode{},
+		EvCodec:         EvCmdCodec{},
+		ActorCfg:        ActorConfig{MailboxSize: 64, BatchMax: 8},
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+	defer func() {
+		eng.Stop()
+		time.Sleep(50 * time.Millisecond) // 等 actor/publisher 退出再清理 TempDir
+	}()
+	ctx := context.Background()
+
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 9, Side: Sell, Price: 100, Qty: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Seq != 1 || !res.Accepted || res.Rejected || len(res.Events) != 2 || res.Events[1].Type != EvAdded {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	res, err = eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 8, Side: Buy, Price: 100, Qty: 3, TIF: TifIOC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Seq != 2 || res.FilledQty != 2 || len(res.Trades) != 1 || res.Trades[0].MakerOrderID != 1 {
+		t.Fatalf("unexpected fills: %+v", res)
+	}
+	if last := res.Events[len(res.Events)-1]; last.Type != EvExpired || last.Qty != 1 || last.Idx != 2 {
+		t.Fatalf("unexpected tail event: %+v", last)
+	}
+
+	res, err = eng.Submit(ctx, sym, Command{Type: CmdCancel, ReqID: 3, CancelOrderID: 404})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Rejected || res.Code != RejectOrderNotFound {
+		t.Fatalf("unexpected reject: %+v", res)
+	}
+
+	// 已过期的 ctx：不等待，直接返回
+	dead, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	if _, err := eng.Submit(dead, sym, Command{Type: CmdSubmitLimit, ReqID: 4, OrderID: 4, UserID: 9, Side: Sell, Price: 101, Qty: 1}); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+	if _, err := eng.Submit(ctx, sym, Command{Type: CmdType(99)}); !errors.Is(err, ErrBadCommand) {
+		t.Fatalf("want ErrBadCommand, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/wal"
+)
+
+// recEmitter：按顺序记录所有事件，便于断言
+type recEmitter struct {
+	evs []Event
+}
+
+func (r *recEmitter) Accepted(reqID uint64, orderID, userID uint64) {
+	r.evs = append(r.evs, Event{Type: EvAccepted, ReqID: reqID, OrderID: orderID, UserID: userID})
+}
+func (r *recEmitter) Rejected(reqID uint64, orderID, userID uint64, code RejectCode) {
+	r.evs = append(r.evs, Event{Type: EvRejected, ReqID: reqID, OrderID: orderID, UserID: userID, Code: code, Reason: code.String()})
+}
+func (r *recEmitter) Added(reqID uint64, orderID, userID uint64) {
+	r.evs = append(r.evs, Event{Type: EvAdded, ReqID: reqID, OrderID: orderID, UserID: userID})
+}
+func (r *recEmitter) Cancelled(reqID uint64, orderID uint64) {
+	r.evs = append(r.evs, Event{Type: EvCancelled, ReqID: reqID, OrderID: orderID})
+}
+func (r *recEmitter) Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvTrade, ReqID: reqID, MakerOrderID: makerOrderID, TakerOrderID: takerOrderID, Price: price, Qty: qty})
+}
+func (r *recEmitter) Expired(reqID uint64, orderID, userID uint64, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvExpired, ReqID: reqID, OrderID: orderID, UserID: userID, Qty: qty})
+}
+
+func (r *recEmitter) Amended(reqID uint64, orderID, userID uint64, price, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvAmended, ReqID: reqID, OrderID: orderID, UserID: userID, Price: price, Qty: qty})
+}
+
+func (r *recEmitter) SelfTradePrevented(reqID uint64, orderID, userID, makerOrderID, takerOrderID uint64, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvSelfTrade, ReqID: reqID, OrderID: orderID, UserID: userID, MakerOrderID: makerOrderID, TakerOrderID: takerOrderID, Qty: qty})
+}
+
+func (r *recEmitter) PhaseChanged(reqID uint64, phase Phase) {
+	r.evs = append(r.evs, Event{Type: EvPhase, ReqID: reqID, Qty: int64(phase)})
+}
+
+func (r *recEmitter) StopAccepted(reqID uint64, orderID, userID uint64, stopPrice, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvStopNew, ReqID: reqID, OrderID: orderID, UserID: userID, Price: stopPrice, Qty: qty})
+}
+
+func (r *recEmitter) StopTriggered(reqID uint64, orderID, userID uint64, price, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvStopFire, ReqID: reqID, OrderID: orderID, UserID: userID, Price: price, Qty: qty})
+}
+
+func (r *recEmitter) StopCancelled(reqID uint64, orderID, userID uint64) {
+	r.evs = append(r.evs, Event{Type: EvStopCxl, ReqID: reqID, OrderID: orderID, UserID: userID})
+}
+
+func (r *recEmitter) Replenished(reqID uint64, orderID uint64, qty int64) {
+	r.evs = append(r.evs, Event{Type: EvReplenish, ReqID: reqID, OrderID: orderID, Qty: qty})
+}
+
+func (r *recEmitter) types() []EventType {
+	out := make([]EventType, 0, len(r.evs))
+	for _, ev := range r.evs {
+		out = append(out, ev.Type)
+	}
+	return out
+}
+
+func assertTypes(t *testing.T, got []EventType, want ...EventType) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("events=%v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events=%v, want %v", got, want)
+		}
+	}
+}
+
+func newHeapBook() OrderBook {
+	return NewHeapBookAdapter(matching.NewLevelOrderHeapBook())
+}
+
+func seedAsk(book OrderBook, orderID uint64, price, qty int64) {
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: orderID, OrderID: orderID, UserID: 9, Side: Sell, Price: price, Qty: qty,
+	}, noopEmitter{})
+}
+
+func TestTIF_IOC_RemainderExpired(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 100, 3)
+
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: 10, OrderID: 10, UserID: 1, Side: Buy, Price: 100, Qty: 5, TIF: TifIOC,
+	}, em)
+	assertTypes(t, em.types(), EvAccepted, EvTrade, EvExpired)
+	if em.evs[2].Qty != 2 {
+		t.Fatalf("expired qty=%d, want 2", em.evs[2].Qty)
+	}
+
+	// IOC 剩余不应挂单：再来一个卖单不会成交
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 2, Side: Sell, Price: 100, Qty: 1,
+	}, em)
+	assertTypes(t, em.types(), EvAccepted, EvAdded)
+}
+
+func TestTIF_FOK_AllOrNothing(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 100, 2)
+	seedAsk(book, 2, 101, 2)
+
+	// 价格内只有 2，不够 3：整单过期，无成交
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: 10, OrderID: 10, UserID: 1, Side: Buy, Price: 100, Qty: 3, TIF: TifFOK,
+	}, em)
+	assertTypes(t, em.types(), EvAccepted, EvExpired)
+	if em.evs[1].Qty != 3 {
+		t.Fatalf("expired qty=%d, want 3", em.evs[1].Qty)
+	}
+
+	// 放宽价格到 101：足够 3，全部成交
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 1, Side: Buy, Price: 101, Qty: 3, TIF: TifFOK,
+	}, em)
+	assertTypes(t, em.types(), EvAccepted, EvTrade, EvTrade)
+}
+
+func TestMarket_SweepsAndExpires(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 100, 1)
+	seedAsk(book, 2, 105, 1)
+
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitMarket, ReqID: 10, OrderID: 10, UserID: 1, Side: Buy, Qty: 3,
+	}, em)
+	assertTypes(t, em.types(), EvAccepted, EvTrade, EvTrade, EvExpired)
+	if em.evs[1].Price != 100 || em.evs[2].Price != 105 || em.evs[3].Qty != 1 {
+		t.Fatalf("unexpected events: %+v", em.evs)
+	}
+
+	// 市价单不允许 PostOnly
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitMarket, ReqID: 11, OrderID: 11, UserID: 1, Side: Buy, Qty: 1, PostOnly: true,
+	}, em)
+	assertTypes(t, em.types(), EvRejected)
+}
+
+func TestPostOnly_RejectWhenCross(t *testing.T) {
+	book := newHeapBook()
+	seedAsk(book, 1, 100, 1)
+
+	em := &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: 10, OrderID: 10, UserID: 1, Side: Buy, Price: 100, Qty: 1, PostOnly: true,
+	}, em)
+	assertTypes(t, em.types(), EvRejected)
+
+	em = &recEmitter{}
+	applyCommandToBook(book, Command{
+		Type: CmdSubmitLimit, ReqID: 11, OrderID: 11, UserID: 1, Side: Buy, Price: 99, Qty: 1, PostOnly: true,
+	}, em)
+	assertTypes(t, em.types(), EvAccepted, EvAdded)
+}
+
+func TestBinaryCmdCodec_V2RoundTripAndV1Compat(t *testing.T) {
+	codec := BinaryCMDCode{}
+	in := Command{
+		Type: CmdSubmitLimit, ReqID: 7, ClientTs: 123, OrderID: 1001, UserID: 2001,
+		Side: Buy, Price: 100, Qty: 5, TIF: TifGTC, PostOnly: true,
+	}
+	p, err := codec.Encode(nil, 42, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, out, err := codec.Decode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 42 || out != in {
+		t.Fatalf("roundtrip mismatch: seq=%d got=%+v want=%+v", seq, out, in)
+	}
+
+	// v1 记录：截掉 v2 追加的字段，解码为 GTC
+	v1 := append([]byte(nil), p[:cmdRecordLenV1]...)
+	v1[offVer] = cmdWalVersion1
+	_, old, err := codec.Decode(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.TIF != TifGTC || old.PostOnly || old.OrderID != in.OrderID {
+		t.Fatalf("v1 decode unexpected: %+v", old)
+	}
+}
+
+func TestReplay_TIFDeterministic(t *testing.T) {
+	cmdPath := filepath.Join(t.TempDir(), "BTCUSDT.wal")
+	codec := BinaryCMDCode{}
+	cmds := []Command{
+		{Type: CmdSubmitLimit, ReqID: 1, OrderID: 1, UserID: 9, Side: Sell, Price: 100, Qty: 2},
+		{Type: CmdSubmitLimit, ReqID: 2, OrderID: 2, UserID: 9, Side: Sell, Price: 101, Qty: 2},
+		{Type: CmdSubmitLimit, ReqID: 3, OrderID: 3, UserID: 1, Side: Buy, Price: 100, Qty: 5, TIF: TifIOC},
+		{Type: CmdSubmitMarket, ReqID: 4, OrderID: 4, UserID: 1, Side: Buy, Qty: 1},
+		{Type: CmdSubmitLimit, ReqID: 5, OrderID: 5, UserID: 1, Side: Buy, Price: 99, Qty: 1, PostOnly: true},
+	}
+
+	live := newHeapBook()
+	w, err := wal.OpenWrite(cmdPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range cmds {
+		p, _ := codec.Encode(nil, uint64(i+1), c)
+		if err := w.Append(p); err != nil {
+			t.Fatal(err)
+		}
+		applyCommandToBook(live, c, noopEmitter{})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := newHeapBook()
+	lastSeq, err := replayCmdWALAndFillOutbox(cmdPath, replayed, nil, 0, 0, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastSeq != uint64(len(cmds)) {
+		t.Fatalf("lastSeq=%d", lastSeq)
+	}
+
+	lb := live.(*HeapBookAdapter).B
+	rb := replayed.(*HeapBookAdapter).B
+	la, lok := lb.BestAsk()
+	ra, rok := rb.BestAsk()
+	lbid, lbok := lb.BestBid()
+	rbid, rbok := rb.BestBid()
+	if la != ra || lok != rok || lbid != rbid || lbok != rbok {
+		t.Fatalf("book mismatch: live ask=%d/%v bid=%d/%v replay ask=%d/%v bid=%d/%v",
+			la, lok, lbid, lbok, ra, rok, rbid, rbok)
+	}
+	if ra != 101 || rbid != 99 {
+		t.Fatalf("unexpected replayed book: ask=%d bid=%d", ra, rbid)
+	}
+}
@@ -2,6 +2,9 @@ package engine
 
 import (
 	"errors"
+
+	"github.com/google/uuid"
+	"gopherex.com/internal/matching"
 )
 
 // 定义变量
@@ -10,8 +13,28 @@ import (
 type CmdType uint8
 
 const (
-	CmdSubmitLimit CmdType = iota + 1 // 提交
-	CmdCancel                         // 取消
+	CmdSubmitLimit  CmdType = iota + 1 // 提交
+	CmdCancel                          // 取消
+	CmdSubmitMarket                    // 市价单（不挂单，剩余按 IOC 过期）
+	CmdAmend                           // 改单：OrderID 指向挂单，Price/Qty 为新值（0 表示不改）
+	CmdHalt                            // 停牌
+	CmdResume                          // 恢复连续交易
+	CmdCancelOnly                      // 进入只撤单阶段
+	CmdCancelAll                       // 撤掉 UserID 在该交易对的所有挂单（cancel-all-for-user）
+	CmdAuction                         // 进入集合竞价：只收单不撮合
+	CmdUncross                         // 竞价撮合：按单一价格成交后回到连续交易
+	CmdSubmitStop                      // 止损单：StopPrice 触发价，Price=0 为 stop-market，否则 stop-limit
+	CmdSetFees                         // 换费率表（Fees）：actor 发现注册表费率变化时自己写入，不接受外部提交
+	CmdSetSTP                          // 换自成交防护模式（STP）：同 CmdSetFees，actor 发现注册表 STP 变化时自己写入
+)
+
+// 订单有效期：与 wallet.sql 的 tif 对齐；零值 GTC，兼容旧命令
+type TimeInForce uint8
+
+const (
+	TifGTC TimeInForce = iota // 一直有效：剩余挂单
+	TifIOC                    // 立即成交：剩余过期
+	TifFOK                    // 全部成交：否则整单过期
 )
 
 const (
@@ -23,15 +46,64 @@ const (
 type Command struct {
 	Type     CmdType
 	ReqID    uint64 // 上游幂等/追踪用
-	ClientTs int64  // 可选：审计
+	ClientTs int64  // 可选：审计（UnixNano），引擎不看
+	EngineTs int64  // actor 写 WAL 前按引擎时钟打上（UnixNano，单调不减），下单频率按它计数；调用方设置无效
 
 	// SubmitLimit fields
-	OrderID       uint64 // OrderId 为什么不是内部生成
+	OrderID       uint64 // 下单时 0 表示由引擎按 symbol 编号 + seq 生成（见 EngineOrderID）；调用方自带的不能落在引擎号段
+	ClientOrderID uint64 // 可选：客户端订单号，同一用户的未完结订单里不能重复
 	UserID        uint64
 	Side          uint8
 	Price         int64
 	Qty           int64
-	CancelOrderID uint64 // 取消订单ID
+	TIF           TimeInForce // 有效期（市价单 GTC 视为 IOC）
+	PostOnly      bool        // 只做 maker：会立即成交则拒单
+	CancelOrderID uint64      // 取消订单ID
+	StopPrice     int64       // 止损触发价（CmdSubmitStop）
+	DisplayQty    int64       // 冰山单每片显示数量（只对 GTC 限价单有效），0 表示普通单
+
+	// 下单前风控（PreTradeHook）回填：资金冻结的 entryset 与冻结数量（买单 quote / 卖单 base）
+	// 随命令落 WAL，资金侧对账用；引擎本身不读
+	EntrySetID uuid.UUID
+	Reserved   int64
+
+	// CmdSetFees：之后成交用的费率表（nil 不收），随命令落 WAL（记录尾部变长）
+	Fees *FeeSchedule
+	// CmdSetSTP：之后撮合用的自成交防护模式，随命令落 WAL（记录尾部 1 字节）
+	STP matching.STPMode
+
+	// actor 写 WAL 前的规则校验结果（调用方设置无效，会被覆盖）
+	// 随命令落 WAL，回放时直接按它拒单，保证与线上一致
+	Reject RejectCode
+
+	// 同步提交（Engine.Submit）用：actor 在该命令 CmdEnd 落盘后回填 Result
+	// 不进 WAL；cap=1，调用方超时离开也不会阻塞 actor
+	reply chan Result
+	enqAt int64 // 入队时间（UnixNano），只用于延迟指标，不进 WAL
+}
+
+// OrderSpec：交给 OrderBook 的下单参数（由 Command 翻译而来）
+type OrderSpec struct {
+	OrderID  uint64
+	UserID   uint64
+	Side     uint8
+	Market   bool // 市价单：忽略 Price
+	Price    int64
+	Qty      int64
+	TIF      TimeInForce
+	PostOnly bool
+	Display  int64 // 冰山单每片显示数量，0 表示普通单
+	ClientID uint64
+}
+
+// AmendSpec：交给 OrderBook 的改单参数
+// - Price/Qty 为 0 表示保持原值；Qty 是改后的剩余数量
+// - UserID 非 0 时校验订单归属
+type AmendSpec struct {
+	OrderID uint64
+	UserID  uint64
+	Price   int64
+	Qty     int64
 }
 type EventType uint8
 
@@ -41,6 +113,14 @@ const (
 	EvAdded                          //加入订单薄
 	EvCancelled                      //订单薄取消
 	EvTrade                          // 交易成功
+	EvExpired                        // IOC/FOK/市价单剩余过期（Qty=过期数量）
+	EvAmended                        // 改单成功（Price/Qty=改后的价格与剩余数量）
+	EvSelfTrade                      // 自成交防护：OrderID 被撤/减的订单，Qty=释放数量，Maker/TakerOrderID=触发的一对
+	EvPhase                          // 交易阶段切换（Qty=新的 Phase）
+	EvStopNew                        // 止损单进入触发簿（Price=触发价）
+	EvStopFire                       // 止损单被触发（Price=触发它的成交价），随后作为 taker 提交
+	EvStopCxl                        // 止损单在触发前被撤
+	EvReplenish                      // 冰山单补片：OrderID 重新排到队尾，Qty=新的可见数量
 )
 
 type Event struct {
@@ -60,14 +140,119 @@ type Event struct {
 	TakerOrderID uint64
 	Price        int64
 	Qty          int64
+	TradeID      uint64 // 仅 EvTrade：symbol 编号 + 成交序号（见 ids.go），跨 symbol 唯一
+
+	// 仅 EvTrade：成交双方与手续费（负数为返佣，单位随资产：base 同 Qty，quote 同 Price*Qty）
+	MakerUserID   uint64
+	TakerUserID   uint64
+	TakerSide     uint8
+	MakerFee      int64
+	TakerFee      int64
+	MakerFeeAsset FeeAsset
+	TakerFeeAsset FeeAsset
 
-	// 非热路径：拒单原因（V1 可以改成 code）
+	// Rejected：结构化原因码；Reason 只是 Code.String() 的可读形式
+	Code   RejectCode
 	Reason string
 }
 
+// RejectCode：拒单原因码（事件里的结构化原因，取代自由文本）
+type RejectCode uint16
+
+const (
+	RejectNone             RejectCode = iota
+	RejectBadParams                   // 参数非法（id/数量/方向/TIF 组合等）
+	RejectUnknownCmd                  // 未知命令类型
+	RejectOrderNotFound               // 撤单/改单的订单不存在
+	RejectNotOwner                    // 改单的用户不是订单所有者
+	RejectPostOnlyCross               // PostOnly 会立即成交
+	RejectHalted                      // 交易对停牌
+	RejectCancelOnly                  // 交易对只允许撤单
+	RejectTickSize                    // 价格不是 tick 的整数倍
+	RejectLotSize                     // 数量不是 lot 的整数倍
+	RejectMinNotional                 // 名义价值低于下限
+	RejectPriceBand                   // 价格偏离最新成交价过大
+	RejectAuctionOrder                // 竞价阶段只接受普通 GTC 限价单
+	RejectWrongPhase                  // 当前阶段不允许该控制命令
+	RejectUnsupported                 // 订单簿不支持该命令（如不支持竞价）
+	RejectUserBlocked                 // 用户已被风控冻结（kill-switch），只允许撤单
+	RejectDupClientOrderID            // 客户端订单号与该用户未完结的订单重复
+	RejectRateLimited                 // 用户下单频率超限
+	RejectMaxOpenOrders               // 用户未完结订单数超限
+	RejectMaxOpenNotional             // 用户未完结订单名义价值超限
+	RejectIDExhausted                 // 引擎订单号/成交号号段用完（见 ids.go），只能撤单
+)
+
+var rejectNames = [...]string{
+	RejectNone:             "none",
+	RejectBadParams:        "bad_params",
+	RejectUnknownCmd:       "unknown_cmd",
+	RejectOrderNotFound:    "order_not_found",
+	RejectNotOwner:         "not_owner",
+	RejectPostOnlyCross:    "post_only_cross",
+	RejectHalted:           "halted",
+	RejectCancelOnly:       "cancel_only",
+	RejectTickSize:         "tick_size",
+	RejectLotSize:          "lot_size",
+	RejectMinNotional:      "min_notional",
+	RejectPriceBand:        "price_band",
+	RejectAuctionOrder:     "auction_order",
+	RejectWrongPhase:       "wrong_phase",
+	RejectUnsupported:      "unsupported",
+	RejectUserBlocked:      "user_blocked",
+	RejectDupClientOrderID: "dup_client_order_id",
+	RejectRateLimited:      "rate_limited",
+	RejectMaxOpenOrders:    "max_open_orders",
+	RejectMaxOpenNotional:  "max_open_notional",
+	RejectIDExhausted:      "id_exhausted",
+}
+
+func (c RejectCode) String() string {
+	if int(c) < len(rejectNames) {
+		return rejectNames[c]
+	}
+	return "unknown"
+}
+
+// Result：同步提交的结果，即该 seq 的完整事件集合（到 EvCmdEnd 为止，不含 CmdEnd 本身）
+type Result struct {
+	Seq       uint64
+	OrderID   uint64 // 下单命令的订单号（引擎生成的也在这里拿）
+	Accepted  bool
+	Rejected  bool
+	Code      RejectCode // Rejected 时的原因码
+	FilledQty int64      // 本命令产生的成交总量
+	Trades    []Event    // EvTrade 子集
+	Events    []Event    // 按 Idx 顺序的全部事件
+}
+
+func (r *Result) add(ev Event) {
+	r.Events = append(r.Events, ev)
+	switch ev.Type {
+	case EvAccepted, EvStopNew:
+		r.Accepted = true
+		if r.OrderID == 0 {
+			r.OrderID = ev.OrderID
+		}
+	case EvRejected:
+		r.Rejected = true
+		r.Code = ev.Code
+		if r.OrderID == 0 {
+			r.OrderID = ev.OrderID
+		}
+	case EvTrade:
+		r.Trades = append(r.Trades, ev)
+		r.FilledQty += ev.Qty
+	}
+}
+
 // 定义错误
 var (
-	ErrEngineBusy = errors.New("engine busy: mailbox full")
-	ErrUnknownSym = errors.New("unknown symbol")
-	ErrBadCommand = errors.New("bad command")
+	ErrEngineBusy    = errors.New("engine busy: mailbox full")
+	ErrUnknownSym    = errors.New("unknown symbol")
+	ErrBadCommand    = errors.New("bad command")
+	ErrEngineStopped = errors.New("engine stopped")
+	ErrUserBlocked   = errors.New("user blocked")
+	ErrPreTrade      = errors.New("pre-trade check failed") // PreTradeHook 拒单（资金不足等），实现方用 %w 包装
+	ErrNeedsSubmit   = errors.New("pre-trade hook configured: use Submit")
 )
@@ -0,0 +1,325 @@
+package engine
+
+import (
+	"math/bits"
+	"time"
+)
+
+// 用户级限额（按交易对配置，见 SymbolSpec.MaxOpenOrders / MaxOrderRate / MaxOpenNotional）
+// - 在 actor 写 cmd WAL 前校验，拒单码随命令落 WAL：回放/备库/离线重放直接按码拒，不依赖这里的状态
+// - 只管下单和改单；撤单不受限
+//   改单让名义价值变大时按增量（新 - 旧）校验 MaxOpenNotional，改小/不变不校验；改单不占挂单数也不计频率
+//   原单先看 fresh（同 batch 里已通过校验、还没 apply 的新单/改单），再到簿里找
+// - 未完结 = 簿里的挂单（剩余总量，含冰山隐藏部分）+ 未触发的止损单 + 同一 batch 里已通过校验的新单
+//   同 batch 的新单按可能挂单算（还没 apply，不知道会不会立即成交），偏保守
+//   簿里的按用户累计（bookLoads，由事件维护），止损单由触发簿增删时累计：校验是 O(1)，不扫用户挂单
+//   名义价值按 128 位累计，大价格/大数量不会溢出翻转
+// - 只有可能挂单的命令（GTC 限价单、止损单）受挂单数/名义价值限制；IOC/FOK/市价单不留敞口
+// - 频率按 Command.EngineTs（actor 写 WAL 前按引擎时钟打上，单调不减）所在的秒计数，客户端的 ClientTs 不参与
+//   通过校验的下单命令都计数（不管配没配频率限制）：回放按 WAL 里的 EngineTs 同样计数重建，快照保存当前秒的窗口
+
+// rateSweepAt：频率表超过这个大小时，换秒时清掉旧窗口
+const rateSweepAt = 4096
+
+type rateWindow struct {
+	sec int64
+	n   int
+}
+
+// notional：名义价值（price*qty 的 128 位和，参数都 >= 0）
+type notional struct{ hi, lo uint64 }
+
+func mulNotional(price, qty int64) notional {
+	if price <= 0 || qty <= 0 {
+		return notional{}
+	}
+	hi, lo := bits.Mul64(uint64(price), uint64(qty))
+	return notional{hi, lo}
+}
+
+func (x notional) add(y notional) notional {
+	lo, c := bits.Add64(x.lo, y.lo, 0)
+	return notional{x.hi + y.hi + c, lo}
+}
+
+func (x notional) sub(y notional) notional {
+	lo, b := bits.Sub64(x.lo, y.lo, 0)
+	return notional{x.hi - y.hi - b, lo}
+}
+
+// greater：x > y
+func (x notional) greater(y notional) bool {
+	return x.hi > y.hi || x.hi == y.hi && x.lo > y.lo
+}
+
+// exceeds：x > limit（limit >= 0）
+func (x notional) exceeds(limit int64) bool {
+	return x.hi != 0 || x.lo > uint64(limit)
+}
+
+type userLoad struct {
+	orders   int
+	notional notional
+}
+
+func (u userLoad) plus(o userLoad) userLoad {
+	return userLoad{orders: u.orders + o.orders, notional: u.notional.add(o.notional)}
+}
+
+func (u userLoad) minus(o userLoad) userLoad {
+	return userLoad{orders: u.orders - o.orders, notional: u.notional.sub(o.notional)}
+}
+
+// userLimiter：只在 actor 协程里用（回放时挂在 symState 上重建）
+type userLimiter struct {
+	rate    map[uint64]rateWindow
+	lastSec int64
+	lastTs  int64                   // 最近打上的 EngineTs：本地时钟回拨时不回退
+	pending map[uint64]userLoad     // 本 batch 已通过校验、还没 apply 的新单
+	fresh   map[uint64]RestingOrder // 本 batch 已通过校验的限价新单/改单的价量（按订单号）：同 batch 里再改这些单时当原单
+}
+
+func newUserLimiter() *userLimiter {
+	return &userLimiter{rate: make(map[uint64]rateWindow), pending: make(map[uint64]userLoad), fresh: make(map[uint64]RestingOrder)}
+}
+
+// stamp：按引擎时钟给命令打 EngineTs（随命令落 WAL）
+func (l *userLimiter) stamp(cmd *Command, now int64) {
+	l.lastTs = max(l.lastTs, now)
+	cmd.EngineTs = l.lastTs
+}
+
+// rateOf：该用户在 sec 这一秒已计数的下单数
+func (l *userLimiter) rateOf(userID uint64, sec int64) int {
+	if w := l.rate[userID]; w.sec == sec {
+		return w.n
+	}
+	return 0
+}
+
+// count：通过校验的下单命令计入频率窗口（actor 在 checkLimits、回放在 symState.advance 里调用）
+func (l *userLimiter) count(cmd Command) {
+	sec := cmd.EngineTs / int64(time.Second)
+	w := l.rate[cmd.UserID]
+	if sec > w.sec {
+		w = rateWindow{sec: sec}
+	}
+	w.n++
+	l.rate[cmd.UserID] = w
+	l.lastTs = max(l.lastTs, cmd.EngineTs)
+	if sec > l.lastSec {
+		l.lastSec = sec
+		if len(l.rate) > rateSweepAt {
+			for u, rw := range l.rate {
+				if rw.sec < sec {
+					delete(l.rate, u)
+				}
+			}
+		}
+	}
+}
+
+// window：当前秒的窗口（快照保存，之前的秒已经不影响校验）
+func (l *userLimiter) window() map[uint64]rateWindow {
+	out := make(map[uint64]rateWindow)
+	for u, w := range l.rate {
+		if w.sec == l.lastSec && w.n > 0 {
+			out[u] = w
+		}
+	}
+	return out
+}
+
+// restore：从快照里的窗口开始（在回放 WAL 尾部之前）
+func (l *userLimiter) restore(rate map[uint64]rateWindow) {
+	for u, w := range rate {
+		l.rate[u] = w
+		l.lastSec = max(l.lastSec, w.sec)
+	}
+	l.lastTs = max(l.lastTs, l.lastSec*int64(time.Second))
+}
+
+// checkLimits：下单命令的用户级限额；通过时计入频率窗口与本 batch 的未完结量
+func (a *SymbolActor) checkLimits(cmd *Command) RejectCode {
+	if !isSubmit(cmd.Type) {
+		return RejectNone
+	}
+	l := a.limits
+	var spec SymbolSpec
+	if a.symbols != nil {
+		spec, _ = a.symbols.Get(a.symbol)
+	}
+	if spec.MaxOrderRate > 0 && l.rateOf(cmd.UserID, cmd.EngineTs/int64(time.Second)) >= spec.MaxOrderRate {
+		return RejectRateLimited
+	}
+
+	rests := cmd.Type == CmdSubmitStop || (cmd.Type == CmdSubmitLimit && cmd.TIF == TifGTC)
+	if rests && (spec.MaxOpenOrders > 0 || spec.MaxOpenNotional > 0) {
+		add := userLoad{orders: 1, notional: cmdNotional(*cmd)}
+		next := a.userLoad(cmd.UserID).plus(add)
+		if spec.MaxOpenOrders > 0 && next.orders > spec.MaxOpenOrders {
+			return RejectMaxOpenOrders
+		}
+		if spec.MaxOpenNotional > 0 && next.notional.exceeds(spec.MaxOpenNotional) {
+			return RejectMaxOpenNotional
+		}
+		l.pending[cmd.UserID] = l.pending[cmd.UserID].plus(add)
+		if cmd.Type == CmdSubmitLimit && spec.MaxOpenNotional > 0 {
+			l.fresh[cmd.OrderID] = RestingOrder{OrderID: cmd.OrderID, UserID: cmd.UserID, Price: cmd.Price, Qty: cmd.Qty}
+		}
+	}
+	l.count(*cmd)
+	return RejectNone
+}
+
+// checkAmendLimits：改单把名义价值改大时，按增量校验 MaxOpenNotional；通过时增量计入本 batch 的未完结量
+// 找不到原单 / 不是本人的单：不在这里拒，apply 时簿会拒
+func (a *SymbolActor) checkAmendLimits(cmd *Command) RejectCode {
+	var spec SymbolSpec
+	if a.symbols != nil {
+		spec, _ = a.symbols.Get(a.symbol)
+	}
+	if spec.MaxOpenNotional <= 0 {
+		return RejectNone
+	}
+	o, ok := a.limits.fresh[cmd.OrderID]
+	if !ok {
+		qb, isQB := a.book.(QueryBook)
+		if !isQB {
+			return RejectNone
+		}
+		if o, ok = qb.Order(cmd.OrderID); !ok {
+			return RejectNone
+		}
+	}
+	if cmd.UserID != 0 && cmd.UserID != o.UserID {
+		return RejectNone
+	}
+	price, qty := o.Price, o.Qty+o.Reserve
+	if cmd.Price > 0 {
+		price = cmd.Price
+	}
+	if cmd.Qty > 0 {
+		qty = cmd.Qty
+	}
+	old, next := mulNotional(o.Price, o.Qty+o.Reserve), mulNotional(price, qty)
+	if next.greater(old) {
+		add := userLoad{notional: next.sub(old)}
+		if a.userLoad(o.UserID).plus(add).notional.exceeds(spec.MaxOpenNotional) {
+			return RejectMaxOpenNotional
+		}
+		a.limits.pending[o.UserID] = a.limits.pending[o.UserID].plus(add)
+	}
+	a.limits.fresh[cmd.OrderID] = RestingOrder{OrderID: o.OrderID, UserID: o.UserID, Price: price, Qty: qty}
+	return RejectNone
+}
+
+// userLoad：该用户当前的未完结订单数与名义价值（簿不支持查询时只算止损单和本 batch 的新单）
+func (a *SymbolActor) userLoad(userID uint64) userLoad {
+	return a.limits.pending[userID].plus(a.loads.user(userID)).plus(a.stops.userLoad(userID))
+}
+
+// cmdNotional：下单名义价值；止损市价单按触发价估算
+func cmdNotional(cmd Command) notional {
+	px := cmd.Price
+	if px == 0 && cmd.Type == CmdSubmitStop {
+		px = cmd.StopPrice
+	}
+	return mulNotional(px, cmd.Qty)
+}
+
+// bookLoads：簿里挂单按用户累计的单数/名义价值
+// apply 时由事件记下动过的订单，命令执行完按簿里的现状对账（只看这些订单，不扫全簿）
+type bookLoads struct {
+	qb      QueryBook
+	orders  map[uint64]openLoad // 订单号 → 上次对账时的归属与名义价值
+	users   map[uint64]userLoad
+	touched []uint64
+}
+
+type openLoad struct {
+	user     uint64
+	notional notional
+}
+
+// newBookLoads：从当前簿（快照 + 回放之后）建一次；簿不支持查询/导出时返回 nil（不算簿里的挂单）
+func newBookLoads(book OrderBook) *bookLoads {
+	qb, ok := book.(QueryBook)
+	sb, ok2 := book.(BookSnapshotter)
+	if !ok || !ok2 {
+		return nil
+	}
+	l := &bookLoads{qb: qb, orders: make(map[uint64]openLoad), users: make(map[uint64]userLoad)}
+	for _, o := range sb.SnapshotOrders() {
+		l.set(o.OrderID, o, true)
+	}
+	return l
+}
+
+func (l *bookLoads) user(userID uint64) userLoad {
+	if l == nil {
+		return userLoad{}
+	}
+	return l.users[userID]
+}
+
+func (l *bookLoads) touch(orderID uint64) { l.touched = append(l.touched, orderID) }
+
+// sync：命令执行完，把动过的订单按簿里的现状重新计入
+func (l *bookLoads) sync() {
+	for _, id := range l.touched {
+		o, ok := l.qb.Order(id)
+		l.set(id, o, ok)
+	}
+	l.touched = l.touched[:0]
+}
+
+func (l *bookLoads) set(orderID uint64, o RestingOrder, resting bool) {
+	if old, ok := l.orders[orderID]; ok {
+		u := l.users[old.user].minus(userLoad{orders: 1, notional: old.notional})
+		if u.orders == 0 {
+			delete(l.users, old.user)
+		} else {
+			l.users[old.user] = u
+		}
+		delete(l.orders, orderID)
+	}
+	if !resting {
+		return
+	}
+	cur := openLoad{user: o.UserID, notional: mulNotional(o.Price, o.Qty).add(mulNotional(o.Price, o.Reserve))}
+	l.orders[orderID] = cur
+	l.users[o.UserID] = l.users[o.UserID].plus(userLoad{orders: 1, notional: cur.notional})
+}
+
+// loadEmitter：记下事件里动过的挂单（新挂、成交、撤、改、STP 减量），apply 后 bookLoads.sync 对账
+type loadEmitter struct {
+	Emitter
+	l *bookLoads
+}
+
+func (e loadEmitter) Added(reqID uint64, orderID, userID uint64) {
+	e.l.touch(orderID)
+	e.Emitter.Added(reqID, orderID, userID)
+}
+
+func (e loadEmitter) Cancelled(reqID uint64, orderID uint64) {
+	e.l.touch(orderID)
+	e.Emitter.Cancelled(reqID, orderID)
+}
+
+func (e loadEmitter) Trade(reqID uint64, makerOrderID, takerOrderID, makerUserID, takerUserID uint64, takerSide uint8, price, qty int64) {
+	e.l.touch(makerOrderID)
+	e.l.touch(takerOrderID)
+	e.Emitter.Trade(reqID, makerOrderID, takerOrderID, makerUserID, takerUserID, takerSide, price, qty)
+}
+
+func (e loadEmitter) Amended(reqID uint64, orderID, userID uint64, price, qty int64) {
+	e.l.touch(orderID)
+	e.Emitter.Amended(reqID, orderID, userID, price, qty)
+}
+
+func (e loadEmitter) SelfTradePrevented(reqID uint64, orderID, userID, makerOrderID, takerOrderID uint64, qty int64) {
+	e.l.touch(orderID)
+	e.Emitter.SelfTradePrevented(reqID, orderID, userID, makerOrderID, takerOrderID, qty)
+}
@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUserLimits_OpenOrdersAndNotional(t *testing.T) {
+	const sym = "BTCUSDT"
+	dir := t.TempDir()
+	cfg := replTestCfg(dir)
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, MaxOpenOrders: 3, MaxOpenNotional: 1000})
+	eng := NewEngine(cfg)
+	ctx := context.Background()
+	submit := func(c Command) Result {
+		t.Helper()
+		c.UserID = 1
+		res, err := eng.Submit(ctx, sym, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	submit(Command{Type: CmdSubmitLimit, ReqID: 1, Side: Buy, Price: 100, Qty: 4})
+	submit(Command{Type: CmdSubmitStop, ReqID: 2, Side: Buy, StopPrice: 120, Qty: 2}) // 止损市价单按触发价算 240
+	if res := submit(Command{Type: CmdSubmitLimit, ReqID: 3, Side: Buy, Price: 100, Qty: 4}); res.Code != RejectMaxOpenNotional {
+		t.Fatalf("notional: %+v", res)
+	}
+	b := submit(Command{Type: CmdSubmitLimit, ReqID: 4, Side: Buy, Price: 90, Qty: 4}) // 正好 1000
+	if res := submit(Command{Type: CmdSubmitLimit, ReqID: 5, Side: Buy, Price: 1, Qty: 1}); res.Code != RejectMaxOpenOrders {
+		t.Fatalf("open orders: %+v", res)
+	}
+	// IOC 不留敞口：不受挂单数限制；别的用户不受影响
+	if res := submit(Command{Type: CmdSubmitLimit, ReqID: 6, Side: Sell, Price: 200, Qty: 1, TIF: TifIOC}); res.Rejected {
+		t.Fatalf("ioc: %+v", res)
+	}
+	if res, _ := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 7, UserID: 2, Side: Buy, Price: 1, Qty: 1}); res.Rejected {
+		t.Fatalf("other user: %+v", res)
+	}
+
+	// 成交后按剩余量算名义价值（100 + 240 + 360），单数不变；撤掉一张才能再挂
+	if res, _ := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 8, UserID: 3, Side: Sell, Price: 100, Qty: 3}); res.FilledQty != 3 {
+		t.Fatalf("fill: %+v", res)
+	}
+	if res := submit(Command{Type: CmdSubmitLimit, ReqID: 9, Side: Buy, Price: 50, Qty: 6}); res.Code != RejectMaxOpenOrders {
+		t.Fatalf("still 3 open: %+v", res)
+	}
+	if _, err := eng.Submit(ctx, sym, Command{Type: CmdCancel, ReqID: 10, CancelOrderID: b.OrderID}); err != nil {
+		t.Fatal(err)
+	}
+	o := submit(Command{Type: CmdSubmitLimit, ReqID: 11, Side: Buy, Price: 50, Qty: 6})
+	if o.Rejected {
+		t.Fatalf("after cancel: %+v", o)
+	}
+	if res := submit(Command{Type: CmdAmend, ReqID: 12, OrderID: o.OrderID, Qty: 2}); res.Rejected {
+		t.Fatalf("amend: %+v", res)
+	}
+	// 按事件累计的和扫簿算出来的一致
+	if err := eng.query(ctx, sym, func(a *SymbolActor, qb QueryBook) {
+		for _, u := range []uint64{1, 2, 3} {
+			var want userLoad
+			for _, o := range qb.UserOrders(u) {
+				want = want.plus(userLoad{orders: 1, notional: mulNotional(o.Price, o.Qty+o.Reserve)})
+			}
+			if got := a.loads.user(u); got != want {
+				t.Errorf("user %d load=%+v, want %+v", u, got, want)
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// 拒单码随命令落 WAL：离线重放不带限额配置也得到同样的事件
+	rec, err := RecordedEvents(dir, sym, EvCmdCodec{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := replayWith(t, ReplayConfig{WALDir: dir, Symbol: sym}, cfg.BookFactory)
+	if i := DiffEvents(res.Events, rec); i >= 0 {
+		t.Fatalf("replay differs at #%d: %+v", i, res.Events[i])
+	}
+}
+
+func TestUserLimits_AmendNotional(t *testing.T) {
+	const sym = "BTCUSDT"
+	cfg := replTestCfg(t.TempDir())
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, MaxOpenNotional: 1000})
+	eng := NewEngine(cfg)
+	defer eng.Stop()
+	ctx := context.Background()
+	submit := func(c Command) Result {
+		t.Helper()
+		c.UserID = 1
+		res, err := eng.Submit(ctx, sym, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	a := submit(Command{Type: CmdSubmitLimit, ReqID: 1, Side: Buy, Price: 100, Qty: 4}) // 400
+	b := submit(Command{Type: CmdSubmitLimit, ReqID: 2, Side: Buy, Price: 90, Qty: 5})  // 450
+	// 改大按增量算：400 -> 600 超限（600 + 450），400 -> 550 正好 1000
+	if res := submit(Command{Type: CmdAmend, ReqID: 3, OrderID: a.OrderID, Qty: 6}); res.Code != RejectMaxOpenNotional {
+		t.Fatalf("grow qty: %+v", res)
+	}
+	if res := submit(Command{Type: CmdAmend, ReqID: 4, OrderID: a.OrderID, Price: 110, Qty: 5}); res.Rejected {
+		t.Fatalf("grow to limit: %+v", res)
+	}
+	if res := submit(Command{Type: CmdAmend, ReqID: 5, OrderID: b.OrderID, Price: 91}); res.Code != RejectMaxOpenNotional {
+		t.Fatalf("grow price: %+v", res)
+	}
+	// 改小不校验，腾出的额度别的单能用
+	if res := submit(Command{Type: CmdAmend, ReqID: 6, OrderID: a.OrderID, Qty: 1}); res.Rejected {
+		t.Fatalf("shrink: %+v", res)
+	}
+	if res := submit(Command{Type: CmdAmend, ReqID: 7, OrderID: b.OrderID, Qty: 9}); res.Rejected {
+		t.Fatalf("grow after shrink: %+v", res)
+	}
+	if err := eng.query(ctx, sym, func(a *SymbolActor, _ QueryBook) {
+		if got, want := a.loads.user(1).notional, mulNotional(110, 1).add(mulNotional(90, 9)); got != want {
+			t.Errorf("notional=%+v, want %+v", got, want)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserLimits_OrderRate(t *testing.T) {
+	const sym = "BTCUSDT"
+	cfg := replTestCfg(t.TempDir())
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, MaxOrderRate: 2})
+	sec := int64(1_700_000_000) * int64(time.Second)
+	var clock atomic.Int64
+	cfg.Now = func() time.Time { return time.Unix(0, clock.Load()) }
+	eng := NewEngine(cfg)
+	ctx := context.Background()
+
+	for i, c := range []struct {
+		now, clientTs int64
+		want          RejectCode
+	}{
+		{sec, 0, RejectNone},
+		{sec + 400*int64(time.Millisecond), sec - int64(time.Hour), RejectNone},
+		{sec + 900*int64(time.Millisecond), sec + int64(time.Hour), RejectRateLimited}, // ClientTs 不参与计数
+		{sec - int64(time.Second), 0, RejectRateLimited},                               // 本地时钟回拨：EngineTs 不回退
+		{sec + int64(time.Second), 0, RejectNone},
+	} {
+		clock.Store(c.now)
+		res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: uint64(i + 1), UserID: 1, Side: Buy, Price: 100, Qty: 1, ClientTs: c.clientTs})
+		if err != nil || res.Code != c.want {
+			t.Fatalf("#%d: %+v %v, want %s", i, res, err, c.want)
+		}
+	}
+	// 撤单不限频
+	if res, _ := eng.Submit(ctx, sym, Command{Type: CmdCancel, ReqID: 9, CancelOrderID: EngineOrderID(0, 1)}); res.Rejected {
+		t.Fatalf("cancel: %+v", res)
+	}
+
+	// 同一 batch 内逐条计数：不论怎么分批，同一秒都只进 2 单
+	for i := 0; i < 5; i++ {
+		if err := eng.TrySubmit(sym, Command{Type: CmdSubmitLimit, ReqID: uint64(100 + i), UserID: 2, Side: Buy, Price: 100, Qty: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 200, UserID: 2, Side: Buy, Price: 100, Qty: 1})
+	if err != nil || res.Code != RejectRateLimited {
+		t.Fatalf("after burst: %+v %v", res, err)
+	}
+	var n int
+	for _, o := range bookOrders(t, eng, sym) {
+		if o.UserID == 2 {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Fatalf("user 2 resting=%d, want 2", n)
+	}
+	eng.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// 重启：窗口按 WAL 里的 EngineTs 重建，同一秒不能再借重启多下单
+	eng = NewEngine(cfg)
+	defer eng.Stop()
+	if res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 300, UserID: 2, Side: Buy, Price: 100, Qty: 1}); err != nil || res.Code != RejectRateLimited {
+		t.Fatalf("after restart: %+v %v", res, err)
+	}
+	if res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 301, UserID: 1, Side: Buy, Price: 100, Qty: 1}); err != nil || res.Rejected {
+		t.Fatalf("user 1 second slot: %+v %v", res, err)
+	}
+}
+
+func TestUserLimits_SnapshotKeepsRateWindow(t *testing.T) {
+	sec := int64(1_700_000_000)
+	l := newUserLimiter()
+	for _, c := range []Command{
+		{Type: CmdSubmitLimit, UserID: 1, EngineTs: (sec - 1) * int64(time.Second)},
+		{Type: CmdSubmitLimit, UserID: 2, EngineTs: (sec - 1) * int64(time.Second)},
+		{Type: CmdSubmitLimit, UserID: 1, EngineTs: sec * int64(time.Second)},
+		{Type: CmdSubmitLimit, UserID: 1, EngineTs: sec*int64(time.Second) + 1},
+	} {
+		l.count(c)
+	}
+	h, _, _, err := decodeSnapshot(encodeSnapshot(3, 0, symState{limits: l}, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := symState{limits: newUserLimiter()}
+	h.restore(&st)
+	// 只保存当前秒：用户 2 上一秒的计数不影响
+	if st.limits.rateOf(1, sec) != 2 || len(st.limits.rate) != 1 {
+		t.Fatalf("restored=%+v", st.limits.rate)
+	}
+	var cmd Command
+	st.limits.stamp(&cmd, 0) // 本地时钟落后于快照：EngineTs 不回退到快照之前
+	if cmd.EngineTs < sec*int64(time.Second) {
+		t.Fatalf("engine ts=%d", cmd.EngineTs)
+	}
+}
+
+func TestUserLimits_NotionalOverflow(t *testing.T) {
+	const sym = "BTCUSDT"
+	cfg := replTestCfg(t.TempDir())
+	cfg.Symbols = newTestRegistry(t, SymbolSpec{Symbol: sym, MaxOpenNotional: math.MaxInt64})
+	eng := NewEngine(cfg)
+	defer eng.Stop()
+	ctx := context.Background()
+
+	// 1e10*1e10 超过 int64：不能溢出成负数绕过限额
+	res, err := eng.Submit(ctx, sym, Command{Type: CmdSubmitLimit, ReqID: 1, UserID: 1, Side: Buy, Price: 1e10, Qty: 1e10})
+	if err != nil || res.Code != RejectMaxOpenNotional {
+		t.Fatalf("single: %+v %v", res, err)
+	}
+	// 两张各自不超，合计超过 int64
+	for i, want := range []RejectCode{RejectNone, RejectMaxOpenNotional} {
+		res, err = eng.Submit(ctx, sym, Command{Type: CmdSubmitStop, ReqID: uint64(2 + i), UserID: 1, Side: Buy, StopPrice: 1e9, Qty: 5e9})
+		if err != nil || res.Code != want {
+			t.Fatalf("stop #%d: %+v %v, want %s", i, res, err, want)
+		}
+	}
+}
+
+func TestUserLimits_CmdCodec(t *testing.T) {
+	cmd := Command{Type: CmdSubmitLimit, ReqID: 1, OrderID: 2, UserID: 3, Side: Buy, Price: 100, Qty: 4, ClientTs: 5, EngineTs: 6}
+	p, _ := BinaryCMDCode{}.Encode(nil, 7, cmd)
+	if _, out, err := (BinaryCMDCode{}).Decode(p); err != nil || out.EngineTs != 6 || out.ClientTs != 5 {
+		t.Fatalf("roundtrip: %+v %v", out, err)
+	}
+	v1 := append([]byte(nil), p[:cmdRecordLenV1]...)
+	v1[offVer] = cmdWalVersion1
+	if _, out, err := (BinaryCMDCode{}).Decode(v1); err != nil || out.EngineTs != 0 || out.ClientTs != 5 || out.Qty != 4 {
+		t.Fatalf("v1 decode: %+v %v", out, err)
+	}
+}
@@ -44,6 +44,22 @@ func evTypeName(t uint8) string {
 		return "Cancelled"
 	case 5:
 		return "Trade"
+	case 6:
+		return "Expired"
+	case 7:
+		return "Amended"
+	case 8:
+		return "SelfTradePrevented"
+	case 9:
+		return "PhaseChanged"
+	case 10:
+		return "StopAccepted"
+	case 11:
+		return "StopTriggered"
+	case 12:
+		return "StopCancelled"
+	case 13:
+		return "Replenished"
 	case 250:
 		return "CmdEnd"
 	default:
@@ -59,6 +75,28 @@ func cmdTypeName(t uint8) string {
 		return "SubmitLimit"
 	case 2:
 		return "Cancel"
+	case 3:
+		return "SubmitMarket"
+	case 4:
+		return "Amend"
+	case 5:
+		return "Halt"
+	case 6:
+		return "Resume"
+	case 7:
+		return "CancelOnly"
+	case 8:
+		return "CancelAll"
+	case 9:
+		return "Auction"
+	case 10:
+		return "Uncross"
+	case 11:
+		return "SubmitStop"
+	case 12:
+		return "SetFees"
+	case 13:
+		return "SetSTP"
 	default:
 		return fmt.Sprintf("Unknown(%d)", t)
 	}
@@ -152,16 +190,26 @@ func renderCmdPayload(p []byte) string {
 			// 这里按你们 Command 常用字段打印（没有的字段编译会报错，你删掉对应字段即可）
 			// 目标：一眼看懂 “seq + type + req + 核心参数”
 			switch c.Type {
-			case CmdSubmitLimit:
+			case CmdSubmitLimit, CmdSubmitMarket:
 				return fmt.Sprintf(
-					"Seq:%d  Type:%d(%s)  ReqID:%d  OrderID:%d  UserID:%d  Side:%s  Price:%d  Qty:%d",
-					rec.Seq, c.Type, cmdTypeName(uint8(c.Type)), c.ReqID, c.OrderID, c.UserID, sideName(c.Side), c.Price, c.Qty,
+					"Seq:%d  Type:%d(%s)  ReqID:%d  OrderID:%d  UserID:%d  Side:%s  Price:%d  Qty:%d  TIF:%d  PostOnly:%v  Display:%d",
+					rec.Seq, c.Type, cmdTypeName(uint8(c.Type)), c.ReqID, c.OrderID, c.UserID, sideName(c.Side), c.Price, c.Qty, c.TIF, c.PostOnly, c.DisplayQty,
 				)
 			case CmdCancel:
 				return fmt.Sprintf(
 					"Seq:%d  Type:%d(%s)  ReqID:%d  CancelOrderID:%d",
 					rec.Seq, c.Type, cmdTypeName(uint8(c.Type)), c.ReqID, c.CancelOrderID,
 				)
+			case CmdSubmitStop:
+				return fmt.Sprintf(
+					"Seq:%d  Type:%d(%s)  ReqID:%d  OrderID:%d  UserID:%d  Side:%s  StopPrice:%d  Price:%d  Qty:%d  TIF:%d",
+					rec.Seq, c.Type, cmdTypeName(uint8(c.Type)), c.ReqID, c.OrderID, c.UserID, sideName(c.Side), c.StopPrice, c.Price, c.Qty, c.TIF,
+				)
+			case CmdAmend:
+				return fmt.Sprintf(
+					"Seq:%d  Type:%d(%s)  ReqID:%d  OrderID:%d  UserID:%d  Price:%d  Qty:%d",
+					rec.Seq, c.Type, cmdTypeName(uint8(c.Type)), c.ReqID, c.OrderID, c.UserID, c.Price, c.Qty,
+				)
 			default:
 				return fmt.Sprintf(
 					"Seq:%d  Type:%d(%s)  ReqID:%d  (raw cmd=%s)",
@@ -200,14 +248,49 @@ func renderEvPayload(p []byte) string {
 				)
 			case 2: // Rejected
 				return fmt.Sprintf(
-					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d  Reason:%q → %s",
-					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, ev.Reason, name,
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d  Code:%d(%s) → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, ev.Code, ev.Code, name,
 				)
 			case 5: // Trade
 				return fmt.Sprintf(
 					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  MakerOrderID:%d  TakerOrderID:%d  Price:%d  Qty:%d → %s",
 					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.MakerOrderID, ev.TakerOrderID, ev.Price, ev.Qty, name,
 				)
+			case 6: // Expired
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d  Qty:%d → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, ev.Qty, name,
+				)
+			case 7: // Amended
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d  Price:%d  Qty:%d → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, ev.Price, ev.Qty, name,
+				)
+			case 8: // SelfTradePrevented
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d  MakerOrderID:%d  TakerOrderID:%d  Qty:%d → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, ev.MakerOrderID, ev.TakerOrderID, ev.Qty, name,
+				)
+			case 10, 11: // StopAccepted / StopTriggered
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d  Price:%d  Qty:%d → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, ev.Price, ev.Qty, name,
+				)
+			case 12: // StopCancelled
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  UserID:%d → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.UserID, name,
+				)
+			case 13: // Replenished
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  OrderID:%d  Qty:%d → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, ev.OrderID, ev.Qty, name,
+				)
+			case 9: // PhaseChanged
+				return fmt.Sprintf(
+					"Type:%d %s  Seq:%d  ReqID:%d  Idx:%d  Phase:%s → %s",
+					ev.Type, name, ev.Seq, ev.ReqID, ev.Idx, Phase(ev.Qty), name,
+				)
 			case 250: // CmdEnd
 				return fmt.Sprintf(
 					"Type:%d %s  Seq:%d → CmdEnd(%d)",
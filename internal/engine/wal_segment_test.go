@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopherex.com/internal/matching"
+	"gopherex.com/pkg/wal"
+)
+
+func TestSegmentedWAL_PublishRetainAndRestart(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+
+	newCfg := func(bus *ChanBus) EngineConfig {
+		return EngineConfig{
+			WALDir:          dir,
+			EnableCmdWAL:    true,
+			EnableOutbox:    true,
+			EnablePublisher: true,
+			PublisherPoll:   5 * time.Millisecond,
+			bus:             bus,
+			CmdCodec:        BinaryCMDCode{},
+			EvCodec:         EvCmdCodec{},
+			ActorCfg:        ActorConfig{MailboxSize: 256, BatchMax: 1},
+			WALSegmentBytes: 256, // 很小：逼出多段
+			BookFactory: func(symbol string) (OrderBook, error) {
+				return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+			},
+		}
+	}
+
+	// Run #1：20 条挂单，每条 Accepted + Added
+	bus := NewChanBus(1 << 10)
+	eng := NewEngine(newCfg(bus))
+	const n = 20
+	for i := 1; i <= n; i++ {
+		if err := eng.TrySubmit(sym, Command{
+			Type: CmdSubmitLimit, ReqID: uint64(i), OrderID: uint64(i), UserID: 1, Side: Sell, Price: int64(100 + i), Qty: 1,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		ev := waitEventType(t, bus.C(), uint8(EvAdded), 2*time.Second)
+		if ev.OrderID != uint64(i+1) {
+			t.Fatalf("added order=%d, want %d (order preserved across segments)", ev.OrderID, i+1)
+		}
+	}
+
+	evPath := outboxWalPath(dir, sym)
+	if !wal.IsSegmented(evPath) || !wal.IsSegmented(cmdWalPath(dir, sym)) {
+		t.Fatalf("expected segmented cmd/ev logs")
+	}
+	// cursor 推进后，已发布的 ev 段应被清理
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m, err := wal.LoadManifest(evPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Segments) > 0 && m.Segments[0].First > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ev segments not retained: %+v", m.Segments)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	eng.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// Run #2：从分段 cmd WAL 恢复，不应重复发布旧事件
+	bus2 := NewChanBus(1 << 10)
+	eng2 := NewEngine(newCfg(bus2))
+	defer func() {
+		eng2.Stop()
+		time.Sleep(50 * time.Millisecond) // 等 actor/publisher 退出再清理 TempDir
+	}()
+	a, err := eng2.getOrCreateActor(sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.seq != n {
+		t.Fatalf("seq after recovery=%d, want %d", a.seq, n)
+	}
+	if p, ok := a.book.(*HeapBookAdapter).B.BestAsk(); !ok || p != 101 {
+		t.Fatalf("best ask=%d/%v, want 101", p, ok)
+	}
+	assertNoEvent(t, bus2.C(), 100*time.Millisecond)
+}
+
+// 命名消费者落后于 publisher：它没读到的 ev 段不能被清理，重新打开后能接着读完
+func TestSegmentedWAL_RetainWaitsForSlowConsumer(t *testing.T) {
+	dir := t.TempDir()
+	const sym = "BTCUSDT"
+	bus := NewChanBus(1 << 10)
+	eng := NewEngine(EngineConfig{
+		WALDir:           dir,
+		EnableCmdWAL:     true,
+		EnableOutbox:     true,
+		EnablePublisher:  true,
+		PublisherPoll:    5 * time.Millisecond,
+		OutboxIndexEvery: 2,
+		bus:              bus,
+		CmdCodec:         BinaryCMDCode{},
+		EvCodec:          EvCmdCodec{},
+		ActorCfg:         ActorConfig{MailboxSize: 256, BatchMax: 1},
+		WALSegmentBytes:  256,
+		BookFactory: func(symbol string) (OrderBook, error) {
+			return NewHeapBookAdapter(matching.NewLevelOrderHeapBook()), nil
+		},
+	})
+	defer func() {
+		eng.Stop()
+		time.Sleep(50 * time.Millisecond)
+	}()
+	submit := func(from, to int) {
+		t.Helper()
+		for i := from; i <= to; i++ {
+			if err := eng.TrySubmit(sym, Command{
+				Type: CmdSubmitLimit, ReqID: uint64(i), OrderID: uint64(i), UserID: 1, Side: Sell, Price: int64(100 + i), Qty: 1,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := from; i <= to; i++ {
+			waitEventType(t, bus.C(), uint8(EvAdded), 2*time.Second)
+		}
+	}
+
+	// settle 先注册，只处理到 seq 2 就下线
+	settle, err := eng.OpenConsumer(sym, "settle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit(1, 4)
+	for i := 0; i < 2; i++ {
+		evs, err := settle.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := settle.Ack(evs[len(evs)-1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = settle.Close()
+
+	// 订阅者从 seq 3 开始读，一直挂着不读
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	block := make(chan struct{})
+	go func() {
+		_ = eng.SubscribeEvents(ctx, sym, 3, func([]Event) error {
+			<-block
+			return nil
+		})
+	}()
+	defer close(block)
+
+	// publisher 远远跑到前面：ev.wal 切出很多段，但 settle 之后的段都要留着
+	submit(5, 40)
+	time.Sleep(50 * time.Millisecond)
+	m, err := wal.LoadManifest(outboxWalPath(dir, sym))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Segments) < 4 {
+		t.Fatalf("expected many ev segments: %+v", m.Segments)
+	}
+
+	settle, err = eng.OpenConsumer(sym, "settle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer settle.Close()
+	evs := readAll(t, settle)
+	if len(evs) != 38*2 || evs[0].Seq != 3 || evs[len(evs)-1].Seq != 40 {
+		t.Fatalf("settle resumed at %+v n=%d", evs[0], len(evs))
+	}
+
+	// settle 追上后（订阅者也下线），publisher 下次推进时清理旧段
+	if err := settle.Ack(evs[len(evs)-1]); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	submit(41, 41)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m, err := wal.LoadManifest(outboxWalPath(dir, sym))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Segments) > 0 && m.Segments[0].First > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ev segments not retained after consumer caught up: %+v", m.Segments)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
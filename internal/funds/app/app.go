@@ -63,7 +63,7 @@ func Run(ctx context.Context) error {
 			if err != nil {
 				return nil, err
 			}
-			repo := gmysql.NewBalancesRepo(newGorm)
+			repo := gmysql.NewRepo(newGorm)
 			cache := funds.NewRedisCache(deps.Redis)
 			srv := funds.NewFundsService(c, repo, cache)
 			return func(gs *grpc.Server) error {
@@ -2,14 +2,35 @@ package repo
 
 import (
 	"context"
+	"errors"
 
 	"gopherex.com/internal/funds/repo/model"
 )
 
+var ErrInsufficientFunds = errors.New("funds: insufficient balance")
+
 type BalancesRepo interface {
 	GetBalances(ctx context.Context, userID uint64, asset string) ([]model.BalanceRow, error)
 }
 
+// Transfer：同一用户同一资产在两个 bucket 之间划转（冻结/解冻），记一笔 entryset + 两条分录
+type Transfer struct {
+	IdempotencyKey string
+	Type           string // entryset 类型：RESERVE / RELEASE
+	Reason         string // 分录原因：FREEZE / UNFREEZE
+	RefID          string
+	UserID         uint64
+	Asset          string
+	From, To       string // bucket
+	Amount         int64  // > 0
+}
+
+type LedgerRepo interface {
+	// Transfer：幂等键处理过直接返回原 entryset_id；From 余额不足返回 ErrInsufficientFunds，不落任何记录
+	Transfer(ctx context.Context, t Transfer) (entrysetID string, err error)
+}
+
 type Repo interface {
 	BalancesRepo
+	LedgerRepo
 }
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+type EntrySetRow struct {
+	EntrySetID     string    `gorm:"column:entryset_id;primaryKey;type:char(36);not null"`
+	IdempotencyKey string    `gorm:"column:idempotency_key;type:varchar(128);not null"`
+	EsType         string    `gorm:"column:es_type;type:varchar(32);not null"`
+	RefID          string    `gorm:"column:ref_id;type:varchar(128);not null"`
+	Status         uint8     `gorm:"column:status;not null"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (EntrySetRow) TableName() string {
+	return "ledger_entrysets"
+}
+
+type EntryRow struct {
+	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+	EntrySetID string    `gorm:"column:entryset_id;type:char(36);not null"`
+	OwnerType  uint8     `gorm:"column:owner_type;not null"`
+	OwnerID    uint64    `gorm:"column:owner_id;not null"`
+	Asset      string    `gorm:"column:asset;type:varchar(16);not null"`
+	Bucket     string    `gorm:"column:bucket;type:varchar(32);not null"`
+	Delta      int64     `gorm:"column:delta;not null"`
+	Reason     string    `gorm:"column:reason;type:varchar(32);not null"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (EntryRow) TableName() string {
+	return "ledger_entries"
+}
@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gopherex.com/internal/funds"
+	"gopherex.com/internal/funds/repo"
+	"gopherex.com/internal/funds/repo/model"
+	"gorm.io/gorm"
+)
+
+type ledgerRepo struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepo(db *gorm.DB) repo.LedgerRepo {
+	return &ledgerRepo{db: db}
+}
+
+// NewRepo：余额查询 + 账本划转
+func NewRepo(db *gorm.DB) repo.Repo {
+	return struct {
+		repo.BalancesRepo
+		repo.LedgerRepo
+	}{NewBalancesRepo(db), NewLedgerRepo(db)}
+}
+
+func (r *ledgerRepo) Transfer(ctx context.Context, t repo.Transfer) (string, error) {
+	var id string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先占幂等键：并发的同键请求在唯一索引上等这个事务结束，之后走“已处理”分支
+		es := model.EntrySetRow{
+			EntrySetID:     uuid.NewString(),
+			IdempotencyKey: t.IdempotencyKey,
+			EsType:         t.Type,
+			RefID:          t.RefID,
+			Status:         1,
+		}
+		res := tx.Exec("INSERT IGNORE INTO ledger_entrysets (entryset_id, idempotency_key, es_type, ref_id, status) VALUES (?, ?, ?, ?, ?)",
+			es.EntrySetID, es.IdempotencyKey, es.EsType, es.RefID, es.Status)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return tx.Model(&model.EntrySetRow{}).
+				Where("idempotency_key = ?", t.IdempotencyKey).
+				Pluck("entryset_id", &id).Error
+		}
+
+		// 扣 From：余额不够时一行都不改
+		res = tx.Exec("UPDATE balances SET amount = amount - ? WHERE owner_type = ? AND owner_id = ? AND asset = ? AND bucket = ? AND amount >= ?",
+			t.Amount, funds.OwnerUser, t.UserID, t.Asset, t.From, t.Amount)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return repo.ErrInsufficientFunds
+		}
+		if err := tx.Exec("INSERT INTO balances (owner_type, owner_id, asset, bucket, amount) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE amount = amount + VALUES(amount)",
+			funds.OwnerUser, t.UserID, t.Asset, t.To, t.Amount).Error; err != nil {
+			return err
+		}
+		entries := []model.EntryRow{
+			{EntrySetID: es.EntrySetID, OwnerType: funds.OwnerUser, OwnerID: t.UserID, Asset: t.Asset, Bucket: t.From, Delta: -t.Amount, Reason: t.Reason},
+			{EntrySetID: es.EntrySetID, OwnerType: funds.OwnerUser, OwnerID: t.UserID, Asset: t.Asset, Bucket: t.To, Delta: t.Amount, Reason: t.Reason},
+		}
+		if err := tx.Create(&entries).Error; err != nil {
+			return err
+		}
+		id = es.EntrySetID
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
@@ -2,6 +2,7 @@ package funds
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,7 +22,7 @@ type FundsService struct {
 	ttl   time.Duration
 }
 
-func NewFundsService(context context.Context, repo repo.BalancesRepo, cache Cache) *FundsService {
+func NewFundsService(context context.Context, repo repo.Repo, cache Cache) *FundsService {
 	return &FundsService{
 		ctx:   context,
 		cache: cache,
@@ -62,14 +63,59 @@ func (f *FundsService) GetBalances(ctx context.Context, req *fundsv1.GetBalances
 	return v.(*fundsv1.GetBalancesRes), nil
 }
 
+// Reserve：spot_available → spot_frozen；余额不足返回 FailedPrecondition（撮合侧据此拒单）
 func (f *FundsService) Reserve(ctx context.Context, req *fundsv1.ReserveReq) (*fundsv1.ReserveResp, error) {
-	//TODO implement me
-	panic("implement me")
+	id, err := f.transfer(ctx, repo.Transfer{
+		IdempotencyKey: req.GetIdempotencyKey(),
+		Type:           EsReserve,
+		Reason:         ReasonFreeze,
+		RefID:          req.GetRefId(),
+		UserID:         req.GetUserId(),
+		Asset:          req.GetAsset(),
+		From:           BucketAvailable,
+		To:             BucketFrozen,
+		Amount:         req.GetAmount(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fundsv1.ReserveResp{EntrysetId: id}, nil
 }
 
+// Release：spot_frozen → spot_available；ref_id 一般是冻结时的 entryset_id
 func (f *FundsService) Release(ctx context.Context, req *fundsv1.ReleaseReq) (*fundsv1.ReleaseResp, error) {
-	//TODO implement me
-	panic("implement me")
+	id, err := f.transfer(ctx, repo.Transfer{
+		IdempotencyKey: req.GetIdempotencyKey(),
+		Type:           EsRelease,
+		Reason:         ReasonUnfreeze,
+		RefID:          req.GetRefId(),
+		UserID:         req.GetUserId(),
+		Asset:          req.GetAsset(),
+		From:           BucketFrozen,
+		To:             BucketAvailable,
+		Amount:         req.GetAmount(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fundsv1.ReleaseResp{EntrysetId: id}, nil
+}
+
+// transfer：幂等划转；成功后删掉该用户的余额缓存（单资产和全部）
+func (f *FundsService) transfer(ctx context.Context, t repo.Transfer) (string, error) {
+	if t.IdempotencyKey == "" || t.UserID == 0 || t.Asset == "" || t.Amount <= 0 {
+		return "", status.Error(codes.InvalidArgument, "idempotency_key/user_id/asset/amount required")
+	}
+	id, err := f.repo.Transfer(ctx, t)
+	if errors.Is(err, repo.ErrInsufficientFunds) {
+		return "", status.Errorf(codes.FailedPrecondition, "insufficient %s %s", t.Asset, t.From)
+	}
+	if err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+	_ = f.cache.DelBalances(ctx, t.UserID, t.Asset)
+	_ = f.cache.DelBalances(ctx, t.UserID, "")
+	return id, nil
 }
 
 func (f *FundsService) SettleTrade(ctx context.Context, req *fundsv1.SettleTradeReq) (*fundsv1.SettleTradeResp, error) {
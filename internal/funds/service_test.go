@@ -0,0 +1,93 @@
+package funds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	fundsv1 "gopherex.com/gen/go/fund_service/v1"
+	"gopherex.com/internal/funds/repo"
+	"gopherex.com/internal/funds/repo/model"
+)
+
+// memRepo：按 (user, asset, bucket) 记余额，幂等键记 entryset
+type memRepo struct {
+	bal map[string]int64
+	ids map[string]string
+}
+
+func (m *memRepo) GetBalances(context.Context, uint64, string) ([]model.BalanceRow, error) {
+	return nil, nil
+}
+
+func (m *memRepo) Transfer(_ context.Context, t repo.Transfer) (string, error) {
+	if id, ok := m.ids[t.IdempotencyKey]; ok {
+		return id, nil
+	}
+	from, to := t.Asset+"/"+t.From, t.Asset+"/"+t.To
+	if m.bal[from] < t.Amount {
+		return "", repo.ErrInsufficientFunds
+	}
+	m.bal[from] -= t.Amount
+	m.bal[to] += t.Amount
+	id := t.Type + ":" + t.IdempotencyKey
+	m.ids[t.IdempotencyKey] = id
+	return id, nil
+}
+
+type memCache struct{ dels []string }
+
+func (c *memCache) GetBalances(context.Context, uint64, string) (*fundsv1.GetBalancesRes, bool, error) {
+	return nil, false, nil
+}
+
+func (c *memCache) SetBalances(context.Context, uint64, string, *fundsv1.GetBalancesRes, time.Duration) error {
+	return nil
+}
+
+func (c *memCache) DelBalances(_ context.Context, _ uint64, asset string) error {
+	c.dels = append(c.dels, asset)
+	return nil
+}
+
+func TestFundsService_ReserveRelease(t *testing.T) {
+	ctx := context.Background()
+	r := &memRepo{bal: map[string]int64{"USDT/" + BucketAvailable: 100}, ids: map[string]string{}}
+	c := &memCache{}
+	f := NewFundsService(ctx, r, c)
+
+	rsv := &fundsv1.ReserveReq{IdempotencyKey: "rsv:1", UserId: 7, Asset: "USDT", Amount: 60}
+	res, err := f.Reserve(ctx, rsv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 重试同一个幂等键：同一个 entryset，不重复冻结
+	again, err := f.Reserve(ctx, rsv)
+	if err != nil || again.GetEntrysetId() != res.GetEntrysetId() {
+		t.Fatalf("retry=%v/%v, want %s", again, err, res.GetEntrysetId())
+	}
+	if r.bal["USDT/"+BucketAvailable] != 40 || r.bal["USDT/"+BucketFrozen] != 60 {
+		t.Fatalf("balances after reserve: %v", r.bal)
+	}
+	if len(c.dels) == 0 {
+		t.Fatalf("balance cache not invalidated")
+	}
+
+	_, err = f.Reserve(ctx, &fundsv1.ReserveReq{IdempotencyKey: "rsv:2", UserId: 7, Asset: "USDT", Amount: 41})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("err=%v, want FailedPrecondition", err)
+	}
+
+	if _, err := f.Release(ctx, &fundsv1.ReleaseReq{IdempotencyKey: "rel:1", UserId: 7, Asset: "USDT", Amount: 60, RefId: res.GetEntrysetId()}); err != nil {
+		t.Fatal(err)
+	}
+	if r.bal["USDT/"+BucketAvailable] != 100 || r.bal["USDT/"+BucketFrozen] != 0 {
+		t.Fatalf("balances after release: %v", r.bal)
+	}
+	_, err = f.Release(ctx, &fundsv1.ReleaseReq{IdempotencyKey: "rel:2", UserId: 7, Asset: "USDT", Amount: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("over-release err=%v, want FailedPrecondition", err)
+	}
+}
@@ -7,6 +7,18 @@ const (
 	OwnerSystem = uint8(2)
 )
 
+// 余额桶与账本类型：与 account.sql 的注释对齐
+const (
+	BucketAvailable = "spot_available"
+	BucketFrozen    = "spot_frozen"
+
+	EsReserve = "RESERVE"
+	EsRelease = "RELEASE"
+
+	ReasonFreeze   = "FREEZE"
+	ReasonUnfreeze = "UNFREEZE"
+)
+
 // clone 避免上层修改返回对象影响缓存/并发
 func cloneGetBalancesRes(in *fundsv1.GetBalancesRes) *fundsv1.GetBalancesRes {
 	if in == nil {
@@ -0,0 +1,233 @@
+package matching
+
+// 集合竞价（开盘/复牌）
+// - 竞价期间只收单不撮合：MatchEmit 直接返回，剩余由上层挂单，簿可以是交叉的
+// - Uncross：算出成交量最大的单一价格，所有可成交订单都按这个价成交
+//
+// 竞价价格选择（依次打破平局）：
+//  1. 可成交量最大
+//  2. 剩余不平衡量 |买量-卖量| 最小
+//  3. 市场压力：剩余全部是买方多 → 取最高价；全部是卖方多 → 取最低价
+//  4. 离参考价（最新成交价）最近；没有参考价取候选区间中间偏低的那个
+
+// SetAuction：进入/退出竞价收单模式（退出不会撮合，正常流程用 Uncross 退出）
+func (b *LevelOrderBookHeap) SetAuction(on bool) { b.auction = on }
+
+func (b *LevelOrderBookHeap) InAuction() bool { return b.auction }
+
+// AuctionPrice：按当前簿计算竞价价格与可成交量；没有交叉返回 ok=false
+func (b *LevelOrderBookHeap) AuctionPrice(ref int64) (price, volume int64, ok bool) {
+	bidP, okB := b.bestBidPrice()
+	askP, okA := b.bestAskPrice()
+	if !okB || !okA || bidP < askP {
+		return 0, 0, false
+	}
+
+	// 交叉区间 [bestAsk, bestBid] 内的价位：买盘降序、卖盘升序各沿索引走一遍，顺带累计量
+	// 买盘 cum = 价格 >= p 的买量（需求），卖盘 cum = 价格 <= p 的卖量（供给）
+	type level struct {
+		price, cum int64
+	}
+	var bids, asks []level
+	var cum int64
+	b.bidX.rangeByPriority(func(p int64) bool {
+		if p < askP {
+			return false
+		}
+		lv := b.bids[p]
+		cum += lv.qty + lv.rsv
+		bids = append(bids, level{p, cum})
+		return true
+	})
+	cum = 0
+	b.askX.rangeByPriority(func(p int64) bool {
+		if p > bidP {
+			return false
+		}
+		lv := b.asks[p]
+		cum += lv.qty + lv.rsv
+		asks = append(asks, level{p, cum})
+		return true
+	})
+
+	// 候选价按升序合并两边价位：供给取 <= p 的最后一档，需求取 >= p 的第一档
+	type point struct {
+		price, vol, imb int64
+	}
+	pts := make([]point, 0, len(asks)+len(bids))
+	var supply int64
+	i, j := 0, len(bids)-1 // bids 倒着走也是升序
+	for i < len(asks) || j >= 0 {
+		p := int64(0)
+		switch {
+		case j < 0 || (i < len(asks) && asks[i].price <= bids[j].price):
+			p = asks[i].price
+		default:
+			p = bids[j].price
+		}
+		for i < len(asks) && asks[i].price <= p {
+			supply = asks[i].cum
+			i++
+		}
+		var demand int64
+		if j >= 0 {
+			demand = bids[j].cum
+		}
+		pts = append(pts, point{price: p, vol: min64(demand, supply), imb: demand - supply})
+		for j >= 0 && bids[j].price <= p {
+			j--
+		}
+	}
+
+	// 1) 最大成交量
+	var best int64
+	for _, pt := range pts {
+		if pt.vol > best {
+			best = pt.vol
+		}
+	}
+	if best == 0 {
+		return 0, 0, false
+	}
+	// 2) 最小不平衡
+	minImb := int64(-1)
+	for _, pt := range pts {
+		if pt.vol == best && (minImb < 0 || abs64(pt.imb) < minImb) {
+			minImb = abs64(pt.imb)
+		}
+	}
+	keep := pts[:0]
+	for _, pt := range pts {
+		if pt.vol == best && abs64(pt.imb) == minImb {
+			keep = append(keep, pt)
+		}
+	}
+	// 3) 市场压力
+	allBuy, allSell := true, true
+	for _, pt := range keep {
+		allBuy = allBuy && pt.imb > 0
+		allSell = allSell && pt.imb < 0
+	}
+	switch {
+	case len(keep) == 1:
+		return keep[0].price, best, true
+	case allBuy:
+		return keep[len(keep)-1].price, best, true
+	case allSell:
+		return keep[0].price, best, true
+	}
+	// 4) 参考价
+	if ref <= 0 {
+		return keep[(len(keep)-1)/2].price, best, true
+	}
+	pick := keep[0].price
+	for _, pt := range keep[1:] {
+		if abs64(pt.price-ref) < abs64(pick-ref) {
+			pick = pt.price
+		}
+	}
+	return pick, best, true
+}
+
+// Uncross：按竞价价格一次性成交所有可成交订单，并退出竞价模式
+// 成交双方没有主动方：约定 MakerID=卖单、TakerID=买单，价格统一为竞价价格
+// 同一用户的买卖单按簿的 STP 模式处理（见 auctionSelfTrade），不互相成交
+func (b *LevelOrderBookHeap) Uncross(ref int64, emit func(Trade), onSTP func(SelfTrade)) (price, volume int64) {
+	defer b.SetAuction(false)
+	price, _, ok := b.AuctionPrice(ref)
+	if !ok {
+		return 0, 0
+	}
+	if emit == nil {
+		emit = func(Trade) {}
+	}
+	for {
+		bidP, okB := b.bestBidPrice()
+		askP, okA := b.bestAskPrice()
+		if !okB || !okA || bidP < price || askP > price {
+			break
+		}
+		bl, al := b.bids[bidP], b.asks[askP]
+		bn, an := bl.head, al.head
+		if b.auctionSelfTrade(bn, an, onSTP) {
+			continue
+		}
+		exec := min64(bn.order.Qty, an.order.Qty)
+		tr := Trade{TakerID: bn.order.ID, MakerID: an.order.ID, TakerUserID: bn.order.UserID, MakerUserID: an.order.UserID, Price: price, Qty: exec}
+		tr.TakerRefill = b.fill(bn, exec)
+		tr.Refill = b.fill(an, exec)
+		emit(tr)
+		volume += exec
+	}
+	return price, volume
+}
+
+// auctionSelfTrade：竞价两边都是挂单，入队序号大的（后进簿的单）按 taker 处理，再套用连续撮合的 STP 语义
+// 撤单都是整单撤（含冰山隐藏部分）；Decrement 两边各减 min(可见数量)，减完补片
+func (b *LevelOrderBookHeap) auctionSelfTrade(bn, an *lvNodeHeap, onSTP func(SelfTrade)) bool {
+	if b.stp == STPNone || bn.order.UserID == 0 || bn.order.UserID != an.order.UserID {
+		return false
+	}
+	tn, mn := bn, an
+	if mn.order.Seq > tn.order.Seq {
+		tn, mn = mn, tn
+	}
+	taker, maker := tn.order, mn.order
+	st := SelfTrade{Mode: b.stp, TakerID: taker.ID, MakerID: maker.ID, UserID: taker.UserID}
+	switch b.stp {
+	case STPCancelNewest:
+		st.TakerQty = taker.Qty + taker.Reserve
+	case STPCancelOldest:
+		st.MakerQty = maker.Qty + maker.Reserve
+	case STPCancelBoth:
+		st.TakerQty, st.MakerQty = taker.Qty+taker.Reserve, maker.Qty+maker.Reserve
+	case STPDecrement:
+		d := min64(taker.Qty, maker.Qty)
+		st.TakerQty, st.MakerQty = d, d
+		st.TakerRefill = b.fill(tn, d)
+		st.Refill = b.fill(mn, d)
+	default:
+		return false
+	}
+	if b.stp != STPDecrement {
+		if st.TakerQty > 0 {
+			b.Cancel(st.TakerID)
+		}
+		if st.MakerQty > 0 {
+			b.Cancel(st.MakerID)
+		}
+	}
+	if onSTP != nil {
+		onSTP(st)
+	}
+	return true
+}
+
+// fill：从挂单上扣掉 qty，扣完先给冰山单补片（返回补上的数量），否则摘链；桶空了删桶（heap 懒删除）
+func (b *LevelOrderBookHeap) fill(n *lvNodeHeap, qty int64) (refill int64) {
+	lv := n.lv
+	n.order.Qty -= qty
+	lv.qty -= qty
+	b.touch(n.side, lv.price)
+	if n.order.Qty > 0 {
+		return 0
+	}
+	if refill = b.replenish(n); refill > 0 {
+		return refill
+	}
+	lv.remove(n)
+	b.unindex(n.order)
+	side := n.side
+	b.putNode(n)
+	if lv.empty() {
+		b.dropLevel(side, lv.price)
+	}
+	return 0
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,243 @@
+package matching
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 买：102x5(1) 101x3(2)；卖：99x4(3) 100x4(4)
+// 100/101 成交量都是 8、不平衡都是 0，靠参考价决定
+func auctionBook() *LevelOrderBookHeap {
+	b := NewLevelOrderHeapBook()
+	b.SetAuction(true)
+	for _, o := range []*Order{
+		{ID: 1, Side: Buy, Price: 102, Qty: 5},
+		{ID: 2, Side: Buy, Price: 101, Qty: 3},
+		{ID: 3, Side: Sell, Price: 99, Qty: 4},
+		{ID: 4, Side: Sell, Price: 100, Qty: 4},
+	} {
+		if rest := b.MatchEmit(o, false, func(Trade) { panic("matched in auction") }, nil); rest != o.Qty {
+			panic("auction should not match")
+		}
+		b.Add(o)
+	}
+	return b
+}
+
+func TestAuction_Price(t *testing.T) {
+	b := auctionBook()
+	if p, v, ok := b.AuctionPrice(0); !ok || p != 100 || v != 8 {
+		t.Fatalf("no ref: p=%d v=%d ok=%v", p, v, ok)
+	}
+	if p, _, _ := b.AuctionPrice(105); p != 101 {
+		t.Fatalf("ref=105: p=%d, want 101", p)
+	}
+
+	// 市场压力：只剩买方多 → 取最高价
+	b2 := NewLevelOrderHeapBook()
+	b2.SetAuction(true)
+	b2.Add(&Order{ID: 1, Side: Buy, Price: 101, Qty: 5})
+	b2.Add(&Order{ID: 2, Side: Sell, Price: 100, Qty: 2})
+	if p, v, ok := b2.AuctionPrice(0); !ok || p != 101 || v != 2 {
+		t.Fatalf("buy pressure: p=%d v=%d ok=%v", p, v, ok)
+	}
+
+	// 不交叉：没有竞价价格
+	b3 := NewLevelOrderHeapBook()
+	b3.Add(&Order{ID: 1, Side: Buy, Price: 99, Qty: 1})
+	b3.Add(&Order{ID: 2, Side: Sell, Price: 100, Qty: 1})
+	if _, _, ok := b3.AuctionPrice(0); ok {
+		t.Fatal("uncrossed book should have no auction price")
+	}
+}
+
+func TestAuction_Uncross(t *testing.T) {
+	b := auctionBook()
+	var trades []Trade
+	p, v := b.Uncross(0, func(tr Trade) { trades = append(trades, tr) }, nil)
+	if p != 100 || v != 8 || b.InAuction() {
+		t.Fatalf("p=%d v=%d auction=%v", p, v, b.InAuction())
+	}
+	want := []Trade{
+		{TakerID: 1, MakerID: 3, Price: 100, Qty: 4},
+		{TakerID: 1, MakerID: 4, Price: 100, Qty: 1},
+		{TakerID: 2, MakerID: 4, Price: 100, Qty: 3},
+	}
+	if len(trades) != len(want) {
+		t.Fatalf("trades=%+v", trades)
+	}
+	for i := range want {
+		if trades[i] != want[i] {
+			t.Fatalf("trade[%d]=%+v, want %+v", i, trades[i], want[i])
+		}
+	}
+	if _, ok := b.BestBid(); ok {
+		t.Fatal("bids should be empty")
+	}
+	if _, ok := b.BestAsk(); ok {
+		t.Fatal("asks should be empty")
+	}
+	if _, ok := b.Order(4); ok {
+		t.Fatal("filled order should be gone")
+	}
+}
+
+// 竞价 STP：同一用户的买卖单不互相成交，后进簿的按 taker（newest）处理
+func TestAuction_UncrossSTP(t *testing.T) {
+	build := func(mode STPMode) *LevelOrderBookHeap {
+		b := NewLevelOrderHeapBook()
+		b.SetSTP(mode)
+		b.SetAuction(true)
+		b.Add(&Order{ID: 1, Side: Sell, Price: 100, Qty: 3, UserID: 7})
+		b.Add(&Order{ID: 2, Side: Buy, Price: 101, Qty: 5, UserID: 7})
+		b.Add(&Order{ID: 3, Side: Sell, Price: 100, Qty: 4, UserID: 8})
+		return b
+	}
+	cases := []struct {
+		mode   STPMode
+		st     SelfTrade
+		trades []Trade
+		left   []uint64 // 竞价后还挂着的单
+	}{
+		{STPCancelNewest, SelfTrade{Mode: STPCancelNewest, TakerID: 2, MakerID: 1, UserID: 7, TakerQty: 5}, nil, []uint64{1, 3}},
+		{STPCancelOldest, SelfTrade{Mode: STPCancelOldest, TakerID: 2, MakerID: 1, UserID: 7, MakerQty: 3},
+			[]Trade{{TakerID: 2, MakerID: 3, TakerUserID: 7, MakerUserID: 8, Price: 100, Qty: 4}}, []uint64{2}},
+		{STPCancelBoth, SelfTrade{Mode: STPCancelBoth, TakerID: 2, MakerID: 1, UserID: 7, TakerQty: 5, MakerQty: 3}, nil, []uint64{3}},
+		{STPDecrement, SelfTrade{Mode: STPDecrement, TakerID: 2, MakerID: 1, UserID: 7, TakerQty: 3, MakerQty: 3},
+			[]Trade{{TakerID: 2, MakerID: 3, TakerUserID: 7, MakerUserID: 8, Price: 100, Qty: 2}}, []uint64{3}},
+	}
+	for _, c := range cases {
+		b := build(c.mode)
+		var trades []Trade
+		var sts []SelfTrade
+		b.Uncross(0, func(tr Trade) { trades = append(trades, tr) }, func(st SelfTrade) { sts = append(sts, st) })
+		if len(sts) != 1 || sts[0] != c.st {
+			t.Fatalf("%v: stp=%+v, want %+v", c.mode, sts, c.st)
+		}
+		if len(trades) != len(c.trades) {
+			t.Fatalf("%v: trades=%+v, want %+v", c.mode, trades, c.trades)
+		}
+		for i := range c.trades {
+			if trades[i] != c.trades[i] {
+				t.Fatalf("%v: trade[%d]=%+v, want %+v", c.mode, i, trades[i], c.trades[i])
+			}
+		}
+		for id := uint64(1); id <= 3; id++ {
+			_, ok := b.Order(id)
+			want := false
+			for _, l := range c.left {
+				want = want || l == id
+			}
+			if ok != want {
+				t.Fatalf("%v: order %d resting=%v, want %v", c.mode, id, ok, want)
+			}
+		}
+	}
+}
+
+// 新旧按进簿顺序（入队序号），不按订单号：订单号大的卖单先进簿，后进簿的买单才是 newest
+func TestAuction_STPUsesAcceptanceOrder(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.SetSTP(STPCancelNewest)
+	b.SetAuction(true)
+	b.Add(&Order{ID: 9, Side: Sell, Price: 100, Qty: 3, UserID: 7})
+	b.Add(&Order{ID: 2, Side: Buy, Price: 101, Qty: 5, UserID: 7})
+	var sts []SelfTrade
+	b.Uncross(0, nil, func(st SelfTrade) { sts = append(sts, st) })
+	want := SelfTrade{Mode: STPCancelNewest, TakerID: 2, MakerID: 9, UserID: 7, TakerQty: 5}
+	if len(sts) != 1 || sts[0] != want {
+		t.Fatalf("stp=%+v, want %+v", sts, want)
+	}
+	if _, ok := b.Order(9); !ok {
+		t.Fatal("older order should stay")
+	}
+}
+
+// AuctionPrice 单次遍历与逐价位重新求和的结果一致
+func TestAuction_PriceMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for round := 0; round < 200; round++ {
+		b := NewLevelOrderHeapBook()
+		b.SetAuction(true)
+		for id := uint64(1); id <= uint64(5+r.Intn(40)); id++ {
+			side := uint8(Buy)
+			if r.Intn(2) == 0 {
+				side = Sell
+			}
+			b.Add(&Order{ID: id, Side: side, Price: int64(95 + r.Intn(10)), Qty: int64(1 + r.Intn(9))})
+		}
+		ref := int64(r.Intn(3)) * 100
+		p, v, ok := b.AuctionPrice(ref)
+		bp, bv, bok := bruteAuctionPrice(b, ref)
+		if p != bp || v != bv || ok != bok {
+			t.Fatalf("round %d ref=%d: got (%d,%d,%v), want (%d,%d,%v)", round, ref, p, v, ok, bp, bv, bok)
+		}
+	}
+}
+
+// bruteAuctionPrice：按同样的规则逐个候选价重新求和（参照实现）
+func bruteAuctionPrice(b *LevelOrderBookHeap, ref int64) (int64, int64, bool) {
+	bidP, okB := b.bestBidPrice()
+	askP, okA := b.bestAskPrice()
+	if !okB || !okA || bidP < askP {
+		return 0, 0, false
+	}
+	type point struct{ price, vol, imb int64 }
+	var pts []point
+	for p := askP; p <= bidP; p++ {
+		if b.asks[p] == nil && b.bids[p] == nil {
+			continue
+		}
+		var demand, supply int64
+		for bp, lv := range b.bids {
+			if bp >= p {
+				demand += lv.qty + lv.rsv
+			}
+		}
+		for ap, lv := range b.asks {
+			if ap <= p {
+				supply += lv.qty + lv.rsv
+			}
+		}
+		pts = append(pts, point{p, min64(demand, supply), demand - supply})
+	}
+	var best int64
+	for _, pt := range pts {
+		best = max(best, pt.vol)
+	}
+	if best == 0 {
+		return 0, 0, false
+	}
+	minImb := int64(-1)
+	for _, pt := range pts {
+		if pt.vol == best && (minImb < 0 || abs64(pt.imb) < minImb) {
+			minImb = abs64(pt.imb)
+		}
+	}
+	var keep []point
+	allBuy, allSell := true, true
+	for _, pt := range pts {
+		if pt.vol == best && abs64(pt.imb) == minImb {
+			keep = append(keep, pt)
+			allBuy = allBuy && pt.imb > 0
+			allSell = allSell && pt.imb < 0
+		}
+	}
+	switch {
+	case len(keep) == 1:
+		return keep[0].price, best, true
+	case allBuy:
+		return keep[len(keep)-1].price, best, true
+	case allSell:
+		return keep[0].price, best, true
+	case ref <= 0:
+		return keep[(len(keep)-1)/2].price, best, true
+	}
+	pick := keep[0].price
+	for _, pt := range keep[1:] {
+		if abs64(pt.price-ref) < abs64(pick-ref) {
+			pick = pt.price
+		}
+	}
+	return pick, best, true
+}
@@ -12,6 +12,7 @@ type NaiveOrderBook struct {
 	bids []*Order // 买方
 	asks []*Order // 卖方
 	pos  map[uint64]orderPos
+	seq  uint64 // 最近分配的入队序号（见 Order.Seq）
 }
 
 func NewNaiveOrderBook() *NaiveOrderBook {
@@ -46,6 +47,7 @@ func (b *NaiveOrderBook) Add(order *Order) error {
 	if order == nil || order.Qty <= 0 {
 		return fmt.Errorf("invalid order %v", order)
 	}
+	stampSeq(&b.seq, order)
 	if order.Side == Buy {
 		return b.insertBid(order)
 	}
@@ -187,3 +189,43 @@ func removeAtOrderPtr(s []*Order, i int) []*Order {
 	s[len(s)-1] = nil    // 把最后一个元素置空，避免内存/引用泄漏
 	return s[:len(s)-1]  // 切掉最后一个（长度-1）
 }
+
+// Amend：改价/改量，语义同 LevelOrderBook.Amend（线性查找，对照实现够用）
+func (b *NaiveOrderBook) Amend(orderID uint64, price, qty int64) (requeued, ok bool) {
+	o := b.find(orderID)
+	if o == nil || price <= 0 || qty <= 0 {
+		return false, false
+	}
+	if price == o.Price && qty <= o.Qty {
+		o.Qty = qty
+		return false, true
+	}
+	b.Cancel(orderID)
+	o.Price, o.Qty, o.Seq = price, qty, 0
+	_ = b.Add(o)
+	return true, true
+}
+
+func (b *NaiveOrderBook) find(orderID uint64) *Order {
+	for _, o := range b.bids {
+		if o != nil && o.ID == orderID {
+			return o
+		}
+	}
+	for _, o := range b.asks {
+		if o != nil && o.ID == orderID {
+			return o
+		}
+	}
+	return nil
+}
+
+// RangeOrders：顺序同 LevelOrderBookHeap.RangeOrders（卖盘价格升序，买盘价格降序，同价 FIFO）
+func (b *NaiveOrderBook) RangeOrders(fn func(o Order)) {
+	for _, o := range b.asks {
+		fn(*o)
+	}
+	for _, o := range b.bids {
+		fn(*o)
+	}
+}
@@ -123,3 +123,71 @@ func BenchmarkBestAdvance_ByCancel_LevelHeap(b *testing.B) {
 		}
 	}
 }
+
+// ---------- skiplist 价位索引：同样两组，对照上面的 scan / heap ----------
+
+func BenchmarkBestAdvance_ByMatch_LevelSkipList(b *testing.B) {
+	const levels = 4096
+
+	book := NewLevelOrderSkipListBook()
+	seedAsksLevelHeap(book, levels)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = book.SubmitLimit(&Order{
+			ID:    uint64(1_000_000 + i),
+			Side:  Buy,
+			Price: 1 << 60,
+			Qty:   1,
+		})
+
+		if _, ok := book.BestAsk(); !ok {
+			b.StopTimer()
+			book = NewLevelOrderSkipListBook()
+			seedAsksLevelHeap(book, levels)
+			b.StartTimer()
+		}
+	}
+}
+
+func BenchmarkBestAdvance_ByCancel_LevelSkipList(b *testing.B) {
+	const levels = 4096
+
+	book := NewLevelOrderSkipListBook()
+	seedAsksLevelHeap(book, levels)
+
+	var id uint64 = 1
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = book.Cancel(id)
+		_, _ = book.BestAsk() // 撤空时已出索引，这里 O(1)
+
+		id++
+		if id > levels {
+			b.StopTimer()
+			book = NewLevelOrderSkipListBook()
+			seedAsksLevelHeap(book, levels)
+			id = 1
+			b.StartTimer()
+		}
+	}
+}
+
+// ---------- Bench: 前 20 档深度（heap 要收集全部价位再排序，skiplist 按索引顺序取前 n 个） ----------
+
+func benchDepthTop(b *testing.B, book *LevelOrderBookHeap) {
+	const levels = 4096
+	seedAsksLevelHeap(book, levels)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if d := book.Depth(Sell, 20); len(d) != 20 {
+			b.Fatalf("depth=%d", len(d))
+		}
+	}
+}
+
+func BenchmarkDepthTop20_LevelHeap(b *testing.B)     { benchDepthTop(b, NewLevelOrderHeapBook()) }
+func BenchmarkDepthTop20_LevelSkipList(b *testing.B) { benchDepthTop(b, NewLevelOrderSkipListBook()) }
@@ -0,0 +1,57 @@
+package matching
+
+import "sort"
+
+// 深度增量跟踪：只记“哪些价位变了”，数量在取增量时从价位桶现读
+// 撮合热路径上只多一次 map 写（且只在开启跟踪时）
+
+type depthKey struct {
+	side  uint8
+	price int64
+}
+
+// TrackDepth：开启深度增量跟踪（之前的变化不计）
+func (b *LevelOrderBookHeap) TrackDepth() {
+	if b.dirty == nil {
+		b.dirty = make(map[depthKey]struct{}, 64)
+	}
+}
+
+func (b *LevelOrderBookHeap) touch(side uint8, price int64) {
+	if b.dirty != nil {
+		b.dirty[depthKey{side: side, price: price}] = struct{}{}
+	}
+}
+
+// DepthChanges：输出自上次调用以来变过的价位及其当前可见总量（0 表示价位已空），并清空
+// 顺序固定：买盘价格降序在前，卖盘价格升序在后
+func (b *LevelOrderBookHeap) DepthChanges(fn func(side uint8, price, qty int64)) {
+	if len(b.dirty) == 0 {
+		return
+	}
+	keys := make([]depthKey, 0, len(b.dirty))
+	for k := range b.dirty {
+		keys = append(keys, k)
+	}
+	clear(b.dirty)
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].side != keys[j].side {
+			return keys[i].side == Buy
+		}
+		if keys[i].side == Buy {
+			return keys[i].price > keys[j].price
+		}
+		return keys[i].price < keys[j].price
+	})
+	for _, k := range keys {
+		var qty int64
+		levels := b.asks
+		if k.side == Buy {
+			levels = b.bids
+		}
+		if lv := levels[k.price]; lv != nil {
+			qty = lv.qty
+		}
+		fn(k.side, k.price, qty)
+	}
+}
@@ -0,0 +1,48 @@
+package matching
+
+import "testing"
+
+type depthChange struct {
+	side       uint8
+	price, qty int64
+}
+
+func drain(b *LevelOrderBookHeap) []depthChange {
+	var out []depthChange
+	b.DepthChanges(func(side uint8, price, qty int64) {
+		out = append(out, depthChange{side, price, qty})
+	})
+	return out
+}
+
+func TestDepth_Changes(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.Add(&Order{ID: 1, Side: Sell, Price: 101, Qty: 1}) // 开启跟踪之前：不计
+	b.TrackDepth()
+	b.Add(&Order{ID: 2, Side: Sell, Price: 100, Qty: 2})
+	b.Add(&Order{ID: 3, Side: Buy, Price: 98, Qty: 4})
+	b.Add(&Order{ID: 4, Side: Buy, Price: 99, Qty: 1})
+
+	want := []depthChange{{Buy, 99, 1}, {Buy, 98, 4}, {Sell, 100, 2}}
+	got := drain(b)
+	if len(got) != len(want) {
+		t.Fatalf("changes=%+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("change[%d]=%+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := drain(b); len(got) != 0 {
+		t.Fatalf("drained twice: %+v", got)
+	}
+
+	// 吃光 100、部分吃 101；撤 98
+	b.MatchEmit(&Order{ID: 9, Side: Buy, Price: 101, Qty: 2}, false, nil, nil)
+	b.Cancel(3)
+	got = drain(b)
+	want = []depthChange{{Buy, 98, 0}, {Sell, 100, 0}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("changes=%+v, want %+v", got, want)
+	}
+}
@@ -1,5 +1,6 @@
 package matching
 
+// minPriceHeap：价格小顶堆（买盘存负价，见 price_index.go）
 type minPriceHeap []int64
 
 func (m minPriceHeap) Len() int {
@@ -25,29 +26,3 @@ func (m *minPriceHeap) Pop() any {
 	*m = old[:n-1]
 	return x
 }
-
-type maxPriceHeap []int64
-
-func (m maxPriceHeap) Len() int {
-	return len(m)
-}
-
-func (m maxPriceHeap) Less(i, j int) bool {
-	return m[i] > m[j]
-}
-
-func (m maxPriceHeap) Swap(i, j int) {
-	m[i], m[j] = m[j], m[i]
-}
-
-func (m *maxPriceHeap) Push(x any) {
-	*m = append(*m, x.(int64))
-}
-
-func (m *maxPriceHeap) Pop() any {
-	old := *m
-	n := len(old)
-	x := old[n-1]
-	*m = old[:n-1]
-	return x
-}
@@ -0,0 +1,65 @@
+package matching
+
+import "testing"
+
+func TestIceberg_ReplenishRequeuesAndDepth(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.Add(&Order{ID: 1, Side: Sell, Price: 100, Qty: 10, Display: 3})
+	b.Add(&Order{ID: 2, Side: Sell, Price: 100, Qty: 2})
+
+	if d := b.Depth(Sell, 5); len(d) != 1 || d[0] != (PriceLevel{Price: 100, Qty: 5}) {
+		t.Fatalf("depth=%+v, want only displayed qty", d)
+	}
+	if !b.CanFill(0, Buy, 100, 12, false) || b.CanFill(0, Buy, 100, 13, false) {
+		t.Fatal("CanFill should count hidden qty")
+	}
+
+	var trades []Trade
+	emit := func(tr Trade) { trades = append(trades, tr) }
+
+	// 吃掉 1 的可见 3 张 → 补 3 张排到 2 后面 → 再吃 2 的 1 张
+	b.MatchEmit(&Order{ID: 10, Side: Buy, Price: 100, Qty: 4}, false, emit, nil)
+	want := []Trade{
+		{TakerID: 10, MakerID: 1, Price: 100, Qty: 3, Refill: 3},
+		{TakerID: 10, MakerID: 2, Price: 100, Qty: 1},
+	}
+	for i := range want {
+		if trades[i] != want[i] {
+			t.Fatalf("trade[%d]=%+v, want %+v", i, trades[i], want[i])
+		}
+	}
+	if o, _ := b.Order(1); o.Qty != 3 || o.Reserve != 4 {
+		t.Fatalf("iceberg after refill: %+v", o)
+	}
+	if d := b.Depth(Sell, 0); d[0].Qty != 4 {
+		t.Fatalf("depth=%+v", d)
+	}
+
+	// 扫完：2 剩 1 → 1 的 3(补3) → 3(补1) → 1(完结)
+	trades = trades[:0]
+	rest := b.MatchEmit(&Order{ID: 11, Side: Buy, Price: 100, Qty: 10}, false, emit, nil)
+	if rest != 2 || len(trades) != 4 || trades[1].Refill != 3 || trades[2].Refill != 1 || trades[3].Refill != 0 {
+		t.Fatalf("rest=%d trades=%+v", rest, trades)
+	}
+	if _, ok := b.Order(1); ok {
+		t.Fatal("iceberg should be fully filled")
+	}
+}
+
+func TestIceberg_AmendReducesReserveFirst(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.Add(&Order{ID: 1, Side: Buy, Price: 100, Qty: 10, Display: 4})
+	if requeued, ok := b.Amend(1, 100, 5); !ok || requeued {
+		t.Fatalf("amend requeued=%v ok=%v", requeued, ok)
+	}
+	if o, _ := b.Order(1); o.Qty != 4 || o.Reserve != 1 {
+		t.Fatalf("after reduce: %+v", o)
+	}
+	b.Amend(1, 100, 2)
+	if o, _ := b.Order(1); o.Qty != 2 || o.Reserve != 0 {
+		t.Fatalf("after reduce into display: %+v", o)
+	}
+	if d := b.Depth(Buy, 1); d[0].Qty != 2 {
+		t.Fatalf("depth=%+v", d)
+	}
+}
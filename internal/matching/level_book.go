@@ -1,5 +1,7 @@
 package matching
 
+import "sort"
+
 type priceLevel struct {
 	price int64   // 价格
 	head  *lvNode //头部指针
@@ -65,6 +67,7 @@ type LevelOrderBook struct {
 	bestBid int64                 // 最新买价
 	hasAsk  bool                  //是否存在
 	hasBid  bool                  // 有没有对应盘（避免 0 值歧义） 这个不懂
+	seq     uint64                // 最近分配的入队序号（见 Order.Seq）
 }
 
 func NewLevelOrderBook() *LevelOrderBook {
@@ -83,6 +86,7 @@ func (b *LevelOrderBook) Add(order *Order) {
 	if _, exists := b.byID[order.ID]; exists {
 		return
 	}
+	stampSeq(&b.seq, order)
 	if order.Side == Sell {
 		// 找出卖价格的桶
 		lv := b.asks[order.Price]
@@ -132,6 +136,7 @@ func (b *LevelOrderBook) Cancel(orderID uint64) bool {
 	// 1) 从对应价位桶摘链
 	lv := n.lv
 	lv.remove(n)
+	delete(b.byID, orderID)
 
 	// 2) 删除索引
 	if lv.empty() {
@@ -155,6 +160,50 @@ func (b *LevelOrderBook) Cancel(orderID uint64) bool {
 	return true
 }
 
+// Amend：改价/改量，语义同 LevelOrderBookHeap.Amend
+// - 同价减量：原地改数量，保留 FIFO 位置
+// - 改价或加量：撤掉后重新 Add 到新价位队尾（best 由 Cancel/Add 维护）
+func (b *LevelOrderBook) Amend(orderID uint64, price, qty int64) (requeued, ok bool) {
+	n := b.byID[orderID]
+	if n == nil || price <= 0 || qty <= 0 {
+		return false, false
+	}
+	o := n.order
+	if price == o.Price && qty <= o.Qty {
+		o.Qty = qty
+		return false, true
+	}
+	b.Cancel(orderID)
+	o.Price, o.Qty, o.Seq = price, qty, 0
+	b.Add(o)
+	return true, true
+}
+
+// RangeOrders：顺序同 LevelOrderBookHeap.RangeOrders（卖盘价格升序，买盘价格降序，同价 FIFO）
+func (b *LevelOrderBook) RangeOrders(fn func(o Order)) {
+	askPs := make([]int64, 0, len(b.asks))
+	for p := range b.asks {
+		askPs = append(askPs, p)
+	}
+	sort.Slice(askPs, func(i, j int) bool { return askPs[i] < askPs[j] })
+	for _, p := range askPs {
+		for n := b.asks[p].head; n != nil; n = n.next {
+			fn(*n.order)
+		}
+	}
+
+	bidPs := make([]int64, 0, len(b.bids))
+	for p := range b.bids {
+		bidPs = append(bidPs, p)
+	}
+	sort.Slice(bidPs, func(i, j int) bool { return bidPs[i] > bidPs[j] })
+	for _, p := range bidPs {
+		for n := b.bids[p].head; n != nil; n = n.next {
+			fn(*n.order)
+		}
+	}
+}
+
 // BestAsk 返回当前最优卖价（最低价）
 func (b *LevelOrderBook) BestAsk() (price int64, ok bool) {
 	if !b.hasAsk {
@@ -256,10 +305,12 @@ func (b *LevelOrderBook) matchBuy(taker *Order) []Trade {
 			// 获取最小吃多少笔
 			exec := min64(taker.Qty, maker.Qty)
 			trade = append(trade, Trade{
-				TakerID: taker.ID,
-				MakerID: maker.ID,
-				Price:   lv.price,
-				Qty:     exec,
+				TakerID:     taker.ID,
+				MakerID:     maker.ID,
+				MakerUserID: maker.UserID,
+				TakerUserID: taker.UserID,
+				Price:       lv.price,
+				Qty:         exec,
 			})
 			// 两边都减去数量
 			taker.Qty -= exec
@@ -308,10 +359,12 @@ func (b *LevelOrderBook) matchSell(taker *Order) []Trade {
 
 			exec := min64(taker.Qty, maker.Qty)
 			trades = append(trades, Trade{
-				TakerID: taker.ID,
-				MakerID: maker.ID,
-				Price:   lv.price,
-				Qty:     exec,
+				TakerID:     taker.ID,
+				MakerID:     maker.ID,
+				MakerUserID: maker.UserID,
+				TakerUserID: taker.UserID,
+				Price:       lv.price,
+				Qty:         exec,
 			})
 
 			taker.Qty -= exec
@@ -86,3 +86,74 @@ func TestMatchLeavesRestingOrder(t *testing.T) {
 	}
 
 }
+
+func TestLevelBook_AmendPriority(t *testing.T) {
+	b := NewLevelOrderBook()
+	b.Add(&Order{ID: 1, Side: Sell, Price: 100, Qty: 5})
+	b.Add(&Order{ID: 2, Side: Sell, Price: 100, Qty: 5})
+
+	// 同价减量：保留队首位置
+	if requeued, ok := b.Amend(1, 100, 2); !ok || requeued {
+		t.Fatalf("reduce: requeued=%v ok=%v", requeued, ok)
+	}
+	tr := b.SubmitLimit(&Order{ID: 10, Side: Buy, Price: 100, Qty: 1})
+	if len(tr) != 1 || tr[0].MakerID != 1 {
+		t.Fatalf("reduced order should keep priority, got %+v", tr)
+	}
+
+	// 加量：重新排到队尾
+	if requeued, ok := b.Amend(1, 100, 3); !ok || !requeued {
+		t.Fatalf("increase: requeued=%v ok=%v", requeued, ok)
+	}
+	tr = b.SubmitLimit(&Order{ID: 11, Side: Buy, Price: 100, Qty: 1})
+	if len(tr) != 1 || tr[0].MakerID != 2 {
+		t.Fatalf("increased order should lose priority, got %+v", tr)
+	}
+
+	// 改价：best 跟着变
+	if _, ok := b.Amend(2, 99, 4); !ok {
+		t.Fatalf("amend price failed")
+	}
+	if p, ok := b.BestAsk(); !ok || p != 99 {
+		t.Fatalf("best ask expected 99, got %v %v", p, ok)
+	}
+	if _, ok := b.Amend(404, 99, 1); ok {
+		t.Fatalf("amend unknown order should fail")
+	}
+}
+
+func TestHeapBook_AmendPriority(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.Add(&Order{ID: 1, Side: Buy, Price: 100, Qty: 5})
+	b.Add(&Order{ID: 2, Side: Buy, Price: 100, Qty: 5})
+
+	if requeued, ok := b.Amend(1, 100, 2); !ok || requeued {
+		t.Fatalf("reduce: requeued=%v ok=%v", requeued, ok)
+	}
+	// 桶内总量同步减少：FOK 预检查依赖它
+	if b.CanFill(0, Sell, 100, 8, false) || !b.CanFill(0, Sell, 100, 7, false) {
+		t.Fatalf("level qty not updated after reduce")
+	}
+
+	if requeued, ok := b.Amend(1, 101, 2); !ok || !requeued {
+		t.Fatalf("reprice: requeued=%v ok=%v", requeued, ok)
+	}
+	if p, ok := b.BestBid(); !ok || p != 101 {
+		t.Fatalf("best bid expected 101, got %v %v", p, ok)
+	}
+	if o, ok := b.Order(1); !ok || o.Price != 101 || o.Qty != 2 {
+		t.Fatalf("order after amend: %+v %v", o, ok)
+	}
+}
+
+// 撤空的价位要从对应一侧删掉（曾经按清空后的 node.side 删错边，卖盘留下空桶）
+func TestHeapBook_CancelDropsEmptyLevel(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.Add(&Order{ID: 1, Side: Sell, Price: 101, Qty: 1})
+	b.Add(&Order{ID: 2, Side: Buy, Price: 99, Qty: 1})
+	b.Cancel(1)
+	b.Cancel(2)
+	if len(b.asks) != 0 || len(b.bids) != 0 {
+		t.Fatalf("empty levels left: asks=%d bids=%d", len(b.asks), len(b.bids))
+	}
+}
@@ -1,7 +1,7 @@
 package matching
 
 import (
-	"container/heap"
+	"math"
 	"sync"
 )
 
@@ -10,6 +10,8 @@ type priceLevelHeap struct {
 	head  *lvNodeHeap //头部指针
 	tail  *lvNodeHeap // 尾部指针
 	size  int64       // 桶的大小
+	qty   int64       // 桶内可见总量（深度用）
+	rsv   int64       // 桶内冰山单隐藏总量（qty+rsv 才是可成交量）
 }
 
 // 实现一个双向链表
@@ -42,6 +44,8 @@ func (l *priceLevelHeap) pushBack(n *lvNodeHeap) {
 	}
 	l.tail = n
 	l.size++
+	l.qty += n.order.Qty
+	l.rsv += n.order.Reserve
 }
 
 // 删除节点
@@ -63,6 +67,8 @@ func (l *priceLevelHeap) remove(n *lvNodeHeap) {
 	// 断开节点指针，避免误用
 	n.prev, n.next = nil, nil
 	l.size--
+	l.qty -= n.order.Qty
+	l.rsv -= n.order.Reserve
 }
 func (l *priceLevelHeap) empty() bool {
 	return l.size == 0
@@ -73,23 +79,47 @@ type LevelOrderBookHeap struct {
 	asks map[int64]*priceLevelHeap // 卖盘：price -> level
 	bids map[int64]*priceLevelHeap // 买盘：price -> level
 	byID map[uint64]*lvNodeHeap    // 订单索引：orderID -> node（撤单 O(1)）
-	askH minPriceHeap              // 最新卖价
-	bidH maxPriceHeap              // 最新买价
+	askX priceIndex                // 卖盘价位索引（见 price_index.go）
+	bidX priceIndex                // 买盘价位索引
+	stp  STPMode                   // 自成交防护模式（默认不开启）
+	seq  uint64                    // 最近分配的入队序号（见 Order.Seq）
+	// 集合竞价收单中：只挂单不撮合（见 auction.go）
+	auction bool
+	// 深度增量：自上次 DepthChanges 以来可见数量变过的价位；nil 表示不跟踪（见 depth.go）
+	dirty map[depthKey]struct{}
+
+	// 用户索引：userID -> 该用户的挂单（查询/按用户撤单用，见 query.go）
+	byUser map[uint64]map[uint64]*lvNodeHeap
 	//hasAsk bool                      //是否存在
 	//hasBid bool                      // 有没有对应盘（避免 0 值歧义）
 }
 
 func NewLevelOrderHeapBook() *LevelOrderBookHeap {
-	l := &LevelOrderBookHeap{
+	l := newLevelBook()
+	// 构建两个怼
+	l.askX = newHeapIndex(Sell, l.asks)
+	l.bidX = newHeapIndex(Buy, l.bids)
+	return l
+
+}
+
+// NewLevelOrderSkipListBook：同 NewLevelOrderHeapBook，价位索引换成跳表
+// 撤空的价位立即出索引，best 价不用弹过期项；深度/快照按索引顺序遍历，不用排序
+func NewLevelOrderSkipListBook() *LevelOrderBookHeap {
+	l := newLevelBook()
+	l.askX = newSkipIndex(Sell)
+	l.bidX = newSkipIndex(Buy)
+	return l
+}
+
+func newLevelBook() *LevelOrderBookHeap {
+	return &LevelOrderBookHeap{
 		asks: make(map[int64]*priceLevelHeap, 1024),
 		bids: make(map[int64]*priceLevelHeap, 1024),
 		byID: make(map[uint64]*lvNodeHeap, 1024),
-	}
-	// 构建两个怼
-	heap.Init(&l.askH)
-	heap.Init(&l.bidH)
-	return l
 
+		byUser: make(map[uint64]map[uint64]*lvNodeHeap, 256),
+	}
 }
 
 func (b *LevelOrderBookHeap) Add(order *Order) {
@@ -100,19 +130,26 @@ func (b *LevelOrderBookHeap) Add(order *Order) {
 	if _, exists := b.byID[order.ID]; exists {
 		return
 	}
+	stampSeq(&b.seq, order)
+	// 冰山单：超出一片的部分转入隐藏量
+	if order.Display > 0 && order.Qty > order.Display {
+		order.Reserve += order.Qty - order.Display
+		order.Qty = order.Display
+	}
 	if order.Side == Sell {
 		// 找出卖价格的桶
 		lv := b.asks[order.Price]
 		if lv == nil {
 			lv = &priceLevelHeap{price: order.Price}
 			b.asks[order.Price] = lv
-			heap.Push(&b.askH, order.Price) // 新价位出现：入堆
+			b.askX.insert(order.Price) // 新价位出现：入索引
 		}
 		// 2) 追加到 FIFO 队尾（同价时间优先）
 		n := b.getNode(order, lv, Sell)
 		lv.pushBack(n)
+		b.touch(Sell, order.Price)
 		// 3) 建立 orderID -> node 索引，供 O(1) 撤单使用
-		b.byID[order.ID] = n
+		b.index(n)
 
 		return
 	}
@@ -121,12 +158,13 @@ func (b *LevelOrderBookHeap) Add(order *Order) {
 		if lv == nil {
 			lv = &priceLevelHeap{price: order.Price}
 			b.bids[order.Price] = lv
-			heap.Push(&b.bidH, order.Price) // 新价位出现：入堆
+			b.bidX.insert(order.Price) // 新价位出现：入索引
 
 		}
 		n := b.getNode(order, lv, Buy)
 		lv.pushBack(n)
-		b.byID[order.ID] = n
+		b.touch(Buy, order.Price)
+		b.index(n)
 
 	}
 }
@@ -142,22 +180,90 @@ func (b *LevelOrderBookHeap) Cancel(orderID uint64) bool {
 	}
 
 	// 1) 从对应价位桶摘链
-	lv := n.lv
+	lv, side := n.lv, n.side // putNode 会清空 node，先取出来
 	lv.remove(n)
+	b.touch(side, lv.price)
 
-	delete(b.byID, orderID)
+	b.unindex(n.order)
 	b.putNode(n) // 放回池
 	// 2) 删除索引
 	if lv.empty() {
-		if n.side == Sell {
-			delete(b.asks, lv.price)
-		} else {
-			delete(b.bids, lv.price)
-		}
+		b.dropLevel(side, lv.price)
 	}
 	return true
 }
 
+// Order：按 orderID 查挂单（返回副本，调用方改了不影响簿）
+func (b *LevelOrderBookHeap) Order(orderID uint64) (Order, bool) {
+	n := b.byID[orderID]
+	if n == nil {
+		return Order{}, false
+	}
+	return *n.order, true
+}
+
+// Amend：改价/改量（只改簿，不撮合；新价格会不会穿价由调用方先判断）
+// - qty 是改后的剩余总量（冰山单含隐藏部分）
+// - 同价减量：原地改数量，保留 FIFO 位置（冰山单先减隐藏部分）
+// - 改价或加量：摘链后排到新价位队尾（失去时间优先）
+// 订单不存在或参数非法返回 ok=false
+func (b *LevelOrderBookHeap) Amend(orderID uint64, price, qty int64) (requeued, ok bool) {
+	n := b.byID[orderID]
+	if n == nil || price <= 0 || qty <= 0 {
+		return false, false
+	}
+	o := n.order
+	if price == o.Price && qty <= o.Qty+o.Reserve {
+		cut := o.Qty + o.Reserve - qty
+		fromReserve := min64(cut, o.Reserve)
+		o.Reserve -= fromReserve
+		n.lv.rsv -= fromReserve
+		o.Qty -= cut - fromReserve
+		n.lv.qty -= cut - fromReserve
+		b.touch(n.side, o.Price)
+		return false, true
+	}
+	// Cancel 只归还 node，order 指针仍然有效
+	b.Cancel(orderID)
+	o.Price, o.Qty, o.Reserve, o.Seq = price, qty, 0, 0
+	b.Add(o)
+	return true, true
+}
+
+// replenish：冰山单可见部分吃完后从隐藏量补一片，排到同价位队尾
+// 返回补上的可见数量；0 表示没有隐藏量了（调用方照常摘链）
+func (b *LevelOrderBookHeap) replenish(n *lvNodeHeap) int64 {
+	o := n.order
+	if o.Qty > 0 || o.Reserve <= 0 {
+		return 0
+	}
+	lv := n.lv
+	lv.remove(n)
+	slice := min64(o.Display, o.Reserve)
+	o.Qty, o.Reserve, o.Seq = slice, o.Reserve-slice, 0
+	stampSeq(&b.seq, o)
+	lv.pushBack(n)
+	return slice
+}
+
+// Depth：一侧前 n 档深度（买盘价格降序、卖盘升序），只含可见数量；n<=0 返回全部
+func (b *LevelOrderBookHeap) Depth(side uint8, n int) []PriceLevel {
+	levels, idx := b.asks, b.askX
+	if side == Buy {
+		levels, idx = b.bids, b.bidX
+	}
+	size := len(levels)
+	if n > 0 && n < size {
+		size = n
+	}
+	out := make([]PriceLevel, 0, size)
+	idx.rangeByPriority(func(p int64) bool {
+		out = append(out, PriceLevel{Price: p, Qty: levels[p].qty})
+		return n <= 0 || len(out) < n
+	})
+	return out
+}
+
 // BestAsk 返回当前最优卖价（最低价）
 func (b *LevelOrderBookHeap) BestAsk() (price int64, ok bool) {
 	return b.bestAskPrice()
@@ -168,6 +274,23 @@ func (b *LevelOrderBookHeap) BestBid() (price int64, ok bool) {
 	return b.bestBidPrice()
 }
 
+// RangeOrders：按价格优先、同价 FIFO 的顺序遍历所有挂单（快照用）
+// 卖盘价格升序，买盘价格降序；按此顺序 Add 回新簿即可还原队列优先级
+func (b *LevelOrderBookHeap) RangeOrders(fn func(o Order)) {
+	b.askX.rangeByPriority(func(p int64) bool {
+		for n := b.asks[p].head; n != nil; n = n.next {
+			fn(*n.order)
+		}
+		return true
+	})
+	b.bidX.rangeByPriority(func(p int64) bool {
+		for n := b.bids[p].head; n != nil; n = n.next {
+			fn(*n.order)
+		}
+		return true
+	})
+}
+
 func (b *LevelOrderBookHeap) SubmitLimitBuff(taker *Order, buf []Trade) []Trade {
 	buf = buf[:0]
 	b.MatchLimitEmit(taker, func(t Trade) {
@@ -183,125 +306,127 @@ func (b *LevelOrderBookHeap) SubmitLimit(order *Order) []Trade {
 
 }
 
-func (b *LevelOrderBookHeap) matchBuy(taker *Order, trades []Trade) []Trade {
-	// 获取bestAsk
-	for taker.Qty > 0 {
-		bestP, ok := b.bestAskPrice()
-		if !ok || bestP > taker.Price {
-			break
-		}
-
-		// 3) 拿到 bestAsk 的桶
-		lv := b.asks[bestP]
+// 基于事件
+// MatchLimitEmit：只撮合，不挂单（热路径：目标 0 alloc）
+// 返回剩余数量，由上层决定要不要挂单/撤销（IOC/FOK 都能支持）
 
-		// 开始吃单
-		for taker.Qty > 0 && !lv.empty() {
-			// 从头部开始吃单
-			mn := lv.head
-			maker := mn.order // 单的信息
-			// 获取最小吃多少笔
-			exec := min64(taker.Qty, maker.Qty)
-			trades = append(trades, Trade{
-				TakerID: taker.ID,
-				MakerID: maker.ID,
-				Price:   lv.price,
-				Qty:     exec,
-			})
-			// 两边都减去数量
-			taker.Qty -= exec
-			maker.Qty -= exec
-			// maker 桶被吃完了  摘链 删除索引
-			if maker.Qty == 0 {
-				lv.remove(mn)
-				delete(b.byID, maker.ID)
-				b.putNode(mn) // 关键：归还节点
+func (b *LevelOrderBookHeap) MatchLimitEmit(taker *Order, emit func(Trade)) (restQty int64) {
+	return b.MatchEmit(taker, false, emit, nil)
+}
 
-			}
-		}
-		// 5) 桶吃空了：删除桶，并重算 bestAsk
-		if lv.empty() {
-			delete(b.asks, lv.price)
+// MatchMarketEmit：市价单撮合，不设价格上限/下限，吃到对手盘为空为止
+// 返回剩余数量（市价单剩余由上层按 IOC 处理，不会挂单）
+func (b *LevelOrderBookHeap) MatchMarketEmit(taker *Order, emit func(Trade)) (restQty int64) {
+	return b.MatchEmit(taker, true, emit, nil)
+}
 
+// MatchEmit：通用撮合入口
+// - market=true：不设价格限制
+// - onSTP：接收自成交防护动作（可为 nil；STP 仍然生效，只是上层收不到通知）
+// 被 STP 撤掉的 taker 数量不计入 restQty
+func (b *LevelOrderBookHeap) MatchEmit(taker *Order, market bool, emit func(Trade), onSTP func(SelfTrade)) (restQty int64) {
+	if taker == nil || taker.Qty <= 0 {
+		return 0
+	}
+	if b.auction {
+		return taker.Qty // 竞价收单：不撮合，剩余全部交给上层挂单
+	}
+	if emit == nil {
+		// 避免 nil 函数调用：给一个空 emitter
+		emit = func(Trade) {}
+	}
+	if market {
+		limit := taker.Price
+		switch taker.Side {
+		case Buy:
+			taker.Price = math.MaxInt64
+		case Sell:
+			taker.Price = 0
 		}
+		defer func() { taker.Price = limit }()
 	}
-	// 6) taker 没吃完：挂单入簿（变成 maker）
-	if taker.Qty > 0 {
-		b.Add(taker)
+	switch taker.Side {
+	case Buy:
+		return b.matchBuyEmit(taker, emit, onSTP)
+	case Sell:
+		return b.matchSellEmit(taker, emit, onSTP)
+	default:
+		return taker.Qty
 	}
-	return trades
-
 }
 
-func (b *LevelOrderBookHeap) matchSell(taker *Order, trades []Trade) []Trade {
-
-	for taker.Qty > 0 {
-		bestP, ok := b.bestBidPrice()
-		if !ok || bestP < taker.Price {
-			break
+// CanFill：FOK 预检查，对手盘在可成交价位内的总量（含冰山隐藏量）是否 >= qty
+// market=true 时忽略 price（所有对手价位都可成交）
+// 按价位索引从最优价往下走，够量或越过限价就停，不扫整侧
+// 开了 STP 且 userID 有挂单时按撮合顺序算（见 stpAvail）：预检查通过的 FOK 不会被 STP 截成部分成交
+func (b *LevelOrderBookHeap) CanFill(userID uint64, side uint8, price, qty int64, market bool) bool {
+	if qty <= 0 {
+		return true
+	}
+	var levels map[int64]*priceLevelHeap
+	var idx priceIndex
+	switch side {
+	case Buy:
+		levels, idx = b.asks, b.askX
+	case Sell:
+		levels, idx = b.bids, b.bidX
+	default:
+		return false
+	}
+	self := b.stp != STPNone && userID != 0 && len(b.byUser[userID]) > 0
+	var avail int64
+	idx.rangeByPriority(func(p int64) bool {
+		if !market && (side == Buy && p > price || side == Sell && p < price) {
+			return false
+		}
+		lv := levels[p]
+		if !self {
+			avail += lv.qty + lv.rsv
+			return avail < qty
 		}
+		n, more := b.stpAvail(lv, userID)
+		avail += n
+		return more && avail < qty
+	})
+	return avail >= qty
+}
 
-		lv := b.bids[bestP]
-		if lv == nil || lv.empty() {
+// stpAvail：开了 STP 时该价位上 userID 的 taker 能成交的量；more=false 表示撮合到这里 taker 会被撤/减，后面的价位够不着
+// CancelOldest：自己的单被撤掉，不计；其他模式：碰到自己的单就停，只算排在它前面的可见量（冰山补片排到队尾，在它后面）
+func (b *LevelOrderBookHeap) stpAvail(lv *priceLevelHeap, userID uint64) (avail int64, more bool) {
+	avail = lv.qty + lv.rsv
+	for n := lv.head; n != nil; n = n.next {
+		o := n.order
+		if o.UserID != userID {
 			continue
 		}
-
-		for taker.Qty > 0 && !lv.empty() {
-			mn := lv.head
-			maker := mn.order
-
-			exec := min64(taker.Qty, maker.Qty)
-			trades = append(trades, Trade{
-				TakerID: taker.ID,
-				MakerID: maker.ID,
-				Price:   lv.price,
-				Qty:     exec,
-			})
-
-			taker.Qty -= exec
-			maker.Qty -= exec
-
-			if maker.Qty == 0 {
-				lv.remove(mn)
-				delete(b.byID, maker.ID)
-				b.putNode(mn) // 关键：归还节点
-			}
+		if b.stp == STPCancelOldest {
+			avail -= o.Qty + o.Reserve
+			continue
 		}
-
-		if lv.empty() {
-			delete(b.bids, lv.price)
+		avail = 0
+		for m := lv.head; m != n; m = m.next {
+			avail += m.order.Qty
 		}
+		return avail, false
 	}
-
-	if taker.Qty > 0 {
-		b.Add(taker)
-	}
-	return trades
-
+	return avail, true
 }
 
-// 基于事件
-// MatchLimitEmit：只撮合，不挂单（热路径：目标 0 alloc）
-// 返回剩余数量，由上层决定要不要挂单/撤销（IOC/FOK 都能支持）
-
-func (b *LevelOrderBookHeap) MatchLimitEmit(taker *Order, emit func(Trade)) (restQty int64) {
-	if taker == nil || taker.Qty <= 0 {
-		return 0
-	}
-	if emit == nil {
-		// 避免 nil 函数调用：给一个空 emitter
-		emit = func(Trade) {}
-	}
-	switch taker.Side {
+// WouldCross：PostOnly 检查，该价格的订单进来是否会立即成交（变成 taker）
+func (b *LevelOrderBookHeap) WouldCross(side uint8, price int64) bool {
+	switch side {
 	case Buy:
-		return b.matchBuyEmit(taker, emit)
+		p, ok := b.bestAskPrice()
+		return ok && p <= price
 	case Sell:
-		return b.matchSellEmit(taker, emit)
-	default:
-		return taker.Qty
+		p, ok := b.bestBidPrice()
+		return ok && p >= price
 	}
+	return false
 }
 
-func (b *LevelOrderBookHeap) matchBuyEmit(taker *Order, emit func(Trade)) int64 {
+func (b *LevelOrderBookHeap) matchBuyEmit(taker *Order, emit func(Trade), onSTP func(SelfTrade)) int64 {
 	for taker.Qty > 0 {
 		bestP, ok := b.bestAskPrice()
 		if !ok || bestP > taker.Price {
@@ -312,40 +437,52 @@ func (b *LevelOrderBookHeap) matchBuyEmit(taker *Order, emit func(Trade)) int64
 		if lv == nil || lv.empty() {
 			continue
 		}
+		b.touch(Sell, bestP)
 
 		for taker.Qty > 0 && !lv.empty() {
 			mn := lv.head
+			if b.preventSelfTrade(taker, mn, onSTP) {
+				continue
+			}
 			maker := mn.order
 
 			exec := min64(taker.Qty, maker.Qty)
 
-			// 不构造 slice，直接输出
-			emit(Trade{
-				TakerID: taker.ID,
-				MakerID: maker.ID,
-				Price:   lv.price,
-				Qty:     exec,
-			})
-
 			taker.Qty -= exec
 			maker.Qty -= exec
+			lv.qty -= exec
 
+			// 冰山单先补片；成交在补片之后输出，带上新的可见数量
+			var refill int64
 			if maker.Qty == 0 {
-				lv.remove(mn)
-				delete(b.byID, maker.ID)
-				// 归还节点（你已实现 nodePool 的话）
-				b.putNode(mn)
+				if refill = b.replenish(mn); refill == 0 {
+					lv.remove(mn)
+					b.unindex(maker)
+					// 归还节点（你已实现 nodePool 的话）
+					b.putNode(mn)
+				}
 			}
+
+			// 不构造 slice，直接输出
+			emit(Trade{
+				TakerID:     taker.ID,
+				MakerID:     maker.ID,
+				MakerUserID: maker.UserID,
+				TakerUserID: taker.UserID,
+				Price:       lv.price,
+				Qty:         exec,
+				Refill:      refill,
+			})
 		}
 
 		if lv.empty() {
-			delete(b.asks, lv.price) // heap 不删：lazy deletion
+			b.dropLevel(Sell, lv.price)
 		}
 	}
 	return taker.Qty
 }
 
-func (b *LevelOrderBookHeap) matchSellEmit(taker *Order, emit func(Trade)) int64 {
+func (b *LevelOrderBookHeap) matchSellEmit(taker *Order, emit func(Trade), onSTP func(SelfTrade)) int64 {
 	for taker.Qty > 0 {
 		bestP, ok := b.bestBidPrice()
 		if !ok || bestP < taker.Price {
@@ -355,62 +492,119 @@ func (b *LevelOrderBookHeap) matchSellEmit(taker *Order, emit func(Trade)) int64
 		if lv == nil || lv.empty() {
 			continue
 		}
+		b.touch(Buy, bestP)
 
 		for taker.Qty > 0 && !lv.empty() {
 			mn := lv.head
+			if b.preventSelfTrade(taker, mn, onSTP) {
+				continue
+			}
 			maker := mn.order
 
 			exec := min64(taker.Qty, maker.Qty)
 
-			emit(Trade{
-				TakerID: taker.ID,
-				MakerID: maker.ID,
-				Price:   lv.price,
-				Qty:     exec,
-			})
-
 			taker.Qty -= exec
 			maker.Qty -= exec
+			lv.qty -= exec
 
+			var refill int64
 			if maker.Qty == 0 {
-				lv.remove(mn)
-				delete(b.byID, maker.ID)
-				b.putNode(mn)
+				if refill = b.replenish(mn); refill == 0 {
+					lv.remove(mn)
+					b.unindex(maker)
+					b.putNode(mn)
+				}
 			}
+
+			emit(Trade{
+				TakerID:     taker.ID,
+				MakerID:     maker.ID,
+				MakerUserID: maker.UserID,
+				TakerUserID: taker.UserID,
+				Price:       lv.price,
+				Qty:         exec,
+				Refill:      refill,
+			})
 		}
 
 		if lv.empty() {
-			delete(b.bids, lv.price)
+			b.dropLevel(Buy, lv.price)
 		}
 	}
 	return taker.Qty
 
 }
 
-// bestAskPrice：从最小堆堆顶拿最低卖价；若堆顶对应桶已空/不存在，就弹出继续找
-func (b *LevelOrderBookHeap) bestAskPrice() (int64, bool) {
-	for b.askH.Len() > 0 {
-		p := b.askH[0]
-		lv := b.asks[p]
-		if lv != nil && !lv.empty() {
-			return p, true
+// SetSTP：设置自成交防护模式（按簿配置，撮合时生效）
+func (b *LevelOrderBookHeap) SetSTP(mode STPMode) { b.stp = mode }
+
+func (b *LevelOrderBookHeap) STP() STPMode { return b.stp }
+
+// preventSelfTrade：队首 maker 与 taker 同一用户时按 STP 模式处理，不产生成交
+// 返回 true 表示已处理（maker 可能被摘掉、taker 可能被清零），调用方重新看队首
+func (b *LevelOrderBookHeap) preventSelfTrade(taker *Order, mn *lvNodeHeap, onSTP func(SelfTrade)) bool {
+	maker := mn.order
+	if b.stp == STPNone || taker.UserID == 0 || maker.UserID != taker.UserID {
+		return false
+	}
+	st := SelfTrade{Mode: b.stp, TakerID: taker.ID, MakerID: maker.ID, UserID: taker.UserID}
+	switch b.stp {
+	case STPCancelNewest:
+		st.TakerQty = taker.Qty
+	case STPCancelOldest:
+		st.MakerQty = maker.Qty + maker.Reserve // 冰山单整单撤（含隐藏部分）
+	case STPCancelBoth:
+		st.TakerQty, st.MakerQty = taker.Qty, maker.Qty+maker.Reserve
+	case STPDecrement:
+		// 冰山单按整单（含隐藏部分）减：只减可见片的话 maker 补片排到队尾，taker 会先吃到后面的单
+		d := min64(taker.Qty, maker.Qty+maker.Reserve)
+		st.TakerQty, st.MakerQty = d, d
+	default:
+		return false
+	}
+
+	taker.Qty -= st.TakerQty
+	if st.MakerQty > 0 {
+		lv := mn.lv
+		if st.MakerQty >= maker.Qty+maker.Reserve {
+			lv.remove(mn)
+			b.unindex(maker)
+			b.putNode(mn)
+		} else {
+			// 只可能是 Decrement：先减可见部分，不够再减隐藏量；可见片减完补片
+			vis := min64(st.MakerQty, maker.Qty)
+			maker.Qty -= vis
+			lv.qty -= vis
+			maker.Reserve -= st.MakerQty - vis
+			lv.rsv -= st.MakerQty - vis
+			st.Refill = b.replenish(mn)
 		}
-		heap.Pop(&b.askH) // lazy：丢掉过期价位
 	}
-	return 0, false
+	if onSTP != nil {
+		onSTP(st)
+	}
+	return true
+}
+
+// bestAskPrice：最低卖价（heap 索引会顺带丢掉堆顶的过期价位）
+func (b *LevelOrderBookHeap) bestAskPrice() (int64, bool) {
+	return b.askX.best()
 }
 
-// bestBidPrice：从最大堆堆顶拿最高买价；若桶已空/不存在，就弹出继续找
+// bestBidPrice：最高买价
 func (b *LevelOrderBookHeap) bestBidPrice() (int64, bool) {
-	for b.bidH.Len() > 0 {
-		p := b.bidH[0]
-		lv := b.bids[p]
-		if lv != nil && !lv.empty() {
-			return p, true
-		}
-		heap.Pop(&b.bidH)
+	return b.bidX.best()
+}
+
+// dropLevel：价位撤空后删桶并出索引
+func (b *LevelOrderBookHeap) dropLevel(side uint8, price int64) {
+	if side == Sell {
+		delete(b.asks, price)
+		b.askX.remove(price)
+	} else {
+		delete(b.bids, price)
+		b.bidX.remove(price)
 	}
-	return 0, false
 }
 
 func (b *LevelOrderBookHeap) getNode(order *Order, lv *priceLevelHeap, side uint8) *lvNodeHeap {
@@ -26,10 +26,12 @@ func (b *NaiveOrderBook) matchBuy(taker *Order) []Trade {
 		}
 		execQty := min64(taker.Qty, maker.Qty)
 		trades = append(trades, Trade{
-			TakerID: taker.ID,
-			MakerID: maker.ID,
-			Price:   maker.Price,
-			Qty:     execQty,
+			TakerID:     taker.ID,
+			MakerID:     maker.ID,
+			MakerUserID: maker.UserID,
+			TakerUserID: taker.UserID,
+			Price:       maker.Price,
+			Qty:         execQty,
 		})
 
 		taker.Qty -= execQty
@@ -61,10 +63,12 @@ func (b *NaiveOrderBook) matchSell(taker *Order) []Trade {
 
 		execQty := min64(taker.Qty, maker.Qty)
 		trades = append(trades, Trade{
-			TakerID: taker.ID,
-			MakerID: maker.ID,
-			Price:   maker.Price,
-			Qty:     execQty,
+			TakerID:     taker.ID,
+			MakerID:     maker.ID,
+			MakerUserID: maker.UserID,
+			TakerUserID: taker.UserID,
+			Price:       maker.Price,
+			Qty:         execQty,
 		})
 
 		taker.Qty -= execQty
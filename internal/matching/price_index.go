@@ -0,0 +1,199 @@
+package matching
+
+import "container/heap"
+
+// priceIndex：一侧价位的索引（best 价 + 按优先级遍历），价位桶本身仍在 asks/bids map 里
+// 内部统一存 key = sign*price（卖盘 sign=1，买盘 sign=-1），key 越小优先级越高
+// 撮合/深度/快照只通过这个接口找价位，换索引实现不用动撮合代码
+// - heapIndex：lazy 堆，删价位不动堆，best 时才弹掉过期价；同一价位反复出现/撤空会在堆里留重复项；遍历在堆上按序展开
+// - skipIndex：跳表，插入/删除 O(log n)，best O(1)，按优先级直接遍历（深度/快照不用排序）
+type priceIndex interface {
+	insert(price int64) // 新价位出现
+	remove(price int64) // 价位撤空
+	best() (int64, bool)
+	// rangeByPriority：按优先级遍历有单的价位（卖盘升序、买盘降序），fn 返回 false 停止
+	rangeByPriority(fn func(price int64) bool)
+}
+
+// ---------- heap ----------
+
+type heapIndex struct {
+	h      minPriceHeap
+	sign   int64
+	levels map[int64]*priceLevelHeap // 判断堆顶是否过期
+}
+
+func newHeapIndex(side uint8, levels map[int64]*priceLevelHeap) *heapIndex {
+	x := &heapIndex{sign: sideSign(side), levels: levels}
+	heap.Init(&x.h)
+	return x
+}
+
+func (x *heapIndex) insert(price int64) { heap.Push(&x.h, x.sign*price) }
+func (x *heapIndex) remove(int64)       {} // lazy：best 时再丢
+
+func (x *heapIndex) best() (int64, bool) {
+	for x.h.Len() > 0 {
+		p := x.sign * x.h[0]
+		if lv := x.levels[p]; lv != nil && !lv.empty() {
+			return p, true
+		}
+		heap.Pop(&x.h)
+	}
+	return 0, false
+}
+
+// rangeByPriority：在堆上按优先级展开（辅助堆放待访问的下标），fn 提前返回 false 时只碰堆顶附近，不排全部价位
+// lazy 堆里同一价位可能有多份、也可能已撤空：按 key 顺序出来，重复的相邻，跳过
+func (x *heapIndex) rangeByPriority(fn func(price int64) bool) {
+	if x.h.Len() == 0 {
+		return
+	}
+	w := heapWalk{h: x.h, idx: []int{0}}
+	var last int64
+	emitted := false
+	for len(w.idx) > 0 {
+		i := heap.Pop(&w).(int)
+		for _, c := range [2]int{2*i + 1, 2*i + 2} {
+			if c < len(x.h) {
+				heap.Push(&w, c)
+			}
+		}
+		k := x.h[i]
+		if emitted && k == last {
+			continue
+		}
+		p := x.sign * k
+		if lv := x.levels[p]; lv == nil || lv.empty() {
+			continue
+		}
+		emitted, last = true, k
+		if !fn(p) {
+			return
+		}
+	}
+}
+
+// heapWalk：堆数组下标的小顶堆（按 h[i]），从根开始每弹出一个就压入它的两个孩子
+type heapWalk struct {
+	h   minPriceHeap
+	idx []int
+}
+
+func (w heapWalk) Len() int           { return len(w.idx) }
+func (w heapWalk) Less(i, j int) bool { return w.h[w.idx[i]] < w.h[w.idx[j]] }
+func (w heapWalk) Swap(i, j int)      { w.idx[i], w.idx[j] = w.idx[j], w.idx[i] }
+func (w *heapWalk) Push(v any)        { w.idx = append(w.idx, v.(int)) }
+func (w *heapWalk) Pop() any {
+	n := len(w.idx)
+	v := w.idx[n-1]
+	w.idx = w.idx[:n-1]
+	return v
+}
+
+// ---------- skiplist ----------
+
+const skipMaxLevel = 24 // 1/4 晋升概率下足够覆盖 2^48 个价位
+
+type skipNode struct {
+	key  int64
+	next []*skipNode
+}
+
+type skipIndex struct {
+	head   skipNode
+	level  int // 当前最高层数
+	sign   int64
+	rnd    uint64                  // xorshift 状态：固定种子，结构可复现
+	update [skipMaxLevel]*skipNode // insert/remove 的前驱（复用，避免分配）
+}
+
+func newSkipIndex(side uint8) *skipIndex {
+	return &skipIndex{
+		head:  skipNode{next: make([]*skipNode, skipMaxLevel)},
+		level: 1,
+		sign:  sideSign(side),
+		rnd:   0x9E3779B97F4A7C15,
+	}
+}
+
+// seek：填好每层最后一个 key < k 的前驱，返回第 0 层的下一个节点
+func (x *skipIndex) seek(k int64) *skipNode {
+	n := &x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].key < k {
+			n = n.next[i]
+		}
+		x.update[i] = n
+	}
+	return n.next[0]
+}
+
+func (x *skipIndex) randomLevel() int {
+	lvl := 1
+	for lvl < skipMaxLevel {
+		x.rnd ^= x.rnd << 13
+		x.rnd ^= x.rnd >> 7
+		x.rnd ^= x.rnd << 17
+		if x.rnd&3 != 0 {
+			break
+		}
+		lvl++
+	}
+	return lvl
+}
+
+func (x *skipIndex) insert(price int64) {
+	k := x.sign * price
+	if n := x.seek(k); n != nil && n.key == k {
+		return
+	}
+	lvl := x.randomLevel()
+	for i := x.level; i < lvl; i++ {
+		x.update[i] = &x.head
+	}
+	if lvl > x.level {
+		x.level = lvl
+	}
+	n := &skipNode{key: k, next: make([]*skipNode, lvl)}
+	for i := 0; i < lvl; i++ {
+		n.next[i] = x.update[i].next[i]
+		x.update[i].next[i] = n
+	}
+}
+
+func (x *skipIndex) remove(price int64) {
+	k := x.sign * price
+	n := x.seek(k)
+	if n == nil || n.key != k {
+		return
+	}
+	for i := 0; i < len(n.next); i++ {
+		x.update[i].next[i] = n.next[i]
+	}
+	for x.level > 1 && x.head.next[x.level-1] == nil {
+		x.level--
+	}
+}
+
+func (x *skipIndex) best() (int64, bool) {
+	if n := x.head.next[0]; n != nil {
+		return x.sign * n.key, true
+	}
+	return 0, false
+}
+
+func (x *skipIndex) rangeByPriority(fn func(price int64) bool) {
+	for n := x.head.next[0]; n != nil; n = n.next[0] {
+		if !fn(x.sign * n.key) {
+			return
+		}
+	}
+}
+
+func sideSign(side uint8) int64 {
+	if side == Buy {
+		return -1
+	}
+	return 1
+}
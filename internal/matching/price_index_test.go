@@ -0,0 +1,164 @@
+package matching
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSkipIndex_Order(t *testing.T) {
+	for _, side := range []uint8{Sell, Buy} {
+		x := newSkipIndex(side)
+		for _, p := range []int64{105, 101, 110, 101, 103, 120} {
+			x.insert(p)
+		}
+		x.remove(110)
+		x.remove(999) // 不存在：忽略
+		var got []int64
+		x.rangeByPriority(func(p int64) bool { got = append(got, p); return true })
+		want := []int64{101, 103, 105, 120}
+		if side == Buy {
+			want = []int64{120, 105, 103, 101}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("side=%d got=%v want=%v", side, got, want)
+		}
+		if p, ok := x.best(); !ok || p != want[0] {
+			t.Fatalf("side=%d best=%d %v", side, p, ok)
+		}
+		for _, p := range want {
+			x.remove(p)
+		}
+		if _, ok := x.best(); ok || x.level != 1 {
+			t.Fatalf("side=%d not empty, level=%d", side, x.level)
+		}
+	}
+}
+
+// 随机挂单/撤单/撮合：skiplist 簿与 heap 簿、线性扫描簿逐步对账
+func TestSkipListBook_MatchesReferenceBooks(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	scan := NewLevelOrderBook()
+	hp := NewLevelOrderHeapBook()
+	sk := NewLevelOrderSkipListBook()
+	var live []uint64
+
+	for i := 0; i < 20000; i++ {
+		id := uint64(i + 1)
+		switch op := r.Intn(10); {
+		case op < 3 && len(live) > 0: // 撤单
+			k := r.Intn(len(live))
+			cid := live[k]
+			live[k] = live[len(live)-1]
+			live = live[:len(live)-1]
+			a, b, c := scan.Cancel(cid), hp.Cancel(cid), sk.Cancel(cid)
+			if a != b || b != c {
+				t.Fatalf("#%d cancel %d: scan=%v heap=%v skip=%v", i, cid, a, b, c)
+			}
+		default: // 限价单：价格围绕 1000 波动，既有挂单也有穿价
+			side := uint8(Buy)
+			if r.Intn(2) == 0 {
+				side = Sell
+			}
+			mk := func() *Order {
+				return &Order{ID: id, Side: side, Price: int64(950 + r.Intn(100)), Qty: int64(1 + r.Intn(5))}
+			}
+			o := mk()
+			o2, o3 := *o, *o
+			a, b, c := scan.SubmitLimit(o), submitRest(hp, &o2), submitRest(sk, &o3)
+			if !sameTrades(a, b) || !sameTrades(b, c) {
+				t.Fatalf("#%d trades differ:\nscan=%+v\nheap=%+v\nskip=%+v", i, a, b, c)
+			}
+			live = append(live, id)
+		}
+
+		if i%97 == 0 {
+			for _, side := range []uint8{Buy, Sell} {
+				if dh, ds := hp.Depth(side, 10), sk.Depth(side, 10); !reflect.DeepEqual(dh, ds) {
+					t.Fatalf("#%d depth side=%d:\nheap=%v\nskip=%v", i, side, dh, ds)
+				}
+			}
+		}
+		pa, oka := scan.BestAsk()
+		ph, okh := hp.BestAsk()
+		ps, oks := sk.BestAsk()
+		if pa != ph || ph != ps || oka != okh || okh != oks {
+			t.Fatalf("#%d best ask: scan=%d heap=%d skip=%d", i, pa, ph, ps)
+		}
+		pa, oka = scan.BestBid()
+		ph, okh = hp.BestBid()
+		ps, oks = sk.BestBid()
+		if pa != ph || ph != ps || oka != okh || okh != oks {
+			t.Fatalf("#%d best bid: scan=%d heap=%d skip=%d", i, pa, ph, ps)
+		}
+	}
+
+	collect := func(rng func(func(Order))) []Order {
+		var out []Order
+		rng(func(o Order) { out = append(out, o) })
+		return out
+	}
+	a, b, c := collect(scan.RangeOrders), collect(hp.RangeOrders), collect(sk.RangeOrders)
+	if len(a) == 0 || !reflect.DeepEqual(a, b) || !reflect.DeepEqual(b, c) {
+		t.Fatalf("resting orders differ: scan=%d heap=%d skip=%d", len(a), len(b), len(c))
+	}
+	if !reflect.DeepEqual(hp.Depth(Sell, 0), sk.Depth(Sell, 0)) || !reflect.DeepEqual(hp.Depth(Buy, 0), sk.Depth(Buy, 0)) {
+		t.Fatal("full depth differs")
+	}
+}
+
+// submitRest：heap 簿只撮合不挂单，剩余量照 LevelOrderBook.SubmitLimit 的语义挂回簿
+func submitRest(b *LevelOrderBookHeap, o *Order) []Trade {
+	trades := b.SubmitLimitBuff(o, nil)
+	if o.Qty > 0 {
+		b.Add(o)
+	}
+	return trades
+}
+
+// sameTrades：nil 与空切片视为相同
+func sameTrades(a, b []Trade) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// heap 索引按堆展开遍历：撤空的价位、反复出现留下的重复项都要跳过，顺序与跳表一致
+func TestHeapIndex_RangeSkipsStaleAndDuplicates(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	id := uint64(0)
+	add := func(side uint8, price int64) uint64 {
+		id++
+		b.Add(&Order{ID: id, UserID: 1, Side: side, Price: price, Qty: 1})
+		return id
+	}
+	asksAt := make(map[int64]uint64)
+	bidsAt := make(map[int64]uint64)
+	for _, p := range []int64{105, 101, 110, 103, 120} {
+		asksAt[p] = add(Sell, p)
+		bidsAt[p-50] = add(Buy, p-50)
+	}
+	b.Cancel(asksAt[110]) // 撤空
+	b.Cancel(bidsAt[60])
+	b.Cancel(bidsAt[55])
+	add(Buy, 55) // 重新出现：堆里两份
+	b.Cancel(asksAt[101])
+	add(Sell, 101)
+	add(Sell, 101)
+
+	var asks, bids []int64
+	b.askX.rangeByPriority(func(p int64) bool { asks = append(asks, p); return true })
+	b.bidX.rangeByPriority(func(p int64) bool { bids = append(bids, p); return true })
+	if want := []int64{101, 103, 105, 120}; !reflect.DeepEqual(asks, want) {
+		t.Fatalf("asks=%v want=%v", asks, want)
+	}
+	if want := []int64{70, 55, 53, 51}; !reflect.DeepEqual(bids, want) {
+		t.Fatalf("bids=%v want=%v", bids, want)
+	}
+
+	// CanFill 只看限价以内：101(2) + 103(1)
+	if !b.CanFill(0, Buy, 103, 3, false) || b.CanFill(0, Buy, 103, 4, false) || !b.CanFill(0, Buy, 0, 5, true) {
+		t.Fatal("CanFill buy")
+	}
+	if !b.CanFill(0, Sell, 55, 2, false) || b.CanFill(0, Sell, 55, 3, false) {
+		t.Fatal("CanFill sell")
+	}
+}
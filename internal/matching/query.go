@@ -0,0 +1,68 @@
+package matching
+
+import "sort"
+
+// 只读查询：都不改簿，调用方保证和写操作在同一个协程（engine 里就是 actor）
+
+// index / unindex：维护 byID + byUser 两个索引（挂单入簿/离簿时成对调用）
+func (b *LevelOrderBookHeap) index(n *lvNodeHeap) {
+	b.byID[n.order.ID] = n
+	m := b.byUser[n.order.UserID]
+	if m == nil {
+		m = make(map[uint64]*lvNodeHeap, 4)
+		b.byUser[n.order.UserID] = m
+	}
+	m[n.order.ID] = n
+}
+
+func (b *LevelOrderBookHeap) unindex(o *Order) {
+	delete(b.byID, o.ID)
+	if m := b.byUser[o.UserID]; m != nil {
+		delete(m, o.ID)
+		if len(m) == 0 {
+			delete(b.byUser, o.UserID)
+		}
+	}
+}
+
+// UserOrders：该用户的全部挂单（副本），按订单号升序
+func (b *LevelOrderBookHeap) UserOrders(userID uint64) []Order {
+	m := b.byUser[userID]
+	out := make([]Order, 0, len(m))
+	for _, n := range m {
+		out = append(out, *n.order)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// UserClientOrder：按 (userID, clientID) 查该用户的挂单（扫该用户的挂单，不排序）
+func (b *LevelOrderBookHeap) UserClientOrder(userID, clientID uint64) (Order, bool) {
+	for _, n := range b.byUser[userID] {
+		if n.order.ClientID == clientID {
+			return *n.order, true
+		}
+	}
+	return Order{}, false
+}
+
+// UserOrderCount：该用户当前挂单数
+func (b *LevelOrderBookHeap) UserOrderCount(userID uint64) int {
+	return len(b.byUser[userID])
+}
+
+// BestLevel：一侧最优价位及其可见总量
+func (b *LevelOrderBookHeap) BestLevel(side uint8) (PriceLevel, bool) {
+	if side == Buy {
+		p, ok := b.bestBidPrice()
+		if !ok {
+			return PriceLevel{}, false
+		}
+		return PriceLevel{Price: p, Qty: b.bids[p].qty}, true
+	}
+	p, ok := b.bestAskPrice()
+	if !ok {
+		return PriceLevel{}, false
+	}
+	return PriceLevel{Price: p, Qty: b.asks[p].qty}, true
+}
@@ -0,0 +1,37 @@
+package matching
+
+import "testing"
+
+func TestQuery_UserIndexAndBBO(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.Add(&Order{ID: 3, UserID: 7, Side: Sell, Price: 101, Qty: 2})
+	b.Add(&Order{ID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 1})
+	b.Add(&Order{ID: 2, UserID: 8, Side: Buy, Price: 99, Qty: 5})
+	b.Add(&Order{ID: 4, UserID: 7, Side: Buy, Price: 98, Qty: 9, Display: 3})
+
+	got := b.UserOrders(7)
+	if len(got) != 3 || got[0].ID != 1 || got[1].ID != 3 || got[2].ID != 4 || got[2].Reserve != 6 {
+		t.Fatalf("user orders=%+v", got)
+	}
+	if lv, ok := b.BestLevel(Sell); !ok || lv != (PriceLevel{Price: 100, Qty: 1}) {
+		t.Fatalf("best ask=%+v/%v", lv, ok)
+	}
+	if lv, ok := b.BestLevel(Buy); !ok || lv != (PriceLevel{Price: 99, Qty: 5}) {
+		t.Fatalf("best bid=%+v/%v", lv, ok)
+	}
+
+	// 成交吃掉 1、部分吃 3；撤 4：索引随之更新
+	b.MatchEmit(&Order{ID: 10, UserID: 9, Side: Buy, Price: 101, Qty: 2}, false, nil, nil)
+	b.Cancel(4)
+	got = b.UserOrders(7)
+	if len(got) != 1 || got[0].ID != 3 || got[0].Qty != 1 || b.UserOrderCount(7) != 1 {
+		t.Fatalf("user orders after fill=%+v", got)
+	}
+	b.Cancel(3)
+	if b.UserOrderCount(7) != 0 || len(b.byUser) != 1 {
+		t.Fatalf("byUser=%v", b.byUser)
+	}
+	if _, ok := b.BestLevel(Sell); ok {
+		t.Fatal("asks should be empty")
+	}
+}
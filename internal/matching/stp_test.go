@@ -0,0 +1,141 @@
+package matching
+
+import "testing"
+
+// 卖盘：1(user 7) 在前，2(user 8) 在后，同价 100
+func stpBook(mode STPMode) *LevelOrderBookHeap {
+	b := NewLevelOrderHeapBook()
+	b.SetSTP(mode)
+	b.Add(&Order{ID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 2})
+	b.Add(&Order{ID: 2, UserID: 8, Side: Sell, Price: 100, Qty: 2})
+	return b
+}
+
+func runSTP(b *LevelOrderBookHeap, qty int64) (trades []Trade, sts []SelfTrade, rest int64) {
+	taker := &Order{ID: 10, UserID: 7, Side: Buy, Price: 100, Qty: qty}
+	rest = b.MatchEmit(taker, false, func(t Trade) { trades = append(trades, t) }, func(st SelfTrade) { sts = append(sts, st) })
+	return trades, sts, rest
+}
+
+func TestSTP_Modes(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		trades, sts, _ := runSTP(stpBook(STPNone), 1)
+		if len(trades) != 1 || trades[0].MakerID != 1 || len(sts) != 0 {
+			t.Fatalf("trades=%+v sts=%+v", trades, sts)
+		}
+	})
+
+	t.Run("cancel newest", func(t *testing.T) {
+		b := stpBook(STPCancelNewest)
+		trades, sts, rest := runSTP(b, 3)
+		if len(trades) != 0 || rest != 0 || len(sts) != 1 || sts[0].TakerQty != 3 || sts[0].MakerQty != 0 {
+			t.Fatalf("trades=%+v sts=%+v rest=%d", trades, sts, rest)
+		}
+		if o, ok := b.Order(1); !ok || o.Qty != 2 {
+			t.Fatalf("maker should stay: %+v %v", o, ok)
+		}
+	})
+
+	t.Run("cancel oldest", func(t *testing.T) {
+		b := stpBook(STPCancelOldest)
+		trades, sts, rest := runSTP(b, 3)
+		if len(sts) != 1 || sts[0].MakerID != 1 || sts[0].MakerQty != 2 || sts[0].TakerQty != 0 {
+			t.Fatalf("sts=%+v", sts)
+		}
+		// maker 1 被撤后继续吃 2
+		if len(trades) != 1 || trades[0].MakerID != 2 || trades[0].Qty != 2 || rest != 1 {
+			t.Fatalf("trades=%+v rest=%d", trades, rest)
+		}
+		if _, ok := b.Order(1); ok {
+			t.Fatalf("maker 1 should be removed")
+		}
+	})
+
+	t.Run("cancel both", func(t *testing.T) {
+		b := stpBook(STPCancelBoth)
+		trades, sts, rest := runSTP(b, 3)
+		if len(trades) != 0 || rest != 0 || len(sts) != 1 || sts[0].TakerQty != 3 || sts[0].MakerQty != 2 {
+			t.Fatalf("trades=%+v sts=%+v rest=%d", trades, sts, rest)
+		}
+		if p, ok := b.BestAsk(); !ok || p != 100 || b.CanFill(0, Buy, 100, 3, false) {
+			t.Fatalf("only order 2 should remain")
+		}
+	})
+
+	t.Run("decrement", func(t *testing.T) {
+		b := stpBook(STPDecrement)
+		trades, sts, rest := runSTP(b, 3)
+		// 两边各减 2：maker 1 清零被摘，taker 剩 1 继续吃 2
+		if len(sts) != 1 || sts[0].TakerQty != 2 || sts[0].MakerQty != 2 {
+			t.Fatalf("sts=%+v", sts)
+		}
+		if len(trades) != 1 || trades[0].MakerID != 2 || trades[0].Qty != 1 || rest != 0 {
+			t.Fatalf("trades=%+v rest=%d", trades, rest)
+		}
+		if o, ok := b.Order(2); !ok || o.Qty != 1 {
+			t.Fatalf("order 2: %+v %v", o, ok)
+		}
+	})
+}
+
+// 不带回调的撮合入口同样受 STP 约束
+func TestSTP_SubmitLimitBuff(t *testing.T) {
+	b := stpBook(STPCancelOldest)
+	trades := b.SubmitLimitBuff(&Order{ID: 10, UserID: 7, Side: Buy, Price: 100, Qty: 3}, nil)
+	if len(trades) != 1 || trades[0].MakerID != 2 || trades[0].Qty != 2 {
+		t.Fatalf("trades=%+v", trades)
+	}
+	if _, ok := b.Order(1); ok {
+		t.Fatal("oldest maker should be cancelled")
+	}
+}
+
+// Decrement 碰到冰山 maker：按整单（含隐藏量）减，taker 不会绕过去吃后面的单
+func TestSTP_DecrementIcebergMaker(t *testing.T) {
+	b := NewLevelOrderHeapBook()
+	b.SetSTP(STPDecrement)
+	b.Add(&Order{ID: 1, UserID: 7, Side: Sell, Price: 100, Qty: 10, Display: 3})
+	b.Add(&Order{ID: 2, UserID: 8, Side: Sell, Price: 100, Qty: 2})
+	trades, sts, rest := runSTP(b, 5)
+	if len(trades) != 0 || rest != 0 || len(sts) != 1 || sts[0].TakerQty != 5 || sts[0].MakerQty != 5 || sts[0].Refill != 3 {
+		t.Fatalf("trades=%+v sts=%+v rest=%d", trades, sts, rest)
+	}
+	if o, ok := b.Order(1); !ok || o.Qty != 3 || o.Reserve != 2 {
+		t.Fatalf("order 1: %+v %v", o, ok)
+	}
+	if o, ok := b.Order(2); !ok || o.Qty != 2 {
+		t.Fatalf("order 2: %+v %v", o, ok)
+	}
+}
+
+// FOK 预检查按 STP 模式算：通过了就能整单成交，不会被 STP 截成部分成交
+func TestSTP_CanFill(t *testing.T) {
+	// 100：1(user 8，冰山 2+2) 在前，2(user 7) 在后；101：3(user 8)
+	book := func(mode STPMode) *LevelOrderBookHeap {
+		b := NewLevelOrderHeapBook()
+		b.SetSTP(mode)
+		b.Add(&Order{ID: 1, UserID: 8, Side: Sell, Price: 100, Qty: 4, Display: 2})
+		b.Add(&Order{ID: 2, UserID: 7, Side: Sell, Price: 100, Qty: 2})
+		b.Add(&Order{ID: 3, UserID: 8, Side: Sell, Price: 101, Qty: 5})
+		return b
+	}
+	if b := book(STPNone); !b.CanFill(7, Buy, 101, 11, false) {
+		t.Fatal("none: own orders count")
+	}
+	for _, mode := range []STPMode{STPCancelNewest, STPCancelBoth, STPDecrement} {
+		b := book(mode)
+		// 碰到 2 之前只有 1 的可见片（补片排到 2 后面）
+		if b.CanFill(7, Buy, 101, 3, false) || !b.CanFill(7, Buy, 101, 2, false) || !b.CanFill(0, Buy, 101, 11, false) {
+			t.Fatalf("%v: CanFill", mode)
+		}
+		var filled int64
+		rest := b.MatchEmit(&Order{ID: 10, UserID: 7, Side: Buy, Price: 101, Qty: 2}, false, func(tr Trade) { filled += tr.Qty }, nil)
+		if filled != 2 || rest != 0 {
+			t.Fatalf("%v: filled=%d rest=%d", mode, filled, rest)
+		}
+	}
+	b := book(STPCancelOldest)
+	if !b.CanFill(7, Buy, 101, 9, false) || b.CanFill(7, Buy, 101, 10, false) {
+		t.Fatal("cancel oldest: own orders must not count")
+	}
+}
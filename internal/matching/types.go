@@ -9,18 +9,95 @@ const (
 )
 
 // 订单薄
+// 冰山单：Display>0 时挂单只露出 Display 那么多（Qty），其余藏在 Reserve
+// 可见部分吃完从 Reserve 补一片，重新排到同价位队尾（失去时间优先）
 type Order struct {
-	ID     uint64 // 交易id
-	Side   uint8
-	Price  int64 //价格
-	Qty    int64 //
-	UserID uint64
+	ID       uint64 // 交易id
+	Side     uint8
+	Price    int64 //价格
+	Qty      int64 // 可见数量（非冰山单即剩余数量）
+	UserID   uint64
+	Display  int64  // 冰山单每片显示数量，0 表示普通单
+	Reserve  int64  // 冰山单隐藏的剩余数量
+	ClientID uint64 // 客户端订单号（用户内唯一），撮合不看，只随订单保存
+	PostOnly bool   // 只做 maker：撮合不看，改单穿价时由上层拒
+	// Seq：入队序号（簿内递增，每次排到队尾都重新编号），竞价 STP 按它判断哪边是后下的单
+	// Add 时为 0 由簿分配；非 0（快照恢复）保留原值
+	Seq uint64
+}
+
+// stampSeq：给排到队尾的订单分配入队序号（last 是该簿最近分配的序号）
+// 已有序号（快照恢复）保留，并让后续序号接在它后面
+func stampSeq(last *uint64, o *Order) {
+	if o.Seq == 0 {
+		*last++
+		o.Seq = *last
+	} else if o.Seq > *last {
+		*last = o.Seq
+	}
+}
+
+// 自成交防护（STP）：taker 与 maker 属于同一用户时不成交，按模式撤单/减量
+type STPMode uint8
+
+const (
+	STPNone         STPMode = iota // 不防护（默认）
+	STPCancelNewest                // 撤 taker 剩余，maker 保留
+	STPCancelOldest                // 撤 maker，taker 继续撮合
+	STPCancelBoth                  // maker 与 taker 剩余都撤
+	STPDecrement                   // 两边各减 min(剩余量)（冰山 maker 含隐藏部分），减到 0 的那边等于被撤
+)
+
+var stpNames = [...]string{"none", "cancel_newest", "cancel_oldest", "cancel_both", "decrement"}
+
+func (m STPMode) String() string {
+	if int(m) < len(stpNames) {
+		return stpNames[m]
+	}
+	return "unknown"
+}
+
+// ParseSTPMode：配置里的模式名（见 stpNames），空串为不防护
+func ParseSTPMode(s string) (STPMode, bool) {
+	if s == "" {
+		return STPNone, true
+	}
+	for i, n := range stpNames {
+		if n == s {
+			return STPMode(i), true
+		}
+	}
+	return STPNone, false
+}
+
+// SelfTrade：一次 STP 动作，*Qty 为从对应订单上撤掉/减掉的数量（0 表示未动）
+type SelfTrade struct {
+	Mode     STPMode
+	TakerID  uint64
+	MakerID  uint64
+	UserID   uint64
+	TakerQty int64
+	MakerQty int64
+	Refill   int64 // maker 是冰山单且因此补了一片：新的可见数量
+	// 集合竞价两边都是挂单：taker 冰山单补片数量
+	TakerRefill int64
 }
 
 // 交易
 type Trade struct {
-	TakerID uint64
-	MakerID uint64
-	Price   int64
-	Qty     int64
+	TakerID     uint64
+	MakerID     uint64
+	TakerUserID uint64
+	MakerUserID uint64
+	Price       int64
+	Qty         int64
+	Refill      int64 // maker 是冰山单且本笔吃完可见部分后补了一片：新的可见数量
+	// 集合竞价两边都是挂单：买方（TakerID）冰山单补片数量
+	TakerRefill int64
+}
+
+// PriceLevel：一档深度（只含可见数量，冰山单隐藏部分不计）
+type PriceLevel struct {
+	Price int64
+	Qty   int64
 }
@@ -20,9 +20,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	clientv3 "go.etcd.io/etcd/client/v3"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/stats"
 	"gopherex.com/pkg/config"
 	"gopherex.com/pkg/logger"
 	"gopherex.com/pkg/register"
@@ -111,7 +111,7 @@ type Options struct {
 	// gRPC interceptors (chained as-is); provide service-specific additions
 	UnaryInterceptors  []grpc.UnaryServerInterceptor
 	StreamInterceptors []grpc.StreamServerInterceptor
-	StatsHandler       grpc.StatsHandler
+	StatsHandler       stats.Handler // 如 otelgrpc.NewServerHandler()
 
 	// Listen addresses
 	MetricsAddr string
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 撮合引擎指标：按 symbol 打标签（每个 symbol 一个 actor）
+var (
+	EngineBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "batch_size",
+		Help:      "Commands per actor batch",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12), // 1 ~ 2048
+	}, []string{"symbol"})
+
+	EngineWALFlushDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "wal_flush_duration_seconds",
+		Help:      "WAL flush (write + fsync) latency",
+		Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 16), // 50µs ~ 1.6s
+	}, []string{"symbol", "log"}) // log: cmd | ev
+
+	EngineCmdLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "command_latency_seconds",
+		Help:      "Latency from mailbox enqueue to events durable in outbox",
+		Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 16),
+	}, []string{"symbol"})
+
+	EngineMailboxDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "mailbox_depth",
+		Help:      "Commands waiting in actor mailbox",
+	}, []string{"symbol"})
+
+	EngineMailboxFull = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "mailbox_full_total",
+		Help:      "TrySubmit rejected because mailbox was full",
+	}, []string{"symbol"})
+
+	EngineRejects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "rejects_total",
+		Help:      "Rejected commands by reason",
+	}, []string{"symbol", "reason"})
+
+	EngineSnapshotFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "snapshot_failures_total",
+		Help:      "Order book snapshots that failed to write, or WAL retention that failed after a snapshot",
+	}, []string{"symbol", "stage"}) // stage: write | retain
+
+	EnginePublisherLagBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "publisher_lag_bytes",
+		Help:      "Outbox bytes not yet acked by the event sink",
+	}, []string{"symbol"})
+
+	EnginePublisherLagSeq = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "gopherex",
+		Subsystem: "engine",
+		Name:      "publisher_lag_seq",
+		Help:      "Commands durable in outbox but not yet acked by the event sink",
+	}, []string{"symbol"})
+)
@@ -55,7 +55,7 @@ func OpenReader(path string, offset int64, opts ReaderOptions) (*Reader, error)
 		br:             bufio.NewReaderSize(f, opts.BufferSize),
 		off:            offset,
 		maxPayload:     maxPayload,
-		allowTail:      false,
+		allowTail:      opts.AllowTruncatedTail,
 		truncatedTail:  false,
 		lastGoodOffset: offset, // 已经偏移了
 	}, nil
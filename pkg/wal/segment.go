@@ -0,0 +1,496 @@
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 分段 WAL：一个逻辑日志 = 多个段文件 + 一个 manifest
+//
+//	<base>.manifest                  段列表（JSON，原子替换）
+//	<base>.<first:020d>.seg          段文件，first 为段内第一个字节的逻辑偏移
+//
+// 逻辑偏移在所有段之间连续，所以上层 cursor/offset 语义与单文件 WAL 完全一致。
+
+var (
+	ErrSegmentGone = errors.New("wal: offset points to a removed segment")
+)
+
+type SegmentOptions struct {
+	BufSize         int           // 写缓冲，<=0 默认 1MB
+	MaxSegmentBytes int64         // 段大小上限，<=0 不按大小切
+	MaxSegmentAge   time.Duration // 段存活时长上限，<=0 不按时间切
+}
+
+type SegmentInfo struct {
+	File    string `json:"file"`    // 段文件名（与 base 同目录）
+	First   int64  `json:"first"`   // 段起始逻辑偏移（含）
+	Last    int64  `json:"last"`    // 段结束逻辑偏移（不含）；活跃段以文件大小为准
+	Sealed  bool   `json:"sealed"`  // 已切走，不会再写
+	Created int64  `json:"created"` // 创建时间 unix nano（按时间切段用）
+}
+
+type Manifest struct {
+	Segments []SegmentInfo `json:"segments"`
+}
+
+func manifestPath(base string) string { return base + ".manifest" }
+
+func segmentName(base string, first int64) string {
+	return fmt.Sprintf("%s.%020d.seg", filepath.Base(base), first)
+}
+
+// LoadManifest：manifest 不存在返回空 manifest
+func LoadManifest(base string) (Manifest, error) {
+	var m Manifest
+	b, err := os.ReadFile(manifestPath(base))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return m, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// saveManifest：tmp + fsync + rename
+func saveManifest(base string, m Manifest) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	path := manifestPath(base)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, defaultFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+func segPath(base string, s SegmentInfo) string {
+	return filepath.Join(filepath.Dir(base), s.File)
+}
+
+// loadManifestWithTail：加载 manifest，并用活跃段文件大小修正其 Last
+func loadManifestWithTail(base string) (Manifest, error) {
+	m, err := LoadManifest(base)
+	if err != nil || len(m.Segments) == 0 {
+		return m, err
+	}
+	last := &m.Segments[len(m.Segments)-1]
+	if !last.Sealed {
+		st, err := os.Stat(segPath(base, *last))
+		switch {
+		case err == nil:
+			last.Last = last.First + st.Size()
+		case os.IsNotExist(err):
+			last.Last = last.First
+		default:
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// SegmentedWriter：按大小/时间切段的 WAL 写端，接口与 Writer 一致
+type SegmentedWriter struct {
+	base string
+	opts SegmentOptions
+
+	mu  sync.Mutex // 保护 man（切段与 Retain 可能在不同协程）
+	man Manifest
+
+	w       *Writer // 活跃段
+	first   int64   // 活跃段起始逻辑偏移
+	created time.Time
+}
+
+func OpenSegmented(base string, opts SegmentOptions) (*SegmentedWriter, error) {
+	m, err := loadManifestWithTail(base)
+	if err != nil {
+		return nil, err
+	}
+	sw := &SegmentedWriter{base: base, opts: opts, man: m}
+
+	if len(m.Segments) == 0 {
+		// 兼容：base 本身是旧的单文件 WAL，收编为第 0 段
+		if st, err := os.Stat(base); err == nil && !st.IsDir() {
+			info := SegmentInfo{File: segmentName(base, 0), First: 0, Last: st.Size(), Created: time.Now().UnixNano()}
+			if err := os.Rename(base, segPath(base, info)); err != nil {
+				return nil, err
+			}
+			sw.man.Segments = append(sw.man.Segments, info)
+			if err := saveManifest(base, sw.man); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := sw.newSegmentLocked(0); err != nil {
+				return nil, err
+			}
+			return sw, nil
+		}
+	}
+
+	active := sw.man.Segments[len(sw.man.Segments)-1]
+	if active.Sealed {
+		if err := sw.newSegmentLocked(active.Last); err != nil {
+			return nil, err
+		}
+		return sw, nil
+	}
+	w, err := OpenWrite(segPath(base, active), opts.BufSize)
+	if err != nil {
+		return nil, err
+	}
+	sw.w = w
+	sw.first = active.First
+	sw.created = time.Unix(0, active.Created)
+	return sw, nil
+}
+
+// newSegmentLocked：先写 manifest 再建文件；崩溃在两者之间时，下次 Open 会补建空文件
+func (s *SegmentedWriter) newSegmentLocked(first int64) error {
+	now := time.Now()
+	info := SegmentInfo{File: segmentName(s.base, first), First: first, Last: first, Created: now.UnixNano()}
+	s.man.Segments = append(s.man.Segments, info)
+	if err := saveManifest(s.base, s.man); err != nil {
+		s.man.Segments = s.man.Segments[:len(s.man.Segments)-1]
+		return err
+	}
+	w, err := OpenWrite(segPath(s.base, info), s.opts.BufSize)
+	if err != nil {
+		return err
+	}
+	s.w = w
+	s.first = first
+	s.created = now
+	return nil
+}
+
+func (s *SegmentedWriter) shouldRotate() bool {
+	size := s.w.off
+	if size == 0 {
+		return false
+	}
+	if s.opts.MaxSegmentBytes > 0 && size >= s.opts.MaxSegmentBytes {
+		return true
+	}
+	return s.opts.MaxSegmentAge > 0 && time.Since(s.created) >= s.opts.MaxSegmentAge
+}
+
+// rotate：旧段 Flush+Sync+Close 后标记 sealed，再开新段
+func (s *SegmentedWriter) rotate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end := s.first + s.w.off
+	if err := s.w.Close(); err != nil {
+		return err
+	}
+	last := &s.man.Segments[len(s.man.Segments)-1]
+	last.Last = end
+	last.Sealed = true
+	return s.newSegmentLocked(end)
+}
+
+func (s *SegmentedWriter) Append(payload []byte) error {
+	if s.shouldRotate() {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	return s.w.Append(payload)
+}
+
+func (s *SegmentedWriter) Flush() error { return s.w.Flush() }
+
+// Offset：下一条记录的逻辑偏移（含未 flush 的数据）
+func (s *SegmentedWriter) Offset() int64 { return s.first + s.w.off }
+
+func (s *SegmentedWriter) Close() error {
+	err := s.w.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.man.Segments[len(s.man.Segments)-1].Last = s.first + s.w.off
+	if merr := saveManifest(s.base, s.man); err == nil {
+		err = merr
+	}
+	return err
+}
+
+// Manifest：当前段列表副本
+func (s *SegmentedWriter) Manifest() Manifest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := Manifest{Segments: make([]SegmentInfo, len(s.man.Segments))}
+	copy(out.Segments, s.man.Segments)
+	return out
+}
+
+type RetainOptions struct {
+	Archive bool // true：改名为 .archive 保留；false：直接删除
+}
+
+// Retain：删除/归档所有 Last <= upTo 的已封存段（活跃段永远保留）
+// upTo 由上层决定：outbox 取 cursor，cmd WAL 取最老快照覆盖到的偏移
+func (s *SegmentedWriter) Retain(upTo int64, opts RetainOptions) (removed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for n < len(s.man.Segments)-1 {
+		seg := s.man.Segments[n]
+		if !seg.Sealed || seg.Last > upTo {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	drop := append([]SegmentInfo(nil), s.man.Segments[:n]...)
+
+	// 先改 manifest，再动文件：崩溃最多留下孤儿文件，不会出现 manifest 指向不存在的段
+	s.man.Segments = append(s.man.Segments[:0:0], s.man.Segments[n:]...)
+	if err := saveManifest(s.base, s.man); err != nil {
+		s.man.Segments = append(drop, s.man.Segments...)
+		return 0, err
+	}
+	for _, seg := range drop {
+		p := segPath(s.base, seg)
+		if opts.Archive {
+			err = os.Rename(p, p+".archive")
+		} else {
+			err = os.Remove(p)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// IsSegmented：base 是否是分段 WAL（以 manifest 是否存在为准）
+func IsSegmented(base string) bool {
+	_, err := os.Stat(manifestPath(base))
+	return err == nil
+}
+
+// SegmentedSize：日志逻辑末尾偏移（manifest 不存在返回 0）
+func SegmentedSize(base string) (int64, error) {
+	m, err := loadManifestWithTail(base)
+	if err != nil || len(m.Segments) == 0 {
+		return 0, err
+	}
+	return m.Segments[len(m.Segments)-1].Last, nil
+}
+
+// ReplaySegmented：按段顺序回放；只有最后一段允许尾部半写
+func ReplaySegmented(base string, opts ReplayOptions, onRecord func(payload []byte) error) (ReplayStats, error) {
+	var st ReplayStats
+	m, err := loadManifestWithTail(base)
+	if err != nil {
+		return st, err
+	}
+	for i, seg := range m.Segments {
+		o := opts
+		if i < len(m.Segments)-1 {
+			o.AllowTruncatedTail = false
+		}
+		s, err := Replay(segPath(base, seg), o, onRecord)
+		st.Records += s.Records
+		st.BytesRead = seg.First + s.BytesRead
+		st.LastGoodOffset = seg.First + s.LastGoodOffset
+		st.TruncatedTail = s.TruncatedTail
+		if err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// TruncateSegmentedTo：把逻辑日志截断到 offset（修复用，调用时不能有打开的写端）
+func TruncateSegmentedTo(base string, offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("wal: negative truncate offset %d", offset)
+	}
+	m, err := loadManifestWithTail(base)
+	if err != nil || len(m.Segments) == 0 {
+		return err
+	}
+	if offset >= m.Segments[len(m.Segments)-1].Last {
+		return nil
+	}
+	if offset < m.Segments[0].First {
+		return ErrSegmentGone
+	}
+	keep := len(m.Segments)
+	for i, seg := range m.Segments {
+		if offset < seg.Last {
+			keep = i + 1
+			break
+		}
+	}
+
+	drop := m.Segments[keep:]
+	m.Segments = m.Segments[:keep]
+	tail := &m.Segments[keep-1]
+	if err := TruncateTo(segPath(base, *tail), offset-tail.First); err != nil {
+		return err
+	}
+	// 截断后的最后一段重新变成活跃段
+	tail.Last = offset
+	tail.Sealed = false
+	if err := saveManifest(base, m); err != nil {
+		return err
+	}
+	for _, seg := range drop {
+		_ = os.Remove(segPath(base, seg))
+	}
+	return nil
+}
+
+// SegmentedReader：跨段透明读取，Next 的语义与 Reader 一致（nextOffset 为逻辑偏移）
+type SegmentedReader struct {
+	base string
+	opts ReaderOptions
+
+	man  Manifest
+	idx  int     // 当前段下标
+	cur  *Reader // 当前段 reader
+	off  int64   // 下一条记录的逻辑偏移
+	last int64   // 最后一条完整记录之后的逻辑偏移
+}
+
+func OpenSegmentedReader(base string, offset int64, opts ReaderOptions) (*SegmentedReader, error) {
+	m, err := loadManifestWithTail(base)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Segments) == 0 {
+		return nil, os.ErrNotExist
+	}
+	r := &SegmentedReader{base: base, opts: opts, man: m, off: offset, last: offset}
+	if err := r.openAt(offset); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SegmentedReader) find(offset int64) int {
+	for i, seg := range r.man.Segments {
+		if offset < seg.First {
+			return -1
+		}
+		if offset < seg.Last || !seg.Sealed {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *SegmentedReader) openAt(offset int64) error {
+	i := r.find(offset)
+	if i < 0 {
+		return ErrSegmentGone
+	}
+	seg := r.man.Segments[i]
+	cur, err := OpenReader(segPath(r.base, seg), offset-seg.First, r.opts)
+	if err != nil {
+		return err
+	}
+	if r.cur != nil {
+		_ = r.cur.Close()
+	}
+	r.cur, r.idx = cur, i
+	return nil
+}
+
+func (r *SegmentedReader) Next() (payload []byte, nextOffset int64, err error) {
+	for {
+		seg := r.man.Segments[r.idx]
+		p, next, err := r.cur.Next()
+		if err == nil {
+			r.off = seg.First + next
+			r.last = r.off
+			return p, r.off, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, r.off, err
+		}
+		// 当前段读完：若已封存且有下一段，切过去；否则刷新 manifest 看看写端是否切段了
+		if !seg.Sealed || r.idx == len(r.man.Segments)-1 {
+			m, merr := loadManifestWithTail(r.base)
+			if merr != nil {
+				return nil, r.off, merr
+			}
+			// Retain 可能删掉了前面的段，下标会变：按文件名重新定位当前段
+			idx := -1
+			for i := range m.Segments {
+				if m.Segments[i].File == seg.File {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				return nil, r.off, ErrSegmentGone
+			}
+			r.man, r.idx = m, idx
+			seg = m.Segments[idx]
+			if !seg.Sealed || idx == len(m.Segments)-1 {
+				return nil, r.off, io.EOF
+			}
+		}
+		if err := r.openAt(r.man.Segments[r.idx+1].First); err != nil {
+			// 写端切段时先写 manifest 再建文件：最后一段的文件还没出现，当作读到末尾
+			if errors.Is(err, os.ErrNotExist) && r.idx+1 == len(r.man.Segments)-1 {
+				return nil, r.off, io.EOF
+			}
+			return nil, r.off, err
+		}
+	}
+}
+
+func (r *SegmentedReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	return r.cur.Close()
+}
+
+func (r *SegmentedReader) TruncatedTail() bool   { return r.cur != nil && r.cur.TruncatedTail() }
+func (r *SegmentedReader) LastGoodOffset() int64 { return r.last }
@@ -0,0 +1,283 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendN(t *testing.T, w *SegmentedWriter, from, n int) {
+	t.Helper()
+	for i := from; i < from+n; i++ {
+		if err := w.Append([]byte(fmt.Sprintf("rec-%04d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readAll(t *testing.T, base string, off int64) (recs []string, end int64) {
+	t.Helper()
+	r, err := OpenSegmentedReader(base, off, ReaderOptions{AllowTruncatedTail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	end = off
+	for {
+		p, next, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return recs, end
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, string(p))
+		end = next
+	}
+}
+
+func TestSegmented_RotateAndReadAcross(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "BTCUSDT.ev.wal")
+	// 每条记录 8+8=16 字节，段上限 64 => 每段 4 条
+	w, err := OpenSegmented(base, SegmentOptions{MaxSegmentBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, w, 0, 10)
+
+	m := w.Manifest()
+	if len(m.Segments) != 3 {
+		t.Fatalf("segments=%d, want 3: %+v", len(m.Segments), m.Segments)
+	}
+	if m.Segments[0].First != 0 || m.Segments[0].Last != 64 || !m.Segments[0].Sealed || m.Segments[1].First != 64 {
+		t.Fatalf("unexpected manifest: %+v", m.Segments)
+	}
+
+	recs, end := readAll(t, base, 0)
+	if len(recs) != 10 || recs[9] != "rec-0009" || end != w.Offset() {
+		t.Fatalf("recs=%v end=%d offset=%d", recs, end, w.Offset())
+	}
+
+	// 从段中间的逻辑偏移开始读
+	recs, _ = readAll(t, base, 48)
+	if len(recs) != 7 || recs[0] != "rec-0003" {
+		t.Fatalf("from 48: %v", recs)
+	}
+
+	// 重开写端：继续写在活跃段，偏移连续
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err = OpenSegmented(base, SegmentOptions{MaxSegmentBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	appendN(t, w, 10, 2)
+	var n int
+	if _, err := ReplaySegmented(base, ReplayOptions{AllowTruncatedTail: true}, func([]byte) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 {
+		t.Fatalf("replayed %d, want 12", n)
+	}
+}
+
+func TestSegmented_ReaderFollowsRotation(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "x.wal")
+	w, err := OpenSegmented(base, SegmentOptions{MaxSegmentBytes: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	appendN(t, w, 0, 1)
+
+	r, err := OpenSegmentedReader(base, 0, ReaderOptions{AllowTruncatedTail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("want EOF, got %v", err)
+	}
+
+	// 写端切段后，同一个 reader 应能继续读到新段
+	appendN(t, w, 1, 3)
+	got := 0
+	for {
+		_, _, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got++
+	}
+	if got != 3 {
+		t.Fatalf("got %d records after rotation, want 3", got)
+	}
+}
+
+// 切段瞬间 manifest 已登记下一段但文件还没建：reader 应返回 EOF 稍后重试，而不是报错
+func TestSegmented_ReaderNextSegmentNotCreated(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "x.wal")
+	w, err := OpenSegmented(base, SegmentOptions{MaxSegmentBytes: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, w, 0, 3)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Segments) < 2 {
+		t.Fatalf("expected rotation: %+v", m.Segments)
+	}
+	tail := m.Segments[len(m.Segments)-1]
+	b, err := os.ReadFile(segPath(base, tail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(segPath(base, tail)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenSegmentedReader(base, 0, ReaderOptions{AllowTruncatedTail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got := 0
+	for {
+		_, _, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got++
+	}
+	// 文件建出来后同一个 reader 接着读
+	if err := os.WriteFile(segPath(base, tail), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, _, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got++
+	}
+	if got != 3 {
+		t.Fatalf("got %d records, want 3", got)
+	}
+}
+
+func TestSegmented_RetainAndTruncate(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "x.wal")
+	w, err := OpenSegmented(base, SegmentOptions{MaxSegmentBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, w, 0, 10) // 段：[0,64) [64,128) [128,160)
+
+	// cursor 落在第二段中间：只能删第一段
+	removed, err := w.Retain(100, RetainOptions{})
+	if err != nil || removed != 1 {
+		t.Fatalf("removed=%d err=%v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(base), segmentName(base, 0))); !os.IsNotExist(err) {
+		t.Fatalf("segment 0 should be deleted, stat err=%v", err)
+	}
+	if _, err := OpenSegmentedReader(base, 0, ReaderOptions{}); !errors.Is(err, ErrSegmentGone) {
+		t.Fatalf("want ErrSegmentGone, got %v", err)
+	}
+	// 活跃段永远不删
+	if removed, _ := w.Retain(1<<40, RetainOptions{Archive: true}); removed != 1 {
+		t.Fatalf("removed=%d, want 1 (active kept)", removed)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 截断到活跃段中间：后面的记录消失
+	if err := TruncateSegmentedTo(base, 144); err != nil {
+		t.Fatal(err)
+	}
+	recs, end := readAll(t, base, 128)
+	if len(recs) != 1 || end != 144 {
+		t.Fatalf("after truncate recs=%v end=%d", recs, end)
+	}
+}
+
+func TestSegmented_AdoptLegacySingleFile(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "legacy.wal")
+	lw, err := OpenWrite(base, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_ = lw.Append([]byte("old"))
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenSegmented(base, SegmentOptions{MaxSegmentBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, w, 0, 1)
+	_ = w.Close()
+
+	if !IsSegmented(base) {
+		t.Fatalf("manifest not created")
+	}
+	recs, _ := readAll(t, base, 0)
+	if len(recs) != 4 || recs[0] != "old" {
+		t.Fatalf("recs=%v", recs)
+	}
+}
+
+func TestReader_AllowTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.wal")
+	w, err := OpenWrite(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Append([]byte("ok"))
+	_ = w.Close()
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	_, _ = f.Write([]byte{1, 2, 3})
+	_ = f.Close()
+
+	r, err := OpenReader(path, 0, ReaderOptions{AllowTruncatedTail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Next(); !errors.Is(err, io.EOF) || !r.TruncatedTail() {
+		t.Fatalf("want EOF with truncated tail, got err=%v tail=%v", err, r.TruncatedTail())
+	}
+}
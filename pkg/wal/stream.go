@@ -0,0 +1,49 @@
+package wal
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// 流式帧：沿用 WAL 记录格式（len(4) + crc32(4) + payload），用于把 WAL 记录原样发到网络对端
+// 对端逐帧校验 CRC；len=0 的空帧可用作心跳
+
+// WriteFrame：写一帧（w 通常是 bufio.Writer，调用方负责 Flush）
+func WriteFrame(w io.Writer, payload []byte) error {
+	var hdr [headerSize]byte
+	binary.LittleEndian.PutUint32(hdr[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(hdr[4:], crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// ReadFrame：读一帧并校验；maxPayload<=0 用 DefaultMaxPayload
+func ReadFrame(r io.Reader, maxPayload int) ([]byte, error) {
+	if maxPayload <= 0 {
+		maxPayload = DefaultMaxPayload
+	}
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	ln := int(binary.LittleEndian.Uint32(hdr[0:4]))
+	crc := binary.LittleEndian.Uint32(hdr[4:8])
+	if ln > maxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	payload := make([]byte, ln)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != crc {
+		return nil, ErrChecksumMismatch
+	}
+	return payload, nil
+}
@@ -75,6 +75,9 @@ func (w *Writer) Append(payload []byte) error {
 	return nil
 }
 
+// Offset：下一条记录的偏移（含未 flush 的数据）
+func (w *Writer) Offset() int64 { return w.off }
+
 // 刷新到磁盘
 func (w *Writer) Flush() error {
 	// 将buf刷新到内存 什么时候写入磁盘依据操作系统